@@ -3,8 +3,10 @@ package ackhandler
 import (
 	"time"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qlog"
 )
 
 // SentPacketHandler handles ACKs received for outgoing packets
@@ -29,6 +31,7 @@ type SentPacketHandler interface {
 	DuplicatePacket(packet *Packet)
 
 	GetStatistics() (uint64, uint64, uint64)
+	GetDeadlineStatistics() (uint64, uint64)
 	GetLastPackets() uint64
 	GetAckedBytes() protocol.ByteCount
 	GetSentBytes() protocol.ByteCount
@@ -36,6 +39,15 @@ type SentPacketHandler interface {
 	GetBytesInFlight() protocol.ByteCount
 
 	GetPathAlpha() float32
+	// GetPathArm returns the arm of the bandit choosing alpha
+	GetPathArm() int
+
+	// SetTracer sets the tracer of the recovery and bandit events of the path
+	SetTracer(tracer *qlog.ConnectionTracer, pathID protocol.PathID)
+	// SetPacketOutcomeCallback sets a callback called when a packet is acked, or queued for retransmission
+	SetPacketOutcomeCallback(cb func(packet *Packet, acked bool))
+	// SetClock sets the clock giving the send times and the loss detection times, the system clock by default
+	SetClock(clock congestion.Clock)
 
 	// czy
 	CalculateMeetRatio() float32
@@ -60,4 +72,7 @@ type ReceivedPacketHandler interface {
 	//czy
 	UpdateCurNotSent(curNotSent uint16)
 	UpdateAlpha(alpha uint16)
+
+	// SetClock sets the clock giving the receive times and the ACK alarm, the system clock by default
+	SetClock(clock congestion.Clock)
 }
@@ -17,6 +17,10 @@ type Packet struct {
 
 	SendTime time.Time
 	Deadline time.Time
+	// Deferred is set if the scheduler held the packet back for a later batch because no path met its deadline,
+	// HeldForCost if it held it back to wait for a path without cost
+	Deferred    bool
+	HeldForCost bool
 }
 
 // GetFramesForRetransmission gets all the frames for retransmission
@@ -53,6 +57,10 @@ func (p *Packet) IsRetransmittable() bool {
 			return true
 		case *wire.PathsFrame:
 			return true
+		case *wire.PathCostFrame:
+			return true
+		case *wire.PathQualityFrame:
+			return true
 		}
 	}
 	return false
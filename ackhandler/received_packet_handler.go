@@ -4,6 +4,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
@@ -38,6 +39,8 @@ type receivedPacketHandler struct {
 
 	curNotSent uint16
 	alpha      uint16
+
+	clock congestion.Clock
 }
 
 // NewReceivedPacketHandler creates a new receivedPacketHandler
@@ -46,9 +49,15 @@ func NewReceivedPacketHandler(version protocol.VersionNumber) ReceivedPacketHand
 		packetHistory: newReceivedPacketHistory(),
 		ackSendDelay:  protocol.AckSendDelay,
 		version:       version,
+		clock:         congestion.DefaultClock{},
 	}
 }
 
+// SetClock sets the clock giving the receive times and the ACK alarm
+func (h *receivedPacketHandler) SetClock(clock congestion.Clock) {
+	h.clock = clock
+}
+
 func (h *receivedPacketHandler) GetStatistics() (uint64, uint64, uint64) {
 	return h.packets, h.packetsHasDeadline, h.packetsMeetDeadline
 }
@@ -63,7 +72,7 @@ func (h *receivedPacketHandler) ReceivedPacket(packetNumber protocol.PacketNumbe
 
 	if packetNumber > h.largestObserved {
 		h.largestObserved = packetNumber
-		h.largestObservedReceivedTime = time.Now()
+		h.largestObservedReceivedTime = h.clock.Now()
 	}
 
 	if packetNumber <= h.lowerLimit {
@@ -121,7 +130,7 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 			h.ackQueued = true
 		} else {
 			if h.ackAlarm.IsZero() {
-				h.ackAlarm = time.Now().Add(h.ackSendDelay)
+				h.ackAlarm = h.clock.Now().Add(h.ackSendDelay)
 			}
 		}
 	}
@@ -133,19 +142,19 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 }
 
 func (h *receivedPacketHandler) GetAckFrame() *wire.AckFrame {
-	if !h.ackQueued && (h.ackAlarm.IsZero() || h.ackAlarm.After(time.Now())) {
+	if !h.ackQueued && (h.ackAlarm.IsZero() || h.ackAlarm.After(h.clock.Now())) {
 		return nil
 	}
 
 	ackRanges := h.packetHistory.GetAckRanges()
 	ack := &wire.AckFrame{
-		LargestAcked:       h.largestObserved,
-		LowestAcked:        ackRanges[len(ackRanges)-1].First,
-		PacketReceivedTime: h.largestObservedReceivedTime,
-		NumMeetDeadline:    h.packetsMeetDeadlineSinceLastAck,
-		NumHasDeadline:     h.packetNotMeetDeadlineSinceLastAck,
-		CurNotSent:         h.curNotSent,
-		Alpha:              h.alpha,
+		LargestAcked:    h.largestObserved,
+		LowestAcked:     ackRanges[len(ackRanges)-1].First,
+		DelayTime:       h.clock.Now().Sub(h.largestObservedReceivedTime),
+		NumMeetDeadline: h.packetsMeetDeadlineSinceLastAck,
+		NumHasDeadline:  h.packetNotMeetDeadlineSinceLastAck,
+		CurNotSent:      h.curNotSent,
+		Alpha:           h.alpha,
 	}
 
 	if len(ackRanges) > 1 {
@@ -10,6 +10,11 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+// manualClock is a clock that only advances when told to
+type manualClock struct{ now time.Time }
+
+func (c *manualClock) Now() time.Time { return c.now }
+
 var _ = Describe("receivedPacketHandler", func() {
 	var (
 		handler *receivedPacketHandler
@@ -174,6 +179,17 @@ var _ = Describe("receivedPacketHandler", func() {
 				handler.ackQueued = true
 			})
 
+			It("sets the delay since the reception of the largest packet on the clock of the handler", func() {
+				clock := &manualClock{now: time.Unix(946684800, 0)}
+				handler.SetClock(clock)
+				err := handler.ReceivedPacket(1, true)
+				Expect(err).ToNot(HaveOccurred())
+				clock.now = clock.now.Add(7 * time.Millisecond)
+				ack := handler.GetAckFrame()
+				Expect(ack).ToNot(BeNil())
+				Expect(ack.DelayTime).To(Equal(7 * time.Millisecond))
+			})
+
 			It("generates a simple ACK frame", func() {
 				err := handler.ReceivedPacket(1, true)
 				Expect(err).ToNot(HaveOccurred())
@@ -11,6 +11,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	"github.com/lucas-clemente/quic-go/qerr"
+	"github.com/lucas-clemente/quic-go/qlog"
 )
 
 var rttArray []float64
@@ -84,6 +85,8 @@ type sentPacketHandler struct {
 
 	onRTOCallback func(time.Time) bool
 
+	logger utils.Logger
+
 	// The number of times an RTO has been sent without receiving an ack.
 	rtoCount uint32
 
@@ -113,11 +116,28 @@ type sentPacketHandler struct {
 
 	// Dealine Meeting Ratio
 	DeadlineRatio float32
+
+	tracer *qlog.ConnectionTracer
+	pathID protocol.PathID
+	// lastMetrics are the last traced metrics, to only trace them when they change
+	lastMetrics qlog.MetricsUpdated
+
+	onPacketOutcome func(packet *Packet, acked bool)
+
+	// clock is shared with the cubic sender, so that SetClock changes the clock of both
+	clock *handlerClock
+
+	version protocol.VersionNumber
+}
+
+// handlerClock is the clock of a handler, that can be replaced after the handler was created
+type handlerClock struct {
+	congestion.Clock
 }
 
 type ChangePointDetectionHandler struct {
-	totalMeetDeadline       uint16
-	totalHasDeadline        uint16
+	totalMeetDeadline       uint64
+	totalHasDeadline        uint64
 	curMeetDeadline         uint16
 	curHasDeadline          uint16
 	alpha                   float32           // RTT discount factor, every RTT has an alpha
@@ -134,15 +154,16 @@ type BanditInformation struct {
 	curArmIndex  int
 }
 
-// NewSentPacketHandler creates a new sentPacketHandler
-func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlgorithm, onRTOCallback func(time.Time) bool) SentPacketHandler {
+// NewSentPacketHandler creates a new sentPacketHandler, the version tells if the ACKs carry deadline information
+func NewSentPacketHandler(version protocol.VersionNumber, rttStats *congestion.RTTStats, cong congestion.SendAlgorithm, onRTOCallback func(time.Time) bool, logger utils.Logger) SentPacketHandler {
 	var congestionControl congestion.SendAlgorithm
+	clock := &handlerClock{congestion.DefaultClock{}}
 
 	if cong != nil {
 		congestionControl = cong
 	} else {
 		congestionControl = congestion.NewCubicSender(
-			congestion.DefaultClock{},
+			clock,
 			rttStats,
 			false, /* don't use reno since chromium doesn't (why?) */
 			protocol.InitialCongestionWindow,
@@ -154,11 +175,14 @@ func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlg
 	bandit := NewBanditInformation()
 
 	return &sentPacketHandler{
+		version:            version,
 		packetHistory:      NewPacketList(),
 		stopWaitingManager: stopWaitingManager{},
 		rttStats:           rttStats,
 		congestion:         congestionControl,
 		onRTOCallback:      onRTOCallback,
+		logger:             logger,
+		clock:              clock,
 		changePDInfo: ChangePointDetectionHandler{
 			alpha:                   1.0,
 			banditInformation:       bandit,
@@ -183,6 +207,11 @@ func (h *sentPacketHandler) GetStatistics() (uint64, uint64, uint64) {
 	return h.packets, h.retransmissions, h.losses
 }
 
+// GetDeadlineStatistics returns the number of sent packets with a deadline, and of those that met it, as reported by the peer
+func (h *sentPacketHandler) GetDeadlineStatistics() (uint64, uint64) {
+	return h.changePDInfo.totalHasDeadline, h.changePDInfo.totalMeetDeadline
+}
+
 func (h *sentPacketHandler) largestInOrderAcked() protocol.PacketNumber {
 	if f := h.packetHistory.Front(); f != nil {
 		return f.Value.PacketNumber - 1
@@ -194,6 +223,26 @@ func (h *sentPacketHandler) GetLastPackets() uint64 {
 	return uint64(h.lastSentPacketNumber)
 }
 
+// SetTracer sets the tracer of the recovery and bandit events of the path
+func (h *sentPacketHandler) SetTracer(tracer *qlog.ConnectionTracer, pathID protocol.PathID) {
+	h.tracer = tracer
+	h.pathID = pathID
+}
+
+// SetPacketOutcomeCallback sets a callback called when a packet is acked, or queued for retransmission
+func (h *sentPacketHandler) SetPacketOutcomeCallback(cb func(packet *Packet, acked bool)) {
+	h.onPacketOutcome = cb
+}
+
+// SetClock sets the clock of the handler and of its cubic sender
+func (h *sentPacketHandler) SetClock(clock congestion.Clock) {
+	h.clock.Clock = clock
+}
+
+func (h *sentPacketHandler) GetPathArm() int {
+	return h.changePDInfo.banditInformation.curArmIndex
+}
+
 func (h *sentPacketHandler) GetPathAlpha() float32 {
 	return h.changePDInfo.banditInformation.armsAlpha[h.changePDInfo.banditInformation.curArmIndex]
 }
@@ -220,7 +269,7 @@ func (h *sentPacketHandler) SentPacket(packet *Packet) error {
 	}
 
 	h.lastSentPacketNumber = packet.PacketNumber
-	now := time.Now()
+	now := h.clock.Now()
 
 	// Update some statistics
 	h.packets++
@@ -244,7 +293,9 @@ func (h *sentPacketHandler) SentPacket(packet *Packet) error {
 	} else {
 		h.numNonRetransmittablePackets++
 	}
-	fmt.Println("Sent packet:", packet.PacketNumber, "with", packet.Length, "bytes", ". In sendtime:", packet.SendTime, ". Deadline:", packet.Deadline, ".")
+	if h.logger.Debug() {
+		h.logger.Debugf("Sent packet %d with %d bytes, send time %s, deadline %s", packet.PacketNumber, packet.Length, packet.SendTime, packet.Deadline)
+	}
 	h.congestion.OnPacketSent(
 		now,
 		h.bytesInFlight,
@@ -262,13 +313,15 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 	if ackFrame.LargestAcked > h.lastSentPacketNumber {
 		return errAckForUnsentPacket
 	}
-	fmt.Println("received AckFrame:", ackFrame)
-	fmt.Println("Meet Deadline packet number:", ackFrame.NumMeetDeadline)
-	fmt.Println("All Deadline Packet number:", ackFrame.NumHasDeadline)
-	fmt.Println("Receive Cur Not Sent:", ackFrame.CurNotSent)
-	fmt.Println("Receive Alpha", ackFrame.Alpha)
+	if h.logger.Debug() {
+		h.logger.Debugf("Received ACK frame %#v", ackFrame)
+		h.logger.Debugf("Meet deadline packets: %d, packets with deadline: %d, cur not sent: %d, alpha: %d", ackFrame.NumMeetDeadline, ackFrame.NumHasDeadline, ackFrame.CurNotSent, ackFrame.Alpha)
+	}
 
-	h.updateDeadlineInformation(ackFrame)
+	// the ACKs of versions without deadlines carry no deadline information
+	if h.version.UsesDeadlines() {
+		h.updateDeadlineInformation(ackFrame)
+	}
 
 	// duplicate or out-of-order ACK
 	if withPacketNumber <= h.largestReceivedPacketWithAck {
@@ -318,8 +371,8 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 		//AddBandwidthArray(bandwidth)
 
 		// Display rtt and bandwidth to save
-		DisplayInformation(ackFrame.PathID, newSmoothRTT, bandwidth)
-		DisplayDeadlineInfo(ackFrame.PathID, bandwidth, h.DeadlineRatio)
+		DisplayInformation(h.logger, ackFrame.PathID, newSmoothRTT, bandwidth)
+		DisplayDeadlineInfo(h.logger, ackFrame.PathID, bandwidth, h.DeadlineRatio)
 	}
 
 	if rttUpdated {
@@ -338,9 +391,23 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 		}
 	}
 
+	losses := h.losses
 	h.detectLostPackets()
 	h.updateLossDetectionAlarm()
 
+	if h.tracer.Enabled() {
+		h.tracer.Trace(&qlog.AckProcessed{
+			PathID:          h.pathID,
+			LargestAcked:    ackFrame.LargestAcked,
+			LowestAcked:     ackFrame.LowestAcked,
+			AckedPackets:    len(ackedPackets),
+			LostPackets:     int(h.losses - losses),
+			NumMeetDeadline: ackFrame.NumMeetDeadline,
+			NumHasDeadline:  ackFrame.NumHasDeadline,
+		})
+		h.traceMetrics()
+	}
+
 	h.garbageCollectSkippedPackets()
 	h.stopWaitingManager.ReceivedAck(ackFrame)
 
@@ -466,7 +533,7 @@ func (h *sentPacketHandler) maybeUpdateRTT(largestAcked protocol.PacketNumber, a
 	for el := h.packetHistory.Front(); el != nil; el = el.Next() {
 		packet := el.Value
 		if packet.PacketNumber == largestAcked {
-			h.rttStats.UpdateRTT(rcvTime.Sub(packet.SendTime), ackDelay, time.Now())
+			h.rttStats.UpdateRTT(rcvTime.Sub(packet.SendTime), ackDelay, h.clock.Now())
 			return true
 		}
 		// Packets are sorted by number, so we can stop searching
@@ -508,7 +575,7 @@ func (h *sentPacketHandler) updateLossDetectionAlarm() {
 
 func (h *sentPacketHandler) detectLostPackets() {
 	h.lossTime = time.Time{}
-	now := time.Now()
+	now := h.clock.Now()
 
 	maxRTT := float64(utils.MaxDuration(h.rttStats.LatestRTT(), h.rttStats.SmoothedRTT()))
 	delayUntilLost := time.Duration((1.0 + timeReorderingFraction) * maxRTT)
@@ -594,6 +661,28 @@ func (h *sentPacketHandler) OnAlarm() {
 	}
 
 	h.updateLossDetectionAlarm()
+	h.traceMetrics()
+}
+
+// traceMetrics traces the congestion window and the RTT estimates, if they changed since they were last traced
+func (h *sentPacketHandler) traceMetrics() {
+	if !h.tracer.Enabled() {
+		return
+	}
+	metrics := qlog.MetricsUpdated{
+		PathID:           h.pathID,
+		CongestionWindow: h.congestion.GetCongestionWindow(),
+		BytesInFlight:    h.bytesInFlight,
+		SmoothedRTT:      h.rttStats.SmoothedRTT(),
+		LatestRTT:        h.rttStats.LatestRTT(),
+		MinRTT:           h.rttStats.MinRTT(),
+		RTTVariance:      h.rttStats.MeanDeviation(),
+	}
+	if metrics == h.lastMetrics {
+		return
+	}
+	h.lastMetrics = metrics
+	h.tracer.Trace(&metrics)
 }
 
 func (h *sentPacketHandler) GetAlarmTimeout() time.Time {
@@ -622,6 +711,9 @@ func (h *sentPacketHandler) onPacketAcked(packetElement *PacketElement) {
 	h.tlpCount = 0
 	h.packetHistory.Remove(packetElement)
 	h.ackedBytes += packetElement.Value.Length
+	if h.onPacketOutcome != nil {
+		h.onPacketOutcome(&packetElement.Value, true)
+	}
 }
 
 func (h *sentPacketHandler) DequeuePacketForRetransmission() *Packet {
@@ -708,6 +800,9 @@ func (h *sentPacketHandler) queuePacketForRetransmission(packetElement *PacketEl
 	h.retransmissionQueue = append(h.retransmissionQueue, packet)
 	h.packetHistory.Remove(packetElement)
 	h.stopWaitingManager.QueuedRetransmissionForPacketNumber(packet.PacketNumber)
+	if h.onPacketOutcome != nil {
+		h.onPacketOutcome(packet, false)
+	}
 }
 
 func (h *sentPacketHandler) DuplicatePacket(packet *Packet) {
@@ -781,14 +876,22 @@ func (h *sentPacketHandler) updateDeadlineInformation(ackFrame *wire.AckFrame) {
 	reward := h.CalculateHistoryMeetRatio(armIndex)
 	h.DeadlineRatio = reward
 	reward = reward - float32(ackFrame.CurNotSent)/float32(batch)
+	h.logger.Debugf("old total reward: %f", h.changePDInfo.banditInformation.totalReward[armIndex])
 	h.changePDInfo.updateBanditInfo(reward, armIndex)
 
 	// Update alpha
 	h.changePDInfo.updateAlpha()
+	h.tracer.Trace(&qlog.BanditUpdate{
+		Bandit:  qlog.BanditAlpha,
+		PathID:  h.pathID,
+		Arm:     armIndex,
+		Reward:  float64(reward),
+		NextArm: h.changePDInfo.banditInformation.curArmIndex,
+	})
 
 	// Update total Deadline Information
-	h.changePDInfo.totalMeetDeadline = h.changePDInfo.totalMeetDeadline + ackFrame.NumMeetDeadline
-	h.changePDInfo.totalHasDeadline = h.changePDInfo.totalHasDeadline + ackFrame.NumHasDeadline
+	h.changePDInfo.totalMeetDeadline += uint64(ackFrame.NumMeetDeadline)
+	h.changePDInfo.totalHasDeadline += uint64(ackFrame.NumHasDeadline)
 	//fmt.Println("curMeetDeadline:", h.changePDInfo.curMeetDeadline)
 	//fmt.Println("curHasDeadline:", h.changePDInfo.curHasDeadline)
 	//fmt.Println("totalMeetDeadline:", h.changePDInfo.totalMeetDeadline)
@@ -798,7 +901,6 @@ func (h *sentPacketHandler) updateDeadlineInformation(ackFrame *wire.AckFrame) {
 func (cpd *ChangePointDetectionHandler) updateBanditInfo(reward float32, armIndex int) {
 	// update reward
 	//cpd.banditInformation.totalReward[cpd.banditInformation.curArmIndex] += reward
-	fmt.Println("old total reward:", cpd.banditInformation.totalReward[armIndex])
 	//update discount reward
 	cpd.banditInformation.totalReward[armIndex] =
 		gamma*cpd.banditInformation.totalReward[armIndex] + reward
@@ -968,15 +1070,13 @@ func AddBandwidthArray(newBandwidth float64) {
 	bandwidthArray = append(bandwidthArray, newBandwidth)
 }
 
-func DisplayInformation(pathID protocol.PathID, rtt, bandwidth float64) {
-	fmt.Println("rtt(ms):", rtt)
-	fmt.Println("pathID", pathID, ", bandwidth(Mbps):", bandwidth)
-	fmt.Println(" ")
+func DisplayInformation(logger utils.Logger, pathID protocol.PathID, rtt, bandwidth float64) {
+	logger.Debugf("rtt(ms): %f", rtt)
+	logger.Debugf("pathID %d, bandwidth(Mbps): %f", pathID, bandwidth)
 }
 
-func DisplayDeadlineInfo(pathID protocol.PathID, bandwidth float64, deadlineRatio float32) {
-	fmt.Println("pathID", pathID, ", deadline bandwidth(Mbps):", bandwidth*float64(deadlineRatio))
-	fmt.Println(" ")
+func DisplayDeadlineInfo(logger utils.Logger, pathID protocol.PathID, bandwidth float64, deadlineRatio float32) {
+	logger.Debugf("pathID %d, deadline bandwidth(Mbps): %f", pathID, bandwidth*float64(deadlineRatio))
 }
 
 // computeBandwidth compute bandwidth follow ack rate like bbr. This function compute bandwidth with one sample
@@ -985,9 +1085,7 @@ func computeBandwidth(largestACK, largestInOrderACK protocol.PacketNumber, rcvTi
 	timeDelta := rcvTime.Sub(lastACKTime).Seconds()        //second
 	bandwidth := float64(ackDelta) * 8 / (timeDelta * 1e6) //Mbps
 
-	fmt.Println("ackDelta:", ackDelta)
-	fmt.Println("timeDelta:", timeDelta)
-	fmt.Println("bandwidth(Mbps):", bandwidth)
+	utils.Debugf("ackDelta: %d, timeDelta: %f, bandwidth(Mbps): %f", ackDelta, timeDelta, bandwidth)
 	return bandwidth
 }
 
@@ -5,7 +5,9 @@ import (
 
 	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qlog"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -58,6 +60,22 @@ func (m *mockCongestion) OnPacketLost(n protocol.PacketNumber, l protocol.ByteCo
 	m.packetsLost = append(m.packetsLost, []interface{}{n, l, bif})
 }
 
+type mockTracer struct {
+	events []*qlog.Event
+}
+
+func (t *mockTracer) Trace(ev *qlog.Event) {
+	t.events = append(t.events, ev)
+}
+
+func (t *mockTracer) eventData() []qlog.EventData {
+	var data []qlog.EventData
+	for _, ev := range t.events {
+		data = append(data, ev.Data)
+	}
+	return data
+}
+
 func retransmittablePacket(num protocol.PacketNumber) *Packet {
 	return &Packet{PacketNumber: num, Length: 1, Frames: []wire.Frame{&wire.PingFrame{}}}
 }
@@ -74,7 +92,7 @@ var _ = Describe("SentPacketHandler", func() {
 
 	BeforeEach(func() {
 		rttStats := &congestion.RTTStats{}
-		handler = NewSentPacketHandler(rttStats, nil, nil).(*sentPacketHandler)
+		handler = NewSentPacketHandler(protocol.VersionMPDeadline, rttStats, nil, nil, utils.DefaultLogger).(*sentPacketHandler)
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
 			Data:     []byte{0x13, 0x37},
@@ -837,4 +855,132 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.rtoCount).To(BeEquivalentTo(1))
 		})
 	})
+
+	Context("deadline statistics", func() {
+		It("sums the deadline statistics of all ACKs", func() {
+			for i := 1; i <= 2; i++ {
+				err := handler.SentPacket(retransmittablePacket(protocol.PacketNumber(i)))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1, NumMeetDeadline: 3, NumHasDeadline: 4}, 1, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 1, NumMeetDeadline: 0xffff, NumHasDeadline: 0xffff}, 2, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			hasDeadline, meetDeadline := handler.GetDeadlineStatistics()
+			Expect(hasDeadline).To(Equal(uint64(0xffff + 4)))
+			Expect(meetDeadline).To(Equal(uint64(0xffff + 3)))
+		})
+
+		It("ignores the deadline fields of the ACKs in versions without deadlines", func() {
+			handler.version = protocol.VersionMP
+			err := handler.SentPacket(retransmittablePacket(1))
+			Expect(err).ToNot(HaveOccurred())
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			hasDeadline, meetDeadline := handler.GetDeadlineStatistics()
+			Expect(hasDeadline).To(BeZero())
+			Expect(meetDeadline).To(BeZero())
+			Expect(handler.changePDInfo.banditInformation.totalNumPlay).To(BeZero())
+			Expect(handler.GetPathAlpha()).To(Equal(float32(0.9)))
+		})
+	})
+
+	Context("tracing", func() {
+		var tracer *mockTracer
+
+		BeforeEach(func() {
+			tracer = &mockTracer{}
+			handler.SetTracer(qlog.NewConnectionTracer(tracer, utils.SystemClock{}, protocol.PerspectiveClient, 0x1337), 3)
+			for i := 1; i <= 3; i++ {
+				err := handler.SentPacket(retransmittablePacket(protocol.PacketNumber(i)))
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("traces processed ACKs, the metrics and the bandit update", func() {
+			getPacket := func(p protocol.PacketNumber) *Packet {
+				for el := handler.packetHistory.Front(); el != nil; el = el.Next() {
+					if el.Value.PacketNumber == p {
+						return &el.Value
+					}
+				}
+				return nil
+			}
+			getPacket(2).SendTime = time.Now().Add(-time.Second)
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 1, NumMeetDeadline: 1, NumHasDeadline: 2, Alpha: 10}, 1, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			data := tracer.eventData()
+			Expect(data).To(HaveLen(3))
+			Expect(data[0]).To(Equal(&qlog.BanditUpdate{
+				Bandit:  qlog.BanditAlpha,
+				PathID:  3,
+				Arm:     1,
+				Reward:  float64(handler.DeadlineRatio),
+				NextArm: handler.GetPathArm(),
+			}))
+			Expect(data[1]).To(Equal(&qlog.AckProcessed{
+				PathID:          3,
+				LargestAcked:    2,
+				LowestAcked:     1,
+				AckedPackets:    2,
+				NumMeetDeadline: 1,
+				NumHasDeadline:  2,
+			}))
+			metrics := data[2].(*qlog.MetricsUpdated)
+			Expect(metrics.PathID).To(Equal(protocol.PathID(3)))
+			Expect(metrics.BytesInFlight).To(Equal(protocol.ByteCount(1)))
+			Expect(metrics.CongestionWindow).To(Equal(handler.GetCongestionWindow()))
+			Expect(metrics.LatestRTT).To(BeNumerically("~", time.Second, 100*time.Millisecond))
+			for _, ev := range tracer.events {
+				Expect(ev.ConnectionID).To(Equal(protocol.ConnectionID(0x1337)))
+				Expect(ev.Perspective).To(Equal(protocol.PerspectiveClient))
+			}
+		})
+
+		It("only traces the metrics when they change", func() {
+			handler.tlpCount = maxTailLossProbes
+			handler.OnAlarm()
+			Expect(tracer.eventData()).To(HaveLen(1))
+			Expect(tracer.eventData()[0]).To(BeAssignableToTypeOf(&qlog.MetricsUpdated{}))
+			handler.traceMetrics()
+			Expect(tracer.events).To(HaveLen(1))
+		})
+	})
+
+	Context("packet outcomes", func() {
+		var (
+			acked         []protocol.PacketNumber
+			retransmitted []protocol.PacketNumber
+		)
+
+		BeforeEach(func() {
+			acked = nil
+			retransmitted = nil
+			handler.SetPacketOutcomeCallback(func(p *Packet, isAcked bool) {
+				if isAcked {
+					acked = append(acked, p.PacketNumber)
+				} else {
+					retransmitted = append(retransmitted, p.PacketNumber)
+				}
+			})
+			for i := 1; i <= 3; i++ {
+				err := handler.SentPacket(retransmittablePacket(protocol.PacketNumber(i)))
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+
+		It("reports acked packets", func() {
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 1}, 1, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(acked).To(Equal([]protocol.PacketNumber{1, 2}))
+			Expect(retransmitted).To(BeEmpty())
+		})
+
+		It("reports packets queued for retransmission", func() {
+			handler.tlpCount = maxTailLossProbes
+			handler.OnAlarm() // RTO, retransmitting the two oldest packets
+			Expect(acked).To(BeEmpty())
+			Expect(retransmitted).To(Equal([]protocol.PacketNumber{1, 2}))
+		})
+	})
 })
@@ -10,6 +10,8 @@ import (
 )
 
 func TestBenchmark(t *testing.T) {
+	// the flags of the testing package are only defined once the test binary runs
+	flag.Parse()
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Benchmark Suite")
 }
@@ -17,10 +19,14 @@ func TestBenchmark(t *testing.T) {
 var (
 	size    int // file size in MB, will be read from flags
 	samples int // number of samples for Measure, will be read from flags
+
+	matrix bool   // run the scheduler matrix, will be read from flags
+	report string // file the results of the scheduler matrix are written to, will be read from flags
 )
 
 func init() {
 	flag.IntVar(&size, "size", 50, "data length (in MB)")
 	flag.IntVar(&samples, "samples", 6, "number of samples")
-	flag.Parse()
+	flag.BoolVar(&matrix, "matrix", false, "run the scheduler matrix in virtual time")
+	flag.StringVar(&report, "report", "", "CSV file for the results of the scheduler matrix (stdout if empty)")
 }
@@ -0,0 +1,278 @@
+package benchmark
+
+import (
+	"crypto/tls"
+	"encoding/csv"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/netem"
+	"github.com/lucas-clemente/quic-go/workload"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// the schedulers of the matrix, by the name they are reported with
+var matrixSchedulers = []struct{ name, schedulerName string }{
+	{"rtt", "rtt"},
+	{"ecf", "ecf"},
+	{"blest", "blest"},
+	{"peek", "peek"},
+	{"BatchEDF", "BatchEDF"},
+	{"DaMPS", "BatchLinOptNoCost"},
+	{"CaDaMPS", "BatchLinOpt"},
+}
+
+const (
+	wifiIP     = "10.0.0.1"
+	cellularIP = "10.0.1.1"
+	serverIP   = "10.2.0.1"
+)
+
+// a matrixProfile configures the WiFi and the cellular path of the client, in both directions
+type matrixProfile struct {
+	name                   string
+	wifiUp, wifiDown       netem.LinkConfig
+	cellularUp, cellularDn netem.LinkConfig
+	// handover takes the WiFi link down at this virtual time after the start, if not zero
+	handover time.Duration
+}
+
+func symmetric(delay time.Duration, bandwidth float64) netem.LinkConfig {
+	return netem.LinkConfig{Delay: delay, Bandwidth: bandwidth, QueueSize: 100}
+}
+
+var matrixProfiles = func() []matrixProfile {
+	wifi := symmetric(15*time.Millisecond, 1.25e6)
+	cellular := symmetric(50*time.Millisecond, 500e3)
+	lossyCellular := netem.LinkConfig{Delay: 60 * time.Millisecond, Jitter: 10 * time.Millisecond, Bandwidth: 500e3, QueueSize: 100, Loss: 0.03}
+	return []matrixProfile{
+		{name: "symmetric", wifiUp: symmetric(20*time.Millisecond, 1e6), wifiDown: symmetric(20*time.Millisecond, 1e6),
+			cellularUp: symmetric(20*time.Millisecond, 1e6), cellularDn: symmetric(20*time.Millisecond, 1e6)},
+		{name: "asymmetric", wifiUp: wifi, wifiDown: wifi, cellularUp: cellular, cellularDn: cellular},
+		{name: "lossy-cellular", wifiUp: wifi, wifiDown: wifi, cellularUp: lossyCellular, cellularDn: lossyCellular},
+		{name: "handover", wifiUp: wifi, wifiDown: wifi, cellularUp: cellular, cellularDn: cellular, handover: 2 * time.Second},
+	}
+}()
+
+// a matrixWorkload is the data the client uploads, generated by a workload.Generator and received by a workload.Sink
+type matrixWorkload struct {
+	name string
+	spec *workload.Spec
+}
+
+var matrixWorkloads = []matrixWorkload{
+	// 2 MB written at once without deadline, in messages of 10 kB so that an unfinished transfer reports what arrived
+	{name: "bulk", spec: &workload.Spec{Flows: []workload.Flow{
+		{Name: "bulk", Count: 200, Size: workload.Size{Mean: 10e3}},
+	}}},
+	// 10 s of a 25 fps stream of 1.2 Mbit/s
+	{name: "periodic", spec: &workload.Spec{Flows: []workload.Flow{
+		{Name: "video", Period: workload.Duration(40 * time.Millisecond), Count: 250, Size: workload.Size{Mean: 6e3}, Deadline: workload.Duration(150 * time.Millisecond)},
+	}}},
+}
+
+// a matrixResult is a row of the report
+type matrixResult struct {
+	scheduler, profile, workload string
+	seed                         int64
+	// completed is false if the transfer didn't finish within the limit of the simulation
+	completed bool
+	// duration is the virtual time from the start until the server received the last message, or until the limit
+	duration time.Duration
+	// received is the payload of the messages the server received
+	received uint64
+	// meetRatio is the fraction of the messages with a deadline that the server received in time,
+	// measured per message by the sink. The messages that never arrived missed their deadline. NaN without deadlines.
+	meetRatio float64
+	// cellularBytes are the bytes the client sent on the cellular path and that were acknowledged
+	cellularBytes uint64
+	cost          float64
+}
+
+var matrixHeader = []string{"scheduler", "profile", "workload", "seed", "completed", "duration_s", "goodput_mbps", "deadline_meet_ratio", "cellular_bytes", "cost"}
+
+func (r *matrixResult) record() []string {
+	goodput := 0.
+	if r.duration > 0 {
+		goodput = float64(r.received) * 8 / r.duration.Seconds() / 1e6
+	}
+	meetRatio := ""
+	if !math.IsNaN(r.meetRatio) {
+		meetRatio = strconv.FormatFloat(r.meetRatio, 'f', 4, 64)
+	}
+	return []string{
+		r.scheduler, r.profile, r.workload,
+		strconv.FormatInt(r.seed, 10),
+		strconv.FormatBool(r.completed),
+		strconv.FormatFloat(r.duration.Seconds(), 'f', 3, 64),
+		strconv.FormatFloat(goodput, 'f', 3, 64),
+		meetRatio,
+		strconv.FormatUint(r.cellularBytes, 10),
+		strconv.FormatFloat(r.cost, 'f', 1, 64),
+	}
+}
+
+// runMatrixCell uploads a workload from a client with a WiFi and a cellular path, in virtual time
+func runMatrixCell(schedulerName string, profile matrixProfile, wl matrixWorkload, seed int64) matrixResult {
+	sim := netem.NewSimulation(seed)
+	clientHost := sim.AddHost("client")
+	serverHost := sim.AddHost("server")
+	wifi, err := sim.Connect(clientHost, wifiIP, serverHost, serverIP, profile.wifiUp, profile.wifiDown)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = sim.Connect(clientHost, cellularIP, serverHost, serverIP, profile.cellularUp, profile.cellularDn)
+	Expect(err).ToNot(HaveOccurred())
+	clock := sim.Clock()
+	start := clock.Now()
+	if profile.handover > 0 {
+		down := netem.LinkConfig{Loss: 1}
+		clock.AfterFunc(profile.handover, func() { wifi.SetConfig(down, down) })
+	}
+
+	server, err := quic.ListenAddr(serverIP+":4433", testdata.GetTLSConfig(), &quic.Config{
+		Network:  serverHost,
+		Clock:    clock,
+		RandSeed: seed,
+	})
+	Expect(err).ToNot(HaveOccurred())
+	defer server.Close()
+
+	var (
+		sink       = &workload.Sink{Clock: clock}
+		done       = make(chan struct{})
+		finished   time.Time
+		clientSess = make(chan quic.Session, 1)
+	)
+	// an unfinished transfer is reported as such, the errors of the sessions closed after the simulation are ignored
+	go func() {
+		sess, err := server.Accept()
+		if err != nil {
+			return
+		}
+		if err := sink.Serve(sess); err != nil {
+			return
+		}
+		finished = clock.Now()
+		close(done)
+	}()
+	go func() {
+		sess, err := quic.DialAddr(serverIP+":4433", &tls.Config{InsecureSkipVerify: true}, &quic.Config{
+			Network:       clientHost,
+			CreatePaths:   true,
+			Clock:         clock,
+			RandSeed:      seed,
+			SchedulerName: schedulerName,
+			// WiFi is free, otherwise its path would get one of the static costs
+			PathCosts: map[string]float64{wifiIP: 0, cellularIP: 1},
+		})
+		if err != nil {
+			return
+		}
+		clientSess <- sess
+		gen := &workload.Generator{Spec: wl.spec, Seed: seed, Clock: clock}
+		gen.Run(sess)
+	}()
+
+	runErr := sim.Run(done, 2*time.Minute)
+	result := matrixResult{
+		profile:   profile.name,
+		workload:  wl.name,
+		seed:      seed,
+		completed: runErr == nil,
+		meetRatio: math.NaN(),
+	}
+	if result.completed {
+		result.duration = finished.Sub(start)
+	} else {
+		result.duration = clock.Now().Sub(start)
+	}
+	// the deadline meet ratio counts the announced messages, those that never arrived missed their deadline
+	deadlines := make(map[string]bool)
+	var withDeadline, met int
+	for _, f := range wl.spec.Flows {
+		if f.Deadline > 0 {
+			deadlines[f.Name] = true
+			withDeadline += f.Count
+		}
+	}
+	for _, r := range sink.Report() {
+		result.received += r.Bytes
+		if deadlines[r.Name] {
+			met += r.Met
+		}
+	}
+	if withDeadline > 0 {
+		result.meetRatio = float64(met) / float64(withDeadline)
+	}
+	select {
+	case sess := <-clientSess:
+		stats := sess.ConnectionStats()
+		result.cost = stats.CostSpent
+		for _, p := range stats.Paths {
+			if addr, ok := p.LocalAddr.(*net.UDPAddr); ok && addr.IP.Equal(net.ParseIP(cellularIP)) {
+				result.cellularBytes += p.BytesAcked
+			}
+		}
+		// the writer of an unfinished transfer stays blocked, in the virtual time that no longer advances
+		sess.Close(nil)
+	default:
+	}
+	return result
+}
+
+// the rows of the report, in the order the cells ran
+var matrixResults []matrixResult
+
+var _ = Describe("Scheduler matrix", func() {
+	BeforeEach(func() {
+		if !matrix {
+			Skip("the scheduler matrix only runs with -matrix")
+		}
+		// the schedulers read their bandit parameters from this file
+		if _, err := os.Stat("../output/lin"); err != nil {
+			Skip("the scheduler matrix needs ../output/lin: " + err.Error())
+		}
+	})
+
+	for i := range matrixSchedulers {
+		sched := matrixSchedulers[i]
+		for j := range matrixProfiles {
+			profile := matrixProfiles[j]
+			for k := range matrixWorkloads {
+				wl := matrixWorkloads[k]
+
+				It(sched.name+" with a "+wl.name+" workload on "+profile.name+" paths", func() {
+					result := runMatrixCell(sched.schedulerName, profile, wl, GinkgoRandomSeed())
+					result.scheduler = sched.name
+					matrixResults = append(matrixResults, result)
+				})
+			}
+		}
+	}
+})
+
+var _ = AfterSuite(func() {
+	if len(matrixResults) == 0 {
+		return
+	}
+	out := io.Writer(os.Stdout)
+	if report != "" {
+		f, err := os.Create(report)
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+		out = f
+	}
+	w := csv.NewWriter(out)
+	Expect(w.Write(matrixHeader)).To(Succeed())
+	for i := range matrixResults {
+		Expect(w.Write(matrixResults[i].record())).To(Succeed())
+	}
+	w.Flush()
+	Expect(w.Error()).ToNot(HaveOccurred())
+})
@@ -0,0 +1,86 @@
+package quic
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/pcapng"
+)
+
+// capturePacket writes a datagram to the packet capture, if any
+func capturePacket(capture pcapng.Capturer, t time.Time, direction pcapng.Direction, localAddr, remoteAddr net.Addr, data []byte, comment string) {
+	if capture == nil {
+		return
+	}
+	capture.Capture(&pcapng.Packet{
+		Time:       t,
+		Direction:  direction,
+		LocalAddr:  localAddr,
+		RemoteAddr: remoteAddr,
+		Data:       data,
+		Comment:    comment,
+	})
+}
+
+// captureReceived writes a datagram received on one of the sockets to the packet capture, if any.
+// The datagram isn't attributed to a session yet, its path ID and packet number are read from its public header.
+func (pcm *pconnManager) captureReceived(rcvRawPacket *receivedRawPacket) {
+	if pcm.capture == nil {
+		return
+	}
+	sentBy := protocol.PerspectiveServer
+	if pcm.perspective == protocol.PerspectiveServer {
+		sentBy = protocol.PerspectiveClient
+	}
+	capturePacket(pcm.capture, rcvRawPacket.rcvTime, pcapng.Inbound, rcvRawPacket.rcvPconn.LocalAddr(), rcvRawPacket.remoteAddr, rcvRawPacket.data, receivedComment(rcvRawPacket.data, sentBy))
+}
+
+// receivedComment describes a received datagram.
+// The version of the session isn't known, the header is parsed as a multipath one with a deadline first, then without.
+func receivedComment(data []byte, sentBy protocol.Perspective) string {
+	hdr, err := wire.ParsePublicHeader(bytes.NewReader(data), sentBy, protocol.VersionMPDeadline)
+	if err != nil {
+		hdr, err = wire.ParsePublicHeader(bytes.NewReader(data), sentBy, protocol.VersionMP)
+	}
+	switch {
+	case err != nil:
+		return fmt.Sprintf("invalid public header: %s", err)
+	case hdr.ResetFlag:
+		return fmt.Sprintf("public reset, connection %x", hdr.ConnectionID)
+	case hdr.VersionFlag && sentBy == protocol.PerspectiveServer:
+		return fmt.Sprintf("version negotiation, connection %x", hdr.ConnectionID)
+	}
+	comment := fmt.Sprintf("path %d, packet %d", hdr.PathID, hdr.PacketNumber)
+	if !hdr.Deadline.IsZero() {
+		comment += ", deadline " + hdr.Deadline.UTC().Format(time.RFC3339Nano)
+	}
+	return comment
+}
+
+// captureSent writes a datagram sent by the session to the packet capture, if any
+func (s *session) captureSent(conn connection, data []byte, comment func() string) {
+	if s.config.PacketCapture == nil {
+		return
+	}
+	capturePacket(s.config.PacketCapture, s.clock.Now(), pcapng.Outbound, conn.LocalAddr(), conn.RemoteAddr(), data, comment())
+}
+
+// packetComment describes a packet sent on a path, with the batch decision it belongs to
+func (sch *scheduler) packetComment(packet *packedPacket, pth *path) string {
+	comment := fmt.Sprintf("path %d, packet %d", pth.pathID, packet.number)
+	if packet.m_deadline.IsZero() {
+		return comment + ", scheduler " + sch.SchedulerName
+	}
+	comment += fmt.Sprintf(", deadline in %s, scheduler %s", packet.m_deadline.Sub(pth.sess.clock.Now()).Round(time.Microsecond), sch.SchedulerName)
+	if sch.batchCount > 0 {
+		comment += fmt.Sprintf(", batch %d", sch.batchCount)
+	}
+	if sch.solver != "" {
+		comment += ", solver " + sch.solver
+	}
+	return comment
+}
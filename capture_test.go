@@ -0,0 +1,104 @@
+package quic
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/pcapng"
+	"github.com/lucas-clemente/quic-go/schedlog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockCapturer struct {
+	packets []pcapng.Packet
+}
+
+func (c *mockCapturer) Capture(p *pcapng.Packet) {
+	c.packets = append(c.packets, *p)
+}
+
+var _ = Describe("Packet capture", func() {
+	var capture *mockCapturer
+
+	writeHeader := func(hdr *wire.PublicHeader, pers protocol.Perspective) []byte {
+		b := &bytes.Buffer{}
+		Expect(hdr.Write(b, protocol.VersionMP, pers)).To(Succeed())
+		return append(b.Bytes(), []byte("payload")...)
+	}
+
+	BeforeEach(func() {
+		capture = &mockCapturer{}
+	})
+
+	It("captures the datagrams received by the pconnManager, with their path and packet number", func() {
+		pcm := newPconnManager(protocol.PerspectiveServer, &Config{PacketCapture: capture})
+		localAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4433}
+		remoteAddr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 1337}
+		data := writeHeader(&wire.PublicHeader{
+			ConnectionID:    0x1337,
+			MultipathFlag:   true,
+			PathID:          3,
+			PacketNumber:    42,
+			PacketNumberLen: protocol.PacketNumberLen2,
+		}, protocol.PerspectiveClient)
+		rcvTime := time.Now()
+		pcm.captureReceived(&receivedRawPacket{
+			rcvPconn:   &mockPacketConn{addr: localAddr},
+			remoteAddr: remoteAddr,
+			data:       data,
+			rcvTime:    rcvTime,
+		})
+		Expect(capture.packets).To(HaveLen(1))
+		p := capture.packets[0]
+		Expect(p.Time).To(Equal(rcvTime))
+		Expect(p.Direction).To(Equal(pcapng.Inbound))
+		Expect(p.LocalAddr).To(Equal(localAddr))
+		Expect(p.RemoteAddr).To(Equal(remoteAddr))
+		Expect(p.Data).To(Equal(data))
+		Expect(p.Comment).To(Equal("path 3, packet 42"))
+	})
+
+	It("doesn't capture without a packet capture", func() {
+		pcm := newPconnManager(protocol.PerspectiveServer, nil)
+		Expect(pcm.capture).To(BeNil())
+		pcm.captureReceived(&receivedRawPacket{rcvPconn: &mockPacketConn{addr: &net.UDPAddr{}}, data: []byte{0x08}})
+	})
+
+	It("describes the deadline of a received packet", func() {
+		b := &bytes.Buffer{}
+		hdr := &wire.PublicHeader{
+			ConnectionID:    0x1337,
+			MultipathFlag:   true,
+			PathID:          3,
+			PacketNumber:    42,
+			PacketNumberLen: protocol.PacketNumberLen2,
+			Deadline:        time.Date(2017, 7, 14, 2, 40, 0, 5000, time.UTC),
+		}
+		Expect(hdr.Write(b, protocol.VersionMPDeadline, protocol.PerspectiveClient)).To(Succeed())
+		b.WriteString("payload")
+		Expect(receivedComment(b.Bytes(), protocol.PerspectiveClient)).To(Equal("path 3, packet 42, deadline 2017-07-14T02:40:00.000005Z"))
+	})
+
+	It("describes public resets and invalid headers", func() {
+		reset := wire.WritePublicReset(0x1337, 1, 0)
+		Expect(receivedComment(reset, protocol.PerspectiveServer)).To(Equal("public reset, connection 1337"))
+		Expect(receivedComment(nil, protocol.PerspectiveServer)).To(HavePrefix("invalid public header: "))
+	})
+
+	It("describes the packets sent in a batch with their deadline and decision", func() {
+		sch := &scheduler{SchedulerName: "BatchLinOpt", batchCount: 7, solver: schedlog.SolverLinOpt}
+		pth := &path{pathID: 2, sess: &session{clock: utils.SystemClock{}}}
+		packet := &packedPacket{number: 12, m_deadline: time.Now().Add(time.Hour)}
+		Expect(sch.packetComment(packet, pth)).To(MatchRegexp(`^path 2, packet 12, deadline in 59m59\.\d+s, scheduler BatchLinOpt, batch 7, solver linopt$`))
+	})
+
+	It("describes the packets sent without deadline", func() {
+		sch := &scheduler{SchedulerName: "rtt"}
+		Expect(sch.packetComment(&packedPacket{number: 3}, &path{pathID: 1})).To(Equal("path 1, packet 3, scheduler rtt"))
+	})
+})
@@ -52,7 +52,7 @@ func DialAddr(addr string, tlsConf *tls.Config, config *Config) (Session, error)
 		return nil, err
 	}
 	// Create the pconnManager here. It will be used to manage UDP connections
-	pconnMgr := &pconnManager{perspective: protocol.PerspectiveClient}
+	pconnMgr := newPconnManager(protocol.PerspectiveClient, config)
 	err = pconnMgr.setup(nil, nil)
 	if err != nil {
 		return nil, err
@@ -72,7 +72,7 @@ func DialAddrNonFWSecure(
 		return nil, err
 	}
 	// Create the pconnManager here. It will be used to manage UDP connections
-	pconnMgr := &pconnManager{perspective: protocol.PerspectiveClient}
+	pconnMgr := newPconnManager(protocol.PerspectiveClient, config)
 	err = pconnMgr.setup(nil, nil)
 	if err != nil {
 		return nil, err
@@ -110,7 +110,7 @@ func DialNonFWSecure(
 	var pconnMgr *pconnManager
 
 	if pconnMgrArg == nil {
-		pconnMgr = &pconnManager{perspective: protocol.PerspectiveClient}
+		pconnMgr = newPconnManager(protocol.PerspectiveClient, config)
 		err := pconnMgr.setup(pconn, nil)
 		if err != nil {
 			return nil, err
@@ -179,6 +179,10 @@ func populateClientConfig(config *Config) *Config {
 	if config.IdleTimeout != 0 {
 		idleTimeout = config.IdleTimeout
 	}
+	pathsFrameInterval := protocol.DefaultPathsFrameInterval
+	if config.PathsFrameInterval != 0 {
+		pathsFrameInterval = config.PathsFrameInterval
+	}
 
 	maxReceiveStreamFlowControlWindow := config.MaxReceiveStreamFlowControlWindow
 	if maxReceiveStreamFlowControlWindow == 0 {
@@ -199,6 +203,24 @@ func populateClientConfig(config *Config) *Config {
 		KeepAlive:                             config.KeepAlive,
 		CacheHandshake:                        config.CacheHandshake,
 		CreatePaths:                           config.CreatePaths,
+		SchedulerName:                         config.SchedulerName,
+		WeightsFile:                           config.WeightsFile,
+		Training:                              config.Training,
+		Epsilon:                               config.Epsilon,
+		AllowedCongestion:                     config.AllowedCongestion,
+		DumpExperiences:                       config.DumpExperiences,
+		PathCosts:                             config.PathCosts,
+		CostBudget:                            config.CostBudget,
+		PathsFrameInterval:                    pathsFrameInterval,
+		Tracer:                                config.Tracer,
+		DecisionRecorder:                      config.DecisionRecorder,
+		PacketCapture:                         config.PacketCapture,
+		KeyLogWriter:                          config.KeyLogWriter,
+		Observer:                              config.Observer,
+		DeadlineMissRateThreshold:             config.DeadlineMissRateThreshold,
+		Network:                               config.Network,
+		Clock:                                 config.Clock,
+		RandSeed:                              config.RandSeed,
 	}
 }
 
@@ -258,6 +280,8 @@ listenLoop:
 		select {
 		case <-c.closeListen:
 			break listenLoop
+		case <-c.pconnMgr.closed:
+			break listenLoop
 		case err = <-c.pconnMgr.errorConn:
 			c.session.Close(err)
 			break listenLoop
@@ -290,19 +314,17 @@ func (c *client) handlePacket(rcvRawPacket *receivedRawPacket) {
 	r := bytes.NewReader(packet)
 	//fmt.Println("In Client handlePacket, packet bytes is:", packet)
 	hdr, err := wire.ParsePublicHeader(r, protocol.PerspectiveServer, c.version)
-
-	fmt.Println("Client curNotSentNum:", hdr.CurNotSent)
-	fmt.Println("Clietn Alpha:", hdr.Alpha)
-	//czy: get deadline from received packet header
-	fmt.Println("Client Received packet. PacketNumber:", hdr.PacketNumber, "Deadline:", hdr.Deadline)
-	fmt.Println("receive time:", rcvTime)
-	fmt.Println("Deadline - receive time:", hdr.Deadline.Sub(rcvTime))
-
 	if err != nil {
-		utils.Errorf("error parsing packet from %s: %s", remoteAddr.String(), err.Error())
+		utils.Errorf("error parsing packet from %s: %s", remoteAddr.String(), qerr.Error(qerr.InvalidPacketHeader, err.Error()))
 		// drop this packet if we can't parse the Public Header
 		return
 	}
+
+	//czy: get deadline from received packet header
+	if utils.Debug() {
+		utils.Debugf("Client received packet 0x%x for connection %x: cur not sent %d, alpha %d", hdr.PacketNumber, hdr.ConnectionID, hdr.CurNotSent, hdr.Alpha)
+		utils.Debugf("Deadline %s, received at %s, deadline - receive time: %s", hdr.Deadline, rcvTime, hdr.Deadline.Sub(rcvTime))
+	}
 	// reject packets with truncated connection id if we didn't request truncation
 	if hdr.TruncateConnectionID && !c.config.RequestConnectionIDTruncation {
 		return
@@ -326,7 +348,7 @@ func (c *client) handlePacket(rcvRawPacket *receivedRawPacket) {
 		}
 		pr, err := wire.ParsePublicReset(r)
 		if err != nil {
-			utils.Infof("Received a Public Reset for connection %x. An error occurred parsing the packet.")
+			utils.Infof("Received a Public Reset for connection %x. An error occurred parsing the packet.", hdr.ConnectionID)
 			return
 		}
 		utils.Infof("Received Public Reset, rejected packet number: %#x.", pr.RejectedPacketNumber)
@@ -380,6 +402,10 @@ func (c *client) handlePacketWithVersionFlag(hdr *wire.PublicHeader, remoteAddr
 		return qerr.InvalidVersion
 	}
 
+	if c.version.UsesDeadlines() && !newVersion.UsesDeadlines() {
+		utils.Infof("Server doesn't support deadlines, they won't be sent to the server.")
+	}
+
 	// switch to negotiated version
 	c.version = newVersion
 	var err error
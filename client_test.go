@@ -9,8 +9,10 @@ import (
 	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	"github.com/lucas-clemente/quic-go/qerr"
+	"github.com/lucas-clemente/quic-go/qlog"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -35,7 +37,7 @@ var _ = Describe("Client", func() {
 			ConnectionID:    connID,
 			PacketNumber:    1,
 			PacketNumberLen: 1,
-		}).Write(b, protocol.VersionWhatever, protocol.PerspectiveServer)
+		}).Write(b, protocol.SupportedVersions[0], protocol.PerspectiveServer)
 		Expect(err).ToNot(HaveOccurred())
 		return b.Bytes()
 	}
@@ -51,7 +53,7 @@ var _ = Describe("Client", func() {
 		config = &Config{
 			Versions: []protocol.VersionNumber{protocol.SupportedVersions[0], 77, 78},
 		}
-		pconnMgr = &pconnManager{}
+		pconnMgr = &pconnManager{clock: utils.SystemClock{}}
 		pconnMgr.setup(packetConn, addr)
 
 		msess, _, _ := newMockSession(nil, pconnMgr, false, 0, 0, nil, nil, nil)
@@ -267,11 +269,17 @@ var _ = Describe("Client", func() {
 				HandshakeTimeout:              1337 * time.Minute,
 				IdleTimeout:                   42 * time.Hour,
 				RequestConnectionIDTruncation: true,
+				Tracer:                        qlog.NewWriter(&bytes.Buffer{}, "client"),
+				SchedulerName:                 "BatchLinOpt",
+				Epsilon:                       0.1,
 			}
 			c := populateClientConfig(config)
 			Expect(c.HandshakeTimeout).To(Equal(1337 * time.Minute))
 			Expect(c.IdleTimeout).To(Equal(42 * time.Hour))
 			Expect(c.RequestConnectionIDTruncation).To(BeTrue())
+			Expect(c.Tracer).To(Equal(config.Tracer))
+			Expect(c.SchedulerName).To(Equal("BatchLinOpt"))
+			Expect(c.Epsilon).To(Equal(0.1))
 		})
 
 		It("fills in default values if options are not set in the Config", func() {
@@ -317,7 +325,7 @@ var _ = Describe("Client", func() {
 					ConnectionID:    0x1337,
 				}
 				b := &bytes.Buffer{}
-				err := ph.Write(b, protocol.VersionWhatever, protocol.PerspectiveServer)
+				err := ph.Write(b, cl.version, protocol.PerspectiveServer)
 				Expect(err).ToNot(HaveOccurred())
 				cl.handlePacket(&receivedRawPacket{remoteAddr: nil, data: b.Bytes()})
 				Expect(cl.versionNegotiated).To(BeTrue())
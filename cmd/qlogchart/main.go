@@ -0,0 +1,66 @@
+// Command qlogchart renders a qlog trace written by a qlog.Writer as a self-contained report,
+// with one chart per metric and one line per path:
+// smoothed RTT, congestion window, arm of the alpha bandit, deadline-meet ratio reported by the peer,
+// cumulative cost and the packets allocated to each path by the scheduler.
+// The HTML report also lists the deadline misses of the sessions by cause.
+//
+// The report has no external dependencies, it can be attached to the results of an experiment as is.
+//
+// Usage:
+//
+//	qlogchart [-conn decafbad] [-format html|svg] [-o report.html] trace.qlog
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	conn := flag.String("conn", "", "only chart the session with this connection ID")
+	format := flag.String("format", "html", "format of the report, html or svg")
+	output := flag.String("o", "", "file to write the report in, instead of the standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] trace.qlog\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*format != "html" && *format != "svg") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *conn, *format, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(traceFile, conn, format, output string) error {
+	f, err := os.Open(traceFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	t, err := readTrace(f, conn)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		out, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		w = out
+	}
+	charts := t.charts()
+	if format == "svg" {
+		return writeSVG(w, charts)
+	}
+	return writeHTML(w, traceFile, t, charts)
+}
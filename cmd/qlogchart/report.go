@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+)
+
+// writeHTML writes the report as an HTML page, with the charts inlined
+func writeHTML(w io.Writer, traceFile string, t *trace, charts []*chart) error {
+	summaries, err := t.missSummaries()
+	if err != nil {
+		return err
+	}
+	title := t.title
+	if title == "" {
+		title = filepath.Base(traceFile)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprint(bw, "<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:4px 8px;text-align:right}svg{display:block;margin-bottom:1em}</style>\n")
+	fmt.Fprintf(bw, "</head>\n<body>\n<h1>%s</h1>\n", html.EscapeString(title))
+	fmt.Fprintf(bw, "<p>Trace %s, %d events of %d sessions.</p>\n", html.EscapeString(traceFile), len(t.events), len(t.groups))
+
+	if len(summaries) > 0 {
+		fmt.Fprint(bw, "<h2>Deadline misses</h2>\n<table>\n<tr><th>Connection</th><th>No feasible path</th><th>Deferred</th><th>Held for cost</th><th>Retransmitted</th><th>Not sent</th><th>Late on path</th></tr>\n")
+		for _, s := range summaries {
+			d := s.data
+			fmt.Fprintf(bw, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td></tr>\n",
+				html.EscapeString(s.group), d.NoFeasiblePath, d.Deferred, d.HeldForCost, d.Retransmitted, d.NotSent, d.LateOnPath)
+		}
+		fmt.Fprint(bw, "</table>\n")
+	}
+
+	for _, c := range charts {
+		renderChart(bw, c, 0)
+	}
+	fmt.Fprint(bw, "</body>\n</html>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+)
+
+const (
+	chartWidth   = 760
+	chartHeight  = 260
+	marginLeft   = 70
+	marginRight  = 130
+	marginTop    = 30
+	marginBottom = 40
+	// maxPoints is the number of points above which a series is thinned out, to keep the report small
+	maxPoints = 2000
+)
+
+// colors of the series, reused when a chart has more series
+var colors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+type point struct {
+	x, y float64
+}
+
+// A series is the evolution of a metric of one path
+type series struct {
+	label  string
+	points []point
+}
+
+func (s *series) add(x, y float64) {
+	s.points = append(s.points, point{x, y})
+}
+
+// thinned returns at most maxPoints points of the series, always keeping the last one
+func (s *series) thinned() []point {
+	if len(s.points) <= maxPoints {
+		return s.points
+	}
+	stride := (len(s.points) + maxPoints - 1) / maxPoints
+	points := make([]point, 0, maxPoints+1)
+	for i := 0; i < len(s.points); i += stride {
+		points = append(points, s.points[i])
+	}
+	if last := s.points[len(s.points)-1]; points[len(points)-1] != last {
+		points = append(points, last)
+	}
+	return points
+}
+
+// A chart shows a metric of all paths over time
+type chart struct {
+	title string
+	unit  string
+	// step charts hold every value until the next one
+	step   bool
+	series []*series
+}
+
+// bounds returns the ranges of the axes, the y axis always includes 0
+func (c *chart) bounds() (maxX, minY, maxY float64) {
+	for _, s := range c.series {
+		for _, p := range s.points {
+			maxX = math.Max(maxX, p.x)
+			minY = math.Min(minY, p.y)
+			maxY = math.Max(maxY, p.y)
+		}
+	}
+	if maxX == 0 {
+		maxX = 1
+	}
+	if maxY == minY {
+		maxY = minY + 1
+	}
+	return
+}
+
+// niceStep rounds the interval between ticks to 1, 2 or 5 times a power of 10
+func niceStep(raw float64) float64 {
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch f := raw / mag; {
+	case f <= 1:
+		return mag
+	case f <= 2:
+		return 2 * mag
+	case f <= 5:
+		return 5 * mag
+	}
+	return 10 * mag
+}
+
+// ticks returns about n round values between min and max
+func ticks(min, max float64, n int) []float64 {
+	step := niceStep((max - min) / float64(n))
+	var l []float64
+	for v := math.Ceil(min/step) * step; v <= max+step/1e6; v += step {
+		l = append(l, v)
+	}
+	return l
+}
+
+func formatTick(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+// renderChart writes a chart as an svg element, at the given vertical offset.
+// w is buffered by the caller, which reports the write errors.
+func renderChart(w io.Writer, c *chart, offsetY int) {
+	maxX, minY, maxY := c.bounds()
+	plotWidth := float64(chartWidth - marginLeft - marginRight)
+	plotHeight := float64(chartHeight - marginTop - marginBottom)
+	px := func(x float64) float64 { return marginLeft + x/maxX*plotWidth }
+	py := func(y float64) float64 { return marginTop + (maxY-y)/(maxY-minY)*plotHeight }
+
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" y=\"%d\" width=\"%d\" height=\"%d\" font-family=\"sans-serif\" font-size=\"11\">\n", offsetY, chartWidth, chartHeight)
+	fmt.Fprintf(w, "<text x=\"%d\" y=\"18\" font-size=\"14\" font-weight=\"bold\">%s</text>\n", marginLeft, html.EscapeString(c.title))
+	if len(c.series) == 0 {
+		fmt.Fprintf(w, "<text x=\"%d\" y=\"%d\" fill=\"#888\">no events in the trace</text>\n</svg>\n", marginLeft, marginTop+int(plotHeight)/2)
+		return
+	}
+
+	for _, v := range ticks(minY, maxY, 5) {
+		y := py(v)
+		fmt.Fprintf(w, "<line x1=\"%d\" x2=\"%.1f\" y1=\"%.1f\" y2=\"%.1f\" stroke=\"#ddd\"/>\n", marginLeft, marginLeft+plotWidth, y, y)
+		fmt.Fprintf(w, "<text x=\"%d\" y=\"%.1f\" text-anchor=\"end\">%s</text>\n", marginLeft-6, y+4, formatTick(v))
+	}
+	for _, v := range ticks(0, maxX, 8) {
+		x := px(v)
+		fmt.Fprintf(w, "<line x1=\"%.1f\" x2=\"%.1f\" y1=\"%d\" y2=\"%.1f\" stroke=\"#ddd\"/>\n", x, x, marginTop, marginTop+plotHeight)
+		fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"middle\">%s</text>\n", x, marginTop+plotHeight+16, formatTick(v))
+	}
+	fmt.Fprintf(w, "<rect x=\"%d\" y=\"%d\" width=\"%.1f\" height=\"%.1f\" fill=\"none\" stroke=\"#444\"/>\n", marginLeft, marginTop, plotWidth, plotHeight)
+	fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">time (s)</text>\n", marginLeft+plotWidth/2, chartHeight-6)
+	fmt.Fprintf(w, "<text transform=\"translate(14 %.1f) rotate(-90)\" text-anchor=\"middle\">%s</text>\n", marginTop+plotHeight/2, html.EscapeString(c.unit))
+
+	for i, s := range c.series {
+		color := colors[i%len(colors)]
+		fmt.Fprintf(w, "<polyline fill=\"none\" stroke=\"%s\" stroke-width=\"1.5\" points=\"", color)
+		var prev *point
+		for _, p := range s.thinned() {
+			if c.step && prev != nil {
+				fmt.Fprintf(w, "%.1f,%.1f ", px(p.x), py(prev.y))
+			}
+			fmt.Fprintf(w, "%.1f,%.1f ", px(p.x), py(p.y))
+			p := p
+			prev = &p
+		}
+		fmt.Fprint(w, "\"/>\n")
+
+		legendY := marginTop + 8 + 18*i
+		fmt.Fprintf(w, "<line x1=\"%.1f\" x2=\"%.1f\" y1=\"%d\" y2=\"%d\" stroke=\"%s\" stroke-width=\"3\"/>\n", marginLeft+plotWidth+12, marginLeft+plotWidth+32, legendY, legendY, color)
+		fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%d\">%s</text>\n", marginLeft+plotWidth+38, legendY+4, html.EscapeString(s.label))
+	}
+	fmt.Fprint(w, "</svg>\n")
+}
+
+// writeSVG writes all the charts, one below the other, as a single SVG document
+func writeSVG(w io.Writer, charts []*chart) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n", chartWidth, chartHeight*len(charts))
+	fmt.Fprintf(bw, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	for i, c := range charts {
+		renderChart(bw, c, i*chartHeight)
+	}
+	fmt.Fprint(bw, "</svg>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// recordSeparator starts every record of a JSON text sequence (RFC 7464)
+const recordSeparator = 0x1e
+
+// An event is a record of the trace, after the header
+type event struct {
+	// Time is in milliseconds, relative to the reference time of the trace
+	Time    float64         `json:"time"`
+	Name    string          `json:"name"`
+	GroupID string          `json:"group_id"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// eventData holds the fields of the events the charts are made of
+type eventData struct {
+	PathID *int `json:"path_id"`
+	Header struct {
+		PathID int `json:"path_id"`
+	} `json:"header"`
+	Arm  *int    `json:"arm"`
+	Cost float64 `json:"cost"`
+
+	SmoothedRTT      float64 `json:"smoothed_rtt"`
+	CongestionWindow float64 `json:"congestion_window"`
+
+	NumMeetDeadline uint64 `json:"num_meet_deadline"`
+	NumHasDeadline  uint64 `json:"num_has_deadline"`
+
+	Selected bool `json:"selected"`
+
+	NoFeasiblePath uint64 `json:"no_feasible_path"`
+	Deferred       uint64 `json:"deferred"`
+	HeldForCost    uint64 `json:"held_for_cost"`
+	Retransmitted  uint64 `json:"retransmitted"`
+	NotSent        uint64 `json:"not_sent"`
+	LateOnPath     uint64 `json:"late_on_path"`
+}
+
+// A trace is a qlog trace, restricted to one session if a connection ID was given
+type trace struct {
+	title  string
+	events []event
+	// groups are the connection IDs of the sessions, in order of appearance
+	groups []string
+}
+
+// readTrace reads a qlog trace in the JSON-SEQ format
+func readTrace(r io.Reader, conn string) (*trace, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	t := &trace{}
+	seen := make(map[string]bool)
+	for i, rec := range bytes.Split(data, []byte{recordSeparator}) {
+		rec = bytes.TrimSpace(rec)
+		if len(rec) == 0 {
+			continue
+		}
+		var ev event
+		if err := json.Unmarshal(rec, &ev); err != nil {
+			return nil, fmt.Errorf("record %d: %s", i, err)
+		}
+		if ev.Name == "" {
+			// the header
+			var header struct {
+				Title string `json:"title"`
+			}
+			if err := json.Unmarshal(rec, &header); err != nil {
+				return nil, fmt.Errorf("record %d: %s", i, err)
+			}
+			t.title = header.Title
+			continue
+		}
+		if conn != "" && ev.GroupID != conn {
+			continue
+		}
+		if !seen[ev.GroupID] {
+			seen[ev.GroupID] = true
+			t.groups = append(t.groups, ev.GroupID)
+		}
+		t.events = append(t.events, ev)
+	}
+	if len(t.events) == 0 {
+		return nil, fmt.Errorf("no events in the trace")
+	}
+	return t, nil
+}
+
+// A missSummary are the deadline misses of a session by cause
+type missSummary struct {
+	group string
+	data  eventData
+}
+
+// missSummaries returns the deadline miss summaries traced when the sessions closed
+func (t *trace) missSummaries() ([]missSummary, error) {
+	var summaries []missSummary
+	for _, ev := range t.events {
+		if ev.Name != "multipath:deadline_miss_summary" {
+			continue
+		}
+		var d eventData
+		if err := json.Unmarshal(ev.Data, &d); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, missSummary{group: ev.GroupID, data: d})
+	}
+	return summaries, nil
+}
+
+// seriesSet collects the series of a chart, one per session and path
+type seriesSet struct {
+	multipleGroups bool
+	series         map[string]*series
+}
+
+func newSeriesSet(t *trace) *seriesSet {
+	return &seriesSet{multipleGroups: len(t.groups) > 1, series: make(map[string]*series)}
+}
+
+// get returns the series of a path, creating it if needed
+func (s *seriesSet) get(group string, pathID int) *series {
+	label := fmt.Sprintf("path %d", pathID)
+	if pathID < 0 {
+		label = "no path"
+	}
+	if s.multipleGroups {
+		label = group + " " + label
+	}
+	ser, ok := s.series[label]
+	if !ok {
+		ser = &series{label: label}
+		s.series[label] = ser
+	}
+	return ser
+}
+
+// sorted returns the series in the order of their labels
+func (s *seriesSet) sorted() []*series {
+	l := make([]*series, 0, len(s.series))
+	for _, ser := range s.series {
+		l = append(l, ser)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].label < l[j].label })
+	return l
+}
+
+// charts computes the time series of the trace
+func (t *trace) charts() []*chart {
+	var (
+		rtt        = newSeriesSet(t)
+		cwnd       = newSeriesSet(t)
+		arm        = newSeriesSet(t)
+		meetRatio  = newSeriesSet(t)
+		cost       = newSeriesSet(t)
+		allocation = newSeriesSet(t)
+	)
+	type pathKey struct {
+		group  string
+		pathID int
+	}
+	lastArm := make(map[pathKey]int)
+	met := make(map[pathKey]uint64)
+	withDeadline := make(map[pathKey]uint64)
+	spent := make(map[pathKey]float64)
+	allocated := make(map[pathKey]float64)
+
+	for _, ev := range t.events {
+		var d eventData
+		if err := json.Unmarshal(ev.Data, &d); err != nil {
+			continue
+		}
+		// times are charted in seconds
+		x := ev.Time / 1000
+		pathID := -1
+		if d.PathID != nil {
+			pathID = *d.PathID
+		}
+		switch ev.Name {
+		case "recovery:metrics_updated":
+			rtt.get(ev.GroupID, pathID).add(x, d.SmoothedRTT)
+			cwnd.get(ev.GroupID, pathID).add(x, d.CongestionWindow/1000)
+		case "transport:packet_sent":
+			key := pathKey{ev.GroupID, d.Header.PathID}
+			if d.Arm != nil {
+				if last, ok := lastArm[key]; !ok || last != *d.Arm {
+					lastArm[key] = *d.Arm
+					arm.get(ev.GroupID, key.pathID).add(x, float64(*d.Arm))
+				}
+			}
+			if d.Cost > 0 {
+				spent[key] += d.Cost
+				cost.get(ev.GroupID, key.pathID).add(x, spent[key])
+			}
+		case "multipath:ack_processed":
+			key := pathKey{ev.GroupID, pathID}
+			met[key] += d.NumMeetDeadline
+			withDeadline[key] += d.NumHasDeadline
+			if withDeadline[key] > 0 {
+				meetRatio.get(ev.GroupID, pathID).add(x, float64(met[key])/float64(withDeadline[key]))
+			}
+		case "multipath:scheduler_decision":
+			if !d.Selected {
+				pathID = -1
+			}
+			key := pathKey{ev.GroupID, pathID}
+			allocated[key]++
+			allocation.get(ev.GroupID, pathID).add(x, allocated[key])
+		}
+	}
+
+	return []*chart{
+		{title: "Smoothed RTT", unit: "ms", series: rtt.sorted()},
+		{title: "Congestion window", unit: "kB", series: cwnd.sorted()},
+		{title: "Alpha bandit arm", unit: "arm", series: arm.sorted(), step: true},
+		{title: "Deadline-meet ratio", unit: "ratio", series: meetRatio.sorted()},
+		{title: "Cumulative cost", unit: "cost", series: cost.sorted(), step: true},
+		{title: "Scheduler allocation", unit: "decisions", series: allocation.sorted(), step: true},
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// jsonSeq joins records in the JSON-SEQ format
+func jsonSeq(records ...string) string {
+	var b strings.Builder
+	for _, rec := range records {
+		b.WriteByte(recordSeparator)
+		b.WriteString(rec)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+const header = `{"qlog_format":"JSON-SEQ","title":"mpquic"}`
+
+var _ = Describe("Trace", func() {
+	type readCase struct {
+		name    string
+		records []string
+		conn    string
+
+		err    string
+		title  string
+		events []string
+		groups []string
+	}
+
+	readCases := []readCase{
+		{name: "reads the title and the events", records: []string{
+			header,
+			`{"time":1,"name":"transport:packet_sent","group_id":"a1","data":{}}`,
+			`{"time":2,"name":"transport:packet_received","group_id":"a1","data":{}}`,
+		}, title: "mpquic", events: []string{"transport:packet_sent", "transport:packet_received"}, groups: []string{"a1"}},
+		{name: "lists the sessions in order of appearance", records: []string{
+			header,
+			`{"time":1,"name":"transport:packet_sent","group_id":"b2","data":{}}`,
+			`{"time":2,"name":"transport:packet_sent","group_id":"a1","data":{}}`,
+			`{"time":3,"name":"transport:packet_sent","group_id":"b2","data":{}}`,
+		}, title: "mpquic", events: []string{"transport:packet_sent", "transport:packet_sent", "transport:packet_sent"}, groups: []string{"b2", "a1"}},
+		{name: "keeps the events of one session", conn: "a1", records: []string{
+			header,
+			`{"time":1,"name":"transport:packet_sent","group_id":"b2","data":{}}`,
+			`{"time":2,"name":"transport:packet_received","group_id":"a1","data":{}}`,
+		}, title: "mpquic", events: []string{"transport:packet_received"}, groups: []string{"a1"}},
+		{name: "errors on an invalid record", records: []string{header, `{"time":`}, err: "record 2: "},
+		{name: "errors without events", records: []string{header}, err: "no events in the trace"},
+		{name: "errors without events of the session", conn: "c3", records: []string{
+			header,
+			`{"time":1,"name":"transport:packet_sent","group_id":"a1","data":{}}`,
+		}, err: "no events in the trace"},
+	}
+
+	for i := range readCases {
+		c := readCases[i]
+		It(c.name, func() {
+			t, err := readTrace(strings.NewReader(jsonSeq(c.records...)), c.conn)
+			if c.err != "" {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix(c.err))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.title).To(Equal(c.title))
+			var names []string
+			for _, ev := range t.events {
+				names = append(names, ev.Name)
+			}
+			Expect(names).To(Equal(c.events))
+			Expect(t.groups).To(Equal(c.groups))
+		})
+	}
+
+	type chartCase struct {
+		name   string
+		events []string
+		// chart is the title of the chart checked, series its series by label
+		chart  string
+		series map[string][]point
+	}
+
+	chartCases := []chartCase{
+		{name: "charts the RTT of each path", chart: "Smoothed RTT", events: []string{
+			`{"time":1000,"name":"recovery:metrics_updated","group_id":"a1","data":{"path_id":1,"smoothed_rtt":20,"congestion_window":30000}}`,
+			`{"time":2000,"name":"recovery:metrics_updated","group_id":"a1","data":{"path_id":3,"smoothed_rtt":40,"congestion_window":15000}}`,
+		}, series: map[string][]point{"path 1": {{1, 20}}, "path 3": {{2, 40}}}},
+		{name: "charts the congestion window in kB", chart: "Congestion window", events: []string{
+			`{"time":500,"name":"recovery:metrics_updated","group_id":"a1","data":{"path_id":1,"smoothed_rtt":20,"congestion_window":30000}}`,
+		}, series: map[string][]point{"path 1": {{0.5, 30}}}},
+		{name: "charts the changes of bandit arm only", chart: "Alpha bandit arm", events: []string{
+			`{"time":1000,"name":"transport:packet_sent","group_id":"a1","data":{"header":{"path_id":1},"arm":2}}`,
+			`{"time":2000,"name":"transport:packet_sent","group_id":"a1","data":{"header":{"path_id":1},"arm":2}}`,
+			`{"time":3000,"name":"transport:packet_sent","group_id":"a1","data":{"header":{"path_id":1},"arm":0}}`,
+		}, series: map[string][]point{"path 1": {{1, 2}, {3, 0}}}},
+		{name: "charts the cumulative cost of each path", chart: "Cumulative cost", events: []string{
+			`{"time":1000,"name":"transport:packet_sent","group_id":"a1","data":{"header":{"path_id":3},"cost":2}}`,
+			`{"time":2000,"name":"transport:packet_sent","group_id":"a1","data":{"header":{"path_id":1}}}`,
+			`{"time":3000,"name":"transport:packet_sent","group_id":"a1","data":{"header":{"path_id":3},"cost":2}}`,
+		}, series: map[string][]point{"path 3": {{1, 2}, {3, 4}}}},
+		{name: "charts the cumulative deadline-meet ratio", chart: "Deadline-meet ratio", events: []string{
+			`{"time":1000,"name":"multipath:ack_processed","group_id":"a1","data":{"path_id":1,"num_meet_deadline":0,"num_has_deadline":0}}`,
+			`{"time":2000,"name":"multipath:ack_processed","group_id":"a1","data":{"path_id":1,"num_meet_deadline":1,"num_has_deadline":2}}`,
+			`{"time":3000,"name":"multipath:ack_processed","group_id":"a1","data":{"path_id":1,"num_meet_deadline":2,"num_has_deadline":2}}`,
+		}, series: map[string][]point{"path 1": {{2, 0.5}, {3, 0.75}}}},
+		{name: "charts the packets without a path apart", chart: "Scheduler allocation", events: []string{
+			`{"time":1000,"name":"multipath:scheduler_decision","group_id":"a1","data":{"path_id":1,"selected":true}}`,
+			`{"time":2000,"name":"multipath:scheduler_decision","group_id":"a1","data":{"path_id":1,"selected":false}}`,
+			`{"time":3000,"name":"multipath:scheduler_decision","group_id":"a1","data":{"path_id":1,"selected":true}}`,
+		}, series: map[string][]point{"path 1": {{1, 1}, {3, 2}}, "no path": {{2, 1}}}},
+		{name: "labels the series with the session when there are several", chart: "Smoothed RTT", events: []string{
+			`{"time":1000,"name":"recovery:metrics_updated","group_id":"a1","data":{"path_id":1,"smoothed_rtt":20}}`,
+			`{"time":1000,"name":"recovery:metrics_updated","group_id":"b2","data":{"path_id":1,"smoothed_rtt":30}}`,
+		}, series: map[string][]point{"a1 path 1": {{1, 20}}, "b2 path 1": {{1, 30}}}},
+	}
+
+	for i := range chartCases {
+		c := chartCases[i]
+		It(c.name, func() {
+			t, err := readTrace(strings.NewReader(jsonSeq(append([]string{header}, c.events...)...)), "")
+			Expect(err).ToNot(HaveOccurred())
+			var ch *chart
+			for _, cht := range t.charts() {
+				if cht.title == c.chart {
+					ch = cht
+				}
+			}
+			Expect(ch).ToNot(BeNil())
+			series := make(map[string][]point)
+			for _, s := range ch.series {
+				series[s.label] = s.points
+			}
+			Expect(series).To(Equal(c.series))
+		})
+	}
+
+	It("reads the deadline miss summaries", func() {
+		t, err := readTrace(strings.NewReader(jsonSeq(header,
+			`{"time":1000,"name":"transport:packet_sent","group_id":"a1","data":{}}`,
+			`{"time":2000,"name":"multipath:deadline_miss_summary","group_id":"a1","data":{"no_feasible_path":3,"late_on_path":2}}`,
+		)), "")
+		Expect(err).ToNot(HaveOccurred())
+		summaries, err := t.missSummaries()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(summaries).To(HaveLen(1))
+		Expect(summaries[0].group).To(Equal("a1"))
+		Expect(summaries[0].data.NoFeasiblePath).To(BeEquivalentTo(3))
+		Expect(summaries[0].data.LateOnPath).To(BeEquivalentTo(2))
+	})
+})
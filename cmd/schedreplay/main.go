@@ -0,0 +1,107 @@
+// Command schedreplay replays the decisions of a decision log with other schedulers, without a network,
+// and compares their deadline-meet ratio, cost and unused capacity with the recorded decisions.
+//
+// A packet meets its deadline if the one-way delay of its path, half of the recorded smoothed RTT, is below it.
+// Packets without a path miss their deadline: unlike in a session, they are not kept for the next batch.
+//
+// Usage:
+//
+//	schedreplay [-schedulers rtt,ecf,BatchLinOpt] [-conn decafbad] decisions.jsonl
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/schedlog"
+)
+
+// recorded is the name of the recorded decisions in the report
+const recorded = "recorded"
+
+func main() {
+	schedulers := flag.String("schedulers", strings.Join(quic.ReplaySchedulers, ","), "comma-separated schedulers to replay")
+	conn := flag.String("conn", "", "only replay the decisions of the session with this connection ID")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] decisions.jsonl\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	results, err := replay(schedlog.NewReader(f), strings.Split(*schedulers, ","), *conn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	report(os.Stdout, results)
+}
+
+// replay runs the schedulers on every decision of the log.
+// The first result is the one of the recorded decisions, followed by the one of each scheduler.
+func replay(r *schedlog.Reader, schedulers []string, conn string) ([]*result, error) {
+	replays := make([]*quic.SchedulerReplay, len(schedulers))
+	results := []*result{{name: recorded}}
+	for i, name := range schedulers {
+		var err error
+		if replays[i], err = quic.NewSchedulerReplay(name); err != nil {
+			return nil, err
+		}
+		results = append(results, &result{name: name})
+	}
+
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Type != schedlog.TypeDecision || (conn != "" && rec.ConnectionID != conn) {
+			continue
+		}
+		d := rec.Decision
+		results[0].add(d, recordedSelection(d))
+		for i, replay := range replays {
+			results[i+1].add(d, replay.SelectBatch(d))
+		}
+	}
+}
+
+// recordedSelection are the paths on which the packets of a recorded decision were sent
+func recordedSelection(d *schedlog.Decision) []int {
+	selected := append([]int(nil), d.Selected...)
+	for _, i := range d.Deferred {
+		if i < len(selected) {
+			selected[i] = schedlog.NoPath
+		}
+	}
+	return selected
+}
+
+// report prints the results side by side
+func report(w io.Writer, results []*result) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "scheduler\tdecisions\tpackets\tsent\tdeadline met\tcost\tcost/packet\tunused capacity\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\t%.2f\t%.3f\t%.1f%%\t\n",
+			r.name, r.decisions, r.packets, r.sent, 100*r.meetRatio(), r.cost, r.costPerPacket(), 100*r.unusedCapacity())
+	}
+	tw.Flush()
+}
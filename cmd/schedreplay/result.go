@@ -0,0 +1,71 @@
+package main
+
+import "github.com/lucas-clemente/quic-go/schedlog"
+
+// A result accumulates the metrics of a scheduler over the replayed decisions
+type result struct {
+	name string
+
+	decisions int
+	packets   int
+	sent      int
+	met       int
+	cost      float64
+	// capacity is the number of packets that fit in the congestion windows of the paths, unused the part of it left empty
+	capacity float64
+	unused   float64
+}
+
+// add accounts the paths selected for the packets of a decision
+func (r *result) add(d *schedlog.Decision, selected []int) {
+	r.decisions++
+	r.packets += len(d.Deadlines)
+
+	paths := make(map[int]schedlog.PathState, len(d.Paths))
+	for _, state := range d.Paths {
+		paths[state.PathID] = state
+	}
+	used := make(map[int]float64)
+	for i, pathID := range selected {
+		state, ok := paths[pathID]
+		if !ok || i >= len(d.Deadlines) {
+			continue
+		}
+		r.sent++
+		used[pathID]++
+		r.cost += state.Cost
+		if state.SmoothedRTT/2 <= float64(d.Deadlines[i]) {
+			r.met++
+		}
+	}
+	for _, state := range d.Paths {
+		r.capacity += state.RemainingCwnd
+		if state.RemainingCwnd > used[state.PathID] {
+			r.unused += state.RemainingCwnd - used[state.PathID]
+		}
+	}
+}
+
+// meetRatio is the fraction of packets that met their deadline, packets without a path included
+func (r *result) meetRatio() float64 {
+	if r.packets == 0 {
+		return 0
+	}
+	return float64(r.met) / float64(r.packets)
+}
+
+// costPerPacket is the average cost of the sent packets
+func (r *result) costPerPacket() float64 {
+	if r.sent == 0 {
+		return 0
+	}
+	return r.cost / float64(r.sent)
+}
+
+// unusedCapacity is the fraction of the capacity of the paths left empty
+func (r *result) unusedCapacity() float64 {
+	if r.capacity == 0 {
+		return 0
+	}
+	return r.unused / r.capacity
+}
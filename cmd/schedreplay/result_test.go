@@ -0,0 +1,80 @@
+package main
+
+import (
+	"github.com/lucas-clemente/quic-go/schedlog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Result", func() {
+	// a fast free path with room for 2 packets, a slow metered path with room for 4
+	decision := func(deadlines ...int) *schedlog.Decision {
+		return &schedlog.Decision{
+			Deadlines: deadlines,
+			Paths: []schedlog.PathState{
+				{PathID: 1, SmoothedRTT: 20, RemainingCwnd: 2},
+				{PathID: 3, SmoothedRTT: 80, RemainingCwnd: 4, Cost: 2},
+			},
+		}
+	}
+
+	type resultCase struct {
+		name     string
+		decision *schedlog.Decision
+		selected []int
+
+		sent           int
+		met            int
+		meetRatio      float64
+		costPerPacket  float64
+		unusedCapacity float64
+	}
+
+	cases := []resultCase{
+		{name: "accounts the packets sent on the fast path", decision: decision(20, 30), selected: []int{1, 1},
+			sent: 2, met: 2, meetRatio: 1, costPerPacket: 0, unusedCapacity: 4.0 / 6},
+		{name: "counts the deadlines missed on the slow path", decision: decision(20, 50), selected: []int{3, 3},
+			sent: 2, met: 1, meetRatio: 0.5, costPerPacket: 2, unusedCapacity: 4.0 / 6},
+		{name: "counts the packets without a path as missed", decision: decision(20, 30), selected: []int{1, schedlog.NoPath},
+			sent: 1, met: 1, meetRatio: 0.5, costPerPacket: 0, unusedCapacity: 5.0 / 6},
+		{name: "ignores the paths that weren't recorded", decision: decision(20), selected: []int{5},
+			sent: 0, met: 0, meetRatio: 0, costPerPacket: 0, unusedCapacity: 1},
+		{name: "ignores the selections beyond the packets of the decision", decision: decision(20), selected: []int{1, 1, 1},
+			sent: 1, met: 1, meetRatio: 1, costPerPacket: 0, unusedCapacity: 5.0 / 6},
+		{name: "doesn't count a path used beyond its window as unused", decision: decision(20, 20, 20), selected: []int{1, 1, 1},
+			sent: 3, met: 3, meetRatio: 1, costPerPacket: 0, unusedCapacity: 4.0 / 6},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		It(c.name, func() {
+			r := &result{name: "test"}
+			r.add(c.decision, c.selected)
+			Expect(r.decisions).To(Equal(1))
+			Expect(r.packets).To(Equal(len(c.decision.Deadlines)))
+			Expect(r.sent).To(Equal(c.sent))
+			Expect(r.met).To(Equal(c.met))
+			Expect(r.meetRatio()).To(BeNumerically("~", c.meetRatio))
+			Expect(r.costPerPacket()).To(BeNumerically("~", c.costPerPacket))
+			Expect(r.unusedCapacity()).To(BeNumerically("~", c.unusedCapacity))
+		})
+	}
+
+	It("accumulates the decisions", func() {
+		r := &result{}
+		r.add(decision(20, 50), []int{1, 3})
+		r.add(decision(20, 50), []int{3, 3})
+		Expect(r.decisions).To(Equal(2))
+		Expect(r.packets).To(Equal(4))
+		Expect(r.meetRatio()).To(Equal(0.75))
+		Expect(r.costPerPacket()).To(Equal(6.0 / 4))
+		Expect(r.unusedCapacity()).To(Equal(8.0 / 12))
+	})
+
+	It("has no ratios without decisions", func() {
+		r := &result{}
+		Expect(r.meetRatio()).To(BeZero())
+		Expect(r.costPerPacket()).To(BeZero())
+		Expect(r.unusedCapacity()).To(BeZero())
+	})
+})
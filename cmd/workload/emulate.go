@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/netem"
+	"github.com/lucas-clemente/quic-go/workload"
+)
+
+const serverIP = "10.2.0.1"
+
+// simulationLimit is the virtual time after which an emulated workload is aborted
+const simulationLimit = time.Hour
+
+// parsePaths parses the links of the emulated paths, e.g. "15ms:10,50ms:4"
+func parsePaths(s string) ([]netem.LinkConfig, error) {
+	var links []netem.LinkConfig
+	for _, p := range strings.Split(s, ",") {
+		fields := strings.Split(p, ":")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid path %q, expected delay:bandwidth", p)
+		}
+		delay, err := time.ParseDuration(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %s", p, err)
+		}
+		mbps, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || mbps <= 0 {
+			return nil, fmt.Errorf("invalid bandwidth in path %q", p)
+		}
+		links = append(links, netem.LinkConfig{Delay: delay, Bandwidth: mbps * 1e6 / 8, QueueSize: 100})
+	}
+	return links, nil
+}
+
+// runEmulated runs the generator and the sink over an emulated network, with a link per path between them
+func runEmulated(spec *workload.Spec, paths string, virtual bool, config *quic.Config, arrivals string) error {
+	links, err := parsePaths(paths)
+	if err != nil {
+		return err
+	}
+	var (
+		network *netem.Network
+		sim     *netem.Simulation
+		clock   quic.Clock
+	)
+	if virtual {
+		sim = netem.NewSimulation(config.RandSeed)
+		network = sim.Network
+		clock = sim.Clock()
+	} else {
+		network = netem.NewNetwork(config.RandSeed)
+	}
+	clientHost := network.AddHost("client")
+	serverHost := network.AddHost("server")
+	// the first path is free, e.g. a WiFi network, the others cost, e.g. cellular networks
+	costs := make(map[string]float64)
+	for i, link := range links {
+		ip := fmt.Sprintf("10.0.%d.1", i)
+		if _, err := network.Connect(clientHost, ip, serverHost, serverIP, link, link); err != nil {
+			return err
+		}
+		costs[ip] = 1
+		if i == 0 {
+			costs[ip] = 0
+		}
+	}
+
+	server, err := quic.ListenAddr(serverIP+":4433", testdata.GetTLSConfig(), &quic.Config{Network: serverHost, Clock: clock, RandSeed: config.RandSeed})
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	clientConfig := *config
+	clientConfig.Network = clientHost
+	clientConfig.Clock = clock
+	clientConfig.CreatePaths = len(links) > 1
+	clientConfig.PathCosts = costs
+
+	sink := &workload.Sink{Clock: clock}
+	done := make(chan struct{})
+	errs := make(chan error, 2)
+	go func() {
+		defer close(done)
+		sess, err := server.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		errs <- sink.Serve(sess)
+		sess.Close(nil)
+	}()
+	go func() {
+		sess, err := quic.DialAddr(serverIP+":4433", &tls.Config{InsecureSkipVerify: true}, &clientConfig)
+		if err != nil {
+			errs <- err
+			return
+		}
+		g := &workload.Generator{Spec: spec, Seed: config.RandSeed, Clock: clock}
+		errs <- g.Run(sess)
+	}()
+
+	if virtual {
+		err = sim.Run(done, simulationLimit)
+	} else {
+		// the sink finishes once it received everything, a failed generator ends the run earlier
+	wait:
+		for {
+			select {
+			case <-done:
+				break wait
+			case err = <-errs:
+				if err != nil {
+					break wait
+				}
+			}
+		}
+	}
+	if err == nil {
+		err = firstError(errs)
+	}
+	report(os.Stdout, sink.Report())
+	if arrivals != "" {
+		if err := writeArrivals(arrivals, sink.Arrivals()); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// firstError returns the first error reported by the generator and the sink that already finished
+func firstError(errs chan error) error {
+	for {
+		select {
+		case err := <-errs:
+			if err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
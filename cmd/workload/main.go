@@ -0,0 +1,151 @@
+// Command workload sends the messages of a workload spec on a session and measures which of them met their deadline.
+//
+// The server is a sink: it reports the deadline hit rate and the latencies of every flow of each session it received.
+// The client is a generator: it writes each flow of the spec on its own stream, every message with the deadline of its flow.
+// With -emulate, both run in this process over a netem network with one link per path, in virtual time unless -realtime is set.
+//
+// The sink measures the latency from the time the generator wrote a message, on the clock of the generator:
+// over a real network, the clocks of the client and the server must be synchronised.
+//
+// Usage:
+//
+//	workload -listen :4433 [-arrivals arrivals.csv]
+//	workload -connect server:4433 [-m] [-scheduler BatchLinOpt] spec.json
+//	workload -emulate [-paths 15ms:10,50ms:4] [-realtime] [-scheduler BatchLinOpt] spec.json
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/workload"
+)
+
+func main() {
+	listen := flag.String("listen", "", "run the sink on this address")
+	connect := flag.String("connect", "", "run the generator towards the sink at this address")
+	emulate := flag.Bool("emulate", false, "run the generator and the sink over an emulated network")
+	paths := flag.String("paths", "15ms:10,50ms:4", "emulated paths, comma-separated one-way delay:bandwidth in Mbit/s")
+	realtime := flag.Bool("realtime", false, "run the emulated network in real time rather than in virtual time")
+	multipath := flag.Bool("m", false, "create a path per interface of the client")
+	scheduler := flag.String("scheduler", "", "scheduler of the generator")
+	seed := flag.Int64("seed", 1, "seed of the message sizes, the scheduler and the emulated links")
+	arrivals := flag.String("arrivals", "", "CSV file to append every message received by the sink to")
+	certFile := flag.String("cert", "", "certificate of the sink, a test certificate if not set")
+	keyFile := flag.String("key", "", "key of the certificate of the sink")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -listen addr | -connect addr spec.json | -emulate spec.json\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	modes := 0
+	for _, set := range []bool{*listen != "", *connect != "", *emulate} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 || (*listen == "") != (flag.NArg() == 1) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config := &quic.Config{
+		CreatePaths:   *multipath,
+		SchedulerName: *scheduler,
+		RandSeed:      *seed,
+	}
+	var err error
+	switch {
+	case *listen != "":
+		var tlsConfig *tls.Config
+		if tlsConfig, err = loadTLSConfig(*certFile, *keyFile); err == nil {
+			err = runSink(*listen, tlsConfig, *arrivals)
+		}
+	case *connect != "":
+		var spec *workload.Spec
+		if spec, err = workload.LoadSpec(flag.Arg(0)); err == nil {
+			err = runGenerator(*connect, spec, config)
+		}
+	default:
+		var spec *workload.Spec
+		if spec, err = workload.LoadSpec(flag.Arg(0)); err == nil {
+			err = runEmulated(spec, *paths, !*realtime, config, *arrivals)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func loadTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return testdata.GetTLSConfig(), nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("-cert and -key must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// outputMutex serializes the reports of the sessions received by the sink
+var outputMutex sync.Mutex
+
+// runSink receives workloads until it is interrupted, and reports each session
+func runSink(addr string, tlsConfig *tls.Config, arrivals string) error {
+	ln, err := quic.ListenAddr(addr, tlsConfig, nil)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	fmt.Fprintf(os.Stderr, "sink listening on %s\n", ln.Addr())
+	for {
+		sess, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			sink := &workload.Sink{}
+			err := sink.Serve(sess)
+			sess.Close(nil)
+			outputMutex.Lock()
+			defer outputMutex.Unlock()
+			fmt.Printf("session from %s\n", sess.RemoteAddr())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "session from %s failed: %s\n", sess.RemoteAddr(), err)
+			}
+			report(os.Stdout, sink.Report())
+			if arrivals != "" {
+				if err := writeArrivals(arrivals, sink.Arrivals()); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+		}()
+	}
+}
+
+// runGenerator sends the workload and waits for the sink to close the session
+func runGenerator(addr string, spec *workload.Spec, config *quic.Config) error {
+	sess, err := quic.DialAddr(addr, &tls.Config{InsecureSkipVerify: true}, config)
+	if err != nil {
+		return err
+	}
+	g := &workload.Generator{Spec: spec, Seed: config.RandSeed}
+	if err := g.Run(sess); err != nil {
+		sess.Close(err)
+		return err
+	}
+	<-sess.Context().Done()
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/workload"
+)
+
+// report prints the reports of the flows
+func report(w io.Writer, reports []workload.FlowReport) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "flow\tmessages\treceived\tbytes\tdeadline hit\tmedian latency\tp95 latency\tmax latency\t")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\t%s\t%s\t%s\t\n",
+			r.Name, r.Messages, r.Received, r.Bytes, 100*r.HitRate(), ms(r.MedianLatency), ms(r.P95Latency), ms(r.MaxLatency))
+	}
+	tw.Flush()
+}
+
+// ms formats a duration in milliseconds
+func ms(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 1, 64) + "ms"
+}
+
+var arrivalsHeader = []string{"flow", "seq", "size", "sent_unix_ns", "latency_ms", "deadline_ms", "met"}
+
+// writeArrivals appends the arrivals to a CSV file, with a header if the file is new
+func writeArrivals(filename string, arrivals []workload.Arrival) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		if err := w.Write(arrivalsHeader); err != nil {
+			return err
+		}
+	}
+	for i := range arrivals {
+		a := &arrivals[i]
+		err := w.Write([]string{
+			a.Flow,
+			strconv.FormatUint(uint64(a.Seq), 10),
+			strconv.Itoa(a.Size),
+			strconv.FormatInt(a.Sent.UnixNano(), 10),
+			strconv.FormatFloat(float64(a.Latency())/float64(time.Millisecond), 'f', 3, 64),
+			strconv.FormatFloat(float64(a.Deadline)/float64(time.Millisecond), 'f', 3, 64),
+			strconv.FormatBool(a.Met()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -21,13 +21,15 @@ type mockPacketConn struct {
 }
 
 func (c *mockPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	// block until there's data or an error, the pconnManager may already be reading when a test sets them
+	for start := time.Now(); c.dataToRead == nil && c.readErr == nil; time.Sleep(time.Millisecond) {
+		if time.Since(start) > time.Hour {
+			return 0, nil, io.EOF
+		}
+	}
 	if c.readErr != nil {
 		return 0, nil, c.readErr
 	}
-	if c.dataToRead == nil { // block if there's no data
-		time.Sleep(time.Hour)
-		return 0, nil, io.EOF
-	}
 	n := copy(b, c.dataToRead)
 	c.dataToRead = nil
 	return n, c.dataReadFrom, nil
@@ -0,0 +1,153 @@
+package quic
+
+import (
+	"net"
+	"sort"
+	"time"
+)
+
+// PathStats is a snapshot of the statistics of a path
+type PathStats struct {
+	PathID     PathID
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// Open is false once the path is closed
+	Open              bool
+	PotentiallyFailed bool
+
+	SmoothedRTT      time.Duration
+	LatestRTT        time.Duration
+	MinRTT           time.Duration
+	CongestionWindow uint64
+	BytesInFlight    uint64
+
+	PacketsSent     uint64
+	Retransmissions uint64
+	PacketsLost     uint64
+	PacketsReceived uint64
+	// BytesAcked is the number of bytes sent on the path and acknowledged by the peer
+	BytesAcked uint64
+	// BytesReceived is the number of bytes of the packets received on the path, duplicates included
+	BytesReceived uint64
+	// Received packets with a deadline, and those of them that arrived before it
+	ReceivedWithDeadline uint64
+	ReceivedMeetDeadline uint64
+	// Sent packets with a deadline, and those of them that the peer received before it, as reported in its ACKs
+	SentWithDeadline uint64
+	SentMeetDeadline uint64
+
+	// Alpha is the deadline discount factor currently chosen by the bandit of the path, Arm the arm of the bandit it corresponds to
+	Alpha float32
+	Arm   int
+	// Cost is the cost of sending a packet on the path, CostSpent the sum of the costs of the packets sent on it
+	Cost      float64
+	CostSpent float64
+
+	// DeadlineMisses are the causes of the deadline misses of the packets sent on the path,
+	// the packets that weren't sent are only counted in the ConnectionStats
+	DeadlineMisses DeadlineMisses
+}
+
+// ConnectionStats is a snapshot of the statistics of a session
+type ConnectionStats struct {
+	ConnectionID ConnectionID
+	// Version is the QUIC version negotiated by the endpoints
+	Version VersionNumber
+	// RandSeed is the seed of the session, setting it as the RandSeed of the config reproduces the random decisions
+	RandSeed int64
+	// Paths contains one entry per path, sorted by path ID
+	Paths []PathStats
+
+	// The totals of the counters of all paths
+	PacketsSent          uint64
+	Retransmissions      uint64
+	PacketsLost          uint64
+	PacketsReceived      uint64
+	ReceivedWithDeadline uint64
+	ReceivedMeetDeadline uint64
+	SentWithDeadline     uint64
+	SentMeetDeadline     uint64
+	CostSpent            float64
+	// PacketsWithCost is the number of packets sent on paths with a cost
+	PacketsWithCost uint64
+
+	// NotSentPackets is the number of packets for which the batch schedulers found no path meeting their deadline
+	NotSentPackets uint64
+	// DeferredPackets is the number of packets that the batch schedulers held back for a later batch,
+	// waiting for a path that meets their deadline or has no cost
+	DeferredPackets uint64
+	// DeadlineMisses are the causes of the deadline misses of all the packets with a deadline, sent or not
+	DeadlineMisses DeadlineMisses
+}
+
+// ConnectionStats returns a snapshot of the statistics of the session.
+// The statistics are written by the run loop, so the snapshot is taken there, or here once the run loop returned.
+func (s *session) ConnectionStats() ConnectionStats {
+	req := make(chan ConnectionStats, 1)
+	select {
+	case s.statsRequests <- req:
+		return <-req
+	case <-s.ctx.Done():
+		return s.connectionStats()
+	}
+}
+
+// connectionStats returns a snapshot of the statistics of the session, it must be called from the run loop
+func (s *session) connectionStats() ConnectionStats {
+	s.pathsLock.RLock()
+	defer s.pathsLock.RUnlock()
+
+	stats := ConnectionStats{
+		ConnectionID:    s.connectionID,
+		Version:         s.version,
+		RandSeed:        s.randSeed,
+		Paths:           make([]PathStats, 0, len(s.paths)),
+		CostSpent:       s.scheduler.GetTotalCost(),
+		PacketsWithCost: s.scheduler.GetTotalPktWithCost(),
+		NotSentPackets:  s.scheduler.GetNotSentPackets(),
+		DeferredPackets: s.scheduler.deferredPackets,
+		DeadlineMisses:  s.scheduler.unsentDeadlineMisses,
+	}
+	for _, pth := range s.paths {
+		ps := pth.stats()
+		stats.Paths = append(stats.Paths, ps)
+		stats.PacketsSent += ps.PacketsSent
+		stats.Retransmissions += ps.Retransmissions
+		stats.PacketsLost += ps.PacketsLost
+		stats.PacketsReceived += ps.PacketsReceived
+		stats.ReceivedWithDeadline += ps.ReceivedWithDeadline
+		stats.ReceivedMeetDeadline += ps.ReceivedMeetDeadline
+		stats.SentWithDeadline += ps.SentWithDeadline
+		stats.SentMeetDeadline += ps.SentMeetDeadline
+		stats.DeadlineMisses.add(ps.DeadlineMisses)
+	}
+	sort.Slice(stats.Paths, func(i, j int) bool { return stats.Paths[i].PathID < stats.Paths[j].PathID })
+	return stats
+}
+
+// stats returns a snapshot of the statistics of the path
+func (p *path) stats() PathStats {
+	ps := PathStats{
+		PathID:            p.pathID,
+		LocalAddr:         p.conn.LocalAddr(),
+		RemoteAddr:        p.conn.RemoteAddr(),
+		Open:              p.open.Get(),
+		PotentiallyFailed: p.potentiallyFailed.Get(),
+		SmoothedRTT:       p.rttStats.SmoothedRTT(),
+		LatestRTT:         p.rttStats.LatestRTT(),
+		MinRTT:            p.rttStats.MinRTT(),
+		CongestionWindow:  uint64(p.sentPacketHandler.GetCongestionWindow()),
+		BytesInFlight:     uint64(p.sentPacketHandler.GetBytesInFlight()),
+		BytesAcked:        uint64(p.sentPacketHandler.GetAckedBytes()),
+		BytesReceived:     uint64(p.bytesReceived),
+		Alpha:             p.sentPacketHandler.GetPathAlpha(),
+		Arm:               p.sentPacketHandler.GetPathArm(),
+		Cost:              p.getCost(),
+		CostSpent:         p.costSpent,
+		DeadlineMisses:    p.deadlineMisses,
+	}
+	ps.PacketsSent, ps.Retransmissions, ps.PacketsLost = p.sentPacketHandler.GetStatistics()
+	ps.PacketsReceived, ps.ReceivedWithDeadline, ps.ReceivedMeetDeadline = p.receivedPacketHandler.GetStatistics()
+	ps.SentWithDeadline, ps.SentMeetDeadline = p.sentPacketHandler.GetDeadlineStatistics()
+	return ps
+}
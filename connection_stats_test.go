@@ -0,0 +1,87 @@
+package quic
+
+import (
+	"context"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection statistics", func() {
+	var sess *session
+
+	BeforeEach(func() {
+		sess = newTestSession(&Config{}, &scheduler{})
+		sess.paths[3] = newTestPath(sess, 3, 0)
+		sess.paths[1] = newTestPath(sess, 1, 0)
+	})
+
+	It("reports the statistics of each path, sorted by path ID", func() {
+		pth := sess.paths[3]
+		for pn := protocol.PacketNumber(1); pn <= 2; pn++ {
+			err := pth.sentPacketHandler.SentPacket(&ackhandler.Packet{PacketNumber: pn, Frames: []wire.Frame{&wire.PingFrame{}}, Length: 100, SendTime: time.Now()})
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(sess.paths[1].receivedPacketHandler.ReceivedPacket(1, true)).To(Succeed())
+		pth.hasCost = true
+		pth.cost = 2
+		pth.costSpent = 4
+
+		stats := sess.connectionStats()
+		Expect(stats.ConnectionID).To(Equal(ConnectionID(0x1337)))
+		Expect(stats.Version).To(Equal(sess.version))
+		Expect(stats.Paths).To(HaveLen(2))
+		Expect(stats.Paths[0].PathID).To(Equal(PathID(1)))
+		Expect(stats.Paths[0].PacketsReceived).To(BeEquivalentTo(1))
+		Expect(stats.Paths[0].LocalAddr.String()).To(Equal("10.0.0.1:1001"))
+		Expect(stats.Paths[1].PathID).To(Equal(PathID(3)))
+		Expect(stats.Paths[1].Open).To(BeTrue())
+		Expect(stats.Paths[1].RemoteAddr.String()).To(Equal("10.0.0.2:443"))
+		Expect(stats.Paths[1].PacketsSent).To(BeEquivalentTo(2))
+		Expect(stats.Paths[1].BytesInFlight).To(BeEquivalentTo(200))
+		Expect(stats.Paths[1].Cost).To(Equal(2.0))
+		Expect(stats.Paths[1].CostSpent).To(Equal(4.0))
+	})
+
+	It("sums the counters of all paths", func() {
+		Expect(sess.paths[1].sentPacketHandler.SentPacket(&ackhandler.Packet{PacketNumber: 1, Length: 100})).To(Succeed())
+		Expect(sess.paths[3].sentPacketHandler.SentPacket(&ackhandler.Packet{PacketNumber: 1, Length: 100})).To(Succeed())
+		Expect(sess.paths[3].receivedPacketHandler.ReceivedPacket(1, true)).To(Succeed())
+		sess.scheduler.totalCost = 6
+		sess.scheduler.totalPktWithCost = 3
+		sess.scheduler.NotSentPackets = 2
+		sess.scheduler.deferredPackets = 5
+
+		stats := sess.connectionStats()
+		Expect(stats.PacketsSent).To(BeEquivalentTo(2))
+		Expect(stats.PacketsReceived).To(BeEquivalentTo(1))
+		Expect(stats.CostSpent).To(Equal(6.0))
+		Expect(stats.PacketsWithCost).To(BeEquivalentTo(3))
+		Expect(stats.NotSentPackets).To(BeEquivalentTo(2))
+		Expect(stats.DeferredPackets).To(BeEquivalentTo(5))
+	})
+	It("takes the snapshot in the run loop", func() {
+		sess.statsRequests = make(chan chan ConnectionStats)
+		sess.ctx, sess.ctxCancel = context.WithCancel(context.Background())
+		defer sess.ctxCancel()
+		go func() {
+			defer GinkgoRecover()
+			req := <-sess.statsRequests
+			req <- ConnectionStats{ConnectionID: 42}
+		}()
+		Expect(sess.ConnectionStats().ConnectionID).To(Equal(ConnectionID(42)))
+	})
+
+	It("takes the snapshot itself once the run loop returned", func() {
+		sess.statsRequests = make(chan chan ConnectionStats)
+		sess.ctx, sess.ctxCancel = context.WithCancel(context.Background())
+		sess.ctxCancel()
+		stats := sess.ConnectionStats()
+		Expect(stats.ConnectionID).To(Equal(ConnectionID(0x1337)))
+		Expect(stats.Paths).To(HaveLen(2))
+	})
+})
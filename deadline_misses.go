@@ -0,0 +1,122 @@
+package quic
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/qlog"
+)
+
+// DeadlineMisses attributes the packets with a deadline that missed it to the cause of the miss.
+// The peer only reports how many packets met their deadline, so the arrival time of an acknowledged packet
+// is estimated as the middle of the interval between its sending and the reception of its ACK.
+type DeadlineMisses struct {
+	// NoFeasiblePath counts the packets not sent because their deadline was shorter than the one-way delay of every path
+	NoFeasiblePath uint64
+	// Deferred counts the packets that selective preservation held back for a later batch, waiting for a path meeting their deadline
+	Deferred uint64
+	// HeldForCost counts the packets that CaDaMPS held back for a later batch, waiting for a path without cost
+	HeldForCost uint64
+	// Retransmitted counts the packets that were lost and queued for retransmission after their deadline was due
+	Retransmitted uint64
+	// NotSent counts the other packets the batch schedulers didn't send, counted in NotSentPackets
+	NotSent uint64
+	// LateOnPath counts the packets sent without being held back that arrived after their deadline on their path
+	LateOnPath uint64
+}
+
+// Total is the number of packets that missed their deadline
+func (m DeadlineMisses) Total() uint64 {
+	return m.NoFeasiblePath + m.Deferred + m.HeldForCost + m.Retransmitted + m.NotSent + m.LateOnPath
+}
+
+func (m *DeadlineMisses) add(o DeadlineMisses) {
+	m.NoFeasiblePath += o.NoFeasiblePath
+	m.Deferred += o.Deferred
+	m.HeldForCost += o.HeldForCost
+	m.Retransmitted += o.Retransmitted
+	m.NotSent += o.NotSent
+	m.LateOnPath += o.LateOnPath
+}
+
+// countHeldBack counts a packet held back by the scheduler that missed its deadline, returns false if it wasn't held back
+func (m *DeadlineMisses) countHeldBack(deferred, heldForCost bool) bool {
+	switch {
+	case deferred:
+		m.Deferred++
+	case heldForCost:
+		m.HeldForCost++
+	default:
+		return false
+	}
+	return true
+}
+
+// heldPacket is a packet of a batch that the scheduler held back for a later one
+type heldPacket struct {
+	deadline    time.Time
+	deferred    bool
+	heldForCost bool
+}
+
+// heldPacket returns how the packet at the given index of the current batch was held back in earlier batches
+func (sch *scheduler) heldPacket(index int) heldPacket {
+	if index < len(sch.batchHeld) {
+		return sch.batchHeld[index]
+	}
+	return heldPacket{}
+}
+
+// countUnsentDeadlineMiss attributes the deadline miss of a packet of the batch that no path was selected for.
+// deadline is the time left before its deadline, in milliseconds.
+func (sch *scheduler) countUnsentDeadlineMiss(s *session, index int, deadline int) {
+	held := sch.heldPacket(index)
+	if sch.unsentDeadlineMisses.countHeldBack(held.deferred, held.heldForCost) {
+		return
+	}
+	minRtt := sch.GetMinimunRTT(s)
+	if deadline <= 0 || (!isFloat64Zero(minRtt) && float64(deadline) < minRtt/2) {
+		sch.unsentDeadlineMisses.NoFeasiblePath++
+	} else {
+		sch.unsentDeadlineMisses.NotSent++
+	}
+}
+
+// countDeadlineMiss attributes the deadline miss of a packet sent on the path, once it is acknowledged or queued for retransmission
+func (p *path) countDeadlineMiss(packet *ackhandler.Packet, acked bool, now time.Time) {
+	if packet.Deadline.IsZero() {
+		return
+	}
+	if !acked {
+		// a packet queued for retransmission before its deadline isn't counted, the retransmission may still meet it
+		if now.After(packet.Deadline) {
+			p.deadlineMisses.Retransmitted++
+		}
+		return
+	}
+	arrival := packet.SendTime.Add(now.Sub(packet.SendTime) / 2)
+	if !arrival.After(packet.Deadline) {
+		return
+	}
+	if !p.deadlineMisses.countHeldBack(packet.Deferred, packet.HeldForCost) {
+		p.deadlineMisses.LateOnPath++
+	}
+}
+
+// reportDeadlineMisses logs and traces the causes of the deadline misses of the session when it closes
+func (s *session) reportDeadlineMisses() {
+	misses := s.connectionStats().DeadlineMisses
+	if misses.Total() == 0 {
+		return
+	}
+	s.logger.Infof("Deadline misses of %x: %d no feasible path, %d deferred, %d held for cost, %d retransmitted, %d not sent, %d late on path",
+		s.connectionID, misses.NoFeasiblePath, misses.Deferred, misses.HeldForCost, misses.Retransmitted, misses.NotSent, misses.LateOnPath)
+	s.tracer.Trace(&qlog.DeadlineMissSummary{
+		NoFeasiblePath: misses.NoFeasiblePath,
+		Deferred:       misses.Deferred,
+		HeldForCost:    misses.HeldForCost,
+		Retransmitted:  misses.Retransmitted,
+		NotSent:        misses.NotSent,
+		LateOnPath:     misses.LateOnPath,
+	})
+}
@@ -0,0 +1,97 @@
+package quic
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deadline miss attribution", func() {
+	var (
+		sess *session
+		sch  *scheduler
+	)
+
+	BeforeEach(func() {
+		sch = &scheduler{SchedulerName: "BatchLinOpt", rand: rand.New(rand.NewSource(0))}
+		sess = newTestSession(&Config{}, sch)
+		sess.paths[0] = newTestPath(sess, 0, 30*time.Millisecond)
+		sess.paths[3] = newTestPath(sess, 3, 20*time.Millisecond)
+		sess.paths[1] = newTestPath(sess, 1, 40*time.Millisecond)
+	})
+
+	It("carries the reason a packet was held back to the next batch", func() {
+		now := time.Now()
+		deadlines := []int{20, 40, 50}
+		pthBatch := []*path{sess.paths[3], nil, sess.paths[1]}
+		sch.selectivePreservation(sess, deadlines, pthBatch, now)
+		sch.choosePacketsForLowCost(sess, deadlines, pthBatch, now)
+		Expect(sch.waitPackets).To(HaveLen(2))
+
+		next := sch.GenerateBatchDeadline(5, now)
+		Expect(next).To(HaveLen(5))
+		Expect(next[3:]).To(Equal([]int{40, 50}))
+		Expect(sch.heldPacket(0)).To(Equal(heldPacket{}))
+		Expect(sch.heldPacket(3).deferred).To(BeTrue())
+		Expect(sch.heldPacket(4).heldForCost).To(BeTrue())
+		Expect(sch.heldPacket(5)).To(Equal(heldPacket{}))
+		Expect(sch.waitPackets).To(BeEmpty())
+	})
+
+	It("keeps both reasons of a packet held back twice", func() {
+		now := time.Now()
+		sch.choosePacketsForLowCost(sess, []int{50}, []*path{sess.paths[1]}, now)
+		sch.GenerateBatchDeadline(1, now)
+		sch.selectivePreservation(sess, []int{50}, []*path{nil}, now)
+		sch.GenerateBatchDeadline(1, now)
+		Expect(sch.heldPacket(0).deferred).To(BeTrue())
+		Expect(sch.heldPacket(0).heldForCost).To(BeTrue())
+	})
+
+	It("attributes the packets that weren't sent", func() {
+		// the fastest path has an RTT of 20ms, so a one-way delay of about 10ms
+		sch.countUnsentDeadlineMiss(sess, 0, 5)
+		sch.countUnsentDeadlineMiss(sess, 0, -3)
+		sch.countUnsentDeadlineMiss(sess, 0, 25)
+		sch.batchHeld = []heldPacket{{}, {deferred: true}, {heldForCost: true}}
+		sch.countUnsentDeadlineMiss(sess, 1, 5)
+		sch.countUnsentDeadlineMiss(sess, 2, 25)
+		Expect(sch.unsentDeadlineMisses).To(Equal(DeadlineMisses{NoFeasiblePath: 2, NotSent: 1, Deferred: 1, HeldForCost: 1}))
+	})
+
+	It("attributes the packets sent on a path", func() {
+		pth := sess.paths[3]
+		now := time.Now()
+		sent := now.Add(-20 * time.Millisecond)
+		// arrived 10ms after being sent
+		pth.countDeadlineMiss(&ackhandler.Packet{SendTime: sent, Deadline: sent.Add(15 * time.Millisecond)}, true, now)
+		pth.countDeadlineMiss(&ackhandler.Packet{SendTime: sent}, false, now)
+		Expect(pth.deadlineMisses.Total()).To(BeZero())
+		pth.countDeadlineMiss(&ackhandler.Packet{SendTime: sent, Deadline: sent.Add(5 * time.Millisecond)}, true, now)
+		pth.countDeadlineMiss(&ackhandler.Packet{SendTime: sent, Deadline: sent.Add(5 * time.Millisecond), Deferred: true}, true, now)
+		pth.countDeadlineMiss(&ackhandler.Packet{SendTime: sent, Deadline: sent.Add(5 * time.Millisecond), HeldForCost: true}, true, now)
+		pth.countDeadlineMiss(&ackhandler.Packet{SendTime: sent, Deadline: sent.Add(5 * time.Millisecond), Deferred: true}, false, now)
+		Expect(pth.deadlineMisses).To(Equal(DeadlineMisses{LateOnPath: 1, Deferred: 1, HeldForCost: 1, Retransmitted: 1}))
+	})
+
+	It("doesn't count the packets retransmitted before their deadline", func() {
+		pth := sess.paths[1]
+		now := time.Now()
+		pth.countDeadlineMiss(&ackhandler.Packet{SendTime: now.Add(-20 * time.Millisecond), Deadline: now.Add(time.Millisecond)}, false, now)
+		pth.countDeadlineMiss(&ackhandler.Packet{SendTime: now.Add(-20 * time.Millisecond), Deadline: now}, false, now)
+		Expect(pth.deadlineMisses.Total()).To(BeZero())
+	})
+
+	It("sums the causes of all paths in the connection stats", func() {
+		sch.unsentDeadlineMisses = DeadlineMisses{NoFeasiblePath: 1, NotSent: 2}
+		sess.paths[1].deadlineMisses = DeadlineMisses{LateOnPath: 3, Retransmitted: 1}
+		sess.paths[3].deadlineMisses = DeadlineMisses{LateOnPath: 1, Deferred: 4}
+		stats := sess.connectionStats()
+		Expect(stats.Paths[1].DeadlineMisses).To(Equal(DeadlineMisses{LateOnPath: 3, Retransmitted: 1}))
+		Expect(stats.DeadlineMisses).To(Equal(DeadlineMisses{NoFeasiblePath: 1, NotSent: 2, LateOnPath: 4, Retransmitted: 1, Deferred: 4}))
+		Expect(stats.DeadlineMisses.Total()).To(BeEquivalentTo(12))
+	})
+})
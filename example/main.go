@@ -10,6 +10,7 @@ import (
 	"log"
 	"mime/multipart"
 	"net/http"
+	"os"
 	"path"
 	"runtime"
 	"strings"
@@ -20,6 +21,9 @@ import (
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/h2quic"
 	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/pcapng"
+	"github.com/lucas-clemente/quic-go/qlog"
+	"github.com/lucas-clemente/quic-go/schedlog"
 )
 
 type binds []string
@@ -111,9 +115,6 @@ func getBuildDir() string {
 
 func main() {
 	// defer profile.Start().Stop()
-	go func() {
-		log.Println(http.ListenAndServe("0.0.0.0:6060", nil))
-	}()
 	// runtime.SetBlockProfileRate(1)
 
 	verbose := flag.Bool("v", false, "verbose")
@@ -130,7 +131,12 @@ func main() {
 	output := flag.String("outputpath", "", "Output path for DL agent")
 	specFile := flag.String("spec", "", "Spec file for DL agent")
 	valid_congestion := flag.Int("validCongestion", 0, "% of allowed congestion")
-	dumpExperiences := flag.Bool("validating", false, "If yes, server dumps experiences in /tmp")
+	dumpExperiences := flag.Bool("validating", false, "If yes, server dumps experiences in the decision log")
+	decisionLog := flag.String("decisionlog", "", "(optional) file to record the scheduler decisions in, as JSON lines")
+	pcapFile := flag.String("pcap", "", "(optional) pcapng file to capture the datagrams of the sessions in")
+	keyLog := flag.String("keylog", "", "(optional) file to write the keys of the sessions in, to decrypt the capture")
+	qlogFile := flag.String("qlog", "", "(optional) file to write a qlog trace of the sessions in, see cmd/qlogchart")
+	seed := flag.Int64("seed", 0, "(optional) seed of the random decisions of the scheduler, the sessions log the seed they use")
 
 	flag.Parse()
 
@@ -142,7 +148,8 @@ func main() {
 	// }
 	// Init agents
 	if *training && *scheduler == "dqnAgent" {
-		quic.GetTrainingAgent(*wFile, *specFile, *output, *epsilon)
+		// the scheduler explores with the epsilon of the config, from the seed of the session
+		quic.GetTrainingAgent(*wFile, *specFile, *output, 0)
 	} else if *scheduler == "dqnAgent" {
 		quic.GetAgent(*wFile, *specFile)
 	}
@@ -163,20 +170,81 @@ func main() {
 		bs = binds{"0.0.0.0:6121"}
 	}
 
+	quicConfig := &quic.Config{
+		SchedulerName:     *scheduler,
+		WeightsFile:       *wFile,
+		Training:          *training,
+		Epsilon:           *epsilon,
+		AllowedCongestion: *valid_congestion,
+		DumpExperiences:   *dumpExperiences,
+		RandSeed:          *seed,
+	}
+	if *decisionLog != "" {
+		f, err := os.Create(*decisionLog)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		quicConfig.DecisionRecorder = schedlog.NewWriter(f)
+	}
+	if *pcapFile != "" {
+		f, err := os.Create(*pcapFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		quicConfig.PacketCapture, err = pcapng.NewWriter(f)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if *keyLog != "" {
+		f, err := os.Create(*keyLog)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		quicConfig.KeyLogWriter = f
+	}
+	if *qlogFile != "" {
+		f, err := os.Create(*qlogFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		quicConfig.Tracer = qlog.NewWriter(f, "example server")
+	}
+
+	servers := make([]*h2quic.Server, len(bs))
+	for i, b := range bs {
+		servers[i] = &h2quic.Server{
+			Server:     &http.Server{Addr: b},
+			QuicConfig: quicConfig,
+		}
+	}
+
+	go func() {
+		// pprof registers its handlers on the default mux
+		debugMux := http.NewServeMux()
+		debugMux.Handle("/debug/pprof/", http.DefaultServeMux)
+		debugMux.Handle("/metrics", h2quic.MetricsHandler(servers...))
+		log.Println(http.ListenAndServe("0.0.0.0:6060", debugMux))
+	}()
+
 	//don't use Init()
 
 	var wg sync.WaitGroup
 	wg.Add(len(bs))
-	for _, b := range bs {
+	for i, b := range bs {
 		bCap := b
+		server := servers[i]
 		go func() {
 			var err error
 			if *tcp {
 				err = h2quic.ListenAndServe(bCap, certFile, keyFile, nil)
 				fmt.Println("This a TCP server!")
 			} else {
-				err = h2quic.ListenAndServeQUIC(bCap, certFile, keyFile, nil, *scheduler, *wFile, *training, *epsilon,
-					*valid_congestion, *dumpExperiences)
+				err = server.ListenAndServeTLS(certFile, keyFile)
 				fmt.Println("This a QUIC server!")
 			}
 			if err != nil {
@@ -0,0 +1,124 @@
+package h2quic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	quic "github.com/lucas-clemente/quic-go"
+)
+
+const (
+	gauge   = "gauge"
+	counter = "counter"
+)
+
+type pathMetric struct {
+	name, help, typ string
+	value           func(p *quic.PathStats) float64
+}
+
+type sessionMetric struct {
+	name, help, typ string
+	value           func(s *quic.ConnectionStats) float64
+}
+
+var pathMetrics = []pathMetric{
+	{"mpquic_path_smoothed_rtt_seconds", "Smoothed RTT of the path.", gauge, func(p *quic.PathStats) float64 { return p.SmoothedRTT.Seconds() }},
+	{"mpquic_path_latest_rtt_seconds", "Latest RTT sample of the path.", gauge, func(p *quic.PathStats) float64 { return p.LatestRTT.Seconds() }},
+	{"mpquic_path_min_rtt_seconds", "Minimum RTT of the path.", gauge, func(p *quic.PathStats) float64 { return p.MinRTT.Seconds() }},
+	{"mpquic_path_congestion_window_bytes", "Congestion window of the path.", gauge, func(p *quic.PathStats) float64 { return float64(p.CongestionWindow) }},
+	{"mpquic_path_bytes_in_flight", "Bytes in flight on the path.", gauge, func(p *quic.PathStats) float64 { return float64(p.BytesInFlight) }},
+	{"mpquic_path_acked_bytes_total", "Bytes acknowledged on the path, its rate is the goodput of the path.", counter, func(p *quic.PathStats) float64 { return float64(p.BytesAcked) }},
+	{"mpquic_path_packets_sent_total", "Packets sent on the path.", counter, func(p *quic.PathStats) float64 { return float64(p.PacketsSent) }},
+	{"mpquic_path_retransmissions_total", "Packets retransmitted from the path.", counter, func(p *quic.PathStats) float64 { return float64(p.Retransmissions) }},
+	{"mpquic_path_packets_lost_total", "Packets lost on the path.", counter, func(p *quic.PathStats) float64 { return float64(p.PacketsLost) }},
+	{"mpquic_path_loss_ratio", "Fraction of the packets sent on the path that were lost.", gauge, func(p *quic.PathStats) float64 { return ratio(p.PacketsLost, p.PacketsSent) }},
+	{"mpquic_path_packets_received_total", "Packets received on the path.", counter, func(p *quic.PathStats) float64 { return float64(p.PacketsReceived) }},
+	{"mpquic_path_sent_with_deadline_total", "Packets with a deadline sent on the path, as reported by the peer.", counter, func(p *quic.PathStats) float64 { return float64(p.SentWithDeadline) }},
+	{"mpquic_path_sent_meet_deadline_total", "Packets sent on the path that met their deadline, as reported by the peer.", counter, func(p *quic.PathStats) float64 { return float64(p.SentMeetDeadline) }},
+	{"mpquic_path_deadline_meet_ratio", "Fraction of the packets with a deadline sent on the path that met it.", gauge, func(p *quic.PathStats) float64 { return ratio(p.SentMeetDeadline, p.SentWithDeadline) }},
+	{"mpquic_path_alpha", "Deadline discount factor chosen by the bandit of the path.", gauge, func(p *quic.PathStats) float64 { return float64(p.Alpha) }},
+	{"mpquic_path_bandit_arm", "Arm of the bandit of the path in use.", gauge, func(p *quic.PathStats) float64 { return float64(p.Arm) }},
+	{"mpquic_path_cost_spent_total", "Sum of the costs of the packets sent on the path.", counter, func(p *quic.PathStats) float64 { return p.CostSpent }},
+}
+
+var sessionMetrics = []sessionMetric{
+	{"mpquic_session_deadline_meet_ratio", "Fraction of the packets with a deadline sent in the session that met it.", gauge, func(s *quic.ConnectionStats) float64 { return ratio(s.SentMeetDeadline, s.SentWithDeadline) }},
+	{"mpquic_session_cost_spent_total", "Sum of the costs of the packets sent in the session.", counter, func(s *quic.ConnectionStats) float64 { return s.CostSpent }},
+	{"mpquic_session_not_sent_packets_total", "Packets of batches for which the scheduler found no path.", counter, func(s *quic.ConnectionStats) float64 { return float64(s.NotSentPackets) }},
+	{"mpquic_session_deferred_packets_total", "Packets of batches held back for a later batch.", counter, func(s *quic.ConnectionStats) float64 { return float64(s.DeferredPackets) }},
+}
+
+// MetricsHandler serves the statistics of the live sessions of the servers in the Prometheus text format
+func MetricsHandler(servers ...*Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var stats []quic.ConnectionStats
+		for _, s := range servers {
+			for _, sess := range s.Sessions() {
+				stats = append(stats, sess.ConnectionStats())
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, stats)
+	})
+}
+
+// writeMetrics writes the metrics of the sessions, sorted by connection ID
+func writeMetrics(w io.Writer, stats []quic.ConnectionStats) {
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ConnectionID < stats[j].ConnectionID })
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	var met, withDeadline uint64
+	var cost float64
+	for i := range stats {
+		met += stats[i].SentMeetDeadline
+		withDeadline += stats[i].SentWithDeadline
+		cost += stats[i].CostSpent
+	}
+	writeHeader(bw, "mpquic_sessions", "Live sessions.", gauge)
+	writeSample(bw, "mpquic_sessions", "", float64(len(stats)))
+	writeHeader(bw, "mpquic_deadline_meet_ratio", "Fraction of the packets with a deadline sent in the live sessions that met it.", gauge)
+	writeSample(bw, "mpquic_deadline_meet_ratio", "", ratio(met, withDeadline))
+	writeHeader(bw, "mpquic_cost_spent", "Sum of the costs of the packets sent in the live sessions.", gauge)
+	writeSample(bw, "mpquic_cost_spent", "", cost)
+
+	for _, m := range sessionMetrics {
+		writeHeader(bw, m.name, m.help, m.typ)
+		for i := range stats {
+			writeSample(bw, m.name, fmt.Sprintf(`connection_id="%x"`, stats[i].ConnectionID), m.value(&stats[i]))
+		}
+	}
+	for _, m := range pathMetrics {
+		writeHeader(bw, m.name, m.help, m.typ)
+		for i := range stats {
+			for j := range stats[i].Paths {
+				p := &stats[i].Paths[j]
+				writeSample(bw, m.name, fmt.Sprintf(`connection_id="%x",path_id="%d"`, stats[i].ConnectionID, p.PathID), m.value(p))
+			}
+		}
+	}
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// ratio is 0 if there is nothing to divide, so that no NaN reaches the exported metrics
+func ratio(a, b uint64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
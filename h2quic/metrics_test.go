@@ -0,0 +1,108 @@
+package h2quic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var stats []quic.ConnectionStats
+
+	BeforeEach(func() {
+		stats = []quic.ConnectionStats{
+			{
+				ConnectionID:     0xdecafbad,
+				SentWithDeadline: 10,
+				SentMeetDeadline: 5,
+				CostSpent:        3,
+				Paths: []quic.PathStats{{
+					PathID:           3,
+					SmoothedRTT:      25 * time.Millisecond,
+					PacketsSent:      10,
+					PacketsLost:      1,
+					BytesAcked:       13500,
+					SentWithDeadline: 10,
+					SentMeetDeadline: 5,
+					Arm:              2,
+					CostSpent:        3,
+				}},
+			},
+			{
+				ConnectionID:     0x1337,
+				SentWithDeadline: 10,
+				SentMeetDeadline: 10,
+				CostSpent:        1.5,
+			},
+		}
+	})
+
+	metrics := func(stats []quic.ConnectionStats) string {
+		buf := &strings.Builder{}
+		writeMetrics(buf, stats)
+		return buf.String()
+	}
+
+	It("writes the totals of the live sessions", func() {
+		out := metrics(stats)
+		Expect(out).To(ContainSubstring("# HELP mpquic_sessions Live sessions.\n# TYPE mpquic_sessions gauge\nmpquic_sessions 2\n"))
+		Expect(out).To(ContainSubstring("\nmpquic_deadline_meet_ratio 0.75\n"))
+		Expect(out).To(ContainSubstring("\nmpquic_cost_spent 4.5\n"))
+	})
+
+	It("writes the metrics of the sessions and of their paths, sorted by connection ID", func() {
+		out := metrics(stats)
+		Expect(out).To(ContainSubstring("# TYPE mpquic_session_cost_spent_total counter\n" +
+			"mpquic_session_cost_spent_total{connection_id=\"1337\"} 1.5\n" +
+			"mpquic_session_cost_spent_total{connection_id=\"decafbad\"} 3\n"))
+		Expect(out).To(ContainSubstring("\nmpquic_path_smoothed_rtt_seconds{connection_id=\"decafbad\",path_id=\"3\"} 0.025\n"))
+		Expect(out).To(ContainSubstring("\nmpquic_path_acked_bytes_total{connection_id=\"decafbad\",path_id=\"3\"} 13500\n"))
+		Expect(out).To(ContainSubstring("\nmpquic_path_loss_ratio{connection_id=\"decafbad\",path_id=\"3\"} 0.1\n"))
+		Expect(out).To(ContainSubstring("\nmpquic_path_deadline_meet_ratio{connection_id=\"decafbad\",path_id=\"3\"} 0.5\n"))
+		Expect(out).To(ContainSubstring("\nmpquic_path_bandit_arm{connection_id=\"decafbad\",path_id=\"3\"} 2\n"))
+	})
+
+	It("writes a header for every metric", func() {
+		for _, line := range strings.Split(strings.TrimSpace(metrics(stats)), "\n") {
+			if strings.HasPrefix(line, "#") {
+				Expect(line).To(MatchRegexp(`^# (HELP mpquic_\w+ .+\.|TYPE mpquic_\w+ (gauge|counter))$`))
+			} else {
+				Expect(line).To(MatchRegexp(`^mpquic_\w+(\{[^}]+\})? \S+$`))
+			}
+		}
+	})
+
+	It("writes zero ratios if nothing was sent", func() {
+		out := metrics(nil)
+		Expect(out).To(ContainSubstring("\nmpquic_deadline_meet_ratio 0\n"))
+		Expect(out).ToNot(ContainSubstring("NaN"))
+		Expect(ratio(0, 0)).To(BeZero())
+	})
+
+	It("serves the metrics of the sessions of the servers", func() {
+		s := &Server{}
+		for i := range stats {
+			sess := &mockSession{stats: stats[i]}
+			sess.ctx, sess.ctxCancel = context.WithCancel(context.Background())
+			s.trackSession(sess)
+			if i == 1 {
+				sess.ctxCancel()
+			}
+		}
+		Eventually(s.Sessions).Should(HaveLen(1))
+
+		rec := httptest.NewRecorder()
+		MetricsHandler(s).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("text/plain; version=0.0.4"))
+		Expect(rec.Body.String()).To(ContainSubstring("\nmpquic_sessions 1\n"))
+		Expect(rec.Body.String()).ToNot(ContainSubstring("1337"))
+	})
+})
@@ -46,6 +46,7 @@ func (s *mockStream) Context() context.Context                     { return s.ct
 func (s *mockStream) SetDeadline(time.Time) error                  { panic("not implemented") }
 func (s *mockStream) SetReadDeadline(time.Time) error              { panic("not implemented") }
 func (s *mockStream) SetWriteDeadline(time.Time) error             { panic("not implemented") }
+func (s *mockStream) SetDeliveryDeadline(time.Duration)            { panic("not implemented") }
 func (s *mockStream) GetBytesSent() (protocol.ByteCount, error)    { panic("not implemented") }
 func (s *mockStream) GetBytesRetrans() (protocol.ByteCount, error) { panic("not implemented") }
 
@@ -51,6 +51,9 @@ type Server struct {
 	listenerMutex sync.Mutex
 	listener      quic.Listener
 
+	sessionsMutex sync.Mutex
+	sessions      map[quic.Session]struct{}
+
 	supportedVersionsAsString string
 }
 
@@ -112,10 +115,39 @@ func (s *Server) serveImpl(tlsConfig *tls.Config, conn net.PacketConn) error {
 		if err != nil {
 			return err
 		}
+		s.trackSession(sess)
 		go s.handleHeaderStream(sess.(streamCreator))
 	}
 }
 
+// trackSession keeps track of a session until it is closed
+func (s *Server) trackSession(sess quic.Session) {
+	s.sessionsMutex.Lock()
+	if s.sessions == nil {
+		s.sessions = make(map[quic.Session]struct{})
+	}
+	s.sessions[sess] = struct{}{}
+	s.sessionsMutex.Unlock()
+
+	go func() {
+		<-sess.Context().Done()
+		s.sessionsMutex.Lock()
+		delete(s.sessions, sess)
+		s.sessionsMutex.Unlock()
+	}()
+}
+
+// Sessions returns the live sessions accepted by the server
+func (s *Server) Sessions() []quic.Session {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+	sessions := make([]quic.Session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
 func (s *Server) handleHeaderStream(session streamCreator) {
 	stream, err := session.AcceptStream()
 	if err != nil {
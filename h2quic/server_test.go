@@ -36,6 +36,7 @@ type mockSession struct {
 	streamOpenErr       error
 	ctx                 context.Context
 	ctxCancel           context.CancelFunc
+	stats               quic.ConnectionStats
 }
 
 func (s *mockSession) GetOrOpenStream(id protocol.StreamID) (quic.Stream, error) {
@@ -71,6 +72,9 @@ func (s *mockSession) RemoteAddr() net.Addr {
 func (s *mockSession) Context() context.Context {
 	return s.ctx
 }
+func (s *mockSession) ConnectionStats() quic.ConnectionStats {
+	return s.stats
+}
 
 var _ = Describe("H2 server", func() {
 	var (
@@ -0,0 +1,356 @@
+package self_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/integrationtests/tools/testserver"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/netem"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multipath chaos in virtual time", func() {
+	const (
+		serverIP   = "10.1.0.1"
+		serverAddr = serverIP + ":4433"
+		wifiIP     = "10.0.0.1"
+		cellularIP = "10.0.1.1"
+		// newIP is an interface the client gets during the transfer
+		newIP = "10.0.2.1"
+	)
+
+	var (
+		sim                *netem.Simulation
+		clientHost         *netem.Host
+		serverHost         *netem.Host
+		wifi, cellular     *netem.Link
+		wifiLink, cellLink netem.LinkConfig
+		down               = netem.LinkConfig{Loss: 1}
+		goroutinesBefore   map[string]bool
+	)
+
+	BeforeEach(func() {
+		goroutinesBefore = goroutineIDs()
+		sim = netem.NewSimulation(GinkgoRandomSeed())
+		clientHost = sim.AddHost("client")
+		serverHost = sim.AddHost("server")
+		wifiLink = netem.LinkConfig{Delay: 15 * time.Millisecond, Bandwidth: 500e3, QueueSize: 100}
+		cellLink = netem.LinkConfig{Delay: 40 * time.Millisecond, Bandwidth: 250e3, QueueSize: 100}
+		var err error
+		wifi, err = sim.Connect(clientHost, wifiIP, serverHost, serverIP, wifiLink, wifiLink)
+		Expect(err).ToNot(HaveOccurred())
+		cellular, err = sim.Connect(clientHost, cellularIP, serverHost, serverIP, cellLink, cellLink)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	// transfer uploads the data from the client to the server while chaos runs, then closes everything.
+	// It returns the statistics of the client and the server at the end of the transfer.
+	transfer := func(data []byte, chaos func(clock *netem.VirtualClock)) (quic.ConnectionStats, quic.ConnectionStats) {
+		server, err := quic.ListenAddr(serverAddr, testdata.GetTLSConfig(), &quic.Config{
+			Network:  serverHost,
+			Clock:    sim.Clock(),
+			RandSeed: GinkgoRandomSeed(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		// the goroutines report to the test rather than failing it, they may outlive a failed simulation
+		received := make(chan []byte, 1)
+		errs := make(chan error, 2)
+		serverSessions := make(chan quic.Session, 1)
+		clientSessions := make(chan quic.Session, 1)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			sess, err := server.Accept()
+			if err != nil {
+				errs <- err
+				return
+			}
+			serverSessions <- sess
+			str, err := sess.AcceptStream()
+			if err != nil {
+				errs <- err
+				return
+			}
+			d, err := ioutil.ReadAll(str)
+			if err != nil {
+				errs <- err
+				return
+			}
+			received <- d
+		}()
+		go func() {
+			sess, err := quic.DialAddr(serverAddr, &tls.Config{InsecureSkipVerify: true}, &quic.Config{
+				Network:     clientHost,
+				CreatePaths: true,
+				Clock:       sim.Clock(),
+				RandSeed:    GinkgoRandomSeed(),
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			clientSessions <- sess
+			str, err := sess.OpenStreamSync()
+			if err == nil {
+				_, err = str.Write(data)
+			}
+			if err == nil {
+				err = str.Close()
+			}
+			if err != nil {
+				errs <- err
+			}
+		}()
+		chaos(sim.Clock())
+
+		runErr := sim.Run(done, 5*time.Minute)
+		var clientSess, servSess quic.Session
+		select {
+		case servSess = <-serverSessions:
+		default:
+		}
+		select {
+		case clientSess = <-clientSessions:
+		default:
+		}
+		// the sessions are closed without the network, the virtual time doesn't advance anymore
+		if clientSess != nil {
+			defer clientSess.Close(nil)
+		}
+		defer server.Close()
+		var transferErr error
+		select {
+		case transferErr = <-errs:
+		default:
+		}
+		if runErr != nil || transferErr != nil {
+			fmt.Fprintf(GinkgoWriter, "failed at %s\n", sim.Clock().Now())
+			logPaths("client", clientSess)
+			logPaths("server", servSess)
+		}
+		Expect(runErr).ToNot(HaveOccurred())
+		Expect(transferErr).ToNot(HaveOccurred())
+		var d []byte
+		Expect(received).To(Receive(&d))
+		Expect(bytes.Equal(d, data)).To(BeTrue())
+		clientStats := clientSess.ConnectionStats()
+		serverStats := servSess.ConnectionStats()
+		expectConsistentStats(clientStats)
+		expectConsistentStats(serverStats)
+		expectMatchingStats(clientStats, serverStats, len(data))
+		return clientStats, serverStats
+	}
+
+	AfterEach(func() {
+		Eventually(func() []string { return leakedGoroutines(goroutinesBefore) }, 5*time.Second).Should(BeEmpty())
+	})
+
+	// localPaths returns the paths of the client whose local address has the IP
+	localPaths := func(stats quic.ConnectionStats, ip string) []quic.PathStats {
+		var paths []quic.PathStats
+		for _, p := range stats.Paths {
+			if addr, ok := p.LocalAddr.(*net.UDPAddr); ok && addr.IP.Equal(net.ParseIP(ip)) {
+				paths = append(paths, p)
+			}
+		}
+		return paths
+	}
+
+	It("completes a transfer while the paths go down and up", func() {
+		rnd := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		transfer(testserver.GeneratePRData(3e6), func(clock *netem.VirtualClock) {
+			// every second, one of the links is down for up to 2 s
+			for t := time.Second; t < 10*time.Second; t += time.Second {
+				link, up := wifi, wifiLink
+				if rnd.Intn(2) == 1 {
+					link, up = cellular, cellLink
+				}
+				outage := time.Duration(rnd.Int63n(int64(2 * time.Second)))
+				clock.AfterFunc(t, func() { link.SetConfig(down, down) })
+				clock.AfterFunc(t+outage, func() { link.SetConfig(up, up) })
+			}
+		})
+	})
+
+	It("completes a transfer while a NAT rebinds the ports of the client", func() {
+		// The NAT drops the packets the server sends to a port it rebound, until the client sends again.
+		// The server then retransmits its WINDOW_UPDATEs, a client blocked by flow control would wait for them.
+		_, serverStats := transfer(testserver.GeneratePRData(3e6), func(clock *netem.VirtualClock) {
+			for t := 500 * time.Millisecond; t < 10*time.Second; t += 700 * time.Millisecond {
+				clock.AfterFunc(t, wifi.Rebind)
+				clock.AfterFunc(t+300*time.Millisecond, cellular.Rebind)
+			}
+		})
+		// the server follows the new ports of the client. A path the scheduler stopped using before a rebinding keeps its old port.
+		var rebound int
+		for _, p := range serverStats.Paths {
+			if p.RemoteAddr.(*net.UDPAddr).Port >= 50000 {
+				rebound++
+			}
+		}
+		Expect(rebound).ToNot(BeZero())
+	})
+
+	It("completes a transfer while the client loses and gains addresses", func() {
+		clientStats, _ := transfer(testserver.GeneratePRData(4e6), func(clock *netem.VirtualClock) {
+			clock.AfterFunc(time.Second, func() { Expect(clientHost.RemoveIP(cellularIP)).To(Succeed()) })
+			clock.AfterFunc(2500*time.Millisecond, func() { Expect(clientHost.AddIP(cellularIP)).To(Succeed()) })
+			clock.AfterFunc(3*time.Second, func() {
+				_, err := sim.Connect(clientHost, newIP, serverHost, serverIP, cellLink, cellLink)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+		// the path of the removed address failed, a new one was created when it came back
+		Expect(localPaths(clientStats, cellularIP)).To(HaveLen(2))
+		Expect(localPaths(clientStats, newIP)).To(HaveLen(1))
+	})
+
+	It("completes a transfer while the interface of the initial path goes away", func() {
+		transfer(testserver.GeneratePRData(2e6), func(clock *netem.VirtualClock) {
+			clock.AfterFunc(time.Second, func() { Expect(clientHost.RemoveIP(wifiIP)).To(Succeed()) })
+		})
+	})
+
+	for i := 0; i < 3; i++ {
+		run := i
+
+		It(fmt.Sprintf("completes a transfer under random chaos, run %d", run), func() {
+			rnd := rand.New(rand.NewSource(GinkgoRandomSeed() + int64(run)))
+			var events []string
+			transfer(testserver.GeneratePRData(3e6), func(clock *netem.VirtualClock) {
+				for t := 500 * time.Millisecond; t < 15*time.Second; t += time.Duration(250+rnd.Intn(1000)) * time.Millisecond {
+					link, up, ip := wifi, wifiLink, wifiIP
+					if rnd.Intn(2) == 1 {
+						link, up, ip = cellular, cellLink, cellularIP
+					}
+					duration := time.Duration(rnd.Int63n(int64(2 * time.Second)))
+					switch rnd.Intn(3) {
+					case 0:
+						events = append(events, fmt.Sprintf("%s: %s down for %s", t, ip, duration))
+						clock.AfterFunc(t, func() { link.SetConfig(down, down) })
+						clock.AfterFunc(t+duration, func() { link.SetConfig(up, up) })
+					case 1:
+						events = append(events, fmt.Sprintf("%s: %s rebound", t, ip))
+						clock.AfterFunc(t, link.Rebind)
+					case 2:
+						events = append(events, fmt.Sprintf("%s: %s removed for %s", t, ip, duration))
+						// the address may already be removed by an earlier event
+						clock.AfterFunc(t, func() { clientHost.RemoveIP(ip) })
+						clock.AfterFunc(t+duration, func() { clientHost.AddIP(ip) })
+					}
+				}
+				fmt.Fprintf(GinkgoWriter, "chaos:\n%s\n", strings.Join(events, "\n"))
+			})
+		})
+	}
+})
+
+// expectConsistentStats checks the counters of the paths of one end against each other
+func expectConsistentStats(stats quic.ConnectionStats) {
+	for i, p := range stats.Paths {
+		if i > 0 {
+			ExpectWithOffset(1, p.PathID).To(BeNumerically(">", stats.Paths[i-1].PathID))
+		}
+		ExpectWithOffset(1, p.PacketsLost).To(BeNumerically("<=", p.PacketsSent))
+		ExpectWithOffset(1, p.ReceivedWithDeadline).To(BeNumerically("<=", p.PacketsReceived))
+		ExpectWithOffset(1, p.ReceivedMeetDeadline).To(BeNumerically("<=", p.ReceivedWithDeadline))
+		ExpectWithOffset(1, p.SentMeetDeadline).To(BeNumerically("<=", p.SentWithDeadline))
+	}
+}
+
+// expectMatchingStats checks the statistics of the sender of the data against those of its receiver.
+// The network doesn't duplicate packets, so an end receives at most the packets sent by its peer, and a packet
+// acknowledged by the peer was received by it. The acknowledgments of the last packets may still be on their way
+// when the transfer ends, and the packets declared lost are no longer counted when they are acknowledged, so the
+// acknowledged bytes only bound the received bytes from below. The ends are compared as a whole: the first packet
+// sent on a new path doesn't carry its path ID, the peer counts it on the initial path.
+func expectMatchingStats(sender, receiver quic.ConnectionStats, dataLen int) {
+	ExpectWithOffset(1, receiver.PacketsReceived).To(BeNumerically("<=", sender.PacketsSent))
+	ExpectWithOffset(1, sender.PacketsReceived).To(BeNumerically("<=", receiver.PacketsSent))
+	ExpectWithOffset(1, bytesAcked(sender)).To(BeNumerically("<=", bytesReceived(receiver)))
+	ExpectWithOffset(1, bytesAcked(receiver)).To(BeNumerically("<=", bytesReceived(sender)))
+	// the stream data reached the receiver, with the headers and the frames around it
+	ExpectWithOffset(1, bytesReceived(receiver)).To(BeNumerically(">", dataLen))
+}
+
+// bytesAcked returns the number of bytes sent on all paths and acknowledged by the peer
+func bytesAcked(stats quic.ConnectionStats) uint64 {
+	var n uint64
+	for _, p := range stats.Paths {
+		n += p.BytesAcked
+	}
+	return n
+}
+
+// bytesReceived returns the number of bytes of the packets received on all paths
+func bytesReceived(stats quic.ConnectionStats) uint64 {
+	var n uint64
+	for _, p := range stats.Paths {
+		n += p.BytesReceived
+	}
+	return n
+}
+
+// logPaths writes the statistics of the paths of a session, ginkgo shows them if the test fails
+func logPaths(name string, sess quic.Session) {
+	if sess == nil {
+		return
+	}
+	for _, p := range sess.ConnectionStats().Paths {
+		fmt.Fprintf(GinkgoWriter, "%s path %d %s -> %s: open %t, potentially failed %t, %d sent, %d lost, %d received\n",
+			name, p.PathID, p.LocalAddr, p.RemoteAddr, p.Open, p.PotentiallyFailed, p.PacketsSent, p.PacketsLost, p.PacketsReceived)
+	}
+}
+
+var goroutineHeader = regexp.MustCompile(`^goroutine (\d+) \[`)
+
+// goroutineStacks returns the stacks of all goroutines, by goroutine ID
+func goroutineStacks() map[string]string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := make(map[string]string)
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		if m := goroutineHeader.FindStringSubmatch(stack); m != nil {
+			stacks[m[1]] = stack
+		}
+	}
+	return stacks
+}
+
+func goroutineIDs() map[string]bool {
+	ids := make(map[string]bool)
+	for id := range goroutineStacks() {
+		ids[id] = true
+	}
+	return ids
+}
+
+// leakedGoroutines returns the stacks of the goroutines of quic-go started since the snapshot and still running
+func leakedGoroutines(before map[string]bool) []string {
+	var leaked []string
+	for id, stack := range goroutineStacks() {
+		if !before[id] && strings.Contains(stack, "lucas-clemente/quic-go") {
+			leaked = append(leaked, stack)
+		}
+	}
+	return leaked
+}
@@ -0,0 +1,121 @@
+package self_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/integrationtests/tools/proxy"
+	"github.com/lucas-clemente/quic-go/integrationtests/tools/testserver"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Multipath through a proxy", func() {
+	var (
+		server quic.Listener
+		proxy  *quicproxy.MultipathProxy
+	)
+
+	AfterEach(func() {
+		Expect(proxy.Close()).To(Succeed())
+		Expect(server.Close()).To(Succeed())
+	})
+
+	// run starts the server and the proxy, the server sends the first half of the data right away,
+	// and the second half after a pause
+	run := func(pause time.Duration, paths ...quicproxy.PathOpts) {
+		var err error
+		server, err = quic.ListenAddr("localhost:0", testdata.GetTLSConfig(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		proxy, err = quicproxy.NewMultipathProxy(&quicproxy.MultipathOpts{
+			RemoteAddr: server.Addr().String(),
+			Paths:      paths,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		go func() {
+			defer GinkgoRecover()
+			sess, err := server.Accept()
+			if err != nil {
+				return
+			}
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			half := len(testserver.PRData) / 2
+			_, err = str.Write(testserver.PRData[:half])
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(pause)
+			_, err = str.Write(testserver.PRData[half:])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+	}
+
+	download := func() quic.Session {
+		sess, err := quic.DialAddr(proxy.LocalAddr(0).String(), &tls.Config{InsecureSkipVerify: true}, &quic.Config{
+			Network:     proxy.ClientNetwork(),
+			CreatePaths: true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		str, err := sess.OpenStreamSync()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write([]byte("GET"))
+		Expect(err).ToNot(HaveOccurred())
+		data, err := ioutil.ReadAll(gbytes.TimeoutReader(str, 30*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(testserver.PRData))
+		return sess
+	}
+
+	// packetsReceivedOn returns the number of packets the client received on the paths of an interface of the proxy client network
+	packetsReceivedOn := func(sess quic.Session, ip string) uint64 {
+		var n uint64
+		for _, p := range sess.ConnectionStats().Paths {
+			if p.LocalAddr.(*net.UDPAddr).IP.Equal(net.ParseIP(ip)) {
+				n += p.PacketsReceived
+			}
+		}
+		return n
+	}
+
+	It("hands over to the cellular path when the WiFi path goes down", func() {
+		run(
+			2*time.Second,
+			quicproxy.PathOpts{Schedule: []quicproxy.ImpairmentStep{
+				{Impairment: quicproxy.Impairment{Delay: 5 * time.Millisecond}},
+				{At: time.Second, Impairment: quicproxy.Impairment{Down: true}},
+			}},
+			quicproxy.PathOpts{Schedule: []quicproxy.ImpairmentStep{
+				{Impairment: quicproxy.Impairment{Delay: 25 * time.Millisecond}},
+			}},
+		)
+		sess := download()
+		defer sess.Close(nil)
+		Expect(packetsReceivedOn(sess, "192.0.2.2")).ToNot(BeZero())
+	})
+
+	It("survives a degradation of the cellular path", func() {
+		run(
+			time.Second,
+			quicproxy.PathOpts{Schedule: []quicproxy.ImpairmentStep{
+				{Impairment: quicproxy.Impairment{Delay: 10 * time.Millisecond}},
+			}},
+			quicproxy.PathOpts{Schedule: []quicproxy.ImpairmentStep{
+				{Impairment: quicproxy.Impairment{Delay: 20 * time.Millisecond}},
+				// the RTT steps up, then a loss burst
+				{At: 500 * time.Millisecond, Impairment: quicproxy.Impairment{Delay: 100 * time.Millisecond}},
+				{At: 1500 * time.Millisecond, Impairment: quicproxy.Impairment{Delay: 100 * time.Millisecond, Loss: 0.3}},
+				{At: 2 * time.Second, Impairment: quicproxy.Impairment{Delay: 20 * time.Millisecond}},
+			}},
+		)
+		sess := download()
+		defer sess.Close(nil)
+		Expect(packetsReceivedOn(sess, "192.0.2.1")).ToNot(BeZero())
+		Expect(packetsReceivedOn(sess, "192.0.2.2")).ToNot(BeZero())
+	})
+})
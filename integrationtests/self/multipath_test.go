@@ -0,0 +1,263 @@
+package self_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/integrationtests/tools/testserver"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/netem"
+	"github.com/lucas-clemente/quic-go/netem/traces"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Multipath over an emulated network", func() {
+	const serverAddr = "10.1.0.1:4433"
+
+	var (
+		network      *netem.Network
+		clientHost   *netem.Host
+		serverHost   *netem.Host
+		links        []*netem.Link
+		server       quic.Listener
+		serverConfig *quic.Config
+	)
+
+	// connect creates one link per path between the client and the server, the first path being the fastest one
+	connect := func(numPaths int) {
+		for i := 0; i < numPaths; i++ {
+			cfg := netem.LinkConfig{
+				Delay:     time.Duration(5*(i+1)) * time.Millisecond,
+				Bandwidth: 2e6,
+				QueueSize: 200,
+			}
+			l, err := network.Connect(clientHost, fmt.Sprintf("10.0.%d.1", i), serverHost, "10.1.0.1", cfg, cfg)
+			Expect(err).ToNot(HaveOccurred())
+			links = append(links, l)
+		}
+	}
+
+	// serve sends data on the first stream opened by the client
+	serve := func(data []byte) {
+		var err error
+		server, err = quic.ListenAddr(serverAddr, testdata.GetTLSConfig(), serverConfig)
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			sess, err := server.Accept()
+			if err != nil {
+				return
+			}
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+	}
+
+	dial := func() quic.Session {
+		sess, err := quic.DialAddr(serverAddr, &tls.Config{InsecureSkipVerify: true}, &quic.Config{
+			Network:     clientHost,
+			CreatePaths: true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return sess
+	}
+
+	BeforeEach(func() {
+		network = netem.NewNetwork(GinkgoRandomSeed())
+		clientHost = network.AddHost("client")
+		serverHost = network.AddHost("server")
+		links = nil
+		serverConfig = &quic.Config{Network: serverHost, SchedulerName: "rtt"}
+	})
+
+	AfterEach(func() {
+		if server != nil {
+			Expect(server.Close()).To(Succeed())
+		}
+	})
+
+	for _, n := range []int{2, 3, 4} {
+		numPaths := n
+
+		It(fmt.Sprintf("transfers data over %d paths", numPaths), func() {
+			connect(numPaths)
+			serve(testserver.PRData)
+			sess := dial()
+			defer sess.Close(nil)
+
+			str, err := sess.OpenStreamSync()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write([]byte("GET"))
+			Expect(err).ToNot(HaveOccurred())
+			data, err := ioutil.ReadAll(gbytes.TimeoutReader(str, 20*time.Second))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal(testserver.PRData))
+
+			// the initial path, and one path per interface of the client
+			Expect(sess.ConnectionStats().Paths).To(HaveLen(numPaths + 1))
+			for _, l := range links {
+				up, down := l.Stats()
+				Expect(up.Sent).ToNot(BeZero())
+				Expect(down.Sent).ToNot(BeZero())
+			}
+		})
+	}
+
+	It("advertises the path costs of the server, the initial path included", func() {
+		connect(2)
+		serverConfig.PathCosts = map[string]float64{"10.1.0.1": 0.5}
+		serve(testserver.PRData)
+		sess := dial()
+		defer sess.Close(nil)
+
+		str, err := sess.OpenStreamSync()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write([]byte("GET"))
+		Expect(err).ToNot(HaveOccurred())
+		data, err := ioutil.ReadAll(gbytes.TimeoutReader(str, 20*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(testserver.PRData))
+
+		// every path of the client ends on the IP of the server
+		paths := sess.ConnectionStats().Paths
+		Expect(paths).To(HaveLen(3))
+		for _, p := range paths {
+			Expect(p.Cost).To(Equal(0.5))
+		}
+	})
+
+	// run with -race: the snapshots are taken while the run loop updates the statistics
+	It("snapshots the statistics during a transfer", func() {
+		connect(2)
+		serve(testserver.PRData)
+		sess := dial()
+		defer sess.Close(nil)
+
+		done := make(chan struct{})
+		polled := make(chan int)
+		go func() {
+			defer GinkgoRecover()
+			var n int
+			var sent uint64
+			for {
+				select {
+				case <-done:
+					polled <- n
+					return
+				default:
+				}
+				stats := sess.ConnectionStats()
+				Expect(stats.PacketsSent).To(BeNumerically(">=", sent))
+				sent = stats.PacketsSent
+				n++
+				time.Sleep(time.Millisecond)
+			}
+		}()
+
+		str, err := sess.OpenStreamSync()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write([]byte("GET"))
+		Expect(err).ToNot(HaveOccurred())
+		data, err := ioutil.ReadAll(gbytes.TimeoutReader(str, 20*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(testserver.PRData))
+		close(done)
+		Expect(<-polled).ToNot(BeZero())
+	})
+
+	It("survives losses on the links", func() {
+		connect(2)
+		for _, l := range links {
+			cfg := netem.LinkConfig{Delay: 10 * time.Millisecond, Bandwidth: 2e6, QueueSize: 200, Loss: 0.02}
+			l.SetConfig(cfg, cfg)
+		}
+		serve(testserver.PRData)
+		sess := dial()
+		defer sess.Close(nil)
+
+		str, err := sess.OpenStreamSync()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write([]byte("GET"))
+		Expect(err).ToNot(HaveOccurred())
+		data, err := ioutil.ReadAll(gbytes.TimeoutReader(str, 30*time.Second))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(testserver.PRData))
+		var lost uint64
+		for _, l := range links {
+			up, down := l.Stats()
+			lost += up.Lost + down.Lost
+		}
+		Expect(lost).ToNot(BeZero())
+	})
+
+	It("meets deadlines over cellular and WiFi traces", func() {
+		// the client uploads on a free WiFi link and a metered cellular link
+		for i, name := range []string{traces.WiFi, traces.Cellular} {
+			up, down, err := traces.Load(name)
+			Expect(err).ToNot(HaveOccurred())
+			up.QueueSize = 200
+			down.QueueSize = 200
+			l, err := network.Connect(clientHost, fmt.Sprintf("10.0.%d.1", i), serverHost, "10.1.0.1", up, down)
+			Expect(err).ToNot(HaveOccurred())
+			links = append(links, l)
+		}
+		data := testserver.GeneratePRData(200 * 1024)
+
+		var err error
+		server, err = quic.ListenAddr(serverAddr, testdata.GetTLSConfig(), serverConfig)
+		Expect(err).ToNot(HaveOccurred())
+		received := make(chan quic.ConnectionStats)
+		go func() {
+			defer GinkgoRecover()
+			sess, err := server.Accept()
+			if err != nil {
+				return
+			}
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			d, err := ioutil.ReadAll(gbytes.TimeoutReader(str, 30*time.Second))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d).To(Equal(data))
+			received <- sess.ConnectionStats()
+		}()
+
+		sess, err := quic.DialAddr(serverAddr, &tls.Config{InsecureSkipVerify: true}, &quic.Config{
+			Network:       clientHost,
+			CreatePaths:   true,
+			SchedulerName: "BatchEDF",
+			PathCosts:     map[string]float64{"10.0.1.1": 1},
+			RandSeed:      GinkgoRandomSeed(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer sess.Close(nil)
+		str, err := sess.OpenStreamSync()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str.Close()).To(Succeed())
+
+		var stats quic.ConnectionStats
+		Eventually(received, 40*time.Second).Should(Receive(&stats))
+		sent := sess.ConnectionStats()
+		meetRatio := float64(stats.ReceivedMeetDeadline) / float64(stats.ReceivedWithDeadline)
+		fmt.Fprintf(GinkgoWriter, "deadline-meet ratio: %.2f, cost: %.0f\n", meetRatio, sent.CostSpent)
+		// the batch scheduler gives packets deadlines of 20 to 50 ms, less than the delay of the cellular link:
+		// these are regression bounds, not targets
+		Expect(stats.ReceivedWithDeadline).ToNot(BeZero())
+		Expect(meetRatio).To(BeNumerically(">=", 0.1))
+		// both the free and the metered link carried data
+		Expect(sent.CostSpent).ToNot(BeZero())
+		for _, l := range links {
+			up, _ := l.Stats()
+			Expect(up.Sent).ToNot(BeZero())
+		}
+	})
+})
@@ -0,0 +1,154 @@
+package self_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/integrationtests/tools/testserver"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/netem"
+	"github.com/lucas-clemente/quic-go/pcapng"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// packetLog is a pcapng.Capturer keeping the time, the addresses, the length and the comment of the datagrams,
+// the data itself is encrypted with random keys
+type packetLog struct {
+	mutex   sync.Mutex
+	packets []string
+}
+
+func (l *packetLog) Capture(p *pcapng.Packet) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.packets = append(l.packets, fmt.Sprintf("%s %d %s %s %d %s", p.Time.Sub(netem.VirtualEpoch), p.Direction, p.LocalAddr, p.RemoteAddr, len(p.Data), p.Comment))
+}
+
+var _ = Describe("Multipath in virtual time", func() {
+	const serverAddr = "10.1.0.1:4433"
+
+	It("simulates a transfer of a minute in a fraction of it", func() {
+		sim := netem.NewSimulation(GinkgoRandomSeed())
+		clientHost := sim.AddHost("client")
+		serverHost := sim.AddHost("server")
+		// two paths of 50 kB/s, the data takes a minute to go through
+		for i := 0; i < 2; i++ {
+			cfg := netem.LinkConfig{Delay: time.Duration(20*(i+1)) * time.Millisecond, Bandwidth: 50e3, QueueSize: 100}
+			_, err := sim.Connect(clientHost, fmt.Sprintf("10.0.%d.1", i), serverHost, "10.1.0.1", cfg, cfg)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		data := testserver.GeneratePRData(6e6)
+
+		server, err := quic.ListenAddr(serverAddr, testdata.GetTLSConfig(), &quic.Config{
+			Network:  serverHost,
+			Clock:    sim.Clock(),
+			RandSeed: GinkgoRandomSeed(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer server.Close()
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			sess, err := server.Accept()
+			Expect(err).ToNot(HaveOccurred())
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			d, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d).To(Equal(data))
+		}()
+		go func() {
+			defer GinkgoRecover()
+			sess, err := quic.DialAddr(serverAddr, &tls.Config{InsecureSkipVerify: true}, &quic.Config{
+				Network:     clientHost,
+				CreatePaths: true,
+				Clock:       sim.Clock(),
+				RandSeed:    GinkgoRandomSeed(),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			str, err := sess.OpenStreamSync()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+
+		start := time.Now()
+		Expect(sim.Run(done, 5*time.Minute)).To(Succeed())
+		elapsed := sim.Clock().Now().Sub(netem.VirtualEpoch)
+		fmt.Fprintf(GinkgoWriter, "simulated %s in %s\n", elapsed, time.Since(start))
+		Expect(elapsed).To(BeNumerically(">", 55*time.Second))
+		Expect(time.Since(start)).To(BeNumerically("<", elapsed/4))
+	})
+	// transfer sends data over two lossy paths in a simulation, and returns the datagrams of the client and the server
+	transfer := func(seed int64) (clientPackets, serverPackets []string) {
+		sim := netem.NewSimulation(seed)
+		clientHost := sim.AddHost("client")
+		serverHost := sim.AddHost("server")
+		for i := 0; i < 2; i++ {
+			cfg := netem.LinkConfig{Delay: time.Duration(20*(i+1)) * time.Millisecond, Jitter: 5 * time.Millisecond, Bandwidth: 200e3, QueueSize: 50, Loss: 0.01}
+			_, err := sim.Connect(clientHost, fmt.Sprintf("10.0.%d.1", i), serverHost, "10.1.0.1", cfg, cfg)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		data := testserver.GeneratePRData(1e6)
+		clientLog := &packetLog{}
+		serverLog := &packetLog{}
+
+		server, err := quic.ListenAddr(serverAddr, testdata.GetTLSConfig(), &quic.Config{
+			Network:       serverHost,
+			Clock:         sim.Clock(),
+			RandSeed:      seed,
+			PacketCapture: serverLog,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer server.Close()
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			sess, err := server.Accept()
+			Expect(err).ToNot(HaveOccurred())
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			d, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(d).To(Equal(data))
+		}()
+		go func() {
+			defer GinkgoRecover()
+			sess, err := quic.DialAddr(serverAddr, &tls.Config{InsecureSkipVerify: true}, &quic.Config{
+				Network:       clientHost,
+				CreatePaths:   true,
+				Clock:         sim.Clock(),
+				RandSeed:      seed,
+				PacketCapture: clientLog,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			str, err := sess.OpenStreamSync()
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+		Expect(sim.Run(done, 5*time.Minute)).To(Succeed())
+		clientLog.mutex.Lock()
+		defer clientLog.mutex.Unlock()
+		serverLog.mutex.Lock()
+		defer serverLog.mutex.Unlock()
+		return clientLog.packets, serverLog.packets
+	}
+
+	It("reproduces a transfer with the same seed", func() {
+		client, server := transfer(GinkgoRandomSeed())
+		Expect(client).ToNot(BeEmpty())
+		client2, server2 := transfer(GinkgoRandomSeed())
+		Expect(client2).To(Equal(client))
+		Expect(server2).To(Equal(server))
+	})
+})
@@ -0,0 +1,106 @@
+package self_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/qerr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Version negotiation tests", func() {
+	var (
+		server        quic.Listener
+		acceptStopped chan struct{}
+	)
+
+	data := []byte("a message that has to survive the round trip")
+
+	// runEchoServer starts a server that echoes the data it receives on the first stream of every session
+	runEchoServer := func(versions []protocol.VersionNumber) {
+		var err error
+		server, err = quic.ListenAddr("localhost:0", testdata.GetTLSConfig(), &quic.Config{Versions: versions})
+		Expect(err).ToNot(HaveOccurred())
+		acceptStopped = make(chan struct{})
+
+		go func() {
+			defer GinkgoRecover()
+			defer close(acceptStopped)
+			for {
+				sess, err := server.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					str, err := sess.AcceptStream()
+					if err != nil {
+						return
+					}
+					io.Copy(str, str)
+				}()
+			}
+		}()
+	}
+
+	AfterEach(func() {
+		Expect(server.Close()).To(Succeed())
+		<-acceptStopped
+	})
+
+	dial := func(versions []protocol.VersionNumber) (quic.Session, error) {
+		return quic.DialAddr(server.Addr().String(), &tls.Config{InsecureSkipVerify: true}, &quic.Config{Versions: versions})
+	}
+
+	// expectEcho checks that the session negotiated the version, and that the server echoes the data
+	expectEcho := func(sess quic.Session, version protocol.VersionNumber) {
+		Expect(sess.ConnectionStats().Version).To(Equal(version))
+		str, err := sess.OpenStreamSync()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		buf := make([]byte, len(data))
+		_, err = io.ReadFull(str, buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buf).To(Equal(data))
+		Expect(sess.Close(nil)).To(Succeed())
+	}
+
+	for i := range protocol.SupportedVersions {
+		for j := range protocol.SupportedVersions {
+			clientVersion := protocol.SupportedVersions[i]
+			serverVersion := protocol.SupportedVersions[j]
+
+			It(fmt.Sprintf("client with version %s, server with version %s", clientVersion, serverVersion), func() {
+				runEchoServer([]protocol.VersionNumber{serverVersion})
+				sess, err := dial([]protocol.VersionNumber{clientVersion})
+				if clientVersion != serverVersion {
+					Expect(err).To(HaveOccurred())
+					Expect(err.(qerr.ErrorCode)).To(Equal(qerr.InvalidVersion))
+					return
+				}
+				Expect(err).ToNot(HaveOccurred())
+				expectEcho(sess, clientVersion)
+			})
+		}
+	}
+
+	It("falls back to multipath without deadlines if the server doesn't support deadlines", func() {
+		runEchoServer([]protocol.VersionNumber{protocol.VersionMP, protocol.Version39})
+		sess, err := dial([]protocol.VersionNumber{protocol.VersionMPDeadline, protocol.VersionMP})
+		Expect(err).ToNot(HaveOccurred())
+		expectEcho(sess, protocol.VersionMP)
+	})
+
+	It("negotiates deadlines if both endpoints support them", func() {
+		runEchoServer(protocol.SupportedVersions)
+		sess, err := dial([]protocol.VersionNumber{protocol.VersionMPDeadline, protocol.Version39})
+		Expect(err).ToNot(HaveOccurred())
+		expectEcho(sess, protocol.VersionMPDeadline)
+	})
+})
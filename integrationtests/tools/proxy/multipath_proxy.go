@@ -0,0 +1,367 @@
+package quicproxy
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// Impairment is the state of a path of a MultipathProxy
+type Impairment struct {
+	// Down drops all the packets of the path, as if its link was down
+	Down bool
+	// Delay is applied to the packets of both directions, so it adds twice its value to the RTT
+	Delay time.Duration
+	// Loss is the probability that a packet is dropped, in each direction
+	Loss float64
+}
+
+// ImpairmentStep sets the impairment of a path, from a time after the start of the proxy on
+type ImpairmentStep struct {
+	At time.Duration
+	Impairment
+}
+
+// PathOpts are the options of a path of a MultipathProxy
+type PathOpts struct {
+	// LocalAddr is the address the path listens on, localhost:0 if empty
+	LocalAddr string
+	// Schedule lists the impairments of the path. The path isn't impaired before the first step.
+	Schedule []ImpairmentStep
+}
+
+// MultipathOpts are multipath proxy options.
+type MultipathOpts struct {
+	// The address this proxy proxies packets to.
+	RemoteAddr string
+	// Paths has one entry per path
+	Paths []PathOpts
+	// Seed makes the losses of the paths reproducible
+	Seed int64
+}
+
+// MultipathProxy is a QUIC proxy with one listening address per path, each path with its own impairment schedule.
+// Use it to script handovers and link degradations, like a WiFi link going down at t=5s.
+// Unlike QuicProxy, it doesn't reorder packets: a path delivers its packets in the order it received them,
+// a packet waits for the ones before it when the delay decreases.
+type MultipathProxy struct {
+	start time.Time
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+
+	paths     []*proxyPath
+	schedules [][]ImpairmentStep
+}
+
+// NewMultipathProxy creates a new multipath UDP proxy
+func NewMultipathProxy(opts *MultipathOpts) (*MultipathProxy, error) {
+	if opts == nil || len(opts.Paths) == 0 {
+		return nil, errors.New("multipath proxy: no paths")
+	}
+	raddr, err := net.ResolveUDPAddr("udp", opts.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	p := &MultipathProxy{
+		start: time.Now(),
+		rand:  rand.New(rand.NewSource(opts.Seed)),
+	}
+	for _, pathOpts := range opts.Paths {
+		schedule := append([]ImpairmentStep(nil), pathOpts.Schedule...)
+		sort.SliceStable(schedule, func(a, b int) bool { return schedule[a].At < schedule[b].At })
+		p.schedules = append(p.schedules, schedule)
+	}
+	for i, pathOpts := range opts.Paths {
+		local := pathOpts.LocalAddr
+		if local == "" {
+			local = "localhost:0"
+		}
+		laddr, err := net.ResolveUDPAddr("udp", local)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		conn, err := net.ListenUDP("udp", laddr)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		path := &proxyPath{
+			proxy:      p,
+			index:      i,
+			conn:       conn,
+			serverAddr: raddr,
+			clients:    make(map[string]*proxyClient),
+		}
+		p.paths = append(p.paths, path)
+		go path.run()
+	}
+	return p, nil
+}
+
+// Close stops all the paths of the proxy
+func (p *MultipathProxy) Close() error {
+	var err error
+	for _, path := range p.paths {
+		if e := path.close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// LocalAddr is the address a path of the proxy is listening on.
+func (p *MultipathProxy) LocalAddr(path int) net.Addr {
+	return p.paths[path].conn.LocalAddr()
+}
+
+// Impairment returns the current impairment of a path
+func (p *MultipathProxy) Impairment(path int) Impairment {
+	return p.impairmentAt(path, time.Since(p.start))
+}
+
+func (p *MultipathProxy) impairmentAt(path int, t time.Duration) Impairment {
+	schedule := p.schedules[path]
+	i := sort.Search(len(schedule), func(i int) bool { return schedule[i].At > t })
+	if i == 0 {
+		return Impairment{}
+	}
+	return schedule[i-1].Impairment
+}
+
+// forward sends a packet on a delay line with the impairment of a path, unless it is dropped
+func (p *MultipathProxy) forward(path int, l *delayLine, data []byte) {
+	impairment := p.Impairment(path)
+	if impairment.Down {
+		return
+	}
+	if impairment.Loss > 0 {
+		p.mutex.Lock()
+		lost := p.rand.Float64() < impairment.Loss
+		p.mutex.Unlock()
+		if lost {
+			return
+		}
+	}
+	l.send(data, impairment.Delay)
+}
+
+// proxyPath is a path of a MultipathProxy
+type proxyPath struct {
+	proxy      *MultipathProxy
+	index      int
+	conn       *net.UDPConn
+	serverAddr *net.UDPAddr
+
+	mutex sync.Mutex
+	// Mapping from client addresses (as host:port) to clients
+	clients map[string]*proxyClient
+}
+
+// proxyClient is a client of a path, with its connection to the server
+type proxyClient struct {
+	serverConn *net.UDPConn
+	// incoming carries the packets to the server, outgoing those to the client
+	incoming, outgoing *delayLine
+}
+
+// run handles the packets from the clients of the path
+func (p *proxyPath) run() error {
+	for {
+		buffer := make([]byte, protocol.MaxPacketSize)
+		n, cliaddr, err := p.conn.ReadFromUDP(buffer)
+		if err != nil {
+			return err
+		}
+		p.mutex.Lock()
+		client, ok := p.clients[cliaddr.String()]
+		if !ok {
+			client, err = p.newClient(cliaddr)
+			if err != nil {
+				p.mutex.Unlock()
+				return err
+			}
+			p.clients[cliaddr.String()] = client
+		}
+		p.mutex.Unlock()
+		p.proxy.forward(p.index, client.incoming, buffer[:n])
+	}
+}
+
+func (p *proxyPath) newClient(cliaddr *net.UDPAddr) (*proxyClient, error) {
+	serverConn, err := net.DialUDP("udp", nil, p.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	c := &proxyClient{
+		serverConn: serverConn,
+		incoming:   newDelayLine(func(data []byte) { serverConn.Write(data) }),
+		outgoing:   newDelayLine(func(data []byte) { p.conn.WriteToUDP(data, cliaddr) }),
+	}
+	// handle the packets from the server to the client
+	go func() {
+		for {
+			buffer := make([]byte, protocol.MaxPacketSize)
+			n, err := serverConn.Read(buffer)
+			if err != nil {
+				return
+			}
+			p.proxy.forward(p.index, c.outgoing, buffer[:n])
+		}
+	}()
+	return c, nil
+}
+
+func (p *proxyPath) close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, c := range p.clients {
+		c.incoming.close()
+		c.outgoing.close()
+		c.serverConn.Close()
+	}
+	return p.conn.Close()
+}
+
+// delayLineSize is the number of packets a delay line holds, further packets are dropped
+const delayLineSize = 1024
+
+type delayedPacket struct {
+	data []byte
+	due  time.Time
+}
+
+// delayLine writes packets in order, each after its delay but not before the packets sent before it
+type delayLine struct {
+	packets chan delayedPacket
+	closed  chan struct{}
+	write   func([]byte)
+}
+
+func newDelayLine(write func([]byte)) *delayLine {
+	l := &delayLine{
+		packets: make(chan delayedPacket, delayLineSize),
+		closed:  make(chan struct{}),
+		write:   write,
+	}
+	go l.run()
+	return l
+}
+
+func (l *delayLine) send(data []byte, delay time.Duration) {
+	select {
+	case l.packets <- delayedPacket{data: data, due: time.Now().Add(delay)}:
+	default:
+		// the line is full
+	}
+}
+
+func (l *delayLine) run() {
+	for {
+		select {
+		case <-l.closed:
+			return
+		case p := <-l.packets:
+			if d := time.Until(p.due); d > 0 {
+				timer := time.NewTimer(d)
+				select {
+				case <-l.closed:
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			l.write(p.data)
+		}
+	}
+}
+
+func (l *delayLine) close() {
+	close(l.closed)
+}
+
+// ClientNetwork returns a network for the client of the proxy, to be used as the Network of its quic.Config.
+// The client gets one interface per path of the proxy.
+func (p *MultipathProxy) ClientNetwork() *ClientNetwork {
+	return &ClientNetwork{proxy: p}
+}
+
+// ClientNetwork is a quic.PacketNetwork with one interface per path of a MultipathProxy. The packets sent from an
+// interface go through its path, whatever their destination, and seem to come from the address they were sent to.
+// The interfaces have the addresses 192.0.2.1, 192.0.2.2, ..., their sockets are UDP sockets bound to localhost.
+type ClientNetwork struct {
+	proxy *MultipathProxy
+}
+
+// InterfaceIPs returns the addresses of the interfaces, one per path of the proxy
+func (n *ClientNetwork) InterfaceIPs() ([]net.IP, error) {
+	ips := make([]net.IP, len(n.proxy.paths))
+	for i := range ips {
+		ips[i] = net.IPv4(192, 0, 2, byte(i+1))
+	}
+	return ips, nil
+}
+
+// ListenUDP opens a socket on an interface. A socket on the unspecified address is a plain UDP socket.
+func (n *ClientNetwork) ListenUDP(addr *net.UDPAddr) (net.PacketConn, error) {
+	if addr == nil || addr.IP == nil || addr.IP.IsUnspecified() {
+		return net.ListenUDP("udp", addr)
+	}
+	ips, _ := n.InterfaceIPs()
+	for i, ip := range ips {
+		if !ip.Equal(addr.IP) {
+			continue
+		}
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: addr.Port})
+		if err != nil {
+			return nil, err
+		}
+		return &pathConn{
+			UDPConn:   conn,
+			localAddr: &net.UDPAddr{IP: ip, Port: conn.LocalAddr().(*net.UDPAddr).Port},
+			proxyAddr: n.proxy.LocalAddr(i),
+		}, nil
+	}
+	return nil, fmt.Errorf("multipath proxy: no interface with address %s", addr.IP)
+}
+
+// pathConn is a socket on an interface of a ClientNetwork
+type pathConn struct {
+	*net.UDPConn
+	localAddr *net.UDPAddr
+	proxyAddr net.Addr
+
+	mutex sync.Mutex
+	// peer is the last address a packet was sent to
+	peer net.Addr
+}
+
+var _ net.PacketConn = &pathConn{}
+
+func (c *pathConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mutex.Lock()
+	c.peer = addr
+	c.mutex.Unlock()
+	return c.UDPConn.WriteTo(b, c.proxyAddr)
+}
+
+func (c *pathConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.UDPConn.ReadFrom(b)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err == nil && c.peer != nil {
+		addr = c.peer
+	}
+	return n, addr, err
+}
+
+func (c *pathConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
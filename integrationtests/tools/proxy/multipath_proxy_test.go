@@ -0,0 +1,180 @@
+package quicproxy
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Multipath QUIC Proxy", func() {
+	var (
+		serverConn *net.UDPConn
+		// the addresses the server received packets from
+		serverReceived chan net.Addr
+		proxy          *MultipathProxy
+	)
+
+	startProxy := func(paths ...PathOpts) {
+		var err error
+		proxy, err = NewMultipathProxy(&MultipathOpts{
+			RemoteAddr: serverConn.LocalAddr().String(),
+			Paths:      paths,
+			Seed:       42,
+		})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		serverReceived = make(chan net.Addr, 100)
+		var err error
+		serverConn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		// an echo server
+		go func(conn *net.UDPConn, received chan<- net.Addr) {
+			for {
+				buf := make([]byte, protocol.MaxPacketSize)
+				n, addr, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					return
+				}
+				received <- addr
+				conn.WriteToUDP(buf[:n], addr)
+			}
+		}(serverConn, serverReceived)
+	})
+
+	AfterEach(func() {
+		if proxy != nil {
+			Expect(proxy.Close()).To(Succeed())
+			proxy = nil
+		}
+		Expect(serverConn.Close()).To(Succeed())
+	})
+
+	It("refuses a proxy without paths", func() {
+		_, err := NewMultipathProxy(&MultipathOpts{RemoteAddr: "localhost:4433"})
+		Expect(err).To(MatchError("multipath proxy: no paths"))
+	})
+
+	It("follows the schedules of the paths", func() {
+		startProxy(PathOpts{Schedule: []ImpairmentStep{
+			{At: 5 * time.Second, Impairment: Impairment{Down: true}},
+			{At: time.Second, Impairment: Impairment{Delay: 10 * time.Millisecond}},
+			{At: 3 * time.Second, Impairment: Impairment{Delay: 50 * time.Millisecond, Loss: 0.2}},
+		}})
+		Expect(proxy.impairmentAt(0, 0)).To(Equal(Impairment{}))
+		Expect(proxy.impairmentAt(0, time.Second)).To(Equal(Impairment{Delay: 10 * time.Millisecond}))
+		Expect(proxy.impairmentAt(0, 4*time.Second)).To(Equal(Impairment{Delay: 50 * time.Millisecond, Loss: 0.2}))
+		Expect(proxy.impairmentAt(0, time.Minute)).To(Equal(Impairment{Down: true}))
+		Expect(proxy.Impairment(0)).To(Equal(Impairment{}))
+	})
+
+	It("forwards the packets of each path with its impairment", func() {
+		startProxy(
+			PathOpts{Schedule: []ImpairmentStep{{Impairment: Impairment{Delay: 50 * time.Millisecond}}}},
+			PathOpts{Schedule: []ImpairmentStep{{Impairment: Impairment{Down: true}}}},
+		)
+		Expect(proxy.LocalAddr(0)).ToNot(Equal(proxy.LocalAddr(1)))
+		conns := make([]*net.UDPConn, 2)
+		for i := range conns {
+			var err error
+			conns[i], err = net.DialUDP("udp", nil, proxy.LocalAddr(i).(*net.UDPAddr))
+			Expect(err).ToNot(HaveOccurred())
+			defer conns[i].Close()
+		}
+		sent := time.Now()
+		_, err := conns[0].Write([]byte("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = conns[1].Write([]byte("foobar"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conns[0].SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		n, err := conns[0].Read(make([]byte, 100))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(6))
+		// delayed in both directions
+		Expect(time.Since(sent)).To(BeNumerically(">=", 100*time.Millisecond))
+		Expect(serverReceived).To(HaveLen(1))
+	})
+
+	It("loses packets", func() {
+		startProxy(PathOpts{Schedule: []ImpairmentStep{{Impairment: Impairment{Loss: 0.5}}}})
+		// a delay line that doesn't write its packets
+		l := &delayLine{packets: make(chan delayedPacket, delayLineSize)}
+		for i := 0; i < 1000; i++ {
+			proxy.forward(0, l, []byte("foobar"))
+		}
+		Expect(len(l.packets)).To(BeNumerically("~", 500, 100))
+	})
+
+	It("delivers the packets of a path in order", func() {
+		var (
+			mutex    sync.Mutex
+			received []int
+		)
+		l := newDelayLine(func(data []byte) {
+			mutex.Lock()
+			received = append(received, int(data[0]))
+			mutex.Unlock()
+		})
+		defer l.close()
+		start := time.Now()
+		// the delay decreases, the second packet waits for the first one
+		l.send([]byte{1}, 50*time.Millisecond)
+		l.send([]byte{2}, 0)
+		l.send([]byte{3}, 60*time.Millisecond)
+		Eventually(func() []int {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return append([]int(nil), received...)
+		}).Should(Equal([]int{1, 2, 3}))
+		Expect(time.Since(start)).To(BeNumerically(">=", 60*time.Millisecond))
+	})
+
+	Context("client network", func() {
+		It("has one interface per path", func() {
+			startProxy(PathOpts{}, PathOpts{})
+			ips, err := proxy.ClientNetwork().InterfaceIPs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(HaveLen(2))
+			Expect(ips[0].Equal(net.ParseIP("192.0.2.1"))).To(BeTrue())
+			Expect(ips[1].IsGlobalUnicast()).To(BeTrue())
+			_, err = proxy.ClientNetwork().ListenUDP(&net.UDPAddr{IP: net.ParseIP("192.0.2.3")})
+			Expect(err).To(MatchError("multipath proxy: no interface with address 192.0.2.3"))
+		})
+
+		It("sends the packets of an interface through its path", func() {
+			startProxy(PathOpts{}, PathOpts{})
+			network := proxy.ClientNetwork()
+			conns := make([]net.PacketConn, 2)
+			for i := range conns {
+				var err error
+				conns[i], err = network.ListenUDP(&net.UDPAddr{IP: net.IPv4(192, 0, 2, byte(i+1))})
+				Expect(err).ToNot(HaveOccurred())
+				defer conns[i].Close()
+				Expect(conns[i].LocalAddr().(*net.UDPAddr).IP.Equal(net.IPv4(192, 0, 2, byte(i+1)))).To(BeTrue())
+			}
+			// the destination is the address the client dialed, that of the first path
+			dialed := proxy.LocalAddr(0)
+			var from []string
+			for _, c := range conns {
+				_, err := c.WriteTo([]byte("foobar"), dialed)
+				Expect(err).ToNot(HaveOccurred())
+				var addr net.Addr
+				Eventually(serverReceived).Should(Receive(&addr))
+				from = append(from, addr.String())
+
+				Expect(c.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+				n, addr, err := c.ReadFrom(make([]byte, 100))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(6))
+				Expect(addr).To(Equal(dialed))
+			}
+			// the server saw two different clients, one per path
+			Expect(from[0]).ToNot(Equal(from[1]))
+		})
+	})
+})
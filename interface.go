@@ -8,11 +8,20 @@ import (
 
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/pcapng"
+	"github.com/lucas-clemente/quic-go/qlog"
+	"github.com/lucas-clemente/quic-go/schedlog"
 )
 
 // The StreamID is the ID of a QUIC stream.
 type StreamID = protocol.StreamID
 
+// The PathID is the ID of a path of a multipath session.
+type PathID = protocol.PathID
+
+// The ConnectionID identifies a QUIC session.
+type ConnectionID = protocol.ConnectionID
+
 // A VersionNumber is a QUIC version number.
 type VersionNumber = protocol.VersionNumber
 
@@ -51,6 +60,10 @@ type Stream interface {
 	// with the connection. It is equivalent to calling both
 	// SetReadDeadline and SetWriteDeadline.
 	SetDeadline(t time.Time) error
+	// SetDeliveryDeadline sets the time the peer has to receive data after it was passed to Write.
+	// The deadline is attached to every StreamFrame carrying that data, including retransmissions.
+	// A zero value means the data has no deadline.
+	SetDeliveryDeadline(d time.Duration)
 	// GetBytesSent returns the number of bytes of the stream that were sent to the peer
 	GetBytesSent() (protocol.ByteCount, error)
 	// GetBytesRetrans returns the number of bytes of the stream that were retransmitted to the peer
@@ -78,6 +91,8 @@ type Session interface {
 	// The context is cancelled when the session is closed.
 	// Warning: This API should not be considered stable and might change soon.
 	Context() context.Context
+	// ConnectionStats returns a snapshot of the statistics of the session and of each of its paths.
+	ConnectionStats() ConnectionStats
 }
 
 // A NonFWSession is a QUIC connection between two peers half-way through the handshake.
@@ -123,6 +138,50 @@ type Config struct {
 	CacheHandshake bool
 	// Should the host try to create new paths, if possible?
 	CreatePaths bool
+	// PathCosts maps local IP addresses to the cost of sending a packet on the paths using them, e.g. to mark a cellular interface as metered.
+	// The costs are advertised to the peer once the handshake completes, so that its scheduler accounts them against its cost budget.
+	// A path on a socket listening on any address, like the initial path of a client, has no local IP to match.
+	PathCosts map[string]float64
+	// CostBudget is the total cost the host is willing to spend on paths with a cost. It is shared with the peer.
+	// If this value is zero, the budget is unlimited.
+	CostBudget float64
+	// PathsFrameInterval is the interval between two PATHS frames, which share the state and quality of the paths with the peer.
+	// If this value is zero, the interval is set to 200 ms.
+	PathsFrameInterval time.Duration
+	// Tracer receives structured events of the sessions, e.g. a qlog.Writer to record qlog traces.
+	// If not set, no events are traced.
+	Tracer qlog.Tracer
+	// DecisionRecorder receives the decisions of the batch schedulers and the outcomes of the packets, e.g. a schedlog.Writer.
+	// If DumpExperiences is set, it also receives the experiences of the DQN agent.
+	// If not set, nothing is recorded.
+	DecisionRecorder schedlog.Recorder
+	// PacketCapture receives the datagrams sent and received on the sockets of the sessions, e.g. a pcapng.Writer.
+	// The comments of the packets carry their path ID, deadline and the decision of the scheduler.
+	// If not set, nothing is captured.
+	PacketCapture pcapng.Capturer
+	// KeyLogWriter receives the keys of the sessions, so that a capture of their packets can be decrypted.
+	// Its lines are described in the documentation of the pcapng package.
+	// If not set, no keys are written.
+	KeyLogWriter io.Writer
+	// Observer is notified of the path lifecycle, cost budget and scheduler events of the sessions.
+	// If not set, nothing is notified.
+	Observer ConnectionObserver
+	// DeadlineMissRateThreshold is the deadline miss rate above which the Observer is notified.
+	// The rate is only checked once the peer reported 100 packets with a deadline.
+	// If this value is zero, the miss rate isn't watched.
+	DeadlineMissRateThreshold float64
+	// Network opens the sockets of the sessions and lists the interfaces the client creates paths on,
+	// e.g. a netem.Host to run sessions over an emulated network.
+	// If not set, the UDP sockets of the operating system are used.
+	Network PacketNetwork
+	// Clock is the clock of the sessions: their timestamps, timers and scheduler decisions follow it.
+	// Together with the Network, a netem.Simulation runs sessions in virtual time.
+	// If not set, the system clock is used.
+	Clock Clock
+	// RandSeed seeds the random decisions of the scheduler and its synthetic deadlines, to reproduce an experiment.
+	// Every session gets its own source seeded with it.
+	// If this value is zero, the session picks a seed, reported in its ConnectionStats.
+	RandSeed int64
 	//Arguments for agent
 	SchedulerName string
 	WeightsFile   string
@@ -132,6 +191,21 @@ type Config struct {
 	DumpExperiences		bool
 }
 
+// A PacketNetwork provides the sockets of the sessions
+type PacketNetwork interface {
+	// InterfaceIPs returns the IP addresses of the local interfaces paths can be created on
+	InterfaceIPs() ([]net.IP, error)
+	// ListenUDP opens a socket on a local address, port 0 selects a free port
+	ListenUDP(addr *net.UDPAddr) (net.PacketConn, error)
+}
+
+// A Clock tells the time of the sessions and runs their timers
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f after the duration, unless stop is called before. f must not block.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
 // A Listener for incoming QUIC connections
 type Listener interface {
 	// Close the server, sending CONNECTION_CLOSE frames to each peer.
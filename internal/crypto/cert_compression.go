@@ -148,7 +148,6 @@ func decompressChain(data []byte) ([][]byte, error) {
 	if hasCompressedCerts {
 		uncompressedLength, err := utils.LittleEndian.ReadUint32(r)
 		if err != nil {
-			fmt.Println(4)
 			return nil, err
 		}
 
@@ -22,6 +22,20 @@ import (
 
 // DeriveQuicCryptoAESKeys derives the client and server keys and creates a matching AES-GCM AEAD instance
 func DeriveQuicCryptoAESKeys(forwardSecure bool, sharedSecret, nonces []byte, connID protocol.ConnectionID, chlo []byte, scfg []byte, cert []byte, divNonce []byte, pers protocol.Perspective) (AEAD, error) {
+	return deriveQuicCryptoAESKeys(forwardSecure, sharedSecret, nonces, connID, chlo, scfg, cert, divNonce, pers, nil)
+}
+
+// DeriveQuicCryptoAESKeysWithKeyLog returns a key derivation function like DeriveQuicCryptoAESKeys, that also writes the derived keys to a key log.
+// Every derivation writes one line, with hex encoded values and the connection ID as in the logs:
+//
+//	<label> <connection ID> <client key> <client IV> <server key> <server IV>
+func DeriveQuicCryptoAESKeysWithKeyLog(keyLog io.Writer) func(forwardSecure bool, sharedSecret, nonces []byte, connID protocol.ConnectionID, chlo []byte, scfg []byte, cert []byte, divNonce []byte, pers protocol.Perspective) (AEAD, error) {
+	return func(forwardSecure bool, sharedSecret, nonces []byte, connID protocol.ConnectionID, chlo []byte, scfg []byte, cert []byte, divNonce []byte, pers protocol.Perspective) (AEAD, error) {
+		return deriveQuicCryptoAESKeys(forwardSecure, sharedSecret, nonces, connID, chlo, scfg, cert, divNonce, pers, keyLog)
+	}
+}
+
+func deriveQuicCryptoAESKeys(forwardSecure bool, sharedSecret, nonces []byte, connID protocol.ConnectionID, chlo []byte, scfg []byte, cert []byte, divNonce []byte, pers protocol.Perspective, keyLog io.Writer) (AEAD, error) {
 	var swap bool
 	if pers == protocol.PerspectiveClient {
 		swap = true
@@ -30,6 +44,15 @@ func DeriveQuicCryptoAESKeys(forwardSecure bool, sharedSecret, nonces []byte, co
 	if err != nil {
 		return nil, err
 	}
+	if keyLog != nil {
+		clientKey, clientIV, serverKey, serverIV := otherKey, otherIV, myKey, myIV
+		if swap {
+			clientKey, clientIV, serverKey, serverIV = myKey, myIV, otherKey, otherIV
+		}
+		if err := writeKeyLog(keyLog, forwardSecure, connID, clientKey, clientIV, serverKey, serverIV); err != nil {
+			return nil, err
+		}
+	}
 	return NewAEADAESGCM12(otherKey, myKey, otherIV, myIV)
 }
 
@@ -1,6 +1,9 @@
 package crypto
 
 import (
+	"bytes"
+	"strings"
+
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 
 	. "github.com/onsi/ginkgo"
@@ -193,5 +196,54 @@ var _ = Describe("QUIC Crypto Key Derivation", func() {
 			Expect(aesgcm.myIV).To(Equal([]byte{0x7, 0xad, 0xab, 0xb8}))
 			Expect(aesgcm.otherIV).To(Equal([]byte{0xf2, 0x7a, 0xcc, 0x42}))
 		})
+
+		It("writes the keys to the key log", func() {
+			keyLog := &bytes.Buffer{}
+			derive := DeriveQuicCryptoAESKeysWithKeyLog(keyLog)
+			for _, pers := range []protocol.Perspective{protocol.PerspectiveServer, protocol.PerspectiveClient} {
+				_, err := derive(
+					false,
+					[]byte("0123456789012345678901"),
+					[]byte("nonce"),
+					protocol.ConnectionID(42),
+					[]byte("chlo"),
+					[]byte("scfg"),
+					[]byte("cert"),
+					[]byte("divnonce"),
+					pers,
+				)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			lines := strings.Split(strings.TrimSuffix(keyLog.String(), "\n"), "\n")
+			Expect(lines).To(HaveLen(2))
+			// both sides log the same keys
+			Expect(lines[0]).To(Equal(lines[1]))
+			fields := strings.Fields(lines[0])
+			Expect(fields).To(HaveLen(6))
+			Expect(fields[0]).To(Equal(KeyLogLabelSecure))
+			Expect(fields[1]).To(Equal("2a"))
+			Expect(fields[2]).To(HaveLen(32))
+			Expect(fields[3]).To(Equal("64ef3c09"))
+			Expect(fields[5]).To(Equal("1cecac9b"))
+		})
+
+		It("labels forward secure keys in the key log", func() {
+			keyLog := &bytes.Buffer{}
+			_, err := DeriveQuicCryptoAESKeysWithKeyLog(keyLog)(
+				true,
+				[]byte("0123456789012345678901"),
+				[]byte("nonce"),
+				protocol.ConnectionID(42),
+				[]byte("chlo"),
+				[]byte("scfg"),
+				[]byte("cert"),
+				nil,
+				protocol.PerspectiveServer,
+			)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(keyLog.String()).To(HavePrefix(KeyLogLabelForwardSecure + " 2a "))
+			Expect(keyLog.String()).To(ContainSubstring(" f27acc42 "))
+			Expect(keyLog.String()).To(HaveSuffix(" 07adabb8\n"))
+		})
 	})
 })
@@ -0,0 +1,32 @@
+package crypto
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// The labels of the lines of the key log
+const (
+	KeyLogLabelSecure        = "QUIC_CRYPTO_SECURE"
+	KeyLogLabelForwardSecure = "QUIC_CRYPTO_FORWARD_SECURE"
+)
+
+// keyLogMutex serializes the lines of all the sessions sharing a key log
+var keyLogMutex sync.Mutex
+
+// writeKeyLog writes the line of the keys of an encryption level of a connection
+func writeKeyLog(w io.Writer, forwardSecure bool, connID protocol.ConnectionID, clientKey, clientIV, serverKey, serverIV []byte) error {
+	label := KeyLogLabelSecure
+	if forwardSecure {
+		label = KeyLogLabelForwardSecure
+	}
+	line := fmt.Sprintf("%s %x %x %x %x %x\n", label, connID, clientKey, clientIV, serverKey, serverIV)
+
+	keyLogMutex.Lock()
+	defer keyLogMutex.Unlock()
+	_, err := io.WriteString(w, line)
+	return err
+}
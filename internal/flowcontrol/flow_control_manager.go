@@ -17,6 +17,7 @@ type flowControlManager struct {
 	connectionParameters handshake.ConnectionParametersManager
 	rttStats             *congestion.RTTStats
 	remoteRTTs           map[protocol.PathID]time.Duration
+	clock                congestion.Clock
 
 	streamFlowController map[protocol.StreamID]*flowController
 	connFlowController   *flowController
@@ -27,14 +28,15 @@ var _ FlowControlManager = &flowControlManager{}
 
 var errMapAccess = errors.New("Error accessing the flowController map.")
 
-// NewFlowControlManager creates a new flow control manager
-func NewFlowControlManager(connectionParameters handshake.ConnectionParametersManager, rttStats *congestion.RTTStats, remoteRTTs map[protocol.PathID]time.Duration) FlowControlManager {
+// NewFlowControlManager creates a new flow control manager, the clock is the clock of the session
+func NewFlowControlManager(connectionParameters handshake.ConnectionParametersManager, rttStats *congestion.RTTStats, remoteRTTs map[protocol.PathID]time.Duration, clock congestion.Clock) FlowControlManager {
 	return &flowControlManager{
 		connectionParameters: connectionParameters,
 		rttStats:             rttStats,
 		remoteRTTs:           remoteRTTs,
+		clock:                clock,
 		streamFlowController: make(map[protocol.StreamID]*flowController),
-		connFlowController:   newFlowController(0, false, connectionParameters, rttStats, remoteRTTs),
+		connFlowController:   newFlowController(0, false, connectionParameters, rttStats, remoteRTTs, clock),
 	}
 }
 
@@ -48,7 +50,7 @@ func (f *flowControlManager) NewStream(streamID protocol.StreamID, contributesTo
 		return
 	}
 
-	f.streamFlowController[streamID] = newFlowController(streamID, contributesToConnection, f.connectionParameters, f.rttStats, f.remoteRTTs)
+	f.streamFlowController[streamID] = newFlowController(streamID, contributesToConnection, f.connectionParameters, f.rttStats, f.remoteRTTs, f.clock)
 }
 
 // RemoveStream removes a closed stream from flow control
@@ -20,7 +20,7 @@ var _ = Describe("Flow Control Manager", func() {
 		mockCpm.EXPECT().GetReceiveConnectionFlowControlWindow().AnyTimes().Return(protocol.ByteCount(200))
 		mockCpm.EXPECT().GetMaxReceiveStreamFlowControlWindow().AnyTimes().Return(protocol.MaxByteCount)
 		mockCpm.EXPECT().GetMaxReceiveConnectionFlowControlWindow().AnyTimes().Return(protocol.MaxByteCount)
-		fcm = NewFlowControlManager(mockCpm, &congestion.RTTStats{}, make(map[protocol.PathID]time.Duration)).(*flowControlManager)
+		fcm = NewFlowControlManager(mockCpm, &congestion.RTTStats{}, make(map[protocol.PathID]time.Duration), congestion.DefaultClock{}).(*flowControlManager)
 	})
 
 	It("creates a connection level flow controller", func() {
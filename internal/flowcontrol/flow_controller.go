@@ -17,6 +17,8 @@ type flowController struct {
 	connectionParameters handshake.ConnectionParametersManager
 	rttStats             *congestion.RTTStats
 	remoteRTTs           map[protocol.PathID]time.Duration
+	// clock times the window updates, for the auto-tuning of the window increment
+	clock congestion.Clock
 
 	bytesSent  protocol.ByteCount
 	sendWindow protocol.ByteCount
@@ -37,13 +39,14 @@ type flowController struct {
 var ErrReceivedSmallerByteOffset = errors.New("Received a smaller byte offset")
 
 // newFlowController gets a new flow controller
-func newFlowController(streamID protocol.StreamID, contributesToConnection bool, connectionParameters handshake.ConnectionParametersManager, rttStats *congestion.RTTStats, remoteRTTs map[protocol.PathID]time.Duration) *flowController {
+func newFlowController(streamID protocol.StreamID, contributesToConnection bool, connectionParameters handshake.ConnectionParametersManager, rttStats *congestion.RTTStats, remoteRTTs map[protocol.PathID]time.Duration, clock congestion.Clock) *flowController {
 	fc := flowController{
 		streamID:                streamID,
 		contributesToConnection: contributesToConnection,
 		connectionParameters:    connectionParameters,
 		rttStats:                rttStats,
 		remoteRTTs:              remoteRTTs,
+		clock:                   clock,
 	}
 
 	if streamID == 0 {
@@ -139,7 +142,7 @@ func (c *flowController) AddBytesRead(n protocol.ByteCount) {
 	// pretend we sent a WindowUpdate when reading the first byte
 	// this way auto-tuning of the window increment already works for the first WindowUpdate
 	if c.bytesRead == 0 {
-		c.lastWindowUpdateTime = time.Now()
+		c.lastWindowUpdateTime = c.clock.Now()
 	}
 	c.bytesRead += n
 }
@@ -160,7 +163,7 @@ func (c *flowController) MaybeUpdateWindow() (bool, protocol.ByteCount /* new in
 			newWindowIncrement = c.receiveWindowIncrement
 		}
 
-		c.lastWindowUpdateTime = time.Now()
+		c.lastWindowUpdateTime = c.clock.Now()
 		c.receiveWindow = c.bytesRead + c.receiveWindowIncrement
 		return true, newWindowIncrement, c.receiveWindow
 	}
@@ -179,7 +182,7 @@ func (c *flowController) maybeAdjustWindowIncrement() {
 		return
 	}
 
-	timeSinceLastWindowUpdate := time.Since(c.lastWindowUpdateTime)
+	timeSinceLastWindowUpdate := c.clock.Now().Sub(c.lastWindowUpdateTime)
 
 	var maxRemoteRTT time.Duration
 	for _, remoteRTT := range c.remoteRTTs {
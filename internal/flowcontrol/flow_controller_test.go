@@ -16,6 +16,7 @@ var _ = Describe("Flow controller", func() {
 	BeforeEach(func() {
 		controller = &flowController{}
 		controller.rttStats = &congestion.RTTStats{}
+		controller.clock = congestion.DefaultClock{}
 	})
 
 	Context("Constructor", func() {
@@ -34,33 +35,33 @@ var _ = Describe("Flow controller", func() {
 		})
 
 		It("reads the stream send and receive windows when acting as stream-level flow controller", func() {
-			fc := newFlowController(5, true, mockCpm, rttStats, make(map[protocol.PathID]time.Duration))
+			fc := newFlowController(5, true, mockCpm, rttStats, make(map[protocol.PathID]time.Duration), congestion.DefaultClock{})
 			Expect(fc.streamID).To(Equal(protocol.StreamID(5)))
 			Expect(fc.receiveWindow).To(Equal(protocol.ByteCount(2000)))
 			Expect(fc.maxReceiveWindowIncrement).To(Equal(mockCpm.GetMaxReceiveStreamFlowControlWindow()))
 		})
 
 		It("reads the stream send and receive windows when acting as connection-level flow controller", func() {
-			fc := newFlowController(0, false, mockCpm, rttStats, make(map[protocol.PathID]time.Duration))
+			fc := newFlowController(0, false, mockCpm, rttStats, make(map[protocol.PathID]time.Duration), congestion.DefaultClock{})
 			Expect(fc.streamID).To(Equal(protocol.StreamID(0)))
 			Expect(fc.receiveWindow).To(Equal(protocol.ByteCount(4000)))
 			Expect(fc.maxReceiveWindowIncrement).To(Equal(mockCpm.GetMaxReceiveConnectionFlowControlWindow()))
 		})
 
 		It("does not set the stream flow control windows for sending", func() {
-			fc := newFlowController(5, true, mockCpm, rttStats, make(map[protocol.PathID]time.Duration))
+			fc := newFlowController(5, true, mockCpm, rttStats, make(map[protocol.PathID]time.Duration), congestion.DefaultClock{})
 			Expect(fc.sendWindow).To(BeZero())
 		})
 
 		It("does not set the connection flow control windows for sending", func() {
-			fc := newFlowController(0, false, mockCpm, rttStats, make(map[protocol.PathID]time.Duration))
+			fc := newFlowController(0, false, mockCpm, rttStats, make(map[protocol.PathID]time.Duration), congestion.DefaultClock{})
 			Expect(fc.sendWindow).To(BeZero())
 		})
 
 		It("says if it contributes to connection-level flow control", func() {
-			fc := newFlowController(1, false, mockCpm, rttStats, make(map[protocol.PathID]time.Duration))
+			fc := newFlowController(1, false, mockCpm, rttStats, make(map[protocol.PathID]time.Duration), congestion.DefaultClock{})
 			Expect(fc.ContributesToConnection()).To(BeFalse())
-			fc = newFlowController(5, true, mockCpm, rttStats, make(map[protocol.PathID]time.Duration))
+			fc = newFlowController(5, true, mockCpm, rttStats, make(map[protocol.PathID]time.Duration), congestion.DefaultClock{})
 			Expect(fc.ContributesToConnection()).To(BeTrue())
 		})
 	})
@@ -124,6 +124,7 @@ var _ = Describe("Client Crypto Setup", func() {
 			aeadChanged,
 			&TransportParameters{},
 			nil,
+			nil,
 		)
 		Expect(err).ToNot(HaveOccurred())
 		cs = csInt.(*cryptoSetupClient)
@@ -74,12 +74,17 @@ func NewCryptoSetup(
 	supportedVersions []protocol.VersionNumber,
 	acceptSTK func(net.Addr, *Cookie) bool,
 	aeadChanged chan<- protocol.EncryptionLevel,
+	keyLogWriter io.Writer,
 ) (CryptoSetup, error) {
 	stkGenerator, err := NewCookieGenerator()
 	if err != nil {
 		return nil, err
 	}
 
+	keyDerivation := crypto.DeriveQuicCryptoAESKeys
+	if keyLogWriter != nil {
+		keyDerivation = crypto.DeriveQuicCryptoAESKeysWithKeyLog(keyLogWriter)
+	}
 	return &cryptoSetupServer{
 		connID:               connID,
 		remoteAddr:           remoteAddr,
@@ -87,7 +92,7 @@ func NewCryptoSetup(
 		supportedVersions:    supportedVersions,
 		scfg:                 scfg,
 		stkGenerator:         stkGenerator,
-		keyDerivation:        crypto.DeriveQuicCryptoAESKeys,
+		keyDerivation:        keyDerivation,
 		keyExchange:          getEphermalKEX,
 		nullAEAD:             crypto.NewNullAEAD(protocol.PerspectiveServer, version),
 		cryptoStream:         cryptoStream,
@@ -8,3 +8,14 @@ const (
 	PerspectiveServer Perspective = 1
 	PerspectiveClient Perspective = 2
 )
+
+func (p Perspective) String() string {
+	switch p {
+	case PerspectiveServer:
+		return "server"
+	case PerspectiveClient:
+		return "client"
+	default:
+		return "invalid perspective"
+	}
+}
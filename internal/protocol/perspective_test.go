@@ -0,0 +1,14 @@
+package protocol
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Perspective", func() {
+	It("has a string representation", func() {
+		Expect(PerspectiveClient.String()).To(Equal("client"))
+		Expect(PerspectiveServer.String()).To(Equal("server"))
+		Expect(Perspective(0).String()).To(Equal("invalid perspective"))
+	})
+})
@@ -119,6 +119,9 @@ const EphermalKeyLifetime = time.Minute
 // DefaultIdleTimeout is the default idle timeout
 const DefaultIdleTimeout = 30 * time.Second
 
+// DefaultPathsFrameInterval is the default interval between two PATHS frames
+const DefaultPathsFrameInterval = 200 * time.Millisecond
+
 // DefaultHandshakeTimeout is the default timeout for a connection until the crypto handshake succeeds.
 const DefaultHandshakeTimeout = 10 * time.Second
 
@@ -15,11 +15,13 @@ const (
 	VersionUnsupported VersionNumber = -1
 	VersionUnknown     VersionNumber = -2
 	VersionMP          VersionNumber = 512
+	VersionMPDeadline  VersionNumber = 513
 )
 
 // SupportedVersions lists the versions that the server supports
 // must be in sorted descending order
 var SupportedVersions = []VersionNumber{
+	VersionMPDeadline,
 	VersionMP,
 	Version39,
 	Version38,
@@ -31,6 +33,11 @@ func (vn VersionNumber) UsesTLS() bool {
 	return vn == VersionTLS
 }
 
+// UsesDeadlines says if this QUIC version carries the deadline information in the Public Header and the ACK frame
+func (vn VersionNumber) UsesDeadlines() bool {
+	return vn == VersionMPDeadline
+}
+
 func (vn VersionNumber) String() string {
 	switch vn {
 	case VersionWhatever:
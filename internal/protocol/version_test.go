@@ -13,6 +13,12 @@ var _ = Describe("Version", func() {
 		Expect(VersionTLS.UsesTLS()).To(BeTrue())
 	})
 
+	It("says if a version carries deadlines", func() {
+		Expect(Version39.UsesDeadlines()).To(BeFalse())
+		Expect(VersionMP.UsesDeadlines()).To(BeFalse())
+		Expect(VersionMPDeadline.UsesDeadlines()).To(BeTrue())
+	})
+
 	It("has the right string representation", func() {
 		Expect(Version37.String()).To(Equal("37"))
 		Expect(Version38.String()).To(Equal("38"))
@@ -31,6 +37,11 @@ var _ = Describe("Version", func() {
 		Expect(VersionNumberToTag(VersionNumber(123))).To(Equal(uint32('Q' + '1'<<8 + '2'<<16 + '3'<<24)))
 	})
 
+	It("converts the multipath versions to tags and back", func() {
+		Expect(VersionTagToNumber(VersionNumberToTag(VersionMP))).To(Equal(VersionMP))
+		Expect(VersionTagToNumber(VersionNumberToTag(VersionMPDeadline))).To(Equal(VersionMPDeadline))
+	})
+
 	It("recognizes supported versions", func() {
 		Expect(IsSupportedVersion(SupportedVersions, 0)).To(BeFalse())
 		Expect(IsSupportedVersion(SupportedVersions, SupportedVersions[0])).To(BeTrue())
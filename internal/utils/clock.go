@@ -0,0 +1,26 @@
+package utils
+
+import "time"
+
+// A Clock tells the time and runs functions after a duration.
+// The session uses it for all its timestamps and timers, so that a simulation can replace it with a virtual clock.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f after the duration, unless stop is called before. f must not block.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// SystemClock is the Clock of the Go stdlib
+type SystemClock struct{}
+
+var _ Clock = SystemClock{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc calls f after the duration
+func (SystemClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
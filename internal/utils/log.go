@@ -62,6 +62,61 @@ func Errorf(format string, args ...interface{}) {
 	}
 }
 
+// A Logger logs messages at the level set by SetLogLevel, prefixed with the context it was created for
+type Logger interface {
+	Debug() bool
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+	// WithPrefix returns a Logger that prefixes the messages with the prefix of this Logger and the given prefix
+	WithPrefix(prefix string) Logger
+}
+
+// DefaultLogger is the Logger without a prefix
+var DefaultLogger Logger = &defaultLogger{}
+
+type defaultLogger struct {
+	prefix string
+}
+
+var _ Logger = &defaultLogger{}
+
+func (l *defaultLogger) Debug() bool {
+	return Debug()
+}
+
+func (l *defaultLogger) Debugf(format string, args ...interface{}) {
+	if logLevel == LogLevelDebug {
+		l.logMessage(format, args...)
+	}
+}
+
+func (l *defaultLogger) Infof(format string, args ...interface{}) {
+	if logLevel >= LogLevelInfo {
+		l.logMessage(format, args...)
+	}
+}
+
+func (l *defaultLogger) Errorf(format string, args ...interface{}) {
+	if logLevel >= LogLevelError {
+		l.logMessage(format, args...)
+	}
+}
+
+func (l *defaultLogger) WithPrefix(prefix string) Logger {
+	if len(l.prefix) > 0 {
+		prefix = l.prefix + " " + prefix
+	}
+	return &defaultLogger{prefix: prefix}
+}
+
+func (l *defaultLogger) logMessage(format string, args ...interface{}) {
+	if len(l.prefix) > 0 {
+		format = l.prefix + " " + format
+	}
+	logMessage(format, args...)
+}
+
 func logMessage(format string, args ...interface{}) {
 	if len(timeFormat) > 0 {
 		log.Printf(time.Now().Format(timeFormat)+" "+format, args...)
@@ -97,6 +97,45 @@ var _ = Describe("Log", func() {
 		Expect(Debug()).To(BeTrue())
 	})
 
+	Context("Logger", func() {
+		It("doesn't log anything at the default level", func() {
+			l := DefaultLogger.WithPrefix("client")
+			l.Debugf("debug")
+			l.Infof("info")
+			l.Errorf("err")
+			Expect(b.Bytes()).To(BeEmpty())
+		})
+
+		It("logs with the log level", func() {
+			SetLogLevel(LogLevelInfo)
+			l := DefaultLogger
+			Expect(l.Debug()).To(BeFalse())
+			l.Debugf("debug")
+			l.Infof("info %d", 1)
+			Expect(b.String()).To(Equal("info 1\n"))
+			SetLogLevel(LogLevelDebug)
+			Expect(l.Debug()).To(BeTrue())
+		})
+
+		It("adds the prefixes", func() {
+			SetLogLevel(LogLevelDebug)
+			l := DefaultLogger.WithPrefix("client").WithPrefix("1337")
+			l.Debugf("debug %s", "foo")
+			l.Errorf("err")
+			Expect(b.String()).To(Equal("client 1337 debug foo\nclient 1337 err\n"))
+		})
+
+		It("adds the prefix after the timestamp", func() {
+			format := "Jan 2, 2006"
+			SetLogTimeFormat(format)
+			SetLogLevel(LogLevelInfo)
+			DefaultLogger.WithPrefix("server").Infof("info")
+			Expect(b.String()).To(HaveSuffix(" server info\n"))
+			_, err := time.Parse(format, b.String()[:b.Len()-len(" server info\n")])
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
 	Context("reading from env", func() {
 		BeforeEach(func() {
 			Expect(logLevel).To(Equal(LogLevelNothing))
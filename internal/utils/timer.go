@@ -1,43 +1,77 @@
 package utils
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // A Timer wrapper that behaves correctly when resetting
 type Timer struct {
-	t        *time.Timer
-	read     bool
-	deadline time.Time
+	clock Clock
+	c     chan time.Time
+
+	mutex sync.Mutex
+	stop  func() bool
+	// generation is incremented by every reset, so that a previous expiration that is already running doesn't fire
+	generation uint64
+	deadline   time.Time
 }
 
 // NewTimer creates a new timer that is not set
 func NewTimer() *Timer {
-	return &Timer{t: time.NewTimer(0)}
+	return NewClockTimer(SystemClock{})
+}
+
+// NewClockTimer creates a new timer running on a clock. Like NewTimer, it fires once right away.
+func NewClockTimer(clock Clock) *Timer {
+	t := &Timer{
+		clock: clock,
+		c:     make(chan time.Time, 1),
+	}
+	t.stop = clock.AfterFunc(0, t.expire(0))
+	return t
+}
+
+// expire returns the function sending the time on the channel for a generation of the timer
+func (t *Timer) expire(generation uint64) func() {
+	return func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		if generation != t.generation {
+			return
+		}
+		select {
+		case t.c <- t.clock.Now():
+		default:
+		}
+	}
 }
 
 // Chan returns the channel of the wrapped timer
 func (t *Timer) Chan() <-chan time.Time {
-	return t.t.C
+	return t.c
 }
 
 // Reset the timer, no matter whether the value was read or not
 func (t *Timer) Reset(deadline time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	if deadline.Equal(t.deadline) {
 		// No need to reset the timer
 		return
 	}
 
-	// We need to drain the timer if the value from its channel was not read yet.
-	// See https://groups.google.com/forum/#!topic/golang-dev/c9UUfASVPoU
-	if !t.t.Stop() && !t.read {
-		<-t.t.C
+	// Drain the channel if the value was not read yet
+	t.stop()
+	t.generation++
+	select {
+	case <-t.c:
+	default:
 	}
-	t.t.Reset(deadline.Sub(time.Now()))
-
-	t.read = false
+	t.stop = t.clock.AfterFunc(deadline.Sub(t.clock.Now()), t.expire(t.generation))
 	t.deadline = deadline
 }
 
-// SetRead should be called after the value from the chan was read
-func (t *Timer) SetRead() {
-	t.read = true
-}
+// SetRead should be called after the value from the chan was read.
+// The timer drains its channel when it is reset, so it only documents that the value was consumed.
+func (t *Timer) SetRead() {}
@@ -42,4 +42,55 @@ var _ = Describe("Timer", func() {
 		t.Reset(time.Now().Add(d))
 		Eventually(t.Chan()).Should(Receive())
 	})
+
+	It("runs on a clock", func() {
+		clock := &manualClock{now: time.Unix(1000, 0)}
+		t := NewClockTimer(clock)
+		clock.fire()
+		Eventually(t.Chan()).Should(Receive())
+		t.Reset(clock.now.Add(time.Hour))
+		t.Reset(clock.now.Add(time.Minute))
+		Expect(clock.timers).To(HaveLen(1))
+		Expect(clock.timers[0].d).To(Equal(time.Minute))
+		Consistently(t.Chan()).ShouldNot(Receive())
+		clock.now = clock.now.Add(time.Minute)
+		clock.fire()
+		Eventually(t.Chan()).Should(Receive(Equal(clock.now)))
+	})
 })
+
+// manualClock is a Clock whose timers fire when the test says so
+type manualClock struct {
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	d time.Duration
+	f func()
+}
+
+func (c *manualClock) Now() time.Time { return c.now }
+
+func (c *manualClock) AfterFunc(d time.Duration, f func()) func() bool {
+	t := &manualTimer{d: d, f: f}
+	c.timers = append(c.timers, t)
+	return func() bool {
+		for i, timer := range c.timers {
+			if timer == t {
+				c.timers = append(c.timers[:i], c.timers[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// fire fires the pending timers
+func (c *manualClock) fire() {
+	timers := c.timers
+	c.timers = nil
+	for _, t := range timers {
+		t.f()
+	}
+}
@@ -28,10 +28,9 @@ type AckFrame struct {
 	LowestAcked  protocol.PacketNumber
 	AckRanges    []AckRange // has to be ordered. The highest ACK range goes first, the lowest ACK range goes last
 
-	// time when the LargestAcked was receiveid
-	// this field Will not be set for received ACKs frames
-	PacketReceivedTime time.Time
-	DelayTime          time.Duration
+	// DelayTime is the time between the reception of the LargestAcked and the sending of the ACK,
+	// set by the receivedPacketHandler for the ACKs to send
+	DelayTime time.Duration
 
 	//czy:add meeting Deadline Information
 	NumMeetDeadline uint16
@@ -86,10 +85,24 @@ func ParseAckFrame(r *bytes.Reader, version protocol.VersionNumber) (*AckFrame,
 	frame.DelayTime = time.Duration(delay) * time.Microsecond
 
 	//czy:parse Deadline information in byte flow
-	frame.NumMeetDeadline, err = utils.GetByteOrder(version).ReadUint16(r)
-	frame.NumHasDeadline, err = utils.GetByteOrder(version).ReadUint16(r)
-	frame.CurNotSent, err = utils.GetByteOrder(version).ReadUint16(r)
-	frame.Alpha, err = utils.GetByteOrder(version).ReadUint16(r)
+	if version.UsesDeadlines() {
+		frame.NumMeetDeadline, err = utils.GetByteOrder(version).ReadUint16(r)
+		if err != nil {
+			return nil, err
+		}
+		frame.NumHasDeadline, err = utils.GetByteOrder(version).ReadUint16(r)
+		if err != nil {
+			return nil, err
+		}
+		frame.CurNotSent, err = utils.GetByteOrder(version).ReadUint16(r)
+		if err != nil {
+			return nil, err
+		}
+		frame.Alpha, err = utils.GetByteOrder(version).ReadUint16(r)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var numAckBlocks uint8
 	if hasMissingRanges {
@@ -251,14 +264,15 @@ func (f *AckFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error
 		utils.GetByteOrder(version).WriteUint48(b, uint64(f.LargestAcked)&(1<<48-1))
 	}
 
-	f.DelayTime = time.Since(f.PacketReceivedTime)
 	utils.GetByteOrder(version).WriteUfloat16(b, uint64(f.DelayTime/time.Microsecond))
 
 	//czy: write Deadline information in byte flow
-	utils.GetByteOrder(version).WriteUint16(b, uint16(f.NumMeetDeadline))
-	utils.GetByteOrder(version).WriteUint16(b, uint16(f.NumHasDeadline))
-	utils.GetByteOrder(version).WriteUint16(b, uint16(f.CurNotSent))
-	utils.GetByteOrder(version).WriteUint16(b, uint16(f.Alpha))
+	if version.UsesDeadlines() {
+		utils.GetByteOrder(version).WriteUint16(b, uint16(f.NumMeetDeadline))
+		utils.GetByteOrder(version).WriteUint16(b, uint16(f.NumHasDeadline))
+		utils.GetByteOrder(version).WriteUint16(b, uint16(f.CurNotSent))
+		utils.GetByteOrder(version).WriteUint16(b, uint16(f.Alpha))
+	}
 
 	var numRanges uint64
 	var numRangesWritten uint64
@@ -272,7 +286,10 @@ func (f *AckFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error
 
 	var firstAckBlockLength protocol.PacketNumber
 	if !f.HasMissingRanges() {
-		firstAckBlockLength = f.LargestAcked - f.LowestAcked + 1
+		// a frame with LargestAcked 0 doesn't ack any packet
+		if f.LargestAcked > 0 {
+			firstAckBlockLength = f.LargestAcked - f.LowestAcked + 1
+		}
 	} else {
 		if f.LargestAcked != f.AckRanges[0].Last {
 			return errInconsistentAckLargestAcked
@@ -366,8 +383,10 @@ func (f *AckFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error
 
 // MinLength of a written frame
 func (f *AckFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
-	length := protocol.ByteCount(1 + 2 + 9 + 1) // 1 TypeByte, 2 ACK delay time, 2*2=4 two uint16 deadline information, 1 Num Timestamp
-	// Deadline Information maybe is 4 bytes, but 4 bytes will error, and every large 4, e.g 5,6 is no error
+	length := protocol.ByteCount(1 + 2 + 1) // 1 TypeByte, 2 ACK delay time, 1 Num Timestamp
+	if version.UsesDeadlines() {
+		length += 4 * 2 // four uint16 deadline information
+	}
 	length += protocol.ByteCount(protocol.GetPacketNumberLength(f.LargestAcked))
 
 	missingSequenceNumberDeltaLen := protocol.ByteCount(f.getMissingSequenceNumberDeltaLen())
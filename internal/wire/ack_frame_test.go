@@ -28,6 +28,27 @@ var _ = Describe("AckFrame", func() {
 			Expect(b.Len()).To(BeZero())
 		})
 
+		It("parses the deadline information in versions with deadlines", func() {
+			b := bytes.NewReader([]byte{0x40,
+				0x1c,     // largest acked
+				0x0, 0x0, // delay time
+				0x0, 0x3, // num meet deadline
+				0x0, 0x4, // num has deadline
+				0x0, 0x5, // cur not sent
+				0x0, 0x6, // alpha
+				0x1c, // block length
+				0,
+			})
+			frame, err := ParseAckFrame(b, protocol.VersionMPDeadline)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.LargestAcked).To(Equal(protocol.PacketNumber(0x1c)))
+			Expect(frame.NumMeetDeadline).To(Equal(uint16(3)))
+			Expect(frame.NumHasDeadline).To(Equal(uint16(4)))
+			Expect(frame.CurNotSent).To(Equal(uint16(5)))
+			Expect(frame.Alpha).To(Equal(uint16(6)))
+			Expect(b.Len()).To(BeZero())
+		})
+
 		It("parses a frame where the largest acked is 0", func() {
 			b := bytes.NewReader([]byte{0x40,
 				0x0,      // largest acked
@@ -637,6 +658,28 @@ var _ = Describe("AckFrame", func() {
 			b = &bytes.Buffer{}
 		})
 
+		It("writes the deadline information in versions with deadlines", func() {
+			frameOrig := &AckFrame{
+				LargestAcked:    0x1337,
+				LowestAcked:     0x100,
+				NumMeetDeadline: 3,
+				NumHasDeadline:  4,
+				CurNotSent:      5,
+				Alpha:           6,
+			}
+			err := frameOrig.Write(b, protocol.VersionMPDeadline)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frameOrig.MinLength(protocol.VersionMPDeadline)).To(BeEquivalentTo(b.Len()))
+			r := bytes.NewReader(b.Bytes())
+			frame, err := ParseAckFrame(r, protocol.VersionMPDeadline)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.NumMeetDeadline).To(Equal(uint16(3)))
+			Expect(frame.NumHasDeadline).To(Equal(uint16(4)))
+			Expect(frame.CurNotSent).To(Equal(uint16(5)))
+			Expect(frame.Alpha).To(Equal(uint16(6)))
+			Expect(r.Len()).To(BeZero())
+		})
+
 		Context("self-consistency", func() {
 			for _, v := range []protocol.VersionNumber{versionLittleEndian, versionBigEndian} {
 				version := v
@@ -661,6 +704,18 @@ var _ = Describe("AckFrame", func() {
 						Expect(r.Len()).To(BeZero())
 					})
 
+					It("writes an ACK frame that doesn't ack any packet", func() {
+						frameOrig := &AckFrame{}
+						err := frameOrig.Write(b, version)
+						Expect(err).ToNot(HaveOccurred())
+						r := bytes.NewReader(b.Bytes())
+						frame, err := ParseAckFrame(r, version)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(frame.LargestAcked).To(BeZero())
+						Expect(frame.LowestAcked).To(BeZero())
+						Expect(r.Len()).To(BeZero())
+					})
+
 					It("writes the correct block length in a simple ACK frame", func() {
 						frameOrig := &AckFrame{
 							LargestAcked: 20,
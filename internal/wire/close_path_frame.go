@@ -63,7 +63,10 @@ func (f *ClosePathFrame) Write(b *bytes.Buffer, version protocol.VersionNumber)
 
 	var firstAckBlockLength protocol.PacketNumber
 	if !f.HasMissingRanges() {
-		firstAckBlockLength = f.LargestAcked - f.LowestAcked + 1
+		// a frame with LargestAcked 0 doesn't ack any packet
+		if f.LargestAcked > 0 {
+			firstAckBlockLength = f.LargestAcked - f.LowestAcked + 1
+		}
 	} else {
 		if f.LargestAcked != f.AckRanges[0].Last {
 			return errInconsistentAckLargestAcked
@@ -0,0 +1,220 @@
+package wire
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// The fuzz targets check that the parsers never panic, and that every frame or header they accept
+// is written back by Write to bytes that parse to the same value.
+// Run them with e.g. go test -run=^$ -fuzz=FuzzParsePublicHeader ./internal/wire
+
+// fuzzVersions are the versions used to parse and write the fuzzed data
+var fuzzVersions = []protocol.VersionNumber{versionLittleEndian, versionBigEndian, protocol.VersionMP, protocol.VersionMPDeadline}
+
+func fuzzVersion(v uint8) protocol.VersionNumber {
+	return fuzzVersions[int(v)%len(fuzzVersions)]
+}
+
+func writeFuzzSeed(f *testing.F, frame Frame) {
+	for v := range fuzzVersions {
+		b := &bytes.Buffer{}
+		if err := frame.Write(b, fuzzVersions[v]); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b.Bytes(), uint8(v))
+	}
+}
+
+func FuzzParsePublicHeader(f *testing.F) {
+	seeds := []struct {
+		hdr  PublicHeader
+		pers protocol.Perspective
+	}{
+		{PublicHeader{ConnectionID: 0x4cfa9f9b668619f6, PacketNumber: 0x1337, PacketNumberLen: protocol.PacketNumberLen2, Deadline: time.Unix(1500000000, 0)}, protocol.PerspectiveClient},
+		{PublicHeader{ConnectionID: 0x4cfa9f9b668619f6, VersionFlag: true, VersionNumber: versionBigEndian, PacketNumber: 1, PacketNumberLen: protocol.PacketNumberLen1}, protocol.PerspectiveClient},
+		{PublicHeader{ConnectionID: 0x4cfa9f9b668619f6, MultipathFlag: true, PathID: 3, PacketNumber: 0xdecafbad, PacketNumberLen: protocol.PacketNumberLen4, CurNotSent: 12, Alpha: 80}, protocol.PerspectiveClient},
+		{PublicHeader{ConnectionID: 0x4cfa9f9b668619f6, DiversificationNonce: bytes.Repeat([]byte{1}, 32), PacketNumber: 42, PacketNumberLen: protocol.PacketNumberLen6, Deadline: time.Unix(1500000000, 1234).In(time.FixedZone("", 3600))}, protocol.PerspectiveServer},
+		{PublicHeader{TruncateConnectionID: true, PacketNumber: 7, PacketNumberLen: protocol.PacketNumberLen1}, protocol.PerspectiveServer},
+		{PublicHeader{ConnectionID: 0x4cfa9f9b668619f6, ResetFlag: true}, protocol.PerspectiveServer},
+	}
+	for _, s := range seeds {
+		for v := range fuzzVersions {
+			b := &bytes.Buffer{}
+			if err := s.hdr.Write(b, fuzzVersions[v], s.pers); err != nil {
+				f.Fatal(err)
+			}
+			f.Add(b.Bytes(), s.pers == protocol.PerspectiveServer, uint8(v))
+		}
+	}
+	f.Add(ComposeVersionNegotiation(0x1337, protocol.SupportedVersions), true, uint8(0))
+
+	f.Fuzz(func(t *testing.T, data []byte, sentByServer bool, v uint8) {
+		pers := protocol.PerspectiveClient
+		if sentByServer {
+			pers = protocol.PerspectiveServer
+		}
+		version := fuzzVersion(v)
+		hdr, err := ParsePublicHeader(bytes.NewReader(data), pers, version)
+		if err != nil {
+			return
+		}
+		// there's no way to write a version negotiation packet, nor a packet with both the reset and the version flag
+		if hdr.VersionFlag && (hdr.ResetFlag || pers == protocol.PerspectiveServer) {
+			return
+		}
+		if hdr.VersionFlag {
+			// only version tags of the form Qxyz can be written back
+			if protocol.VersionTagToNumber(protocol.VersionNumberToTag(hdr.VersionNumber)) != hdr.VersionNumber {
+				return
+			}
+			// the version sent by the client determines the byte order of the packet number
+			version = hdr.VersionNumber
+		}
+
+		b := &bytes.Buffer{}
+		if err := hdr.Write(b, version, pers); err != nil {
+			t.Fatalf("failed to write a parsed header %#v: %s", hdr, err)
+		}
+		r := bytes.NewReader(b.Bytes())
+		hdr2, err := ParsePublicHeader(r, pers, version)
+		if err != nil {
+			t.Fatalf("failed to parse a written header %#v: %s", hdr, err)
+		}
+		if r.Len() != 0 {
+			t.Fatalf("%d bytes left after parsing a written header", r.Len())
+		}
+		if !hdr.Deadline.Equal(hdr2.Deadline) {
+			t.Fatalf("deadline changed from %s to %s", hdr.Deadline, hdr2.Deadline)
+		}
+		hdr.Deadline, hdr2.Deadline = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(hdr, hdr2) {
+			t.Fatalf("header changed from %#v to %#v", hdr, hdr2)
+		}
+	})
+}
+
+func FuzzParseAckFrame(f *testing.F) {
+	writeFuzzSeed(f, &AckFrame{LargestAcked: 1, LowestAcked: 1})
+	writeFuzzSeed(f, &AckFrame{PathID: 1, LargestAcked: 0x1337, LowestAcked: 0x100, NumMeetDeadline: 3, NumHasDeadline: 4, CurNotSent: 5, Alpha: 60})
+	writeFuzzSeed(f, &AckFrame{
+		LargestAcked: 1000,
+		LowestAcked:  1,
+		AckRanges: []AckRange{
+			{First: 980, Last: 1000},
+			{First: 400, Last: 500},
+			{First: 1, Last: 100},
+		},
+	})
+
+	f.Fuzz(func(t *testing.T, data []byte, v uint8) {
+		version := fuzzVersion(v)
+		frame, err := ParseAckFrame(bytes.NewReader(data), version)
+		if err != nil {
+			return
+		}
+		b := &bytes.Buffer{}
+		if err := frame.Write(b, version); err != nil {
+			t.Fatalf("failed to write a parsed frame %#v: %s", frame, err)
+		}
+		frame2, err := ParseAckFrame(bytes.NewReader(b.Bytes()), version)
+		if err != nil {
+			t.Fatalf("failed to parse a written frame %#v: %s", frame, err)
+		}
+		if !reflect.DeepEqual(frame, frame2) {
+			t.Fatalf("frame changed from %#v to %#v", frame, frame2)
+		}
+	})
+}
+
+func FuzzParsePathsFrame(f *testing.F) {
+	writeFuzzSeed(f, &PathsFrame{MaxNumPaths: 255})
+	writeFuzzSeed(f, &PathsFrame{
+		MaxNumPaths: 255,
+		NumPaths:    2,
+		PathIDs:     []protocol.PathID{1, 3},
+		RemoteRTTs:  []time.Duration{30 * time.Millisecond, time.Second},
+	})
+
+	f.Fuzz(func(t *testing.T, data []byte, v uint8) {
+		version := fuzzVersion(v)
+		frame, err := ParsePathsFrame(bytes.NewReader(data), version)
+		if err != nil {
+			return
+		}
+		b := &bytes.Buffer{}
+		if err := frame.Write(b, version); err != nil {
+			t.Fatalf("failed to write a parsed frame %#v: %s", frame, err)
+		}
+		frame2, err := ParsePathsFrame(bytes.NewReader(b.Bytes()), version)
+		if err != nil {
+			t.Fatalf("failed to parse a written frame %#v: %s", frame, err)
+		}
+		if !reflect.DeepEqual(frame, frame2) {
+			t.Fatalf("frame changed from %#v to %#v", frame, frame2)
+		}
+	})
+}
+
+func FuzzParseAddAddressFrame(f *testing.F) {
+	writeFuzzSeed(f, &AddAddressFrame{IPVersion: 4, Addr: net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4242}})
+	writeFuzzSeed(f, &AddAddressFrame{IPVersion: 6, Addr: net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 443}})
+
+	f.Fuzz(func(t *testing.T, data []byte, v uint8) {
+		version := fuzzVersion(v)
+		frame, err := ParseAddAddressFrame(bytes.NewReader(data), version)
+		if err != nil {
+			return
+		}
+		b := &bytes.Buffer{}
+		if err := frame.Write(b, version); err != nil {
+			t.Fatalf("failed to write a parsed frame %#v: %s", frame, err)
+		}
+		frame2, err := ParseAddAddressFrame(bytes.NewReader(b.Bytes()), version)
+		if err != nil {
+			t.Fatalf("failed to parse a written frame %#v: %s", frame, err)
+		}
+		if frame.IPVersion != frame2.IPVersion || !frame.Addr.IP.Equal(frame2.Addr.IP) || frame.Addr.Port != frame2.Addr.Port {
+			t.Fatalf("frame changed from %#v to %#v", frame, frame2)
+		}
+	})
+}
+
+func FuzzParseClosePathFrame(f *testing.F) {
+	writeFuzzSeed(f, &ClosePathFrame{PathID: 1, LargestAcked: 1, LowestAcked: 1})
+	writeFuzzSeed(f, &ClosePathFrame{PathID: 3, LargestAcked: 0x1337, LowestAcked: 0x100})
+	writeFuzzSeed(f, &ClosePathFrame{
+		PathID:       5,
+		LargestAcked: 1000,
+		LowestAcked:  1,
+		AckRanges: []AckRange{
+			{First: 980, Last: 1000},
+			{First: 400, Last: 500},
+			{First: 1, Last: 100},
+		},
+	})
+
+	f.Fuzz(func(t *testing.T, data []byte, v uint8) {
+		version := fuzzVersion(v)
+		frame, err := ParseClosePathFrame(bytes.NewReader(data), version)
+		if err != nil {
+			return
+		}
+		b := &bytes.Buffer{}
+		if err := frame.Write(b, version); err != nil {
+			t.Fatalf("failed to write a parsed frame %#v: %s", frame, err)
+		}
+		frame2, err := ParseClosePathFrame(bytes.NewReader(b.Bytes()), version)
+		if err != nil {
+			t.Fatalf("failed to parse a written frame %#v: %s", frame, err)
+		}
+		if !reflect.DeepEqual(frame, frame2) {
+			t.Fatalf("frame changed from %#v to %#v", frame, frame2)
+		}
+	})
+}
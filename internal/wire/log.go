@@ -26,6 +26,10 @@ func LogFrame(frame Frame, sent bool) {
 		utils.Debugf("\t%s &wire.AddAddressFrame{IPVersion: %d, Addr: %s}", dir, f.IPVersion, f.Addr.String())
 	case *ClosePathFrame:
 		utils.Debugf("\t%s &wire.ClosePathFrame{PathID: 0x%x, LargestAcked: 0x%x, LowestAcked: 0x%x, AckRanges: %#v}", dir, f.PathID, f.LargestAcked, f.LowestAcked, f.AckRanges)
+	case *PathCostFrame:
+		utils.Debugf("\t%s &wire.PathCostFrame{PathIDs: %v, Costs: %v}", dir, f.PathIDs, f.Costs)
+	case *PathQualityFrame:
+		utils.Debugf("\t%s &wire.PathQualityFrame{Paths: %+v}", dir, f.Paths)
 	default:
 		utils.Debugf("\t%s %#v", dir, frame)
 	}
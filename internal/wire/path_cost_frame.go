@@ -0,0 +1,83 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"math"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+var (
+	ErrPathCostsNumber = errors.New("PathCostFrame: number of path IDs and number of costs do not match")
+	ErrInvalidPathCost = errors.New("PathCostFrame: invalid cost")
+)
+
+// costUnit is the resolution of the costs on the wire
+const costUnit = 1000
+
+// A PathCostFrame in QUIC
+// It advertises the cost of sending a packet on the paths of the sender, e.g. because one of them is metered
+type PathCostFrame struct {
+	PathIDs []protocol.PathID
+	Costs   []float64
+}
+
+func (f *PathCostFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error {
+	if len(f.PathIDs) != len(f.Costs) {
+		return ErrPathCostsNumber
+	}
+	if len(f.PathIDs) > math.MaxUint8 {
+		return ErrTooManyPaths
+	}
+
+	typeByte := uint8(0x13)
+	b.WriteByte(typeByte)
+	b.WriteByte(uint8(len(f.PathIDs)))
+
+	for i := 0; i < len(f.PathIDs); i++ {
+		cost := f.Costs[i] * costUnit
+		if cost < 0 || cost > math.MaxUint32 || math.IsNaN(cost) {
+			return ErrInvalidPathCost
+		}
+		b.WriteByte(uint8(f.PathIDs[i]))
+		utils.GetByteOrder(version).WriteUint32(b, uint32(math.Round(cost)))
+	}
+
+	return nil
+}
+
+func ParsePathCostFrame(r *bytes.Reader, version protocol.VersionNumber) (*PathCostFrame, error) {
+	frame := &PathCostFrame{}
+
+	// read the TypeByte
+	_, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	num, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < int(num); i++ {
+		pathID, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		cost, err := utils.GetByteOrder(version).ReadUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		frame.PathIDs = append(frame.PathIDs, protocol.PathID(pathID))
+		frame.Costs = append(frame.Costs, float64(cost)/costUnit)
+	}
+
+	return frame, nil
+}
+
+func (f *PathCostFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
+	return protocol.ByteCount(1 + 1 + 5*len(f.PathIDs)), nil
+}
@@ -0,0 +1,100 @@
+package wire
+
+import (
+	"bytes"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PathCostFrame", func() {
+	Context("when parsing", func() {
+		It("accepts a sample frame", func() {
+			b := bytes.NewReader([]byte{0x13, 0x2,
+				0x1, 0x0, 0x0, 0x7, 0xd0, // path 1, cost 2.0
+				0x3, 0x0, 0x0, 0x0, 0xc8, // path 3, cost 0.2
+			})
+			frame, err := ParsePathCostFrame(b, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.PathIDs).To(Equal([]protocol.PathID{1, 3}))
+			Expect(frame.Costs).To(Equal([]float64{2.0, 0.2}))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("accepts a frame without paths", func() {
+			b := bytes.NewReader([]byte{0x13, 0x0})
+			frame, err := ParsePathCostFrame(b, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.PathIDs).To(BeEmpty())
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("errors on EOFs", func() {
+			data := []byte{0x13, 0x1, 0x1, 0x0, 0x0, 0x7, 0xd0}
+			_, err := ParsePathCostFrame(bytes.NewReader(data), versionBigEndian)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParsePathCostFrame(bytes.NewReader(data[0:i]), versionBigEndian)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := &PathCostFrame{
+				PathIDs: []protocol.PathID{1, 3},
+				Costs:   []float64{2.0, 0.2},
+			}
+			err := frame.Write(b, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b.Bytes()).To(Equal([]byte{0x13, 0x2,
+				0x1, 0x0, 0x0, 0x7, 0xd0,
+				0x3, 0x0, 0x0, 0x0, 0xc8,
+			}))
+		})
+
+		It("is self-consistent", func() {
+			b := &bytes.Buffer{}
+			frameOrig := &PathCostFrame{
+				PathIDs: []protocol.PathID{5},
+				Costs:   []float64{1.234},
+			}
+			err := frameOrig.Write(b, versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			frame, err := ParsePathCostFrame(bytes.NewReader(b.Bytes()), versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(frameOrig))
+		})
+
+		It("refuses to write a frame with a missing cost", func() {
+			frame := &PathCostFrame{
+				PathIDs: []protocol.PathID{1, 3},
+				Costs:   []float64{2.0},
+			}
+			err := frame.Write(&bytes.Buffer{}, versionBigEndian)
+			Expect(err).To(MatchError(ErrPathCostsNumber))
+		})
+
+		It("refuses to write a negative cost", func() {
+			frame := &PathCostFrame{
+				PathIDs: []protocol.PathID{1},
+				Costs:   []float64{-1},
+			}
+			err := frame.Write(&bytes.Buffer{}, versionBigEndian)
+			Expect(err).To(MatchError(ErrInvalidPathCost))
+		})
+
+		It("has the correct min length", func() {
+			b := &bytes.Buffer{}
+			frame := &PathCostFrame{
+				PathIDs: []protocol.PathID{1, 3},
+				Costs:   []float64{2.0, 0.2},
+			}
+			Expect(frame.Write(b, versionBigEndian)).To(Succeed())
+			Expect(frame.MinLength(versionBigEndian)).To(Equal(protocol.ByteCount(b.Len())))
+		})
+	})
+})
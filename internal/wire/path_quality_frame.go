@@ -0,0 +1,129 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+var (
+	ErrInvalidPathQuality = errors.New("PathQualityFrame: invalid path quality")
+)
+
+// ratios are sent as a fraction of maxRatio
+const maxRatio = math.MaxUint16
+
+// unlimitedBudget is the wire representation of an unlimited cost budget
+const unlimitedBudget = math.MaxUint32
+
+// PathQuality is the view an endpoint has of one of its paths
+type PathQuality struct {
+	PathID protocol.PathID
+	// OneWayDelay is the estimated one way delay of the path
+	OneWayDelay time.Duration
+	// LossRate is the fraction of the packets sent on the path that were lost
+	LossRate float64
+	// MeetRatio is the fraction of the packets received with a deadline on the path that met it
+	MeetRatio float64
+	// Budget is the cost budget the endpoint is still willing to spend on the path, +Inf if unlimited
+	Budget float64
+}
+
+// A PathQualityFrame in QUIC
+// It is sent alongside the PathsFrame, to share the quality of each path with the peer
+type PathQualityFrame struct {
+	Paths []PathQuality
+}
+
+func (f *PathQualityFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error {
+	if len(f.Paths) > math.MaxUint8 {
+		return ErrTooManyPaths
+	}
+	for _, q := range f.Paths {
+		if !validRatio(q.LossRate) || !validRatio(q.MeetRatio) || q.Budget < 0 || math.IsNaN(q.Budget) {
+			return ErrInvalidPathQuality
+		}
+	}
+
+	typeByte := uint8(0x14)
+	b.WriteByte(typeByte)
+	b.WriteByte(uint8(len(f.Paths)))
+
+	for _, q := range f.Paths {
+		b.WriteByte(uint8(q.PathID))
+		utils.GetByteOrder(version).WriteUfloat16(b, uint64(q.OneWayDelay/time.Microsecond))
+		utils.GetByteOrder(version).WriteUint16(b, uint16(math.Round(q.LossRate*maxRatio)))
+		utils.GetByteOrder(version).WriteUint16(b, uint16(math.Round(q.MeetRatio*maxRatio)))
+		budget := q.Budget * costUnit
+		if budget >= unlimitedBudget {
+			utils.GetByteOrder(version).WriteUint32(b, unlimitedBudget)
+		} else {
+			utils.GetByteOrder(version).WriteUint32(b, uint32(math.Round(budget)))
+		}
+	}
+
+	return nil
+}
+
+func ParsePathQualityFrame(r *bytes.Reader, version protocol.VersionNumber) (*PathQualityFrame, error) {
+	frame := &PathQualityFrame{}
+
+	// read the TypeByte
+	_, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	num, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < int(num); i++ {
+		q := PathQuality{}
+		pathID, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		q.PathID = protocol.PathID(pathID)
+		oneWayDelay, err := utils.GetByteOrder(version).ReadUfloat16(r)
+		if err != nil {
+			return nil, err
+		}
+		q.OneWayDelay = time.Duration(oneWayDelay) * time.Microsecond
+		lossRate, err := utils.GetByteOrder(version).ReadUint16(r)
+		if err != nil {
+			return nil, err
+		}
+		q.LossRate = float64(lossRate) / maxRatio
+		meetRatio, err := utils.GetByteOrder(version).ReadUint16(r)
+		if err != nil {
+			return nil, err
+		}
+		q.MeetRatio = float64(meetRatio) / maxRatio
+		budget, err := utils.GetByteOrder(version).ReadUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		if budget == unlimitedBudget {
+			q.Budget = math.Inf(1)
+		} else {
+			q.Budget = float64(budget) / costUnit
+		}
+		frame.Paths = append(frame.Paths, q)
+	}
+
+	return frame, nil
+}
+
+func (f *PathQualityFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
+	return protocol.ByteCount(1 + 1 + 11*len(f.Paths)), nil
+}
+
+func validRatio(r float64) bool {
+	return r >= 0 && r <= 1
+}
@@ -0,0 +1,100 @@
+package wire
+
+import (
+	"bytes"
+	"math"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PathQualityFrame", func() {
+	Context("when parsing", func() {
+		It("accepts a sample frame", func() {
+			b := bytes.NewReader([]byte{0x14, 0x1,
+				0x3,       // path ID
+				0x3, 0xe8, // one way delay: 1000 us
+				0x0, 0x0, // loss rate
+				0xff, 0xff, // meet ratio
+				0x0, 0x0, 0xf, 0xa0, // budget: 4.0
+			})
+			frame, err := ParsePathQualityFrame(b, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Paths).To(Equal([]PathQuality{{
+				PathID:      3,
+				OneWayDelay: time.Millisecond,
+				LossRate:    0,
+				MeetRatio:   1,
+				Budget:      4,
+			}}))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("parses an unlimited budget", func() {
+			b := bytes.NewReader([]byte{0x14, 0x1, 0x1, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xff, 0xff, 0xff, 0xff})
+			frame, err := ParsePathQualityFrame(b, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(math.IsInf(frame.Paths[0].Budget, 1)).To(BeTrue())
+		})
+
+		It("errors on EOFs", func() {
+			data := []byte{0x14, 0x1, 0x3, 0x3, 0xe8, 0x0, 0x0, 0xff, 0xff, 0x0, 0x0, 0xf, 0xa0}
+			_, err := ParsePathQualityFrame(bytes.NewReader(data), versionBigEndian)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParsePathQualityFrame(bytes.NewReader(data[0:i]), versionBigEndian)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("is self-consistent", func() {
+			b := &bytes.Buffer{}
+			frameOrig := &PathQualityFrame{Paths: []PathQuality{
+				{PathID: 1, OneWayDelay: 25 * time.Millisecond, LossRate: 0.5, MeetRatio: 0.25, Budget: 1.5},
+				{PathID: 3, OneWayDelay: 5 * time.Millisecond, LossRate: 0, MeetRatio: 1, Budget: math.Inf(1)},
+			}}
+			err := frameOrig.Write(b, versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			frame, err := ParsePathQualityFrame(bytes.NewReader(b.Bytes()), versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Paths).To(HaveLen(2))
+			Expect(frame.Paths[0].PathID).To(Equal(protocol.PathID(1)))
+			Expect(frame.Paths[0].OneWayDelay).To(Equal(25 * time.Millisecond))
+			Expect(frame.Paths[0].LossRate).To(BeNumerically("~", 0.5, 0.0001))
+			Expect(frame.Paths[0].MeetRatio).To(BeNumerically("~", 0.25, 0.0001))
+			Expect(frame.Paths[0].Budget).To(Equal(1.5))
+			Expect(frame.Paths[1]).To(Equal(frameOrig.Paths[1]))
+		})
+
+		It("refuses to write an invalid ratio", func() {
+			frame := &PathQualityFrame{Paths: []PathQuality{{PathID: 1, LossRate: 1.5}}}
+			err := frame.Write(&bytes.Buffer{}, versionBigEndian)
+			Expect(err).To(MatchError(ErrInvalidPathQuality))
+		})
+
+		It("doesn't write anything for an invalid path", func() {
+			b := &bytes.Buffer{}
+			frame := &PathQualityFrame{Paths: []PathQuality{{PathID: 1}, {PathID: 3, MeetRatio: -0.5}}}
+			err := frame.Write(b, versionBigEndian)
+			Expect(err).To(MatchError(ErrInvalidPathQuality))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("refuses to write a negative budget", func() {
+			frame := &PathQualityFrame{Paths: []PathQuality{{PathID: 1, Budget: -1}}}
+			err := frame.Write(&bytes.Buffer{}, versionBigEndian)
+			Expect(err).To(MatchError(ErrInvalidPathQuality))
+		})
+
+		It("has the correct min length", func() {
+			b := &bytes.Buffer{}
+			frame := &PathQualityFrame{Paths: []PathQuality{{PathID: 1}, {PathID: 3}}}
+			Expect(frame.Write(b, versionBigEndian)).To(Succeed())
+			Expect(frame.MinLength(versionBigEndian)).To(Equal(protocol.ByteCount(b.Len())))
+		})
+	})
+})
@@ -3,7 +3,6 @@ package wire
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"io"
 	"time"
 
@@ -20,8 +19,13 @@ var (
 	errReceivedTruncatedConnectionID     = qerr.Error(qerr.InvalidPacketHeader, "receiving packets with truncated ConnectionID is not supported")
 	errInvalidConnectionID               = qerr.Error(qerr.InvalidPacketHeader, "connection ID cannot be 0")
 	errGetLengthNotForVersionNegotiation = errors.New("PublicHeader: GetLength cannot be called for VersionNegotiation packets")
+	errInvalidDeadline                   = qerr.Error(qerr.InvalidPacketHeader, "invalid deadline")
+	errPacketHeaderTooShort              = qerr.Error(qerr.InvalidPacketHeader, "packet header too short")
 )
 
+// deadlineLen is the length of a deadline encoded with time.MarshalBinary
+const deadlineLen = 15
+
 // The PublicHeader of a QUIC packet. Warning: This struct should not be considered stable and will change soon.
 type PublicHeader struct {
 	Raw                  []byte
@@ -122,15 +126,19 @@ func (h *PublicHeader) Write(b *bytes.Buffer, version protocol.VersionNumber, pe
 		return errors.New("PublicHeader: PacketNumberLen not set")
 	}
 
+	if !version.UsesDeadlines() {
+		return nil
+	}
+
 	//czy:deadline
-	//fmt.Println("Before add deadline:", b.Bytes())
 	deadlineTimeBytes, err := h.Deadline.MarshalBinary()
 	if err != nil {
-		fmt.Println("Error writing time to buffer:", err)
-		return nil
+		return err
+	}
+	if len(deadlineTimeBytes) != deadlineLen {
+		return errInvalidDeadline
 	}
-	_, err = b.Write(deadlineTimeBytes)
-	//fmt.Println("After add deadline:", b.Bytes())
+	b.Write(deadlineTimeBytes)
 
 	// write curNotSent uint16
 	b.WriteByte(h.CurNotSent)
@@ -145,7 +153,7 @@ func PeekConnectionID(b *bytes.Reader, packetSentBy protocol.Perspective) (proto
 	var connectionID protocol.ConnectionID
 	publicFlagByte, err := b.ReadByte()
 	if err != nil {
-		return 0, err
+		return 0, errPacketHeaderTooShort
 	}
 	// unread the public flag byte
 	defer b.UnreadByte()
@@ -157,7 +165,7 @@ func PeekConnectionID(b *bytes.Reader, packetSentBy protocol.Perspective) (proto
 	if !truncateConnectionID {
 		connID, err := utils.LittleEndian.ReadUint64(b)
 		if err != nil {
-			return 0, err
+			return 0, errPacketHeaderTooShort
 		}
 		connectionID = protocol.ConnectionID(connID)
 		// unread the connection ID
@@ -181,7 +189,7 @@ func ParsePublicHeader(b *bytes.Reader, packetSentBy protocol.Perspective, versi
 	//fmt.Println("After read Flag, Reader.Len():", b.Len())
 
 	if err != nil {
-		return nil, err
+		return nil, errPacketHeaderTooShort
 	}
 	header.ResetFlag = publicFlagByte&0x02 > 0
 	header.VersionFlag = publicFlagByte&0x01 > 0
@@ -221,7 +229,7 @@ func ParsePublicHeader(b *bytes.Reader, packetSentBy protocol.Perspective, versi
 		// always write the connection ID in little endian
 		connID, err = utils.LittleEndian.ReadUint64(b)
 		if err != nil {
-			return nil, err
+			return nil, errPacketHeaderTooShort
 		}
 		header.ConnectionID = protocol.ConnectionID(connID)
 		if header.ConnectionID == 0 {
@@ -238,7 +246,7 @@ func ParsePublicHeader(b *bytes.Reader, packetSentBy protocol.Perspective, versi
 		if !header.VersionFlag && !header.ResetFlag {
 			header.DiversificationNonce = make([]byte, 32)
 			if _, err := io.ReadFull(b, header.DiversificationNonce); err != nil {
-				return nil, err
+				return nil, errPacketHeaderTooShort
 			}
 		}
 	}
@@ -267,7 +275,7 @@ func ParsePublicHeader(b *bytes.Reader, packetSentBy protocol.Perspective, versi
 		var versionTag uint32
 		versionTag, err = utils.LittleEndian.ReadUint32(b)
 		if err != nil {
-			return nil, err
+			return nil, errPacketHeaderTooShort
 		}
 		header.VersionNumber = protocol.VersionTagToNumber(versionTag)
 		version = header.VersionNumber
@@ -277,7 +285,7 @@ func ParsePublicHeader(b *bytes.Reader, packetSentBy protocol.Perspective, versi
 	if header.MultipathFlag {
 		pathID, err := b.ReadByte()
 		if err != nil {
-			return nil, err
+			return nil, errPacketHeaderTooShort
 		}
 		header.PathID = protocol.PathID(pathID)
 	} else {
@@ -290,32 +298,37 @@ func ParsePublicHeader(b *bytes.Reader, packetSentBy protocol.Perspective, versi
 	if header.hasPacketNumber(packetSentBy) {
 		packetNumber, err := utils.GetByteOrder(version).ReadUintN(b, uint8(header.PacketNumberLen))
 		if err != nil {
-			return nil, err
+			return nil, errPacketHeaderTooShort
 		}
 		header.PacketNumber = protocol.PacketNumber(packetNumber)
 	}
 	//fmt.Println("After read packetNumber, Reader.Len():", b.Len())
-	//fmt.Println("In ParsePublicHeader, header.pathID:", header.PathID)
-	//fmt.Println("header.MultipathFlag:", header.MultipathFlag)
-	//fmt.Println("header.PacketNumber:", header.PacketNumber)
-	// parse deadline
-	//And deadline length maybe always 15 bytes
-	deadlineBytesToRead := make([]byte, 15)
-	_, err = b.Read(deadlineBytesToRead)
-	header.Deadline.UnmarshalBinary(deadlineBytesToRead)
-
-	// parse curNotSent and Alpha
+
+	// Deadline, curNotSent and Alpha are only present in packets that have a packet number
+	if !header.hasPacketNumber(packetSentBy) || !version.UsesDeadlines() {
+		return header, nil
+	}
+	deadlineBytes := make([]byte, deadlineLen)
+	if _, err = io.ReadFull(b, deadlineBytes); err != nil {
+		return nil, errPacketHeaderTooShort
+	}
+	if err = header.Deadline.UnmarshalBinary(deadlineBytes); err != nil {
+		return nil, errInvalidDeadline
+	}
 	header.CurNotSent, err = b.ReadByte()
-	fmt.Println("Parse curNotSent:", header.CurNotSent)
+	if err != nil {
+		return nil, errPacketHeaderTooShort
+	}
 	header.Alpha, err = b.ReadByte()
-	fmt.Println("Parse ALpha:", header.Alpha)
-	//fmt.Println("Parse deadline:", header.Deadline)
+	if err != nil {
+		return nil, errPacketHeaderTooShort
+	}
 	return header, nil
 }
 
 // GetLength gets the length of the publicHeader in bytes.
 // It can only be called for regular packets.
-func (h *PublicHeader) GetLength(pers protocol.Perspective) (protocol.ByteCount, error) {
+func (h *PublicHeader) GetLength(version protocol.VersionNumber, pers protocol.Perspective) (protocol.ByteCount, error) {
 	if h.VersionFlag && h.ResetFlag {
 		return 0, errResetAndVersionFlagSet
 	}
@@ -331,6 +344,11 @@ func (h *PublicHeader) GetLength(pers protocol.Perspective) (protocol.ByteCount,
 			return 0, errPacketNumberLenNotSet
 		}
 		length += protocol.ByteCount(h.PacketNumberLen)
+		if version.UsesDeadlines() {
+			length += deadlineLen // deadline
+			length += 1           // One byte for uint8 curNotSent
+			length += 1           // One byte for uint8 alpha
+		}
 	}
 
 	if !h.TruncateConnectionID {
@@ -348,9 +366,6 @@ func (h *PublicHeader) GetLength(pers protocol.Perspective) (protocol.ByteCount,
 	if h.MultipathFlag {
 		length += 1
 	}
-	length += 15 //Default:15 for deadline
-	length += 1  // One byte for uint8 curNotSent
-	length += 1  // One byte for uint8 alpha
 
 	return length, nil
 }
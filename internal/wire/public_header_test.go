@@ -3,6 +3,7 @@ package wire
 import (
 	"bytes"
 	"encoding/binary"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
@@ -31,7 +32,7 @@ var _ = Describe("Public Header", func() {
 		It("errors if the Public Header is too short", func() {
 			b := bytes.NewReader([]byte{0x09, 0xf6, 0x19, 0x86, 0x66, 0x9b})
 			_, err := PeekConnectionID(b, protocol.PerspectiveClient)
-			Expect(err).To(HaveOccurred())
+			Expect(err).To(MatchError(errPacketHeaderTooShort))
 		})
 
 		It("errors if the Public Header is empty", func() {
@@ -79,6 +80,23 @@ var _ = Describe("Public Header", func() {
 			Expect(b.Len()).To(BeZero())
 		})
 
+		It("errors on a truncated header", func() {
+			data := []byte{0x49, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c, 0x51, 0x30, 0x33, 0x34, 0x05, 0x01}
+			_, err := ParsePublicHeader(bytes.NewReader(data), protocol.PerspectiveClient, protocol.VersionUnknown)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < len(data); i++ {
+				_, err := ParsePublicHeader(bytes.NewReader(data[:i]), protocol.PerspectiveClient, protocol.VersionUnknown)
+				Expect(err).To(MatchError(errPacketHeaderTooShort))
+				Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.InvalidPacketHeader))
+			}
+		})
+
+		It("errors on a truncated diversification nonce", func() {
+			data := append([]byte{0x0c, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c}, make([]byte, 31)...)
+			_, err := ParsePublicHeader(bytes.NewReader(data), protocol.PerspectiveServer, protocol.VersionWhatever)
+			Expect(err).To(MatchError(errPacketHeaderTooShort))
+		})
+
 		It("rejects 0 as a connection ID", func() {
 			b := bytes.NewReader([]byte{0x09, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x51, 0x30, 0x33, 0x30, 0x01})
 			_, err := ParsePublicHeader(b, protocol.PerspectiveClient, protocol.VersionUnknown)
@@ -113,6 +131,68 @@ var _ = Describe("Public Header", func() {
 			Expect(b.Len()).To(BeZero())
 		})
 
+		Context("in a version with deadlines", func() {
+			version := protocol.VersionMPDeadline
+			var extendedFields []byte
+
+			BeforeEach(func() {
+				deadline, err := time.Unix(1500000000, 1234).UTC().MarshalBinary()
+				Expect(err).ToNot(HaveOccurred())
+				extendedFields = append(deadline, 0x3, 0x7) // deadline, curNotSent and alpha
+			})
+
+			It("reads the deadline, curNotSent and alpha", func() {
+				b := bytes.NewReader(append([]byte{0x08, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c, 0xde}, extendedFields...))
+				hdr, err := ParsePublicHeader(b, protocol.PerspectiveClient, version)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hdr.Deadline.Equal(time.Unix(1500000000, 1234))).To(BeTrue())
+				Expect(hdr.CurNotSent).To(Equal(uint8(3)))
+				Expect(hdr.Alpha).To(Equal(uint8(7)))
+				Expect(b.Len()).To(BeZero())
+			})
+
+			It("uses the version sent by the client", func() {
+				data := []byte{0x09, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c, 0, 0, 0, 0, 0xde}
+				binary.LittleEndian.PutUint32(data[9:13], protocol.VersionNumberToTag(version))
+				b := bytes.NewReader(append(data, extendedFields...))
+				hdr, err := ParsePublicHeader(b, protocol.PerspectiveClient, protocol.VersionUnknown)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hdr.VersionNumber).To(Equal(version))
+				Expect(hdr.CurNotSent).To(Equal(uint8(3)))
+				Expect(b.Len()).To(BeZero())
+			})
+
+			It("errors on a truncated deadline", func() {
+				data := append([]byte{0x08, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c, 0xde}, extendedFields...)
+				for i := 10; i < len(data); i++ {
+					_, err := ParsePublicHeader(bytes.NewReader(data[:i]), protocol.PerspectiveClient, version)
+					Expect(err).To(MatchError(errPacketHeaderTooShort))
+				}
+			})
+
+			It("errors on an invalid deadline", func() {
+				data := append([]byte{0x08, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c, 0xde}, extendedFields...)
+				data[10] = 0x42 // unknown encoding version
+				_, err := ParsePublicHeader(bytes.NewReader(data), protocol.PerspectiveClient, version)
+				Expect(err).To(MatchError(errInvalidDeadline))
+			})
+
+			It("does not read a deadline from a PublicReset packet", func() {
+				b := bytes.NewReader(append([]byte{0xa, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8}, extendedFields...))
+				_, err := ParsePublicHeader(b, protocol.PerspectiveServer, version)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b.Len()).To(Equal(len(extendedFields)))
+			})
+
+			It("does not read a deadline in versions without deadlines", func() {
+				b := bytes.NewReader(append([]byte{0x08, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c, 0xde}, extendedFields...))
+				hdr, err := ParsePublicHeader(b, protocol.PerspectiveClient, protocol.VersionMP)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hdr.Deadline).To(BeZero())
+				Expect(b.Len()).To(Equal(len(extendedFields)))
+			})
+		})
+
 		It("returns an unknown version error when receiving a packet without a version for which the version is not given", func() {
 			b := bytes.NewReader([]byte{0x10, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0xef})
 			_, err := ParsePublicHeader(b, protocol.PerspectiveServer, protocol.VersionUnknown)
@@ -269,6 +349,23 @@ var _ = Describe("Public Header", func() {
 	})
 
 	Context("when writing", func() {
+		It("writes the deadline, curNotSent and alpha in versions with deadlines", func() {
+			b := &bytes.Buffer{}
+			hdr := PublicHeader{
+				ConnectionID:    0x4cfa9f9b668619f6,
+				PacketNumber:    2,
+				PacketNumberLen: protocol.PacketNumberLen1,
+				Deadline:        time.Unix(1500000000, 1234),
+				CurNotSent:      3,
+				Alpha:           7,
+			}
+			err := hdr.Write(b, protocol.VersionMPDeadline, protocol.PerspectiveServer)
+			Expect(err).ToNot(HaveOccurred())
+			deadline, err := hdr.Deadline.MarshalBinary()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b.Bytes()).To(Equal(append(append([]byte{0x08, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c, 2}, deadline...), 3, 7)))
+		})
+
 		It("writes a sample header as a server", func() {
 			b := &bytes.Buffer{}
 			hdr := PublicHeader{
@@ -415,7 +512,7 @@ var _ = Describe("Public Header", func() {
 		Context("GetLength", func() {
 			It("errors when calling GetLength for Version Negotiation packets", func() {
 				hdr := PublicHeader{VersionFlag: true}
-				_, err := hdr.GetLength(protocol.PerspectiveServer)
+				_, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveServer)
 				Expect(err).To(MatchError(errGetLengthNotForVersionNegotiation))
 			})
 
@@ -424,7 +521,7 @@ var _ = Describe("Public Header", func() {
 					ResetFlag:   true,
 					VersionFlag: true,
 				}
-				_, err := hdr.GetLength(protocol.PerspectiveServer)
+				_, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveServer)
 				Expect(err).To(MatchError(errResetAndVersionFlagSet))
 			})
 
@@ -433,7 +530,7 @@ var _ = Describe("Public Header", func() {
 					ConnectionID: 0x4cfa9f9b668619f6,
 					PacketNumber: 0xDECAFBAD,
 				}
-				_, err := hdr.GetLength(protocol.PerspectiveServer)
+				_, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveServer)
 				Expect(err).To(MatchError(errPacketNumberLenNotSet))
 			})
 
@@ -443,7 +540,7 @@ var _ = Describe("Public Header", func() {
 					PacketNumber:    0xDECAFBAD,
 					PacketNumberLen: protocol.PacketNumberLen6,
 				}
-				length, err := hdr.GetLength(protocol.PerspectiveServer)
+				length, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveServer)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(length).To(Equal(protocol.ByteCount(1 + 8 + 6))) // 1 byte public flag, 8 bytes connectionID, and packet number
 			})
@@ -457,7 +554,7 @@ var _ = Describe("Public Header", func() {
 					VersionFlag:          true,
 					VersionNumber:        versionLittleEndian,
 				}
-				length, err := hdr.GetLength(protocol.PerspectiveClient)
+				length, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveClient)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(length).To(Equal(protocol.ByteCount(1 + 4 + 6))) // 1 byte public flag, 4 version number, and packet number
 			})
@@ -469,7 +566,7 @@ var _ = Describe("Public Header", func() {
 					PacketNumber:         0xDECAFBAD,
 					PacketNumberLen:      protocol.PacketNumberLen6,
 				}
-				length, err := hdr.GetLength(protocol.PerspectiveServer)
+				length, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveServer)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(length).To(Equal(protocol.ByteCount(1 + 6))) // 1 byte public flag, and packet number
 			})
@@ -480,7 +577,7 @@ var _ = Describe("Public Header", func() {
 					PacketNumber:    0xDECAFBAD,
 					PacketNumberLen: protocol.PacketNumberLen2,
 				}
-				length, err := hdr.GetLength(protocol.PerspectiveServer)
+				length, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveServer)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(length).To(Equal(protocol.ByteCount(1 + 8 + 2))) // 1 byte public flag, 8 byte connectionID, and packet number
 			})
@@ -490,7 +587,7 @@ var _ = Describe("Public Header", func() {
 					DiversificationNonce: []byte("foo"),
 					PacketNumberLen:      protocol.PacketNumberLen1,
 				}
-				length, err := hdr.GetLength(protocol.PerspectiveServer)
+				length, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveServer)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(length).To(Equal(protocol.ByteCount(1 + 8 + 3 + 1))) // 1 byte public flag, 8 byte connectionID, 3 byte DiversificationNonce, 1 byte PacketNumber
 			})
@@ -500,10 +597,24 @@ var _ = Describe("Public Header", func() {
 					ResetFlag:    true,
 					ConnectionID: 0x4cfa9f9b668619f6,
 				}
-				length, err := hdr.GetLength(protocol.PerspectiveServer)
+				length, err := hdr.GetLength(versionLittleEndian, protocol.PerspectiveServer)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(length).To(Equal(protocol.ByteCount(1 + 8))) // 1 byte public flag, 8 byte connectionID
 			})
+
+			It("includes the deadline in versions with deadlines", func() {
+				hdr := PublicHeader{
+					ConnectionID:    0x4cfa9f9b668619f6,
+					PacketNumber:    0xDECAFBAD,
+					PacketNumberLen: protocol.PacketNumberLen4,
+				}
+				length, err := hdr.GetLength(protocol.VersionMPDeadline, protocol.PerspectiveServer)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(length).To(Equal(protocol.ByteCount(1 + 8 + 4 + 15 + 1 + 1))) // 1 byte public flag, 8 byte connectionID, packet number, deadline, curNotSent and alpha
+				b := &bytes.Buffer{}
+				Expect(hdr.Write(b, protocol.VersionMPDeadline, protocol.PerspectiveServer)).To(Succeed())
+				Expect(b.Len()).To(BeEquivalentTo(length))
+			})
 		})
 
 		Context("packet number length", func() {
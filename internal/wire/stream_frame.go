@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
@@ -17,6 +18,11 @@ type StreamFrame struct {
 	DataLenPresent bool
 	Offset         protocol.ByteCount
 	Data           []byte
+	// Deadline is the time by which the data should reach the peer. It is only
+	// serialized in versions that use deadlines, the packet carrying the frame
+	// advertises the earliest deadline of its frames in the PublicHeader.
+	// A zero value means no deadline.
+	Deadline time.Time
 }
 
 var (
@@ -65,6 +71,16 @@ func ParseStreamFrame(r *bytes.Reader, version protocol.VersionNumber) (*StreamF
 		return nil, qerr.Error(qerr.InvalidStreamData, "data len too large")
 	}
 
+	if version.UsesDeadlines() {
+		deadline, err := utils.GetByteOrder(version).ReadUint64(r)
+		if err != nil {
+			return nil, err
+		}
+		if deadline != 0 {
+			frame.Deadline = time.Unix(0, int64(deadline))
+		}
+	}
+
 	if !frame.DataLenPresent {
 		// The rest of the packet is data
 		dataLen = uint16(r.Len())
@@ -146,6 +162,14 @@ func (f *StreamFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) err
 		utils.GetByteOrder(version).WriteUint16(b, uint16(len(f.Data)))
 	}
 
+	if version.UsesDeadlines() {
+		var deadline uint64
+		if !f.Deadline.IsZero() {
+			deadline = uint64(f.Deadline.UnixNano())
+		}
+		utils.GetByteOrder(version).WriteUint64(b, deadline)
+	}
+
 	b.Write(f.Data)
 	return nil
 }
@@ -188,11 +212,14 @@ func (f *StreamFrame) getOffsetLength() protocol.ByteCount {
 
 // MinLength returns the length of the header of a StreamFrame
 // the total length of the StreamFrame is frame.MinLength() + frame.DataLen()
-func (f *StreamFrame) MinLength(protocol.VersionNumber) (protocol.ByteCount, error) {
+func (f *StreamFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
 	length := protocol.ByteCount(1) + protocol.ByteCount(f.calculateStreamIDLength()) + f.getOffsetLength()
 	if f.DataLenPresent {
 		length += 2
 	}
+	if version.UsesDeadlines() {
+		length += 8
+	}
 	return length, nil
 }
 
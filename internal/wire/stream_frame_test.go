@@ -2,6 +2,7 @@ package wire
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/qerr"
@@ -729,4 +730,59 @@ var _ = Describe("StreamFrame", func() {
 			Expect(frame.DataLen()).To(Equal(protocol.ByteCount(6)))
 		})
 	})
+
+	Context("in versions that use deadlines", func() {
+		version := protocol.VersionMPDeadline
+
+		It("writes and parses the deadline", func() {
+			deadline := time.Unix(1500000000, 123456789)
+			f := &StreamFrame{
+				StreamID:       5,
+				Offset:         0x1337,
+				Data:           []byte("foobar"),
+				DataLenPresent: true,
+				Deadline:       deadline,
+			}
+			b := &bytes.Buffer{}
+			Expect(f.Write(b, version)).To(Succeed())
+			Expect(f.MinLength(version)).To(Equal(protocol.ByteCount(b.Len() - len(f.Data))))
+			frame, err := ParseStreamFrame(bytes.NewReader(b.Bytes()), version)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Deadline.Equal(deadline)).To(BeTrue())
+			Expect(frame.Data).To(Equal([]byte("foobar")))
+			Expect(frame.Offset).To(Equal(protocol.ByteCount(0x1337)))
+		})
+
+		It("writes and parses a frame without deadline", func() {
+			f := &StreamFrame{StreamID: 5, Data: []byte("foobar")}
+			b := &bytes.Buffer{}
+			Expect(f.Write(b, version)).To(Succeed())
+			Expect(f.MinLength(version)).To(Equal(protocol.ByteCount(b.Len() - len(f.Data))))
+			frame, err := ParseStreamFrame(bytes.NewReader(b.Bytes()), version)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Deadline).To(BeZero())
+			Expect(frame.Data).To(Equal([]byte("foobar")))
+		})
+
+		It("doesn't write the deadline in other versions", func() {
+			f := &StreamFrame{StreamID: 5, Data: []byte("foobar"), Deadline: time.Now()}
+			b := &bytes.Buffer{}
+			Expect(f.Write(b, protocol.VersionMP)).To(Succeed())
+			Expect(f.MinLength(protocol.VersionMP)).To(Equal(protocol.ByteCount(b.Len() - len(f.Data))))
+			frame, err := ParseStreamFrame(bytes.NewReader(b.Bytes()), protocol.VersionMP)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Deadline).To(BeZero())
+		})
+
+		It("errors on EOFs", func() {
+			f := &StreamFrame{StreamID: 5, Data: []byte("foobar"), DataLenPresent: true, Deadline: time.Now()}
+			b := &bytes.Buffer{}
+			Expect(f.Write(b, version)).To(Succeed())
+			data := b.Bytes()
+			for i := range data[:len(data)-len(f.Data)] {
+				_, err := ParseStreamFrame(bytes.NewReader(data[0:i]), version)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
 })
@@ -0,0 +1,125 @@
+package netem
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// VirtualEpoch is the time a VirtualClock starts at
+var VirtualEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// A VirtualClock is a clock whose time only advances when its timers fire.
+// It is a quic.Clock: the sessions of a Simulation use it for their timestamps and timers.
+type VirtualClock struct {
+	mutex sync.Mutex
+	now   time.Time
+	// timers are ordered by deadline, then by creation
+	timers timerHeap
+	count  uint64
+}
+
+var _ utils.Clock = &VirtualClock{}
+
+// NewVirtualClock creates a clock at VirtualEpoch
+func NewVirtualClock() *VirtualClock {
+	return &VirtualClock{now: VirtualEpoch}
+}
+
+// Now returns the virtual time
+func (c *VirtualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// AfterFunc calls f when the virtual time reaches now+d, unless stop is called before
+func (c *VirtualClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if d < 0 {
+		d = 0
+	}
+	t := &virtualTimer{deadline: c.now.Add(d), count: c.count, f: f}
+	c.count++
+	heap.Push(&c.timers, t)
+	return func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if t.index < 0 {
+			return false
+		}
+		heap.Remove(&c.timers, t.index)
+		return true
+	}
+}
+
+// next returns the deadline of the next timer, false if no timer is pending
+func (c *VirtualClock) next() (time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.timers) == 0 {
+		return time.Time{}, false
+	}
+	return c.timers[0].deadline, true
+}
+
+// fire sets the time to the deadline of the next timer and calls its function, false if no timer is pending.
+// The function is called without the mutex held, it may set new timers.
+func (c *VirtualClock) fire() bool {
+	c.mutex.Lock()
+	if len(c.timers) == 0 {
+		c.mutex.Unlock()
+		return false
+	}
+	timer := heap.Pop(&c.timers).(*virtualTimer)
+	if timer.deadline.After(c.now) {
+		c.now = timer.deadline
+	}
+	c.mutex.Unlock()
+	timer.f()
+	return true
+}
+
+type virtualTimer struct {
+	deadline time.Time
+	count    uint64
+	f        func()
+	// index is the position of the timer in the heap, -1 once it fired or was stopped
+	index int
+}
+
+// timerHeap is a heap.Interface of virtual timers
+type timerHeap []*virtualTimer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].deadline.Equal(h[j].deadline) {
+		return h[i].count < h[j].count
+	}
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*virtualTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
@@ -0,0 +1,71 @@
+package netem
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Virtual clock", func() {
+	var clock *VirtualClock
+
+	BeforeEach(func() {
+		clock = NewVirtualClock()
+	})
+
+	It("starts at the epoch", func() {
+		Expect(clock.Now()).To(Equal(VirtualEpoch))
+	})
+
+	It("fires the timers in order", func() {
+		var fired []int
+		clock.AfterFunc(2*time.Second, func() { fired = append(fired, 3) })
+		clock.AfterFunc(time.Second, func() { fired = append(fired, 1) })
+		clock.AfterFunc(time.Second, func() { fired = append(fired, 2) })
+		next, ok := clock.next()
+		Expect(ok).To(BeTrue())
+		Expect(next).To(Equal(VirtualEpoch.Add(time.Second)))
+		Expect(clock.fire()).To(BeTrue())
+		Expect(fired).To(Equal([]int{1}))
+		Expect(clock.Now()).To(Equal(VirtualEpoch.Add(time.Second)))
+		Expect(clock.fire()).To(BeTrue())
+		Expect(fired).To(Equal([]int{1, 2}))
+		Expect(clock.Now()).To(Equal(VirtualEpoch.Add(time.Second)))
+		Expect(clock.fire()).To(BeTrue())
+		Expect(fired).To(Equal([]int{1, 2, 3}))
+		Expect(clock.Now()).To(Equal(VirtualEpoch.Add(2 * time.Second)))
+		_, ok = clock.next()
+		Expect(ok).To(BeFalse())
+		Expect(clock.fire()).To(BeFalse())
+	})
+
+	It("fires the timers set by a timer at the same time in the next step", func() {
+		var fired bool
+		clock.AfterFunc(time.Second, func() {
+			clock.AfterFunc(0, func() { fired = true })
+		})
+		Expect(clock.fire()).To(BeTrue())
+		Expect(fired).To(BeFalse())
+		Expect(clock.fire()).To(BeTrue())
+		Expect(fired).To(BeTrue())
+		Expect(clock.Now()).To(Equal(VirtualEpoch.Add(time.Second)))
+	})
+
+	It("stops timers", func() {
+		var fired bool
+		stop := clock.AfterFunc(time.Second, func() { fired = true })
+		clock.AfterFunc(2*time.Second, func() {})
+		Expect(stop()).To(BeTrue())
+		Expect(stop()).To(BeFalse())
+		Expect(clock.fire()).To(BeTrue())
+		Expect(fired).To(BeFalse())
+		Expect(clock.Now()).To(Equal(VirtualEpoch.Add(2 * time.Second)))
+	})
+
+	It("doesn't stop a timer that fired", func() {
+		stop := clock.AfterFunc(time.Second, func() {})
+		Expect(clock.fire()).To(BeTrue())
+		Expect(stop()).To(BeFalse())
+	})
+})
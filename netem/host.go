@@ -0,0 +1,282 @@
+package netem
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// firstEphemeralPort is the first port given to sockets bound to port 0
+	firstEphemeralPort = 32768
+	// socketBufferSize is the number of datagrams a socket holds before dropping the ones it receives
+	socketBufferSize = 1024
+)
+
+// A Host is an endpoint of the network, with one interface per IP address
+type Host struct {
+	name    string
+	network *Network
+
+	// ips and sockets are protected by the mutex of the network
+	ips      []net.IP
+	sockets  map[string]*packetConn
+	nextPort int
+}
+
+// Name returns the name of the host
+func (h *Host) Name() string {
+	return h.name
+}
+
+func (h *Host) hasIP(ip net.IP) bool {
+	for _, a := range h.ips {
+		if a.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Host) addIP(ip net.IP) {
+	if !h.hasIP(ip) {
+		h.ips = append(h.ips, ip)
+	}
+}
+
+// AddIP adds an interface with the given address to the host.
+// An address removed by RemoveIP comes back with its links, a new address has no link until it is connected.
+func (h *Host) AddIP(ip string) error {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return fmt.Errorf("netem: invalid IP address %q", ip)
+	}
+	h.network.mutex.Lock()
+	defer h.network.mutex.Unlock()
+	for _, other := range h.network.hosts {
+		if other != h && other.hasIP(addr) {
+			return fmt.Errorf("netem: IP address already used by host %s", other.name)
+		}
+	}
+	h.addIP(addr)
+	return nil
+}
+
+// RemoveIP removes an interface from the host, as if it went down.
+// Its links drop the datagrams sent from or to its address, and the sockets bound to it fail:
+// their reads and writes return an error. The links are kept, to be used again when the address is added back.
+func (h *Host) RemoveIP(ip string) error {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return fmt.Errorf("netem: invalid IP address %q", ip)
+	}
+	h.network.mutex.Lock()
+	defer h.network.mutex.Unlock()
+	if !h.hasIP(addr) {
+		return fmt.Errorf("netem: host %s has no interface %s", h.name, ip)
+	}
+	for i, a := range h.ips {
+		if a.Equal(addr) {
+			h.ips = append(h.ips[:i], h.ips[i+1:]...)
+			break
+		}
+	}
+	for _, c := range h.sockets {
+		if c.addr.IP.Equal(addr) {
+			c.shutdown(errNetDown)
+		}
+	}
+	return nil
+}
+
+// InterfaceIPs returns the IP addresses of the interfaces of the host, in the order they were connected
+func (h *Host) InterfaceIPs() ([]net.IP, error) {
+	h.network.mutex.Lock()
+	defer h.network.mutex.Unlock()
+	ips := make([]net.IP, len(h.ips))
+	copy(ips, h.ips)
+	return ips, nil
+}
+
+// ListenUDP opens a socket on a local address of the host. A nil address, or an unspecified IP,
+// binds the socket to all interfaces, port 0 to a free port.
+func (h *Host) ListenUDP(addr *net.UDPAddr) (net.PacketConn, error) {
+	h.network.mutex.Lock()
+	defer h.network.mutex.Unlock()
+
+	local := &net.UDPAddr{IP: net.IPv4zero}
+	if addr != nil {
+		local.Port = addr.Port
+		if addr.IP != nil {
+			local.IP = addr.IP
+		}
+	}
+	if !local.IP.IsUnspecified() && !h.hasIP(local.IP) {
+		return nil, &net.OpError{Op: "listen", Net: "udp", Addr: local, Err: errNoInterface}
+	}
+	if local.Port == 0 {
+		for h.sockets[socketKey(local.IP, h.nextPort)] != nil {
+			h.nextPort++
+		}
+		local.Port = h.nextPort
+		h.nextPort++
+	} else if h.sockets[socketKey(local.IP, local.Port)] != nil {
+		return nil, &net.OpError{Op: "listen", Net: "udp", Addr: local, Err: errAddrInUse}
+	}
+	c := &packetConn{
+		host:   h,
+		err:    errClosed,
+		addr:   local,
+		queue:  make(chan *datagram, socketBufferSize),
+		closed: make(chan struct{}),
+	}
+	h.sockets[socketKey(local.IP, local.Port)] = c
+	return c, nil
+}
+
+func socketKey(ip net.IP, port int) string {
+	if ip.IsUnspecified() {
+		ip = net.IPv4zero
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
+
+// socket returns the socket a datagram sent to the given address is delivered to, preferring the one bound to its IP.
+// It must be called with the mutex of the network held.
+func (h *Host) socket(addr *net.UDPAddr) *packetConn {
+	if c := h.sockets[socketKey(addr.IP, addr.Port)]; c != nil {
+		return c
+	}
+	return h.sockets[socketKey(net.IPv4zero, addr.Port)]
+}
+
+// send routes a datagram sent by a socket of the host
+func (h *Host) send(c *packetConn, data []byte, to *net.UDPAddr) error {
+	h.network.mutex.Lock()
+	defer h.network.mutex.Unlock()
+	p := h.network.route(h, c.addr.IP, to.IP)
+	if p == nil {
+		return &net.OpError{Op: "write", Net: "udp", Source: c.addr, Addr: to, Err: errNoRoute}
+	}
+	d := &datagram{
+		data: append([]byte(nil), data...),
+		from: &net.UDPAddr{IP: p.from, Port: c.addr.Port},
+		to:   &net.UDPAddr{IP: to.IP, Port: to.Port},
+	}
+	if p.nat != nil && !p.nat.translate(p, d) {
+		// the NAT has no mapping for the destination port
+		return nil
+	}
+	p.send(d, h.network.clock.Now())
+	return nil
+}
+
+// packetConn is a socket of a host
+type packetConn struct {
+	host *Host
+	addr *net.UDPAddr
+
+	queue     chan *datagram
+	closeOnce sync.Once
+	closed    chan struct{}
+	// err is returned by the reads and writes once the socket is closed, or failed with its interface
+	err error
+
+	mutex        sync.Mutex
+	readDeadline time.Time
+}
+
+var _ net.PacketConn = &packetConn{}
+
+func (c *packetConn) enqueue(d *datagram) {
+	select {
+	case <-c.closed:
+	case c.queue <- d:
+	default:
+		// the receive buffer is full
+	}
+}
+
+func (c *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.mutex.Lock()
+	deadline := c.readDeadline
+	c.mutex.Unlock()
+	var timeout chan struct{}
+	if !deadline.IsZero() {
+		timeout = make(chan struct{})
+		clock := c.host.network.clock
+		stop := clock.AfterFunc(deadline.Sub(clock.Now()), func() { close(timeout) })
+		defer stop()
+	}
+	select {
+	case d := <-c.queue:
+		return copy(b, d.data), d.from, nil
+	case <-c.closed:
+		return 0, nil, &net.OpError{Op: "read", Net: "udp", Source: c.addr, Err: c.err}
+	case <-timeout:
+		return 0, nil, &net.OpError{Op: "read", Net: "udp", Source: c.addr, Err: timeoutError{}}
+	}
+}
+
+func (c *packetConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, &net.OpError{Op: "write", Net: "udp", Source: c.addr, Addr: addr, Err: c.err}
+	default:
+	}
+	to, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, &net.OpError{Op: "write", Net: "udp", Source: c.addr, Addr: addr, Err: net.InvalidAddrError("not a UDP address")}
+	}
+	if err := c.host.send(c, b, to); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *packetConn) Close() error {
+	c.host.network.mutex.Lock()
+	defer c.host.network.mutex.Unlock()
+	c.shutdown(errClosed)
+	return nil
+}
+
+// shutdown closes the socket, its reads and writes then return err. It must be called with the mutex of the network held.
+func (c *packetConn) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		delete(c.host.sockets, socketKey(c.addr.IP, c.addr.Port))
+		c.err = err
+		close(c.closed)
+	})
+}
+
+func (c *packetConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+// SetDeadline sets the read deadline, writes never block
+func (c *packetConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the deadline of the next reads, on the clock of the network. It doesn't interrupt a pending read.
+func (c *packetConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline does nothing, writes never block
+func (c *packetConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+// timeoutError is returned by reads after the read deadline
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
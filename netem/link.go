@@ -0,0 +1,164 @@
+package netem
+
+import (
+	"net"
+	"time"
+)
+
+// defaultBurst is the size of the token bucket of a link without Burst, one full-size Ethernet frame
+const defaultBurst = 1500
+
+// LinkConfig configures one direction of a link
+type LinkConfig struct {
+	// Delay is the one-way propagation delay
+	Delay time.Duration
+	// Jitter is the largest deviation from Delay: every datagram is delayed by a uniformly random duration
+	// in [Delay-Jitter, Delay+Jitter], so datagrams may be reordered
+	Jitter time.Duration
+	// Bandwidth is the rate of the token bucket in bytes per second, 0 for an unlimited bandwidth
+	Bandwidth float64
+	// Burst is the size of the token bucket in bytes, it defaults to 1500
+	Burst int
+	// QueueSize is the number of datagrams that can wait for tokens, further datagrams are dropped.
+	// 0 is an unlimited queue.
+	QueueSize int
+	// Loss is the probability that a datagram is lost
+	Loss float64
+
+	// Trace replays a Mahimahi packet-delivery trace instead of the token bucket, Bandwidth and Burst are then ignored.
+	// The trace starts when the link is connected, or when its configuration is changed.
+	Trace *Trace
+	// DelayTrace, if set, replaces Delay. Jitter is still added to the delay of the trace.
+	DelayTrace *DelayTrace
+}
+
+// LinkStats are the statistics of one direction of a link
+type LinkStats struct {
+	// Sent is the number of datagrams sent on the link, including the dropped ones
+	Sent uint64
+	// Lost is the number of datagrams dropped because of Loss, Dropped the number dropped because the queue was full
+	Lost    uint64
+	Dropped uint64
+}
+
+// pipe is one direction of a link
+type pipe struct {
+	network *Network
+
+	from, to         net.IP
+	fromHost, toHost *Host
+	config           LinkConfig
+	stats            LinkStats
+	// nat translates the ports of the datagrams, if the link was rebound. Both pipes of a link share it.
+	nat *nat
+
+	// tokens left in the bucket when the last datagram departed, at lastDeparture
+	tokens        float64
+	lastDeparture time.Time
+	// departure times of the datagrams waiting in the queue
+	queue []time.Time
+
+	// epoch is the start of the traces of the config
+	epoch time.Time
+	// traceNext is the index of the next delivery opportunity of the trace, traceCredit the bytes it can still deliver
+	traceNext   int
+	traceCredit int
+}
+
+// setConfig changes the configuration and restarts the traces, it must be called with the mutex of the network held
+func (p *pipe) setConfig(config LinkConfig, now time.Time) {
+	p.config = config
+	p.epoch = now
+	p.traceNext = 0
+	p.traceCredit = TraceMTU
+}
+
+// send sends a datagram on the pipe at the given time, it must be called with the mutex of the network held
+func (p *pipe) send(d *datagram, now time.Time) {
+	p.stats.Sent++
+	if p.config.Loss > 0 && p.network.rand.Float64() < p.config.Loss {
+		p.stats.Lost++
+		return
+	}
+	departure, ok := p.departure(len(d.data), now)
+	if !ok {
+		p.stats.Dropped++
+		return
+	}
+	delay := p.config.Delay
+	if p.config.DelayTrace != nil {
+		delay = p.config.DelayTrace.Delay(departure.Sub(p.epoch))
+	}
+	arrival := departure.Add(delay + p.network.randomDuration(p.config.Jitter))
+	to := p.toHost
+	p.network.clock.AfterFunc(arrival.Sub(now), func() { p.network.deliver(to, d) })
+}
+
+// departure returns the time a datagram of the given size leaves the token bucket, false if the queue is full
+func (p *pipe) departure(size int, now time.Time) (time.Time, bool) {
+	if p.config.Trace == nil && p.config.Bandwidth <= 0 {
+		return now, true
+	}
+	// datagrams that left the link are not queued anymore
+	queued := 0
+	for queued < len(p.queue) && !p.queue[queued].After(now) {
+		queued++
+	}
+	p.queue = p.queue[queued:]
+	if p.config.QueueSize > 0 && len(p.queue) >= p.config.QueueSize {
+		return time.Time{}, false
+	}
+	if p.config.Trace != nil {
+		return p.traceDeparture(size, now), true
+	}
+
+	burst := float64(p.config.Burst)
+	if burst <= 0 {
+		burst = defaultBurst
+	}
+	if p.lastDeparture.IsZero() {
+		p.tokens = burst
+		p.lastDeparture = now
+	}
+	// the datagram leaves after the ones queued before it, once the bucket holds enough tokens
+	start := now
+	if p.lastDeparture.After(start) {
+		start = p.lastDeparture
+	}
+	tokens := p.tokens + start.Sub(p.lastDeparture).Seconds()*p.config.Bandwidth
+	if tokens > burst {
+		tokens = burst
+	}
+	departure := start
+	if missing := float64(size) - tokens; missing > 0 {
+		departure = start.Add(time.Duration(missing / p.config.Bandwidth * float64(time.Second)))
+		tokens = float64(size)
+	}
+	p.tokens = tokens - float64(size)
+	p.lastDeparture = departure
+	if departure.After(now) {
+		p.queue = append(p.queue, departure)
+	}
+	return departure, true
+}
+
+// traceDeparture returns the time a datagram of the given size is delivered by the trace.
+// The unused bytes of an opportunity are lost when no datagram is waiting for it, as in Mahimahi.
+func (p *pipe) traceDeparture(size int, now time.Time) time.Time {
+	trace := p.config.Trace
+	if next := trace.index(now.Sub(p.epoch)); next > p.traceNext {
+		p.traceNext = next
+		p.traceCredit = TraceMTU
+	}
+	for size > p.traceCredit {
+		size -= p.traceCredit
+		p.traceNext++
+		p.traceCredit = TraceMTU
+	}
+	p.traceCredit -= size
+	departure := p.epoch.Add(trace.at(p.traceNext))
+	if departure.After(now) {
+		p.queue = append(p.queue, departure)
+	}
+	return departure
+}
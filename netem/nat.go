@@ -0,0 +1,43 @@
+package netem
+
+// firstNATPort is the first port a NAT maps the ports of its host to
+const firstNATPort = 50000
+
+// nat translates the ports of the host on the a side of a link, see Link.Rebind
+type nat struct {
+	// host is the host behind the NAT
+	host *Host
+	// outside maps a port of the host to the port seen on the other side of the link, inside the reverse
+	outside map[int]int
+	inside  map[int]int
+	// nextPort is the next port to map a port of the host to. The ports of the previous mappings are not reused.
+	nextPort int
+}
+
+// rebind drops the mappings, the ports of the host are mapped to new ports when they are used next
+func (n *nat) rebind() {
+	n.outside = make(map[int]int)
+	n.inside = make(map[int]int)
+}
+
+// translate rewrites the source port of a datagram leaving the host through the pipe, or the destination port of one
+// sent to it. It returns false if the datagram is sent to a port without mapping. It must be called with the mutex held.
+func (n *nat) translate(p *pipe, d *datagram) bool {
+	if p.fromHost == n.host {
+		port, ok := n.outside[d.from.Port]
+		if !ok {
+			port = n.nextPort
+			n.nextPort++
+			n.outside[d.from.Port] = port
+			n.inside[port] = d.from.Port
+		}
+		d.from.Port = port
+		return true
+	}
+	port, ok := n.inside[d.to.Port]
+	if !ok {
+		return false
+	}
+	d.to.Port = port
+	return true
+}
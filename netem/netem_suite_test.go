@@ -0,0 +1,13 @@
+package netem
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestNetem(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "netem Suite")
+}
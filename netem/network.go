@@ -0,0 +1,183 @@
+// Package netem emulates a multipath network in memory, to test multipath sessions without network namespaces.
+//
+// A Network connects the interfaces of Hosts with point-to-point Links. Both directions of a link have their own
+// one-way delay, jitter, bandwidth, queue and loss, or replay Mahimahi packet-delivery traces and delay traces
+// to reproduce the bandwidth swings of cellular and WiFi links. A Host opens net.PacketConns on the addresses of its interfaces;
+// it is a quic.PacketNetwork, so a session configured with it creates one path per interface of the host.
+//
+// Datagrams sent from a socket bound to an interface take the link of that interface towards the destination.
+// Datagrams sent from a socket bound to the unspecified address take the first link towards the destination.
+//
+// To test how sessions cope with a changing network, interfaces can be removed from a host and added back,
+// and a link can rebind the ports of its first host like a NAT.
+//
+// A Simulation runs the network and the sessions using its clock in virtual time, driven by a discrete-event runner.
+package netem
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+var (
+	errNoRoute     = errors.New("no route to host")
+	errAddrInUse   = errors.New("address already in use")
+	errNoInterface = errors.New("cannot assign requested address")
+	errClosed      = errors.New("use of closed network connection")
+	errNetDown     = errors.New("network is down")
+)
+
+// A Network is a set of hosts connected by links
+type Network struct {
+	mutex sync.Mutex
+
+	rand  *rand.Rand
+	clock utils.Clock
+	hosts []*Host
+	links []*Link
+}
+
+// NewNetwork creates a network running in real time. The seed makes the losses and the jitter of its links reproducible.
+func NewNetwork(seed int64) *Network {
+	return &Network{rand: rand.New(rand.NewSource(seed)), clock: utils.SystemClock{}}
+}
+
+// AddHost adds a host without interfaces to the network
+func (n *Network) AddHost(name string) *Host {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	h := &Host{
+		name:     name,
+		network:  n,
+		sockets:  make(map[string]*packetConn),
+		nextPort: firstEphemeralPort,
+	}
+	n.hosts = append(n.hosts, h)
+	return h
+}
+
+// Connect creates a link between an interface of host a and an interface of host b.
+// The interfaces are created if the hosts don't have them yet, an interface can be the end of several links.
+// ab configures the direction from a to b, ba the direction from b to a.
+func (n *Network) Connect(a *Host, ipA string, b *Host, ipB string, ab, ba LinkConfig) (*Link, error) {
+	addrA := net.ParseIP(ipA)
+	if addrA == nil {
+		return nil, fmt.Errorf("netem: invalid IP address %q", ipA)
+	}
+	addrB := net.ParseIP(ipB)
+	if addrB == nil {
+		return nil, fmt.Errorf("netem: invalid IP address %q", ipB)
+	}
+	if a == b {
+		return nil, errors.New("netem: can't connect a host to itself")
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for _, h := range n.hosts {
+		if (h != a && h.hasIP(addrA)) || (h != b && h.hasIP(addrB)) {
+			return nil, fmt.Errorf("netem: IP address already used by host %s", h.name)
+		}
+	}
+	for _, l := range n.links {
+		if (l.ab.from.Equal(addrA) && l.ab.to.Equal(addrB)) || (l.ba.from.Equal(addrA) && l.ba.to.Equal(addrB)) {
+			return nil, fmt.Errorf("netem: %s and %s are already connected", ipA, ipB)
+		}
+	}
+	a.addIP(addrA)
+	b.addIP(addrB)
+	l := &Link{
+		network: n,
+		ab:      &pipe{network: n, from: addrA, to: addrB, fromHost: a, toHost: b},
+		ba:      &pipe{network: n, from: addrB, to: addrA, fromHost: b, toHost: a},
+	}
+	now := n.clock.Now()
+	l.ab.setConfig(ab, now)
+	l.ba.setConfig(ba, now)
+	n.links = append(n.links, l)
+	return l, nil
+}
+
+// route returns the pipe a datagram sent by a host from a local IP takes towards a remote IP.
+// An unspecified local IP selects the first link towards the remote IP whose interface is up. It must be called with the mutex held.
+func (n *Network) route(h *Host, from, to net.IP) *pipe {
+	for _, l := range n.links {
+		for _, p := range []*pipe{l.ab, l.ba} {
+			if p.fromHost == h && h.hasIP(p.from) && p.to.Equal(to) && (from.IsUnspecified() || p.from.Equal(from)) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// A Link connects an interface of a host to an interface of another host
+type Link struct {
+	network *Network
+	ab, ba  *pipe
+}
+
+// SetConfig changes the configuration of both directions of the link.
+// Datagrams already sent keep the delay they were given, the traces of the new configuration start over.
+func (l *Link) SetConfig(ab, ba LinkConfig) {
+	l.network.mutex.Lock()
+	defer l.network.mutex.Unlock()
+	now := l.network.clock.Now()
+	l.ab.setConfig(ab, now)
+	l.ba.setConfig(ba, now)
+}
+
+// Rebind makes the link behave like a NAT in front of host a, the first host given to Connect, that lost its mappings:
+// the datagrams that a sends afterwards reach b from new source ports, and those that b sends to the previous ports are dropped.
+// Until the first call, the link translates no ports.
+func (l *Link) Rebind() {
+	l.network.mutex.Lock()
+	defer l.network.mutex.Unlock()
+	if l.ab.nat == nil {
+		n := &nat{host: l.ab.fromHost, nextPort: firstNATPort}
+		l.ab.nat = n
+		l.ba.nat = n
+	}
+	l.ab.nat.rebind()
+}
+
+// Stats returns the statistics of both directions of the link
+func (l *Link) Stats() (ab, ba LinkStats) {
+	l.network.mutex.Lock()
+	defer l.network.mutex.Unlock()
+	return l.ab.stats, l.ba.stats
+}
+
+// datagram is a datagram in flight
+type datagram struct {
+	data     []byte
+	from, to *net.UDPAddr
+}
+
+// deliver hands a datagram to the socket it is sent to, dropping it if there is none or if its interface was removed
+func (n *Network) deliver(h *Host, d *datagram) {
+	n.mutex.Lock()
+	var c *packetConn
+	if h.hasIP(d.to.IP) {
+		c = h.socket(d.to)
+	}
+	n.mutex.Unlock()
+	if c == nil {
+		return
+	}
+	c.enqueue(d)
+}
+
+// randomDuration returns a uniformly random duration in [-max, max]. It must be called with the mutex held.
+func (n *Network) randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(n.rand.Int63n(int64(2*max)+1)) - max
+}
@@ -0,0 +1,341 @@
+package netem
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Network", func() {
+	var (
+		network        *Network
+		client, server *Host
+	)
+
+	udpAddr := func(ip string, port int) *net.UDPAddr {
+		return &net.UDPAddr{IP: net.ParseIP(ip), Port: port}
+	}
+
+	listen := func(h *Host, addr *net.UDPAddr) net.PacketConn {
+		c, err := h.ListenUDP(addr)
+		Expect(err).ToNot(HaveOccurred())
+		return c
+	}
+
+	read := func(c net.PacketConn) (string, net.Addr) {
+		b := make([]byte, 100)
+		Expect(c.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		n, addr, err := c.ReadFrom(b)
+		Expect(err).ToNot(HaveOccurred())
+		return string(b[:n]), addr
+	}
+
+	BeforeEach(func() {
+		network = NewNetwork(42)
+		client = network.AddHost("client")
+		server = network.AddHost("server")
+	})
+
+	It("lists the interfaces of the hosts", func() {
+		_, err := network.Connect(client, "10.0.0.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = network.Connect(client, "10.0.1.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		ips, err := client.InterfaceIPs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ips).To(HaveLen(2))
+		Expect(ips[0].Equal(net.ParseIP("10.0.0.1"))).To(BeTrue())
+		Expect(ips[1].Equal(net.ParseIP("10.0.1.1"))).To(BeTrue())
+		ips, err = server.InterfaceIPs()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ips).To(HaveLen(1))
+	})
+
+	It("refuses invalid links", func() {
+		_, err := network.Connect(client, "10.0.0.1", server, "foobar", LinkConfig{}, LinkConfig{})
+		Expect(err).To(MatchError(`netem: invalid IP address "foobar"`))
+		_, err = network.Connect(client, "10.0.0.1", client, "10.0.0.2", LinkConfig{}, LinkConfig{})
+		Expect(err).To(HaveOccurred())
+		_, err = network.Connect(client, "10.0.0.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = network.Connect(server, "10.1.0.1", client, "10.0.0.1", LinkConfig{}, LinkConfig{})
+		Expect(err).To(MatchError("netem: 10.1.0.1 and 10.0.0.1 are already connected"))
+		_, err = network.Connect(server, "10.0.0.1", client, "10.0.1.1", LinkConfig{}, LinkConfig{})
+		Expect(err).To(MatchError("netem: IP address already used by host client"))
+	})
+
+	Context("sockets", func() {
+		BeforeEach(func() {
+			_, err := network.Connect(client, "10.0.0.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("allocates free ports", func() {
+			c1 := listen(client, nil)
+			c2 := listen(client, udpAddr("10.0.0.1", 0))
+			Expect(c1.LocalAddr()).To(Equal(udpAddr("0.0.0.0", firstEphemeralPort)))
+			Expect(c2.LocalAddr()).To(Equal(udpAddr("10.0.0.1", firstEphemeralPort+1)))
+		})
+
+		It("refuses addresses in use or of other hosts", func() {
+			listen(server, udpAddr("0.0.0.0", 4433))
+			_, err := server.ListenUDP(udpAddr("0.0.0.0", 4433))
+			Expect(err).To(MatchError(ContainSubstring("address already in use")))
+			_, err = server.ListenUDP(udpAddr("10.0.0.1", 4433))
+			Expect(err).To(MatchError(ContainSubstring("cannot assign requested address")))
+		})
+
+		It("delivers datagrams after the delay of the link", func() {
+			network.links[0].SetConfig(LinkConfig{Delay: 50 * time.Millisecond}, LinkConfig{})
+			s := listen(server, udpAddr("0.0.0.0", 4433))
+			c := listen(client, nil)
+			sent := time.Now()
+			n, err := c.WriteTo([]byte("foobar"), udpAddr("10.1.0.1", 4433))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(6))
+			data, addr := read(s)
+			Expect(time.Since(sent)).To(BeNumerically(">=", 50*time.Millisecond))
+			Expect(data).To(Equal("foobar"))
+			Expect(addr).To(Equal(udpAddr("10.0.0.1", firstEphemeralPort)))
+		})
+
+		It("returns an error without a route", func() {
+			c := listen(client, nil)
+			_, err := c.WriteTo([]byte("foobar"), udpAddr("10.2.0.1", 4433))
+			Expect(err).To(MatchError(ContainSubstring("no route to host")))
+		})
+
+		It("drops datagrams sent to a port without socket", func() {
+			c := listen(client, nil)
+			_, err := c.WriteTo([]byte("foobar"), udpAddr("10.1.0.1", 4433))
+			Expect(err).ToNot(HaveOccurred())
+			// let the datagram arrive before the socket is opened
+			time.Sleep(20 * time.Millisecond)
+			s := listen(server, udpAddr("0.0.0.0", 4433))
+			Expect(s.SetReadDeadline(time.Now().Add(50 * time.Millisecond))).To(Succeed())
+			_, _, err = s.ReadFrom(make([]byte, 100))
+			Expect(err).To(HaveOccurred())
+			Expect(err.(net.Error).Timeout()).To(BeTrue())
+		})
+
+		It("unblocks reads when closed", func() {
+			c := listen(client, nil)
+			done := make(chan error)
+			go func() {
+				_, _, err := c.ReadFrom(make([]byte, 100))
+				done <- err
+			}()
+			Consistently(done).ShouldNot(Receive())
+			Expect(c.Close()).To(Succeed())
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(err).To(MatchError(ContainSubstring("use of closed network connection")))
+			_, err = c.WriteTo([]byte("foobar"), udpAddr("10.1.0.1", 4433))
+			Expect(err).To(MatchError(ContainSubstring("use of closed network connection")))
+			// the port can be used again
+			listen(client, udpAddr("0.0.0.0", firstEphemeralPort))
+		})
+	})
+
+	It("routes the datagrams of the sockets on the links of their interface", func() {
+		_, err := network.Connect(client, "10.0.0.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = network.Connect(client, "10.0.1.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		s := listen(server, udpAddr("0.0.0.0", 4433))
+		cAny := listen(client, udpAddr("0.0.0.0", 1000))
+		c2 := listen(client, udpAddr("10.0.1.1", 2000))
+
+		_, err = cAny.WriteTo([]byte("any"), udpAddr("10.1.0.1", 4433))
+		Expect(err).ToNot(HaveOccurred())
+		data, addr := read(s)
+		Expect(data).To(Equal("any"))
+		// the first link is used
+		Expect(addr).To(Equal(udpAddr("10.0.0.1", 1000)))
+
+		_, err = c2.WriteTo([]byte("second"), udpAddr("10.1.0.1", 4433))
+		Expect(err).ToNot(HaveOccurred())
+		data, addr = read(s)
+		Expect(data).To(Equal("second"))
+		Expect(addr).To(Equal(udpAddr("10.0.1.1", 2000)))
+
+		// the server replies on the second link
+		_, err = s.WriteTo([]byte("reply"), addr)
+		Expect(err).ToNot(HaveOccurred())
+		data, addr = read(c2)
+		Expect(data).To(Equal("reply"))
+		Expect(addr).To(Equal(udpAddr("10.1.0.1", 4433)))
+		ab, ba := network.links[1].Stats()
+		Expect(ab.Sent).To(BeEquivalentTo(1))
+		Expect(ba.Sent).To(BeEquivalentTo(1))
+	})
+
+	Context("interface changes", func() {
+		var s net.PacketConn
+
+		BeforeEach(func() {
+			_, err := network.Connect(client, "10.0.0.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = network.Connect(client, "10.0.1.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+			Expect(err).ToNot(HaveOccurred())
+			s = listen(server, udpAddr("0.0.0.0", 4433))
+		})
+
+		It("fails the sockets of a removed interface", func() {
+			c := listen(client, udpAddr("10.0.1.1", 2000))
+			done := make(chan error)
+			go func() {
+				_, _, err := c.ReadFrom(make([]byte, 100))
+				done <- err
+			}()
+			Expect(client.RemoveIP("10.0.1.1")).To(Succeed())
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(err).To(MatchError(ContainSubstring("network is down")))
+			_, err = c.WriteTo([]byte("foobar"), udpAddr("10.1.0.1", 4433))
+			Expect(err).To(MatchError(ContainSubstring("network is down")))
+			ips, err := client.InterfaceIPs()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ips).To(HaveLen(1))
+			_, err = client.ListenUDP(udpAddr("10.0.1.1", 0))
+			Expect(err).To(MatchError(ContainSubstring("cannot assign requested address")))
+			Expect(client.RemoveIP("10.0.1.1")).To(MatchError("netem: host client has no interface 10.0.1.1"))
+		})
+
+		It("routes around a removed interface", func() {
+			cAny := listen(client, udpAddr("0.0.0.0", 1000))
+			Expect(client.RemoveIP("10.0.0.1")).To(Succeed())
+			_, err := cAny.WriteTo([]byte("any"), udpAddr("10.1.0.1", 4433))
+			Expect(err).ToNot(HaveOccurred())
+			data, addr := read(s)
+			Expect(data).To(Equal("any"))
+			Expect(addr).To(Equal(udpAddr("10.0.1.1", 1000)))
+			// the datagrams sent to the removed address are dropped
+			_, err = s.WriteTo([]byte("lost"), udpAddr("10.0.0.1", 1000))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cAny.SetReadDeadline(time.Now().Add(50 * time.Millisecond))).To(Succeed())
+			_, _, err = cAny.ReadFrom(make([]byte, 100))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("uses the links of an interface added back", func() {
+			Expect(client.RemoveIP("10.0.1.1")).To(Succeed())
+			Expect(client.AddIP("10.0.1.1")).To(Succeed())
+			c := listen(client, udpAddr("10.0.1.1", 2000))
+			_, err := c.WriteTo([]byte("back"), udpAddr("10.1.0.1", 4433))
+			Expect(err).ToNot(HaveOccurred())
+			data, addr := read(s)
+			Expect(data).To(Equal("back"))
+			Expect(addr).To(Equal(udpAddr("10.0.1.1", 2000)))
+			Expect(client.AddIP("10.1.0.1")).To(MatchError("netem: IP address already used by host server"))
+			Expect(client.AddIP("foobar")).To(MatchError(`netem: invalid IP address "foobar"`))
+		})
+	})
+
+	It("rebinds the ports of a host behind a NAT", func() {
+		link, err := network.Connect(client, "10.0.0.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+		Expect(err).ToNot(HaveOccurred())
+		s := listen(server, udpAddr("0.0.0.0", 4433))
+		c := listen(client, udpAddr("10.0.0.1", 1000))
+		send := func(msg string) net.Addr {
+			_, err := c.WriteTo([]byte(msg), udpAddr("10.1.0.1", 4433))
+			Expect(err).ToNot(HaveOccurred())
+			data, addr := read(s)
+			Expect(data).To(Equal(msg))
+			return addr
+		}
+
+		Expect(send("direct")).To(Equal(udpAddr("10.0.0.1", 1000)))
+		link.Rebind()
+		first := send("mapped")
+		Expect(first).To(Equal(udpAddr("10.0.0.1", firstNATPort)))
+		Expect(send("again")).To(Equal(first))
+		// the replies to the mapped port reach the socket
+		_, err = s.WriteTo([]byte("reply"), first)
+		Expect(err).ToNot(HaveOccurred())
+		data, addr := read(c)
+		Expect(data).To(Equal("reply"))
+		Expect(addr).To(Equal(udpAddr("10.1.0.1", 4433)))
+
+		link.Rebind()
+		Expect(send("rebound")).To(Equal(udpAddr("10.0.0.1", firstNATPort+1)))
+		// the previous mapping is gone
+		_, err = s.WriteTo([]byte("lost"), first)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.SetReadDeadline(time.Now().Add(50 * time.Millisecond))).To(Succeed())
+		_, _, err = c.ReadFrom(make([]byte, 100))
+		Expect(err).To(HaveOccurred())
+		Expect(err.(net.Error).Timeout()).To(BeTrue())
+	})
+
+	Context("impairments", func() {
+		var p *pipe
+
+		BeforeEach(func() {
+			l, err := network.Connect(client, "10.0.0.1", server, "10.1.0.1", LinkConfig{}, LinkConfig{})
+			Expect(err).ToNot(HaveOccurred())
+			p = l.ab
+		})
+
+		It("loses datagrams, reproducibly", func() {
+			lost := func(n *Network) uint64 {
+				c := n.AddHost("client")
+				l, err := n.Connect(c, "10.0.0.1", n.AddHost("server"), "10.1.0.1", LinkConfig{Loss: 0.3}, LinkConfig{})
+				Expect(err).ToNot(HaveOccurred())
+				conn := listen(c, nil)
+				for i := 0; i < 1000; i++ {
+					_, err := conn.WriteTo([]byte("foobar"), udpAddr("10.1.0.1", 4433))
+					Expect(err).ToNot(HaveOccurred())
+				}
+				ab, _ := l.Stats()
+				Expect(ab.Sent).To(BeEquivalentTo(1000))
+				return ab.Lost
+			}
+			l := lost(NewNetwork(1))
+			Expect(l).To(BeNumerically("~", 300, 60))
+			Expect(lost(NewNetwork(1))).To(Equal(l))
+		})
+
+		It("paces datagrams with a token bucket", func() {
+			p.config = LinkConfig{Bandwidth: 100000}
+			now := time.Now()
+			var departures []time.Duration
+			for i := 0; i < 4; i++ {
+				departure, ok := p.departure(1000, now)
+				Expect(ok).To(BeTrue())
+				departures = append(departures, departure.Sub(now))
+			}
+			// the bucket starts full, with 1500 bytes
+			Expect(departures).To(Equal([]time.Duration{0, 5 * time.Millisecond, 15 * time.Millisecond, 25 * time.Millisecond}))
+			// after an idle period, the bucket allows a burst again
+			later := now.Add(time.Second)
+			departure, _ := p.departure(1000, later)
+			Expect(departure).To(Equal(later))
+		})
+
+		It("drops datagrams when the queue is full", func() {
+			p.config = LinkConfig{Bandwidth: 100000, Burst: 1000, QueueSize: 2}
+			now := time.Now()
+			var accepted []bool
+			for i := 0; i < 5; i++ {
+				_, ok := p.departure(1000, now)
+				accepted = append(accepted, ok)
+			}
+			Expect(accepted).To(Equal([]bool{true, true, true, false, false}))
+			// once the first queued datagram left, there's room for another one
+			_, ok := p.departure(1000, now.Add(10*time.Millisecond))
+			Expect(ok).To(BeTrue())
+		})
+
+		It("jitters the delay", func() {
+			for i := 0; i < 100; i++ {
+				d := network.randomDuration(10 * time.Millisecond)
+				Expect(d).To(BeNumerically(">=", -10*time.Millisecond))
+				Expect(d).To(BeNumerically("<=", 10*time.Millisecond))
+			}
+			Expect(network.randomDuration(0)).To(BeZero())
+		})
+	})
+})
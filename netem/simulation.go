@@ -0,0 +1,112 @@
+package netem
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// maxIdlePolls bounds the number of times the runner checks the goroutines before advancing the time anyway,
+// so that a goroutine that never blocks, e.g. one unrelated to the simulation, doesn't stall it
+const maxIdlePolls = 1000
+
+// A Simulation is a network running in virtual time. The time only advances when all goroutines are blocked,
+// then it jumps to the next timer: the delivery of a datagram, or a timer of a session using the Clock.
+// A transfer of a minute simulates in a fraction of that, and its timing doesn't depend on the load of the machine.
+//
+// The timers fire one at a time, in the order of their deadlines then of their creation, and the runner waits
+// until the goroutine woken by a timer is blocked again before firing the next one, on a single processor.
+// With the losses and the jitter of the links following the seed, and the sessions seeded by the RandSeed
+// of their config, a run is reproduced exactly by the same seed. It isn't if the sessions use the read or write
+// deadlines of their streams, which stay on the system clock, or if a goroutine unrelated to the simulation
+// keeps the runner from seeing the others idle within maxIdlePolls.
+type Simulation struct {
+	*Network
+	clock *VirtualClock
+}
+
+// NewSimulation creates an empty network in virtual time, the seed makes the losses and the jitter of its links reproducible
+func NewSimulation(seed int64) *Simulation {
+	clock := NewVirtualClock()
+	n := NewNetwork(seed)
+	n.clock = clock
+	return &Simulation{Network: n, clock: clock}
+}
+
+// Clock returns the clock of the simulation, to be used as the Clock of the quic.Config of its sessions
+func (s *Simulation) Clock() *VirtualClock {
+	return s.clock
+}
+
+// Run advances the virtual time until done is closed, with GOMAXPROCS set to 1.
+// It fails if the virtual time exceeds the limit, or if nothing remains to be done while done isn't closed.
+// The sessions must be started by other goroutines, Run returns once they closed done.
+func (s *Simulation) Run(done <-chan struct{}, limit time.Duration) error {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	end := s.clock.Now().Add(limit)
+	for {
+		waitIdle()
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		next, ok := s.clock.next()
+		if !ok {
+			return errors.New("netem: simulation deadlocked, no timer is pending")
+		}
+		if next.After(end) {
+			return fmt.Errorf("netem: simulation didn't finish within %s", limit)
+		}
+		s.clock.fire()
+	}
+}
+
+// waitIdle waits until all other goroutines are blocked, so that they processed the last events
+func waitIdle() {
+	buf := make([]byte, 1<<16)
+	for i := 0; i < maxIdlePolls; i++ {
+		runtime.Gosched()
+		n := runtime.Stack(buf, true)
+		for n == len(buf) {
+			buf = make([]byte, 2*len(buf))
+			n = runtime.Stack(buf, true)
+		}
+		if !othersBusy(buf[:n]) {
+			return
+		}
+	}
+}
+
+// busyStates are the goroutine states of runtime.Stack that are not waiting for an event
+var busyStates = []string{"running", "runnable", "preempted", "copystack"}
+
+// A goroutine in a syscall is busy, e.g. one reading random bytes for the crypto of a handshake while its thread is descheduled.
+// Only the goroutine of os/signal blocks in a syscall for good.
+const signalReceiver = "os/signal.signal_recv"
+
+// othersBusy tells if a goroutine of a runtime.Stack dump, except the first one that took it, isn't blocked
+func othersBusy(dump []byte) bool {
+	for i, stack := range bytes.Split(dump, []byte("\n\n")) {
+		if i == 0 || !bytes.HasPrefix(stack, []byte("goroutine ")) {
+			continue
+		}
+		start := bytes.IndexByte(stack, '[')
+		end := bytes.IndexAny(stack, ",]")
+		if start < 0 || end < start {
+			continue
+		}
+		state := string(stack[start+1 : end])
+		if state == "syscall" && !bytes.Contains(stack, []byte(signalReceiver)) {
+			return true
+		}
+		for _, busy := range busyStates {
+			if state == busy {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package netem
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Simulation", func() {
+	var (
+		sim            *Simulation
+		client, server *Host
+	)
+
+	serverAddr := &net.UDPAddr{IP: net.ParseIP("10.1.0.1"), Port: 4433}
+
+	BeforeEach(func() {
+		sim = NewSimulation(42)
+		client = sim.AddHost("client")
+		server = sim.AddHost("server")
+	})
+
+	// echo starts a server echoing the datagrams it receives
+	echo := func() net.PacketConn {
+		conn, err := server.ListenUDP(serverAddr)
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			b := make([]byte, 100)
+			for {
+				n, addr, err := conn.ReadFrom(b)
+				if err != nil {
+					return
+				}
+				conn.WriteTo(b[:n], addr)
+			}
+		}()
+		return conn
+	}
+
+	// ping sends datagrams to the echo server one after the other, and returns the indices of those that came back.
+	// The client waits a second for every answer.
+	ping := func(count int) (received []int, err error) {
+		serverConn := echo()
+		defer serverConn.Close()
+		conn, err := client.ListenUDP(nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			b := make([]byte, 100)
+			for i := 0; i < count; i++ {
+				conn.WriteTo([]byte{byte(i)}, serverAddr)
+				conn.SetReadDeadline(sim.Clock().Now().Add(time.Second))
+				if _, _, err := conn.ReadFrom(b); err == nil {
+					received = append(received, int(b[0]))
+				}
+			}
+		}()
+		err = sim.Run(done, time.Hour)
+		return received, err
+	}
+
+	It("runs in virtual time", func() {
+		_, err := sim.Connect(client, "10.0.0.1", server, "10.1.0.1", LinkConfig{Delay: 250 * time.Millisecond}, LinkConfig{Delay: 250 * time.Millisecond})
+		Expect(err).ToNot(HaveOccurred())
+		start := time.Now()
+		received, err := ping(100)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(received).To(HaveLen(100))
+		// every ping takes one RTT
+		Expect(sim.Clock().Now().Sub(VirtualEpoch)).To(Equal(50 * time.Second))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+	})
+
+	It("reproduces the losses of a seed", func() {
+		lossy := LinkConfig{Delay: 10 * time.Millisecond, Jitter: 5 * time.Millisecond, Loss: 0.3}
+		_, err := sim.Connect(client, "10.0.0.1", server, "10.1.0.1", lossy, lossy)
+		Expect(err).ToNot(HaveOccurred())
+		received, err := ping(50)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(received)).To(BeNumerically("~", 25, 10))
+
+		sim = NewSimulation(42)
+		client = sim.AddHost("client")
+		server = sim.AddHost("server")
+		_, err = sim.Connect(client, "10.0.0.1", server, "10.1.0.1", lossy, lossy)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ping(50)).To(Equal(received))
+	})
+
+	It("fails when nothing remains to be done", func() {
+		conn, err := client.ListenUDP(nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			// nothing is ever received
+			conn.ReadFrom(make([]byte, 100))
+		}()
+		Expect(sim.Run(done, time.Hour)).To(MatchError("netem: simulation deadlocked, no timer is pending"))
+	})
+
+	It("fails when the virtual time exceeds the limit", func() {
+		conn, err := client.ListenUDP(nil)
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			conn.SetReadDeadline(sim.Clock().Now().Add(2 * time.Minute))
+			conn.ReadFrom(make([]byte, 100))
+		}()
+		Expect(sim.Run(done, time.Minute)).To(MatchError("netem: simulation didn't finish within 1m0s"))
+	})
+
+	It("tells if other goroutines are busy", func() {
+		Expect(othersBusy([]byte("goroutine 1 [running]:\nmain.main()\n\ngoroutine 5 [select, 2 minutes]:\n"))).To(BeFalse())
+		Expect(othersBusy([]byte("goroutine 1 [running]:\n\ngoroutine 5 [chan receive]:\n\ngoroutine 7 [runnable]:\n"))).To(BeTrue())
+		Expect(othersBusy([]byte("goroutine 1 [running]:\n\ngoroutine 5 [syscall]:\nsyscall.Syscall()\n"))).To(BeTrue())
+		Expect(othersBusy([]byte("goroutine 1 [running]:\n\ngoroutine 5 [syscall, 3 minutes]:\nos/signal.signal_recv()\n"))).To(BeFalse())
+	})
+})
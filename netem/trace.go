@@ -0,0 +1,168 @@
+package netem
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TraceMTU is the number of bytes a link delivers at each delivery opportunity of a Trace, as in Mahimahi
+const TraceMTU = 1504
+
+// A Trace is a Mahimahi packet-delivery trace. Every line of a trace file is the time in milliseconds of an
+// opportunity to deliver TraceMTU bytes; several opportunities at the same millisecond are listed several times.
+// The trace repeats after its last opportunity.
+type Trace struct {
+	opportunities []time.Duration
+	period        time.Duration
+}
+
+// ParseTrace reads a Mahimahi packet-delivery trace
+func ParseTrace(r io.Reader) (*Trace, error) {
+	t := &Trace{}
+	err := readTraceLines(r, func(line int, fields []string) error {
+		if len(fields) != 1 {
+			return fmt.Errorf("netem: trace line %d: expected a timestamp", line)
+		}
+		ms, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("netem: trace line %d: invalid timestamp %q", line, fields[0])
+		}
+		o := time.Duration(ms) * time.Millisecond
+		if n := len(t.opportunities); n > 0 && o < t.opportunities[n-1] {
+			return fmt.Errorf("netem: trace line %d: timestamps must not decrease", line)
+		}
+		t.opportunities = append(t.opportunities, o)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(t.opportunities) == 0 {
+		return nil, errors.New("netem: empty trace")
+	}
+	t.period = t.opportunities[len(t.opportunities)-1]
+	if t.period == 0 {
+		return nil, errors.New("netem: the last timestamp of a trace must be positive")
+	}
+	return t, nil
+}
+
+// LoadTrace reads a Mahimahi packet-delivery trace from a file
+func LoadTrace(filename string) (*Trace, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseTrace(f)
+}
+
+// Period returns the duration after which the trace repeats
+func (t *Trace) Period() time.Duration {
+	return t.period
+}
+
+// Bandwidth returns the average rate of the trace in bytes per second
+func (t *Trace) Bandwidth() float64 {
+	return float64(len(t.opportunities)*TraceMTU) / t.period.Seconds()
+}
+
+// index returns the index of the first opportunity at or after the given time since the start of the trace.
+// Indices keep increasing over the repetitions of the trace.
+func (t *Trace) index(offset time.Duration) int {
+	cycle := int(offset / t.period)
+	within := offset % t.period
+	i := sort.Search(len(t.opportunities), func(i int) bool { return t.opportunities[i] >= within })
+	return cycle*len(t.opportunities) + i
+}
+
+// at returns the time of an opportunity since the start of the trace
+func (t *Trace) at(index int) time.Duration {
+	n := len(t.opportunities)
+	return time.Duration(index/n)*t.period + t.opportunities[index%n]
+}
+
+// A DelayTrace describes how the one-way delay of a link changes over time. Every line of a delay trace file
+// is a time and a delay, both in milliseconds. The delay is interpolated linearly between the lines, the first
+// line must be at time 0, and the trace repeats at the time of its last line.
+type DelayTrace struct {
+	times  []time.Duration
+	delays []time.Duration
+}
+
+// ParseDelayTrace reads a delay trace
+func ParseDelayTrace(r io.Reader) (*DelayTrace, error) {
+	t := &DelayTrace{}
+	err := readTraceLines(r, func(line int, fields []string) error {
+		if len(fields) != 2 {
+			return fmt.Errorf("netem: delay trace line %d: expected a timestamp and a delay", line)
+		}
+		var values [2]time.Duration
+		for i, f := range fields {
+			ms, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return fmt.Errorf("netem: delay trace line %d: invalid number %q", line, f)
+			}
+			values[i] = time.Duration(ms) * time.Millisecond
+		}
+		if n := len(t.times); (n == 0 && values[0] != 0) || (n > 0 && values[0] <= t.times[n-1]) {
+			return fmt.Errorf("netem: delay trace line %d: timestamps must start at 0 and increase", line)
+		}
+		t.times = append(t.times, values[0])
+		t.delays = append(t.delays, values[1])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(t.times) == 0 {
+		return nil, errors.New("netem: empty delay trace")
+	}
+	return t, nil
+}
+
+// LoadDelayTrace reads a delay trace from a file
+func LoadDelayTrace(filename string) (*DelayTrace, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDelayTrace(f)
+}
+
+// Delay returns the delay at the given time since the start of the trace
+func (t *DelayTrace) Delay(offset time.Duration) time.Duration {
+	n := len(t.times)
+	if n == 1 {
+		return t.delays[0]
+	}
+	offset %= t.times[n-1]
+	i := sort.Search(n, func(i int) bool { return t.times[i] > offset }) - 1
+	fraction := float64(offset-t.times[i]) / float64(t.times[i+1]-t.times[i])
+	return t.delays[i] + time.Duration(fraction*float64(t.delays[i+1]-t.delays[i]))
+}
+
+// readTraceLines calls parse with the fields of every line of a trace, skipping empty lines
+func readTraceLines(r io.Reader, parse func(line int, fields []string) error) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := parse(line, fields); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
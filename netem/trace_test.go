@@ -0,0 +1,145 @@
+package netem
+
+import (
+	"bytes"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traces", func() {
+	Context("packet-delivery traces", func() {
+		It("parses a trace", func() {
+			t, err := ParseTrace(bytes.NewBufferString("0\n5\n5\n\n20\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.Period()).To(Equal(20 * time.Millisecond))
+			Expect(t.Bandwidth()).To(BeNumerically("~", 4*TraceMTU/0.02))
+		})
+
+		It("refuses invalid traces", func() {
+			_, err := ParseTrace(bytes.NewBufferString(""))
+			Expect(err).To(MatchError("netem: empty trace"))
+			_, err = ParseTrace(bytes.NewBufferString("0\n0\n"))
+			Expect(err).To(MatchError("netem: the last timestamp of a trace must be positive"))
+			_, err = ParseTrace(bytes.NewBufferString("5\n3\n"))
+			Expect(err).To(MatchError("netem: trace line 2: timestamps must not decrease"))
+			_, err = ParseTrace(bytes.NewBufferString("5\nfoo\n"))
+			Expect(err).To(MatchError(`netem: trace line 2: invalid timestamp "foo"`))
+		})
+
+		It("repeats the opportunities", func() {
+			t, err := ParseTrace(bytes.NewBufferString("0\n5\n5\n20\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.index(0)).To(Equal(0))
+			Expect(t.index(time.Millisecond)).To(Equal(1))
+			Expect(t.index(6 * time.Millisecond)).To(Equal(3))
+			// the first opportunity of the second repetition is at the same time as the last one
+			Expect(t.at(3)).To(Equal(20 * time.Millisecond))
+			Expect(t.at(4)).To(Equal(20 * time.Millisecond))
+			Expect(t.at(5)).To(Equal(25 * time.Millisecond))
+			Expect(t.index(21 * time.Millisecond)).To(Equal(5))
+		})
+	})
+
+	Context("delay traces", func() {
+		It("interpolates the delay", func() {
+			t, err := ParseDelayTrace(bytes.NewBufferString("0 10\n100 30\n200 10\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.Delay(0)).To(Equal(10 * time.Millisecond))
+			Expect(t.Delay(50 * time.Millisecond)).To(Equal(20 * time.Millisecond))
+			Expect(t.Delay(150 * time.Millisecond)).To(Equal(20 * time.Millisecond))
+			Expect(t.Delay(225 * time.Millisecond)).To(Equal(15 * time.Millisecond))
+		})
+
+		It("has a constant delay with a single line", func() {
+			t, err := ParseDelayTrace(bytes.NewBufferString("0 42\n"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(t.Delay(time.Hour)).To(Equal(42 * time.Millisecond))
+		})
+
+		It("refuses invalid traces", func() {
+			_, err := ParseDelayTrace(bytes.NewBufferString("10 10\n"))
+			Expect(err).To(MatchError("netem: delay trace line 1: timestamps must start at 0 and increase"))
+			_, err = ParseDelayTrace(bytes.NewBufferString("0 10\n0 20\n"))
+			Expect(err).To(MatchError("netem: delay trace line 2: timestamps must start at 0 and increase"))
+			_, err = ParseDelayTrace(bytes.NewBufferString("0\n"))
+			Expect(err).To(MatchError("netem: delay trace line 1: expected a timestamp and a delay"))
+		})
+	})
+
+	Context("links replaying traces", func() {
+		var (
+			p   *pipe
+			now time.Time
+		)
+
+		BeforeEach(func() {
+			t, err := ParseTrace(bytes.NewBufferString("10\n10\n30\n"))
+			Expect(err).ToNot(HaveOccurred())
+			d, err := ParseDelayTrace(bytes.NewBufferString("0 10\n100 30\n"))
+			Expect(err).ToNot(HaveOccurred())
+			p = &pipe{network: NewNetwork(42)}
+			now = time.Now()
+			p.setConfig(LinkConfig{Trace: t, DelayTrace: d}, now)
+		})
+
+		It("delivers datagrams at the opportunities of the trace", func() {
+			var departures []time.Duration
+			for _, size := range []int{1000, 500, 1000, 2000} {
+				departure, ok := p.departure(size, now)
+				Expect(ok).To(BeTrue())
+				departures = append(departures, departure.Sub(now))
+			}
+			// the first two datagrams share the first opportunity, the last one needs two opportunities
+			Expect(departures).To(Equal([]time.Duration{
+				10 * time.Millisecond,
+				10 * time.Millisecond,
+				10 * time.Millisecond,
+				30 * time.Millisecond,
+			}))
+		})
+
+		It("wastes the opportunities without datagrams", func() {
+			departure, _ := p.departure(100, now.Add(15*time.Millisecond))
+			Expect(departure.Sub(now)).To(Equal(30 * time.Millisecond))
+			// the opportunity at 30ms delivered less than an MTU, another datagram fits in it
+			departure, _ = p.departure(100, now.Add(20*time.Millisecond))
+			Expect(departure.Sub(now)).To(Equal(30 * time.Millisecond))
+			departure, _ = p.departure(100, now.Add(35*time.Millisecond))
+			Expect(departure.Sub(now)).To(Equal(40 * time.Millisecond))
+		})
+
+		It("drops datagrams when the queue is full", func() {
+			p.config.QueueSize = 1
+			_, ok := p.departure(1500, now)
+			Expect(ok).To(BeTrue())
+			_, ok = p.departure(1500, now)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("delays datagrams according to the delay trace", func() {
+			network := NewNetwork(42)
+			client := network.AddHost("client")
+			server := network.AddHost("server")
+			// the traces start when the link is connected
+			start := time.Now()
+			l, err := network.Connect(client, "10.0.0.1", server, "10.1.0.1", p.config, LinkConfig{})
+			Expect(err).ToNot(HaveOccurred())
+			s, err := server.ListenUDP(&net.UDPAddr{IP: net.ParseIP("10.1.0.1"), Port: 4433})
+			Expect(err).ToNot(HaveOccurred())
+			c, err := client.ListenUDP(nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = c.WriteTo([]byte("foobar"), s.LocalAddr())
+			Expect(err).ToNot(HaveOccurred())
+			// delivered at 10ms after the link was connected, then delayed by 12ms
+			Expect(s.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+			_, _, err = s.ReadFrom(make([]byte, 100))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+			ab, _ := l.Stats()
+			Expect(ab.Sent).To(BeEquivalentTo(1))
+		})
+	})
+})
@@ -0,0 +1,127 @@
+//go:build ignore
+// +build ignore
+
+// gen generates the synthetic traces of the package
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+)
+
+const (
+	// period is the length of the traces in milliseconds
+	period = 8000
+	// segment is the duration in milliseconds during which the bandwidth of a trace is constant
+	segment = 250
+	// delayStep is the interval in milliseconds between the points of the delay traces
+	delayStep = 500
+	mtu       = 1504
+)
+
+type link struct {
+	name string
+	// bandwidth of the downlink in Mbit/s, the uplink gets upFraction of it
+	minRate, maxRate float64
+	upFraction       float64
+	// probability that a segment starts an outage
+	outage float64
+	// one-way delay in milliseconds
+	minDelay, maxDelay int
+}
+
+var links = []link{
+	{name: "cellular", minRate: 1, maxRate: 12, upFraction: 1. / 3, minDelay: 30, maxDelay: 90},
+	{name: "wifi", minRate: 4, maxRate: 20, upFraction: 1. / 2, outage: 0.04, minDelay: 5, maxDelay: 25},
+}
+
+func main() {
+	r := rand.New(rand.NewSource(1))
+	for _, l := range links {
+		rates := l.rates(r)
+		up := make([]float64, len(rates))
+		for i, rate := range rates {
+			up[i] = rate * l.upFraction
+		}
+		write(l.name+".down", func(w *bufio.Writer) { writeTrace(w, rates) })
+		write(l.name+".up", func(w *bufio.Writer) { writeTrace(w, up) })
+		write(l.name+".delay", func(w *bufio.Writer) { writeDelays(w, r, l.minDelay, l.maxDelay) })
+	}
+}
+
+// rates returns the bandwidth of every segment, following a random walk between the bounds of the link
+func (l link) rates(r *rand.Rand) []float64 {
+	rates := make([]float64, period/segment)
+	rate := (l.minRate + l.maxRate) / 2
+	for i := 0; i < len(rates); i++ {
+		if r.Float64() < l.outage {
+			// an outage of one or two segments
+			rates[i] = 0
+			if i+1 < len(rates) && r.Intn(2) == 0 {
+				i++
+				rates[i] = 0
+			}
+			continue
+		}
+		rate += (r.Float64() - 0.5) * (l.maxRate - l.minRate) / 2
+		if rate < l.minRate {
+			rate = 2*l.minRate - rate
+		}
+		if rate > l.maxRate {
+			rate = 2*l.maxRate - rate
+		}
+		rates[i] = rate
+	}
+	return rates
+}
+
+// writeTrace writes the delivery opportunities of a packet-delivery trace with the given bandwidths
+func writeTrace(w *bufio.Writer, rates []float64) {
+	var credit float64
+	last := 0
+	for ms := 1; ms <= period; ms++ {
+		// bytes per millisecond
+		credit += rates[(ms-1)/segment] * 1e6 / 8 / 1000
+		for credit >= mtu {
+			credit -= mtu
+			fmt.Fprintln(w, ms)
+			last = ms
+		}
+	}
+	// the trace repeats after its last opportunity
+	if last < period {
+		fmt.Fprintln(w, period)
+	}
+}
+
+// writeDelays writes a delay trace following a random walk, that ends where it started to repeat smoothly
+func writeDelays(w *bufio.Writer, r *rand.Rand, min, max int) {
+	first := (min + max) / 2
+	delay := first
+	for ms := 0; ms < period; ms += delayStep {
+		fmt.Fprintln(w, ms, delay)
+		delay += r.Intn(max-min+1) - (max-min)/2
+		if delay < min {
+			delay = 2*min - delay
+		}
+		if delay > max {
+			delay = 2*max - delay
+		}
+	}
+	fmt.Fprintln(w, period, first)
+}
+
+func write(filename string, f func(*bufio.Writer)) {
+	file, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	f(w)
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+}
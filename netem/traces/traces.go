@@ -0,0 +1,66 @@
+// Package traces bundles small synthetic Mahimahi traces for the links of the netem emulator.
+//
+// Every link has an uplink and a downlink packet-delivery trace, and a delay trace used for both directions.
+// The traces are generated by gen.go, run go generate to regenerate them.
+package traces
+
+//go:generate go run gen.go
+
+import (
+	"path"
+	"runtime"
+
+	"github.com/lucas-clemente/quic-go/netem"
+)
+
+const (
+	// Cellular is an LTE link whose bandwidth swings between 1 and 12 Mbit/s downstream and a third of it upstream,
+	// with a one-way delay between 30 and 90 ms
+	Cellular = "cellular"
+	// WiFi is a link between 4 and 20 Mbit/s downstream and half of it upstream, with a one-way delay between 5 and
+	// 25 ms and outages of a few hundred milliseconds
+	WiFi = "wifi"
+)
+
+var tracesPath string
+
+func init() {
+	_, filename, _, ok := runtime.Caller(0)
+	if !ok {
+		panic("Failed to get current frame")
+	}
+	tracesPath = path.Dir(filename)
+}
+
+// UplinkPath returns the path of the uplink packet-delivery trace of a link
+func UplinkPath(name string) string {
+	return path.Join(tracesPath, name+".up")
+}
+
+// DownlinkPath returns the path of the downlink packet-delivery trace of a link
+func DownlinkPath(name string) string {
+	return path.Join(tracesPath, name+".down")
+}
+
+// DelayPath returns the path of the delay trace of a link
+func DelayPath(name string) string {
+	return path.Join(tracesPath, name+".delay")
+}
+
+// Load returns the configurations of the uplink and the downlink of a link, replaying its traces.
+// The other fields of the configurations, like the queue size, can be set by the caller.
+func Load(name string) (up, down netem.LinkConfig, err error) {
+	delay, err := netem.LoadDelayTrace(DelayPath(name))
+	if err != nil {
+		return
+	}
+	if up.Trace, err = netem.LoadTrace(UplinkPath(name)); err != nil {
+		return
+	}
+	if down.Trace, err = netem.LoadTrace(DownlinkPath(name)); err != nil {
+		return
+	}
+	up.DelayTrace = delay
+	down.DelayTrace = delay
+	return
+}
@@ -0,0 +1,13 @@
+package traces
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTraces(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "traces Suite")
+}
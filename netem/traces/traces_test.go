@@ -0,0 +1,36 @@
+package traces
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traces", func() {
+	It("loads the cellular traces", func() {
+		up, down, err := Load(Cellular)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(down.Trace.Period()).To(Equal(8 * time.Second))
+		// between 1 and 12 Mbit/s downstream, a third of it upstream
+		Expect(down.Trace.Bandwidth()).To(BeNumerically("~", 6.5e6/8, 5.5e6/8))
+		Expect(up.Trace.Bandwidth()).To(BeNumerically("~", down.Trace.Bandwidth()/3, 10000))
+		Expect(up.DelayTrace).To(Equal(down.DelayTrace))
+		for t := time.Duration(0); t < 10*time.Second; t += 100 * time.Millisecond {
+			Expect(down.DelayTrace.Delay(t)).To(BeNumerically(">=", 30*time.Millisecond))
+			Expect(down.DelayTrace.Delay(t)).To(BeNumerically("<=", 90*time.Millisecond))
+		}
+	})
+
+	It("loads the WiFi traces", func() {
+		up, down, err := Load(WiFi)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(down.Trace.Bandwidth()).To(BeNumerically(">", up.Trace.Bandwidth()))
+		Expect(down.Trace.Bandwidth()).To(BeNumerically("<=", 20e6/8))
+	})
+
+	It("returns an error for an unknown link", func() {
+		_, _, err := Load("foobar")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,161 @@
+package quic
+
+import "sync"
+
+// minDeadlineMissSamples is the number of packets with a deadline the peer must have reported before the deadline miss rate is checked
+const minDeadlineMissSamples = 100
+
+// A ConnectionObserver is notified of the path, budget and scheduler events of the sessions it is configured for.
+// Its methods are called in order, from a goroutine of the session that doesn't hold any lock,
+// so they may call the methods of the session, e.g. ConnectionStats.
+type ConnectionObserver interface {
+	// OnPathUp is called when a path is created, with its initial statistics
+	OnPathUp(sess Session, path PathStats)
+	// OnPathDown is called when a path is closed
+	OnPathDown(sess Session, pathID PathID)
+	// OnPathDegraded is called when a path is marked as potentially failed, after a retransmission timeout without any activity on it,
+	// or when the peer reports it as failed. Schedulers avoid such paths until a packet is received on them again.
+	OnPathDegraded(sess Session, pathID PathID)
+	// OnBudgetExhausted is called once the packets sent on paths with a cost have spent the whole CostBudget
+	OnBudgetExhausted(sess Session, budget float64)
+	// OnSchedulerSwitch is called when a batch scheduler changes the solver it uses, e.g. when BatchLinOpt falls back to EDF.
+	// The solvers are the ones of the decision log, see the schedlog package.
+	OnSchedulerSwitch(sess Session, from, to string)
+	// OnDeadlineMissRateAbove is called when the fraction of the packets with a deadline that missed it,
+	// as reported by the peer, rises above the DeadlineMissRateThreshold.
+	// It is called again if the rate falls below the threshold and rises above it later.
+	OnDeadlineMissRateAbove(sess Session, rate float64)
+}
+
+// BaseConnectionObserver is a ConnectionObserver that ignores all events.
+// It can be embedded to only implement some of the callbacks.
+type BaseConnectionObserver struct{}
+
+var _ ConnectionObserver = BaseConnectionObserver{}
+
+// OnPathUp does nothing
+func (BaseConnectionObserver) OnPathUp(Session, PathStats) {}
+
+// OnPathDown does nothing
+func (BaseConnectionObserver) OnPathDown(Session, PathID) {}
+
+// OnPathDegraded does nothing
+func (BaseConnectionObserver) OnPathDegraded(Session, PathID) {}
+
+// OnBudgetExhausted does nothing
+func (BaseConnectionObserver) OnBudgetExhausted(Session, float64) {}
+
+// OnSchedulerSwitch does nothing
+func (BaseConnectionObserver) OnSchedulerSwitch(Session, string, string) {}
+
+// OnDeadlineMissRateAbove does nothing
+func (BaseConnectionObserver) OnDeadlineMissRateAbove(Session, float64) {}
+
+// observerQueue calls the callbacks of a ConnectionObserver in order, from its own goroutine,
+// so that the session never waits for the observer. The goroutine exits when the queue is empty.
+type observerQueue struct {
+	mutex   sync.Mutex
+	events  []func()
+	running bool
+}
+
+func (q *observerQueue) push(event func()) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.events = append(q.events, event)
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *observerQueue) run() {
+	for {
+		q.mutex.Lock()
+		if len(q.events) == 0 {
+			q.running = false
+			q.mutex.Unlock()
+			return
+		}
+		event := q.events[0]
+		q.events = q.events[1:]
+		q.mutex.Unlock()
+		event()
+	}
+}
+
+// notify queues a callback of the observer of the session, if any
+func (s *session) notify(callback func(o ConnectionObserver)) {
+	o := s.config.Observer
+	if o == nil {
+		return
+	}
+	s.observerEvents.push(func() { callback(o) })
+}
+
+// notifyPathUp notifies the observer that a path was created, once its cost is known
+func (s *session) notifyPathUp(pth *path) {
+	if s.config.Observer == nil {
+		return
+	}
+	stats := pth.stats()
+	s.notify(func(o ConnectionObserver) { o.OnPathUp(s, stats) })
+}
+
+// setPotentiallyFailed marks the path as potentially failed, notifying the observer if it wasn't already
+func (p *path) setPotentiallyFailed() {
+	if p.potentiallyFailed.Get() {
+		return
+	}
+	p.potentiallyFailed.Set(true)
+	p.sess.notify(func(o ConnectionObserver) { o.OnPathDegraded(p.sess, p.pathID) })
+}
+
+// checkDeadlineMissRate notifies the observer when the deadline miss rate reported by the peer rises above the threshold
+func (s *session) checkDeadlineMissRate() {
+	if s.config.Observer == nil || s.config.DeadlineMissRateThreshold <= 0 {
+		return
+	}
+	var withDeadline, meetDeadline uint64
+	s.pathsLock.RLock()
+	for _, pth := range s.paths {
+		w, m := pth.sentPacketHandler.GetDeadlineStatistics()
+		withDeadline += w
+		meetDeadline += m
+	}
+	s.pathsLock.RUnlock()
+	if withDeadline < minDeadlineMissSamples {
+		return
+	}
+	rate := float64(withDeadline-meetDeadline) / float64(withDeadline)
+	if rate <= s.config.DeadlineMissRateThreshold {
+		s.deadlineMissRateAbove = false
+		return
+	}
+	if !s.deadlineMissRateAbove {
+		s.deadlineMissRateAbove = true
+		s.notify(func(o ConnectionObserver) { o.OnDeadlineMissRateAbove(s, rate) })
+	}
+}
+
+// checkBudget notifies the observer once the cost budget is spent
+func (sch *scheduler) checkBudget(s *session) {
+	if sch.budgetExhausted || s.config.CostBudget <= 0 || sch.totalCost < s.config.CostBudget {
+		return
+	}
+	sch.budgetExhausted = true
+	budget := s.config.CostBudget
+	s.notify(func(o ConnectionObserver) { o.OnBudgetExhausted(s, budget) })
+}
+
+// checkSolverSwitch notifies the observer when the solver of the batch scheduler changes
+func (sch *scheduler) checkSolverSwitch(s *session) {
+	if sch.solver == sch.previousSolver {
+		return
+	}
+	from, to := sch.previousSolver, sch.solver
+	sch.previousSolver = sch.solver
+	if from != "" {
+		s.notify(func(o ConnectionObserver) { o.OnSchedulerSwitch(s, from, to) })
+	}
+}
@@ -0,0 +1,180 @@
+package quic
+
+import (
+	"fmt"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/schedlog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockObserver struct {
+	BaseConnectionObserver
+	events chan string
+}
+
+func (o *mockObserver) OnPathUp(_ Session, path PathStats) {
+	o.events <- fmt.Sprintf("up %d, cost %g", path.PathID, path.Cost)
+}
+
+func (o *mockObserver) OnPathDown(_ Session, pathID PathID) {
+	o.events <- fmt.Sprintf("down %d", pathID)
+}
+
+func (o *mockObserver) OnPathDegraded(_ Session, pathID PathID) {
+	o.events <- fmt.Sprintf("degraded %d", pathID)
+}
+
+func (o *mockObserver) OnBudgetExhausted(_ Session, budget float64) {
+	o.events <- fmt.Sprintf("budget %g", budget)
+}
+
+func (o *mockObserver) OnSchedulerSwitch(_ Session, from, to string) {
+	o.events <- fmt.Sprintf("switch %s -> %s", from, to)
+}
+
+func (o *mockObserver) OnDeadlineMissRateAbove(_ Session, rate float64) {
+	o.events <- fmt.Sprintf("miss rate %g", rate)
+}
+
+// deadlineStatsSentPacketHandler reports fixed deadline statistics
+type deadlineStatsSentPacketHandler struct {
+	ackhandler.SentPacketHandler
+	withDeadline, meetDeadline uint64
+}
+
+func (h *deadlineStatsSentPacketHandler) GetDeadlineStatistics() (uint64, uint64) {
+	return h.withDeadline, h.meetDeadline
+}
+
+var _ = Describe("Connection observer", func() {
+	var (
+		sess     *session
+		observer *mockObserver
+	)
+
+	BeforeEach(func() {
+		observer = &mockObserver{events: make(chan string, 10)}
+		sess = newTestSession(&Config{Observer: observer}, &scheduler{SchedulerName: "BatchLinOpt"})
+	})
+
+	It("doesn't notify without an observer", func() {
+		sess.config.Observer = nil
+		sess.paths[1] = newTestPath(sess, 1, 0)
+		sess.paths[1].setPotentiallyFailed()
+		Expect(sess.paths[1].potentiallyFailed.Get()).To(BeTrue())
+		sess.notifyPathUp(sess.paths[1])
+		Consistently(observer.events).ShouldNot(Receive())
+	})
+
+	It("notifies the paths coming up with their cost", func() {
+		pth := newTestPath(sess, 2, 0)
+		pth.setCost(1.5)
+		sess.notifyPathUp(pth)
+		Eventually(observer.events).Should(Receive(Equal("up 2, cost 1.5")))
+	})
+
+	It("notifies degraded paths once", func() {
+		sess.paths[1] = newTestPath(sess, 1, 0)
+		sess.paths[1].setPotentiallyFailed()
+		sess.paths[1].setPotentiallyFailed()
+		Eventually(observer.events).Should(Receive(Equal("degraded 1")))
+		Consistently(observer.events).ShouldNot(Receive())
+		// a packet received on the path clears the flag, a new failure is notified again
+		sess.paths[1].potentiallyFailed.Set(false)
+		sess.paths[1].setPotentiallyFailed()
+		Eventually(observer.events).Should(Receive(Equal("degraded 1")))
+	})
+
+	It("calls the callbacks in order", func() {
+		for i := 1; i <= 5; i++ {
+			pathID := protocol.PathID(i)
+			sess.notify(func(o ConnectionObserver) { o.OnPathDown(sess, pathID) })
+		}
+		for i := 1; i <= 5; i++ {
+			Eventually(observer.events).Should(Receive(Equal(fmt.Sprintf("down %d", i))))
+		}
+	})
+
+	It("notifies once the budget is spent", func() {
+		sess.config.CostBudget = 10
+		sch := sess.scheduler
+		sch.totalCost = 9
+		sch.checkBudget(sess)
+		Consistently(observer.events).ShouldNot(Receive())
+		sch.totalCost = 10
+		sch.checkBudget(sess)
+		sch.totalCost = 11
+		sch.checkBudget(sess)
+		Eventually(observer.events).Should(Receive(Equal("budget 10")))
+		Consistently(observer.events).ShouldNot(Receive())
+	})
+
+	It("doesn't notify an unlimited budget", func() {
+		sess.scheduler.totalCost = 100
+		sess.scheduler.checkBudget(sess)
+		Consistently(observer.events).ShouldNot(Receive())
+	})
+
+	It("notifies the solver switches of the batch scheduler", func() {
+		sch := sess.scheduler
+		sch.recordSolver(schedlog.SolverLinOpt)
+		sch.checkSolverSwitch(sess)
+		sch.recordSolver(schedlog.SolverLinOpt)
+		sch.checkSolverSwitch(sess)
+		Consistently(observer.events).ShouldNot(Receive())
+		sch.recordSolver(schedlog.SolverEDF)
+		sch.checkSolverSwitch(sess)
+		Eventually(observer.events).Should(Receive(Equal("switch linopt -> edf")))
+		sch.recordSolver(schedlog.SolverLinOpt)
+		sch.checkSolverSwitch(sess)
+		Eventually(observer.events).Should(Receive(Equal("switch edf -> linopt")))
+	})
+
+	Context("deadline miss rate", func() {
+		var handlers []*deadlineStatsSentPacketHandler
+
+		BeforeEach(func() {
+			sess.config.DeadlineMissRateThreshold = 0.2
+			handlers = nil
+			for _, pathID := range []protocol.PathID{1, 3} {
+				pth := newTestPath(sess, pathID, 0)
+				h := &deadlineStatsSentPacketHandler{SentPacketHandler: pth.sentPacketHandler}
+				pth.sentPacketHandler = h
+				handlers = append(handlers, h)
+				sess.paths[pathID] = pth
+			}
+		})
+
+		It("waits for enough packets with a deadline", func() {
+			handlers[0].withDeadline = minDeadlineMissSamples - 1
+			sess.checkDeadlineMissRate()
+			Consistently(observer.events).ShouldNot(Receive())
+		})
+
+		It("notifies when the rate of all paths rises above the threshold", func() {
+			handlers[0].withDeadline, handlers[0].meetDeadline = 100, 90
+			handlers[1].withDeadline, handlers[1].meetDeadline = 100, 90
+			sess.checkDeadlineMissRate()
+			Consistently(observer.events).ShouldNot(Receive())
+			handlers[1].meetDeadline = 50
+			sess.checkDeadlineMissRate()
+			sess.checkDeadlineMissRate()
+			Eventually(observer.events).Should(Receive(Equal("miss rate 0.3")))
+			Consistently(observer.events).ShouldNot(Receive())
+		})
+
+		It("notifies again after the rate fell below the threshold", func() {
+			handlers[0].withDeadline, handlers[0].meetDeadline = 100, 50
+			sess.checkDeadlineMissRate()
+			Eventually(observer.events).Should(Receive(Equal("miss rate 0.5")))
+			handlers[0].withDeadline, handlers[0].meetDeadline = 200, 190
+			sess.checkDeadlineMissRate()
+			handlers[0].withDeadline, handlers[0].meetDeadline = 300, 190
+			sess.checkDeadlineMissRate()
+			Eventually(observer.events).Should(Receive(Equal(fmt.Sprintf("miss rate %g", 110.0/300))))
+		})
+	})
+})
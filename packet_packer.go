@@ -9,6 +9,7 @@ import (
 	"github.com/lucas-clemente/quic-go/ackhandler"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
 
@@ -19,6 +20,11 @@ type packedPacket struct {
 	encryptionLevel protocol.EncryptionLevel
 	//czy
 	m_deadline time.Time
+	curNotSent uint8
+	alpha      uint8
+	// whether the scheduler held the packet back for a later batch before sending it
+	deferred    bool
+	heldForCost bool
 }
 
 type packetPacker struct {
@@ -33,6 +39,8 @@ type packetPacker struct {
 	controlFrames []wire.Frame
 	stopWaiting   map[protocol.PathID]*wire.StopWaitingFrame
 	ackFrame      map[protocol.PathID]*wire.AckFrame
+
+	logger utils.Logger
 }
 
 func newPacketPacker(connectionID protocol.ConnectionID,
@@ -41,6 +49,7 @@ func newPacketPacker(connectionID protocol.ConnectionID,
 	streamFramer *streamFramer,
 	perspective protocol.Perspective,
 	version protocol.VersionNumber,
+	logger utils.Logger,
 ) *packetPacker {
 	return &packetPacker{
 		cryptoSetup:          cryptoSetup,
@@ -51,6 +60,7 @@ func newPacketPacker(connectionID protocol.ConnectionID,
 		streamFramer:         streamFramer,
 		stopWaiting:          make(map[protocol.PathID]*wire.StopWaitingFrame),
 		ackFrame:             make(map[protocol.PathID]*wire.AckFrame),
+		logger:               logger,
 	}
 }
 
@@ -60,7 +70,7 @@ func (p *packetPacker) PackConnectionClose(ccf *wire.ConnectionCloseFrame, pth *
 	encLevel, sealer := p.cryptoSetup.GetSealer()
 	ph := p.getPublicHeader(encLevel, pth)
 	raw, err := p.writeAndSealPacket(ph, frames, sealer, pth)
-	fmt.Println("PackConnectionClose--contains a ConnectionCloseFrame")
+	p.logger.Debugf("PackConnectionClose--contains a ConnectionCloseFrame")
 	return &packedPacket{
 		number:          ph.PacketNumber,
 		raw:             raw,
@@ -71,26 +81,26 @@ func (p *packetPacker) PackConnectionClose(ccf *wire.ConnectionCloseFrame, pth *
 
 // PackPing packs a packet that ONLY contains a PingFrame
 func (p *packetPacker) PackPing(pf *wire.PingFrame, pth *path) (*packedPacket, error) {
-	fmt.Println("PackPing--contains a PingFrame")
+	p.logger.Debugf("PackPing--contains a PingFrame")
 	// Add the PingFrame in front of the controlFrames
 	pth.SetLeastUnacked(pth.sentPacketHandler.GetLeastUnacked())
 	p.controlFrames = append([]wire.Frame{pf}, p.controlFrames...)
 	//czy:this deadline is no value
 	var deadline time.Time
-	fmt.Println("PackPing--deadline:", deadline)
+	p.logger.Debugf("PackPing--deadline: %s", deadline)
 	curNotSent := uint8(0)
 	return p.PackPacket(pth, deadline, curNotSent, uint8(1))
 }
 
 func (p *packetPacker) PackAckPacket(pth *path) (*packedPacket, error) {
-	fmt.Println("PackAckPacket--contains a AckFrame")
+	p.logger.Debugf("PackAckPacket--contains a AckFrame")
 	if p.ackFrame[pth.pathID] == nil {
 		return nil, errors.New("packet packer BUG: no ack frame queued")
 	}
 	encLevel, sealer := p.cryptoSetup.GetSealer()
 	ph := p.getPublicHeader(encLevel, pth)
 	frames := []wire.Frame{p.ackFrame[pth.pathID]}
-	fmt.Println("ackFrame:", p.ackFrame[pth.pathID])
+	p.logger.Debugf("ackFrame: %#v", p.ackFrame[pth.pathID])
 	//fmt.Println("frames:", frames)
 	if p.stopWaiting[pth.pathID] != nil {
 		p.stopWaiting[pth.pathID].PacketNumber = ph.PacketNumber
@@ -110,7 +120,7 @@ func (p *packetPacker) PackAckPacket(pth *path) (*packedPacket, error) {
 
 // PackHandshakeRetransmission retransmits a handshake packet, that was sent with less than forward-secure encryption
 func (p *packetPacker) PackHandshakeRetransmission(packet *ackhandler.Packet, pth *path) (*packedPacket, error) {
-	fmt.Println("PackHandshakeRetransmission--contains a StopWaitingFrame")
+	p.logger.Debugf("PackHandshakeRetransmission--contains a StopWaitingFrame")
 	if packet.EncryptionLevel == protocol.EncryptionForwardSecure {
 		return nil, errors.New("PacketPacker BUG: forward-secure encrypted handshake packets don't need special treatment")
 	}
@@ -138,7 +148,7 @@ func (p *packetPacker) PackHandshakeRetransmission(packet *ackhandler.Packet, pt
 // PackPacket packs a new packet
 // the other controlFrames are sent in the next packet, but might be queued and sent in the next packet if the packet would overflow MaxPacketSize otherwise
 func (p *packetPacker) PackPacket(pth *path, deadline time.Time, curNotSent uint8, alpha uint8) (*packedPacket, error) {
-	fmt.Println("PackPacket!")
+	p.logger.Debugf("PackPacket!")
 	if p.streamFramer.HasCryptoStreamFrame() {
 		return p.packCryptoPacket(pth)
 	}
@@ -148,13 +158,12 @@ func (p *packetPacker) PackPacket(pth *path, deadline time.Time, curNotSent uint
 	//czy:Add deadline to publicHeader(encLevel, pth, deadline)
 	publicHeader := p.getPublicHeader(encLevel, pth)
 	//czy
-	publicHeader.Deadline = deadline
 	publicHeader.CurNotSent = curNotSent
 	publicHeader.Alpha = alpha
 
-	publicHeaderLength, err := publicHeader.GetLength(p.perspective)
+	publicHeaderLength, err := publicHeader.GetLength(p.version, p.perspective)
 	if err != nil {
-		fmt.Println("GetLength error!")
+		p.logger.Debugf("GetLength error!")
 		return nil, err
 	}
 	if p.stopWaiting[pth.pathID] != nil {
@@ -177,28 +186,32 @@ func (p *packetPacker) PackPacket(pth *path, deadline time.Time, curNotSent uint
 		maxSize := protocol.MaxPacketSize - protocol.ByteCount(sealer.Overhead()) - publicHeaderLength
 		payloadFrames, err = p.composeNextPacket(maxSize, p.canSendData(encLevel), pth)
 		if err != nil {
-			fmt.Println("composeNextPacket error!")
+			p.logger.Debugf("composeNextPacket error!")
 			return nil, err
 		}
 	}
 
 	// Check if we have enough frames to send
 	if len(payloadFrames) == 0 {
-		fmt.Println("payloadFrame is 0.")
+		p.logger.Debugf("payloadFrame is 0.")
 		return nil, nil
 	}
-	// Don't send out packets that only contain a StopWaitingFrame
-	if len(payloadFrames) == 1 && p.stopWaiting[pth.pathID] != nil {
-		fmt.Println("contain a StopWaitingFrame.")
+	// Don't send out packets that only contain a StopWaitingFrame. A ping is sent without it.
+	if !isPing && len(payloadFrames) == 1 && p.stopWaiting[pth.pathID] != nil {
+		p.logger.Debugf("contain a StopWaitingFrame.")
 		return nil, nil
 	}
 	p.stopWaiting[pth.pathID] = nil
 	p.ackFrame[pth.pathID] = nil
 
+	// The packet has to arrive before the most urgent of its frames
+	deadline = earliestDeadline(payloadFrames, deadline)
+	publicHeader.Deadline = deadline
+
 	//czy:将包头和payload写成数据raw （byte）
 	raw, err := p.writeAndSealPacket(publicHeader, payloadFrames, sealer, pth)
 	if err != nil {
-		fmt.Println("writeAndSeadPacket error!")
+		p.logger.Debugf("writeAndSeadPacket error!")
 		return nil, err
 	}
 	//only packet with frames can be add deadline
@@ -208,13 +221,29 @@ func (p *packetPacker) PackPacket(pth *path, deadline time.Time, curNotSent uint
 		frames:          payloadFrames,
 		encryptionLevel: encLevel,
 		m_deadline:      deadline,
+		curNotSent:      curNotSent,
+		alpha:           alpha,
 	}, nil
 }
 
+// earliestDeadline returns the earliest deadline among the StreamFrames and the given deadline, ignoring zero values
+func earliestDeadline(frames []wire.Frame, deadline time.Time) time.Time {
+	for _, f := range frames {
+		sf, ok := f.(*wire.StreamFrame)
+		if !ok || sf.Deadline.IsZero() {
+			continue
+		}
+		if deadline.IsZero() || sf.Deadline.Before(deadline) {
+			deadline = sf.Deadline
+		}
+	}
+	return deadline
+}
+
 func (p *packetPacker) packCryptoPacket(pth *path) (*packedPacket, error) {
 	encLevel, sealer := p.cryptoSetup.GetSealerForCryptoStream()
 	publicHeader := p.getPublicHeader(encLevel, pth)
-	publicHeaderLength, err := publicHeader.GetLength(p.perspective)
+	publicHeaderLength, err := publicHeader.GetLength(p.version, p.perspective)
 	if err != nil {
 		return nil, err
 	}
@@ -224,7 +253,7 @@ func (p *packetPacker) packCryptoPacket(pth *path) (*packedPacket, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("packCryptoPacket--PacketNumber:", publicHeader.PacketNumber)
+	p.logger.Debugf("packCryptoPacket--PacketNumber: %d", publicHeader.PacketNumber)
 	return &packedPacket{
 		number:          publicHeader.PacketNumber,
 		raw:             raw,
@@ -243,7 +272,7 @@ func (p *packetPacker) composeNextPacket(
 
 	// STOP_WAITING and ACK will always fit
 	if p.stopWaiting[pth.pathID] != nil {
-		fmt.Println("stopWaiting is not nil.")
+		p.logger.Debugf("stopWaiting is not nil.")
 		payloadFrames = append(payloadFrames, p.stopWaiting[pth.pathID])
 		l, err := p.stopWaiting[pth.pathID].MinLength(p.version)
 		if err != nil {
@@ -252,7 +281,7 @@ func (p *packetPacker) composeNextPacket(
 		payloadLength += l
 	}
 	if p.ackFrame[pth.pathID] != nil {
-		fmt.Println("ackFrame is not nil.")
+		p.logger.Debugf("ackFrame is not nil.")
 		payloadFrames = append(payloadFrames, p.ackFrame[pth.pathID])
 		l, err := p.ackFrame[pth.pathID].MinLength(p.version)
 		if err != nil {
@@ -2,13 +2,14 @@ package quic
 
 import (
 	"bytes"
-	"math"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/ackhandler"
 	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -70,10 +71,10 @@ var _ = Describe("Packet packer", func() {
 		streamsMap := newStreamsMap(nil, protocol.PerspectiveServer, nil)
 		streamsMap.streams[1] = cryptoStream
 		streamsMap.openStreams = []protocol.StreamID{1}
-		streamFramer = newStreamFramer(streamsMap, nil)
+		streamFramer = newStreamFramer(streamsMap, nil, protocol.VersionWhatever)
 
 		pth = &path{
-			sentPacketHandler:     ackhandler.NewSentPacketHandler(&congestion.RTTStats{}, nil, nil),
+			sentPacketHandler:     ackhandler.NewSentPacketHandler(protocol.VersionWhatever, &congestion.RTTStats{}, nil, nil, utils.DefaultLogger),
 			packetNumberGenerator: newPacketNumberGenerator(protocol.SkipPacketAveragePeriodLength),
 		}
 
@@ -85,6 +86,7 @@ var _ = Describe("Packet packer", func() {
 			perspective:          protocol.PerspectiveServer,
 			stopWaiting:          make(map[protocol.PathID]*wire.StopWaitingFrame),
 			ackFrame:             make(map[protocol.PathID]*wire.AckFrame),
+			logger:               utils.DefaultLogger,
 		}
 		publicHeaderLen = 1 + 8 + 2 // 1 flag byte, 8 connection ID, 2 packet number
 		maxFrameSize = protocol.MaxPacketSize - protocol.ByteCount((&mockSealer{}).Overhead()) - publicHeaderLen
@@ -92,7 +94,7 @@ var _ = Describe("Packet packer", func() {
 	})
 
 	It("returns nil when no packet is queued", func() {
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(p).To(BeNil())
 		Expect(err).ToNot(HaveOccurred())
 	})
@@ -103,7 +105,7 @@ var _ = Describe("Packet packer", func() {
 			Data:     []byte{0xDE, 0xCA, 0xFB, 0xAD},
 		}
 		streamFramer.AddFrameForRetransmission(f)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p).ToNot(BeNil())
 		b := &bytes.Buffer{}
@@ -119,7 +121,7 @@ var _ = Describe("Packet packer", func() {
 			Data:     []byte("foobar"),
 		}
 		streamFramer.AddFrameForRetransmission(f)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p.encryptionLevel).To(Equal(protocol.EncryptionForwardSecure))
 	})
@@ -184,7 +186,7 @@ var _ = Describe("Packet packer", func() {
 	It("packs only control frames", func() {
 		packer.QueueControlFrame(&wire.RstStreamFrame{}, pth)
 		packer.QueueControlFrame(&wire.WindowUpdateFrame{}, pth)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(p).ToNot(BeNil())
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p.frames).To(HaveLen(2))
@@ -193,11 +195,11 @@ var _ = Describe("Packet packer", func() {
 
 	It("increases the packet number", func() {
 		packer.QueueControlFrame(&wire.RstStreamFrame{}, pth)
-		p1, err := packer.PackPacket(pth)
+		p1, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p1).ToNot(BeNil())
 		packer.QueueControlFrame(&wire.RstStreamFrame{}, pth)
-		p2, err := packer.PackPacket(pth)
+		p2, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p2).ToNot(BeNil())
 		Expect(p2.number).To(BeNumerically(">", p1.number))
@@ -208,7 +210,7 @@ var _ = Describe("Packet packer", func() {
 		swf := &wire.StopWaitingFrame{LeastUnacked: 10}
 		packer.QueueControlFrame(&wire.RstStreamFrame{}, pth)
 		packer.QueueControlFrame(swf, pth)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p).ToNot(BeNil())
 		Expect(p.frames).To(HaveLen(2))
@@ -221,7 +223,7 @@ var _ = Describe("Packet packer", func() {
 		swf := &wire.StopWaitingFrame{LeastUnacked: packetNumber - 0x100}
 		packer.QueueControlFrame(&wire.RstStreamFrame{}, pth)
 		packer.QueueControlFrame(swf, pth)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p.frames[0].(*wire.StopWaitingFrame).PacketNumberLen).To(Equal(protocol.PacketNumberLen4))
 	})
@@ -229,14 +231,14 @@ var _ = Describe("Packet packer", func() {
 	It("does not pack a packet containing only a StopWaitingFrame", func() {
 		swf := &wire.StopWaitingFrame{LeastUnacked: 10}
 		packer.QueueControlFrame(swf, pth)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(p).To(BeNil())
 		Expect(err).ToNot(HaveOccurred())
 	})
 
 	It("packs a packet if it has queued control frames, but no new control frames", func() {
 		packer.controlFrames = []wire.Frame{&wire.BlockedFrame{StreamID: 0}}
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p).ToNot(BeNil())
 	})
@@ -247,7 +249,7 @@ var _ = Describe("Packet packer", func() {
 		packer.controlFrames = []wire.Frame{&wire.BlockedFrame{StreamID: 0}}
 		packer.connectionID = 0x1337
 		packer.version = 123
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p).ToNot(BeNil())
 		hdr, err := wire.ParsePublicHeader(bytes.NewReader(p.raw), protocol.PerspectiveClient, packer.version)
@@ -261,7 +263,7 @@ var _ = Describe("Packet packer", func() {
 		packer.cryptoSetup.(*mockCryptoSetup).encLevelSeal = protocol.EncryptionForwardSecure
 		packer.controlFrames = []wire.Frame{&wire.BlockedFrame{StreamID: 0}}
 		packer.connectionID = 0x1337
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p).ToNot(BeNil())
 		hdr, err := wire.ParsePublicHeader(bytes.NewReader(p.raw), protocol.PerspectiveClient, packer.version)
@@ -308,7 +310,7 @@ var _ = Describe("Packet packer", func() {
 
 	It("only increases the packet number when there is an actual packet to send", func() {
 		pth.packetNumberGenerator.nextToSkip = 1000
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(p).To(BeNil())
 		Expect(err).ToNot(HaveOccurred())
 		Expect(pth.packetNumberGenerator.Peek()).To(Equal(protocol.PacketNumber(1)))
@@ -317,7 +319,7 @@ var _ = Describe("Packet packer", func() {
 			Data:     []byte{0xDE, 0xCA, 0xFB, 0xAD},
 		}
 		streamFramer.AddFrameForRetransmission(f)
-		p, err = packer.PackPacket(pth)
+		p, err = packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(p).ToNot(BeNil())
 		Expect(p.number).To(Equal(protocol.PacketNumber(1)))
@@ -358,12 +360,12 @@ var _ = Describe("Packet packer", func() {
 			}
 			streamFramer.AddFrameForRetransmission(f1)
 			streamFramer.AddFrameForRetransmission(f2)
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.raw).To(HaveLen(int(protocol.MaxPacketSize - 1)))
 			Expect(p.frames).To(HaveLen(1))
 			Expect(p.frames[0].(*wire.StreamFrame).DataLenPresent).To(BeFalse())
-			p, err = packer.PackPacket(pth)
+			p, err = packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.frames).To(HaveLen(1))
 			Expect(p.frames[0].(*wire.StreamFrame).DataLenPresent).To(BeFalse())
@@ -385,7 +387,7 @@ var _ = Describe("Packet packer", func() {
 			streamFramer.AddFrameForRetransmission(f1)
 			streamFramer.AddFrameForRetransmission(f2)
 			streamFramer.AddFrameForRetransmission(f3)
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(p).ToNot(BeNil())
 			Expect(err).ToNot(HaveOccurred())
 			b := &bytes.Buffer{}
@@ -439,23 +441,23 @@ var _ = Describe("Packet packer", func() {
 			}
 			streamFramer.AddFrameForRetransmission(f1)
 			streamFramer.AddFrameForRetransmission(f2)
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.frames).To(HaveLen(1))
 			Expect(p.frames[0].(*wire.StreamFrame).DataLenPresent).To(BeFalse())
 			Expect(p.raw).To(HaveLen(int(protocol.MaxPacketSize)))
-			p, err = packer.PackPacket(pth)
+			p, err = packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(p.frames).To(HaveLen(2))
 			Expect(p.frames[0].(*wire.StreamFrame).DataLenPresent).To(BeTrue())
 			Expect(p.frames[1].(*wire.StreamFrame).DataLenPresent).To(BeFalse())
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.raw).To(HaveLen(int(protocol.MaxPacketSize)))
-			p, err = packer.PackPacket(pth)
+			p, err = packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(p.frames).To(HaveLen(1))
 			Expect(p.frames[0].(*wire.StreamFrame).DataLenPresent).To(BeFalse())
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p).ToNot(BeNil())
-			p, err = packer.PackPacket(pth)
+			p, err = packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p).To(BeNil())
 		})
@@ -468,7 +470,7 @@ var _ = Describe("Packet packer", func() {
 			minLength, _ := f.MinLength(0)
 			f.Data = bytes.Repeat([]byte{'f'}, int(maxFrameSize-minLength+1)) // + 1 since MinceLength is 1 bigger than the actual StreamFrame header
 			streamFramer.AddFrameForRetransmission(f)
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p).ToNot(BeNil())
 			Expect(p.raw).To(HaveLen(int(protocol.MaxPacketSize)))
@@ -498,7 +500,7 @@ var _ = Describe("Packet packer", func() {
 				Data:     []byte("foobar"),
 			}
 			streamFramer.AddFrameForRetransmission(f)
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(p).To(BeNil())
 		})
@@ -511,7 +513,7 @@ var _ = Describe("Packet packer", func() {
 				Data:     []byte("foobar"),
 			}
 			streamFramer.AddFrameForRetransmission(f)
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.encryptionLevel).To(Equal(protocol.EncryptionSecure))
 			Expect(p.frames[0]).To(Equal(f))
@@ -524,7 +526,7 @@ var _ = Describe("Packet packer", func() {
 				Data:     []byte("foobar"),
 			}
 			streamFramer.AddFrameForRetransmission(f)
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p).To(BeNil())
 		})
@@ -532,7 +534,7 @@ var _ = Describe("Packet packer", func() {
 		It("sends unencrypted stream data on the crypto stream", func() {
 			packer.cryptoSetup.(*mockCryptoSetup).encLevelSealCrypto = protocol.EncryptionUnencrypted
 			cryptoStream.dataForWriting = []byte("foobar")
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.encryptionLevel).To(Equal(protocol.EncryptionUnencrypted))
 			Expect(p.frames).To(HaveLen(1))
@@ -542,7 +544,7 @@ var _ = Describe("Packet packer", func() {
 		It("sends encrypted stream data on the crypto stream", func() {
 			packer.cryptoSetup.(*mockCryptoSetup).encLevelSealCrypto = protocol.EncryptionSecure
 			cryptoStream.dataForWriting = []byte("foobar")
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.encryptionLevel).To(Equal(protocol.EncryptionSecure))
 			Expect(p.frames).To(HaveLen(1))
@@ -553,13 +555,62 @@ var _ = Describe("Packet packer", func() {
 			packer.cryptoSetup.(*mockCryptoSetup).encLevelSeal = protocol.EncryptionUnencrypted
 			packer.QueueControlFrame(&wire.AckFrame{}, pth)
 			streamFramer.AddFrameForRetransmission(&wire.StreamFrame{StreamID: 3, Data: []byte("foobar")})
-			p, err := packer.PackPacket(pth)
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.frames).To(HaveLen(1))
 			Expect(func() { _ = p.frames[0].(*wire.AckFrame) }).NotTo(Panic())
 		})
 	})
 
+	Context("deadlines", func() {
+		It("uses the earliest StreamFrame deadline as the packet deadline", func() {
+			now := time.Now()
+			streamFramer.AddFrameForRetransmission(&wire.StreamFrame{StreamID: 3, Data: []byte("foo"), Deadline: now.Add(time.Second)})
+			streamFramer.AddFrameForRetransmission(&wire.StreamFrame{StreamID: 5, Data: []byte("bar"), Deadline: now.Add(time.Millisecond)})
+			p, err := packer.PackPacket(pth, now.Add(time.Minute), 0, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.frames).To(HaveLen(2))
+			Expect(p.m_deadline).To(Equal(now.Add(time.Millisecond)))
+		})
+
+		It("keeps the scheduler deadline if it is earlier", func() {
+			now := time.Now()
+			streamFramer.AddFrameForRetransmission(&wire.StreamFrame{StreamID: 3, Data: []byte("foo"), Deadline: now.Add(time.Second)})
+			p, err := packer.PackPacket(pth, now.Add(time.Millisecond), 0, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.m_deadline).To(Equal(now.Add(time.Millisecond)))
+		})
+
+		It("ignores frames without a deadline", func() {
+			deadline := time.Now().Add(time.Second)
+			frames := []wire.Frame{
+				&wire.StreamFrame{StreamID: 3},
+				&wire.StreamFrame{StreamID: 5, Deadline: deadline},
+				&wire.PingFrame{},
+			}
+			Expect(earliestDeadline(frames, time.Time{})).To(Equal(deadline))
+			Expect(earliestDeadline(frames[:1], time.Time{})).To(BeZero())
+		})
+
+		It("fills a packet with StreamFrames carrying their deadline", func() {
+			packer.version = protocol.VersionMPDeadline
+			streamFramer.version = protocol.VersionMPDeadline
+			now := time.Now()
+			streamFramer.AddFrameForRetransmission(&wire.StreamFrame{StreamID: 3, Data: []byte("foo"), Deadline: now.Add(time.Second)})
+			streamFramer.AddFrameForRetransmission(&wire.StreamFrame{
+				StreamID: 5,
+				Data:     bytes.Repeat([]byte{'f'}, int(protocol.MaxPacketSize)),
+				Deadline: now.Add(time.Millisecond),
+			})
+			p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.raw).To(HaveLen(int(protocol.MaxPacketSize)))
+			Expect(p.frames).To(HaveLen(2))
+			Expect(p.frames[0].(*wire.StreamFrame).Deadline).To(Equal(now.Add(time.Second)))
+			Expect(p.frames[1].(*wire.StreamFrame).Deadline).To(Equal(now.Add(time.Millisecond)))
+		})
+	})
+
 	Context("Blocked frames", func() {
 		It("queues a BLOCKED frame", func() {
 			length := 100
@@ -603,7 +654,7 @@ var _ = Describe("Packet packer", func() {
 
 	It("returns nil if we only have a single STOP_WAITING", func() {
 		packer.QueueControlFrame(&wire.StopWaitingFrame{}, pth)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(p).To(BeNil())
 	})
@@ -611,7 +662,7 @@ var _ = Describe("Packet packer", func() {
 	It("packs a single ACK", func() {
 		ack := &wire.AckFrame{LargestAcked: 42}
 		packer.QueueControlFrame(ack, pth)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(p).ToNot(BeNil())
 		Expect(p.frames[0]).To(Equal(ack))
@@ -620,7 +671,7 @@ var _ = Describe("Packet packer", func() {
 	It("does not return nil if we only have a single ACK but request it to be sent", func() {
 		ack := &wire.AckFrame{}
 		packer.QueueControlFrame(ack, pth)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(p).ToNot(BeNil())
 	})
@@ -628,7 +679,7 @@ var _ = Describe("Packet packer", func() {
 	It("queues a control frame to be sent in the next packet", func() {
 		wuf := &wire.WindowUpdateFrame{StreamID: 5}
 		packer.QueueControlFrame(wuf, pth)
-		p, err := packer.PackPacket(pth)
+		p, err := packer.PackPacket(pth, time.Time{}, 0, 10)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(p.frames).To(HaveLen(1))
 		Expect(p.frames[0]).To(Equal(wuf))
@@ -722,7 +773,7 @@ var _ = Describe("Packet packer", func() {
 			packer.QueueControlFrame(&wire.AckFrame{}, pth)
 			p, err := packer.PackAckPacket(pth)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(p.frames).To(Equal([]wire.Frame{&wire.AckFrame{DelayTime: math.MaxInt64}}))
+			Expect(p.frames).To(Equal([]wire.Frame{&wire.AckFrame{}}))
 		})
 
 		It("packs ACK packets with SWFs", func() {
@@ -731,7 +782,7 @@ var _ = Describe("Packet packer", func() {
 			p, err := packer.PackAckPacket(pth)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(p.frames).To(Equal([]wire.Frame{
-				&wire.AckFrame{DelayTime: math.MaxInt64},
+				&wire.AckFrame{},
 				&wire.StopWaitingFrame{PacketNumber: 1, PacketNumberLen: 2},
 			}))
 		})
@@ -102,10 +102,29 @@ func (u *packetUnpacker) Unpack(publicHeaderBinary []byte, hdr *wire.PublicHeade
 				frame, err = wire.ParsePingFrame(r, u.version)
 			case 0x10:
 				frame, err = wire.ParseAddAddressFrame(r, u.version)
+				if err != nil {
+					err = qerr.Error(qerr.InvalidFrameData, err.Error())
+				}
 			case 0x11:
 				frame, err = wire.ParseClosePathFrame(r, u.version)
+				if err != nil {
+					err = qerr.Error(qerr.InvalidFrameData, err.Error())
+				}
 			case 0x12:
 				frame, err = wire.ParsePathsFrame(r, u.version)
+				if err != nil {
+					err = qerr.Error(qerr.InvalidFrameData, err.Error())
+				}
+			case 0x13:
+				frame, err = wire.ParsePathCostFrame(r, u.version)
+				if err != nil {
+					err = qerr.Error(qerr.InvalidFrameData, err.Error())
+				}
+			case 0x14:
+				frame, err = wire.ParsePathQualityFrame(r, u.version)
+				if err != nil {
+					err = qerr.Error(qerr.InvalidFrameData, err.Error())
+				}
 			default:
 				err = qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("unknown type byte 0x%x", typeByte))
 			}
@@ -189,6 +189,26 @@ var _ = Describe("Packet unpacker", func() {
 		}))
 	})
 
+	It("unpacks PATH_COST frames", func() {
+		f := &wire.PathCostFrame{PathIDs: []protocol.PathID{1}, Costs: []float64{2}}
+		err := f.Write(buf, protocol.VersionWhatever)
+		Expect(err).ToNot(HaveOccurred())
+		setData(buf.Bytes())
+		packet, err := unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packet.frames).To(Equal([]wire.Frame{f}))
+	})
+
+	It("unpacks PATH_QUALITY frames", func() {
+		f := &wire.PathQualityFrame{Paths: []wire.PathQuality{{PathID: 1, MeetRatio: 1, Budget: 4}}}
+		err := f.Write(buf, protocol.VersionWhatever)
+		Expect(err).ToNot(HaveOccurred())
+		setData(buf.Bytes())
+		packet, err := unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(packet.frames).To(Equal([]wire.Frame{f}))
+	})
+
 	It("accepts PING frames", func() {
 		setData([]byte{0x07})
 		packet, err := unpacker.Unpack(hdrBin, hdr, data)
@@ -214,6 +234,11 @@ var _ = Describe("Packet unpacker", func() {
 			0x04: qerr.InvalidWindowUpdateData,
 			0x05: qerr.InvalidBlockedData,
 			0x06: qerr.InvalidStopWaitingData,
+			0x10: qerr.InvalidFrameData,
+			0x11: qerr.InvalidFrameData,
+			0x12: qerr.InvalidFrameData,
+			0x13: qerr.InvalidFrameData,
+			0x14: qerr.InvalidFrameData,
 		} {
 			setData([]byte{b})
 			_, err := unpacker.Unpack(hdrBin, hdr, data)
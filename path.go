@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"math"
 	"time"
 
 	"github.com/lucas-clemente/quic-go/ackhandler"
@@ -9,6 +10,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	"github.com/lucas-clemente/quic-go/qerr"
+	"github.com/lucas-clemente/quic-go/qlog"
 )
 
 const (
@@ -33,6 +35,16 @@ type path struct {
 
 	potentiallyFailed utils.AtomicBool
 
+	// cost of sending a packet on the path, configured locally or advertised by the peer
+	cost    float64
+	hasCost bool
+	// sum of the costs of the packets sent on the path
+	costSpent float64
+	// causes of the deadline misses of the packets sent on the path
+	deadlineMisses DeadlineMisses
+	// view of the path advertised by the peer
+	remoteQuality *wire.PathQuality
+
 	sentPacket chan struct{}
 
 	// It is now the responsibility of the path to keep its packet number
@@ -42,6 +54,8 @@ type path struct {
 	// Used to calculate the next packet number from the truncated wire
 	// representation, and sent back in public reset packets
 	largestRcvdPacketNumber protocol.PacketNumber
+	// bytes of the packets received on the path
+	bytesReceived protocol.ByteCount
 
 	leastUnacked protocol.PacketNumber
 
@@ -61,12 +75,18 @@ func (p *path) setup(oliaSenders map[protocol.PathID]*congestion.OliaSender) {
 		oliaSenders[p.pathID] = cong.(*congestion.OliaSender)
 	}
 
-	sentPacketHandler := ackhandler.NewSentPacketHandler(p.rttStats, cong, p.onRTO)
+	sentPacketHandler := ackhandler.NewSentPacketHandler(p.sess.version, p.rttStats, cong, p.onRTO, p.sess.logger)
+	sentPacketHandler.SetTracer(p.sess.tracer, p.pathID)
+	sentPacketHandler.SetClock(p.sess.clock)
+	if p.sess.config.DecisionRecorder != nil {
+		sentPacketHandler.SetPacketOutcomeCallback(p.onPacketOutcome)
+	}
 
-	now := time.Now()
+	now := p.sess.clock.Now()
 
 	p.sentPacketHandler = sentPacketHandler
 	p.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(p.sess.version)
+	p.receivedPacketHandler.SetClock(p.sess.clock)
 
 	p.packetNumberGenerator = newPacketNumberGenerator(protocol.SkipPacketAveragePeriodLength)
 
@@ -74,12 +94,20 @@ func (p *path) setup(oliaSenders map[protocol.PathID]*congestion.OliaSender) {
 	p.runClosed = make(chan struct{}, 1)
 	p.sentPacket = make(chan struct{}, 1)
 
-	p.timer = utils.NewTimer()
+	p.timer = utils.NewClockTimer(p.sess.clock)
 	p.lastNetworkActivityTime = now
 
 	p.open.Set(true)
 	p.potentiallyFailed.Set(false)
 
+	if p.sess.tracer.Enabled() {
+		p.sess.tracer.Trace(&qlog.PathAdded{
+			PathID:     p.pathID,
+			LocalAddr:  p.conn.LocalAddr(),
+			RemoteAddr: p.conn.RemoteAddr(),
+		})
+	}
+
 	// Once the path is setup, run it
 	go p.run()
 }
@@ -120,6 +148,8 @@ runLoop:
 		}
 	}
 	p.close()
+	p.sess.tracer.Trace(&qlog.PathClosed{PathID: p.pathID})
+	p.sess.notify(func(o ConnectionObserver) { o.OnPathDown(p.sess, p.pathID) })
 	p.runClosed <- struct{}{}
 }
 
@@ -160,7 +190,8 @@ func (p *path) maybeResetTimer() {
 		deadline = utils.MinTime(deadline, lossTime)
 	}
 
-	deadline = utils.MinTime(utils.MaxTime(deadline, time.Now().Add(minPathTimer)), time.Now().Add(maxPathTimer))
+	now := p.sess.clock.Now()
+	deadline = utils.MinTime(utils.MaxTime(deadline, now.Add(minPathTimer)), now.Add(maxPathTimer))
 
 	p.timer.Reset(deadline)
 }
@@ -201,11 +232,11 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 	)
 
 	packet, err := p.sess.unpacker.Unpack(hdr.Raw, hdr, data)
-	if utils.Debug() {
+	if p.sess.logger.Debug() {
 		if err != nil {
-			utils.Debugf("<- Reading packet 0x%x (%d bytes) for connection %x on path %x", hdr.PacketNumber, len(data)+len(hdr.Raw), hdr.ConnectionID, p.pathID)
+			p.sess.logger.Debugf("<- Reading packet 0x%x (%d bytes) for connection %x on path %x", hdr.PacketNumber, len(data)+len(hdr.Raw), hdr.ConnectionID, p.pathID)
 		} else {
-			utils.Debugf("<- Reading packet 0x%x (%d bytes) for connection %x on path %x, %s", hdr.PacketNumber, len(data)+len(hdr.Raw), hdr.ConnectionID, p.pathID, packet.encryptionLevel)
+			p.sess.logger.Debugf("<- Reading packet 0x%x (%d bytes) for connection %x on path %x, %s", hdr.PacketNumber, len(data)+len(hdr.Raw), hdr.ConnectionID, p.pathID, packet.encryptionLevel)
 		}
 	}
 
@@ -214,13 +245,19 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 	if quicErr, ok := err.(*qerr.QuicError); ok && quicErr.ErrorCode == qerr.DecryptionFailure {
 		return err
 	}
+	var addrChanged bool
 	if p.sess.perspective == protocol.PerspectiveServer {
 		// update the remote address, even if unpacking failed for any other reason than a decryption error
+		remoteAddr := p.conn.RemoteAddr()
+		addrChanged = remoteAddr != nil && pkt.remoteAddr != nil && remoteAddr.String() != pkt.remoteAddr.String()
 		p.conn.SetCurrentRemoteAddr(pkt.remoteAddr)
 	}
 	if err != nil {
 		return err
 	}
+	if addrChanged {
+		p.sess.peerAddressChanged(p)
+	}
 
 	p.lastRcvdPacketNumber = hdr.PacketNumber
 	// Only do this after decrupting, so we are sure the packet is not attacker-controlled
@@ -230,10 +267,21 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 	if err = p.receivedPacketHandler.ReceivedPacket(hdr.PacketNumber, isRetransmittable); err != nil {
 		return err
 	}
+	p.bytesReceived += protocol.ByteCount(len(data) + len(hdr.Raw))
 	//czy: statistic num of packet which has deadline and meet deadline
 	if err = p.receivedPacketHandler.StatisticPacketMeet(hdr, pkt.rcvTime); err != nil {
 		return err
 	}
+	p.sess.streamDeadlines.received(packet.frames, pkt.rcvTime)
+	p.sess.tracer.Trace(&qlog.PacketReceived{
+		PathID:       p.pathID,
+		PacketNumber: hdr.PacketNumber,
+		Length:       protocol.ByteCount(len(data) + len(hdr.Raw)),
+		Frames:       packet.frames,
+		Deadline:     hdr.Deadline,
+		CurNotSent:   hdr.CurNotSent,
+		Alpha:        hdr.Alpha,
+	})
 
 	//czy: Update curNotSent in sentPacketHandler, and sent it with ack
 	p.receivedPacketHandler.UpdateCurNotSent(uint16(hdr.CurNotSent))
@@ -249,13 +297,51 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 func (p *path) onRTO(lastSentTime time.Time) bool {
 	// Was there any activity since last sent packet?
 	if p.lastNetworkActivityTime.Before(lastSentTime) {
-		p.potentiallyFailed.Set(true)
+		p.setPotentiallyFailed()
 		p.sess.schedulePathsFrame()
 		return true
 	}
 	return false
 }
 
+func (p *path) setCost(cost float64) {
+	p.cost = cost
+	p.hasCost = true
+}
+
+// getCost returns the cost of sending a packet on the path
+// Paths without a configured or advertised cost fall back to the static costs of the cellular and WiFi paths
+func (p *path) getCost() float64 {
+	if p.hasCost {
+		return p.cost
+	}
+	switch p.pathID {
+	case protocol.PathID(1):
+		return path1Cost
+	case protocol.PathID(3):
+		return path3Cost
+	}
+	return 0
+}
+
+// quality returns the local view of the path, without the cost budget
+func (p *path) quality() wire.PathQuality {
+	q := wire.PathQuality{
+		PathID:      p.pathID,
+		OneWayDelay: p.rttStats.SmoothedRTT() / 2,
+		MeetRatio:   1,
+	}
+	sntPkts, _, sntLost := p.sentPacketHandler.GetStatistics()
+	if sntPkts > 0 {
+		q.LossRate = math.Min(1, float64(sntLost)/float64(sntPkts))
+	}
+	_, hasDeadlinePkts, meetDeadlinePkts := p.receivedPacketHandler.GetStatistics()
+	if hasDeadlinePkts > 0 {
+		q.MeetRatio = math.Min(1, float64(meetDeadlinePkts)/float64(hasDeadlinePkts))
+	}
+	return q
+}
+
 func (p *path) SetLeastUnacked(leastUnacked protocol.PacketNumber) {
 	p.leastUnacked = leastUnacked
 }
@@ -3,7 +3,6 @@ package quic
 import (
 	"errors"
 	"net"
-	"time"
 
 	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -22,13 +21,18 @@ type pathManager struct {
 	remoteAddrs6 []net.UDPAddr
 
 	advertisedLocAddrs map[string]bool
+	// localCosts are the costs configured for the local IPs of the paths, advertisedCosts those the peer was told of
+	localCosts      map[protocol.PathID]float64
+	advertisedCosts map[protocol.PathID]float64
+	// Costs advertised by the peer for paths that do not exist yet
+	remoteCosts map[protocol.PathID]float64
 
 	// TODO (QDC): find a cleaner way
 	oliaSenders map[protocol.PathID]*congestion.OliaSender
 
 	handshakeCompleted chan struct{}
 	runClosed          chan struct{}
-	timer              *time.Timer
+	timer              *utils.Timer
 }
 
 func (pm *pathManager) setup(conn connection) {
@@ -44,9 +48,12 @@ func (pm *pathManager) setup(conn connection) {
 	pm.remoteAddrs4 = make([]net.UDPAddr, 0)
 	pm.remoteAddrs6 = make([]net.UDPAddr, 0)
 	pm.advertisedLocAddrs = make(map[string]bool)
+	pm.localCosts = make(map[protocol.PathID]float64)
+	pm.advertisedCosts = make(map[protocol.PathID]float64)
+	pm.remoteCosts = make(map[protocol.PathID]float64)
 	pm.handshakeCompleted = make(chan struct{}, 1)
 	pm.runClosed = make(chan struct{}, 1)
-	pm.timer = time.NewTimer(0)
+	pm.timer = utils.NewClockTimer(pm.sess.clock)
 	pm.nbPaths = 0
 
 	pm.oliaSenders = make(map[protocol.PathID]*congestion.OliaSender)
@@ -60,12 +67,14 @@ func (pm *pathManager) setup(conn connection) {
 
 	// Setup this first path
 	pm.sess.paths[protocol.InitialPathID].setup(pm.oliaSenders)
+	pm.applyLocalCost(pm.sess.paths[protocol.InitialPathID])
+	pm.sess.notifyPathUp(pm.sess.paths[protocol.InitialPathID])
 
 	// With the initial path, get the remoteAddr to create paths accordingly
 	if conn.RemoteAddr() != nil {
 		remAddr, err := net.ResolveUDPAddr("udp", conn.RemoteAddr().String())
 		if err != nil {
-			utils.Errorf("path manager: encountered error while parsing remote addr: %v", remAddr)
+			pm.sess.logger.Errorf("path manager: encountered error while parsing remote addr: %v", remAddr)
 		}
 
 		if remAddr.IP.To4() != nil {
@@ -85,6 +94,7 @@ func (pm *pathManager) run() {
 	case <-pm.runClosed:
 		return
 	case <-pm.handshakeCompleted:
+		pm.advertisePathCosts()
 		if pm.sess.createPaths {
 			err := pm.createPaths()
 			if err != nil {
@@ -149,20 +159,25 @@ func (pm *pathManager) createPath(locAddr net.UDPAddr, remAddr net.UDPAddr) erro
 		conn:   &conn{pconn: pm.pconnMgr.pconns[locAddr.String()], currentAddr: &remAddr},
 	}
 	pth.setup(pm.oliaSenders)
+	pm.applyLocalCost(pth)
 	pm.sess.paths[pm.nxtPathID] = pth
-	if utils.Debug() {
-		utils.Debugf("Created path %x on %s to %s", pm.nxtPathID, locAddr.String(), remAddr.String())
+	pm.sess.notifyPathUp(pth)
+	if pm.sess.logger.Debug() {
+		pm.sess.logger.Debugf("Created path %x on %s to %s", pm.nxtPathID, locAddr.String(), remAddr.String())
 	}
 	pm.nxtPathID += 2
 	// Send a PING frame to get latency info about the new path and informing the
 	// peer of its existence
 	// Because we hold pathsLock, it is safe to send packet now
-	return pm.sess.sendPing(pth)
+	if err := pm.sess.sendPing(pth); err != nil {
+		return pm.sess.failPath(err)
+	}
+	return nil
 }
 
 func (pm *pathManager) createPaths() error {
-	if utils.Debug() {
-		utils.Debugf("Path manager tries to create paths")
+	if pm.sess.logger.Debug() {
+		pm.sess.logger.Debugf("Path manager tries to create paths")
 	}
 
 	// XXX (QDC): don't let the server create paths for now
@@ -191,10 +206,56 @@ func (pm *pathManager) createPaths() error {
 			}
 		}
 	}
-	pm.sess.schedulePathsFrame()
+	pm.advertisePathCosts()
+	pm.sess.requestPathsFrame()
 	return nil
 }
 
+// applyLocalCost gives the path the cost configured for its local IP, if any.
+// A path on a socket listening on any address has no local IP, and hence no local cost.
+// The caller holds pathsLock, or the path isn't shared yet.
+func (pm *pathManager) applyLocalCost(pth *path) {
+	locAddr := pth.conn.LocalAddr()
+	if locAddr == nil {
+		return
+	}
+	host, _, err := net.SplitHostPort(locAddr.String())
+	if err != nil {
+		return
+	}
+	if cost, ok := pm.sess.config.PathCosts[host]; ok {
+		pth.setCost(cost)
+		pm.localCosts[pth.pathID] = cost
+	}
+}
+
+// advertisePathCosts sends the local costs of the paths that the peer doesn't know of yet, or that changed since
+func (pm *pathManager) advertisePathCosts() {
+	pm.sess.pathsLock.Lock()
+	defer pm.sess.pathsLock.Unlock()
+	pm.queuePathCosts()
+}
+
+// queuePathCosts queues a PATH_COST frame with the local costs to advertise, the caller holds pathsLock
+func (pm *pathManager) queuePathCosts() {
+	// versions without deadlines don't know the PathCostFrame
+	if !pm.sess.version.UsesDeadlines() {
+		return
+	}
+	f := &wire.PathCostFrame{}
+	for pathID, cost := range pm.localCosts {
+		if advertised, ok := pm.advertisedCosts[pathID]; ok && advertised == cost {
+			continue
+		}
+		f.PathIDs = append(f.PathIDs, pathID)
+		f.Costs = append(f.Costs, cost)
+		pm.advertisedCosts[pathID] = cost
+	}
+	if len(f.PathIDs) > 0 {
+		pm.sess.streamFramer.AddPathCostFrameForTransmission(f)
+	}
+}
+
 func (pm *pathManager) createPathFromRemote(p *receivedPacket) (*path, error) {
 	pm.sess.pathsLock.Lock()
 	defer pm.sess.pathsLock.Unlock()
@@ -223,10 +284,18 @@ func (pm *pathManager) createPathFromRemote(p *receivedPacket) (*path, error) {
 	}
 
 	pth.setup(pm.oliaSenders)
+	if cost, ok := pm.remoteCosts[pathID]; ok {
+		pth.setCost(cost)
+		delete(pm.remoteCosts, pathID)
+	}
+	pm.applyLocalCost(pth)
 	pm.sess.paths[pathID] = pth
+	pm.sess.notifyPathUp(pth)
+	// the peer creates paths once the handshake completed
+	pm.queuePathCosts()
 
-	if utils.Debug() {
-		utils.Debugf("Created remote path %x on %s to %s", pathID, localPconn.LocalAddr().String(), remoteAddr.String())
+	if pm.sess.logger.Debug() {
+		pm.sess.logger.Debugf("Created remote path %x on %s to %s", pathID, localPconn.LocalAddr().String(), remoteAddr.String())
 	}
 
 	return pth, nil
@@ -247,6 +316,22 @@ func (pm *pathManager) handleAddAddressFrame(f *wire.AddAddressFrame) error {
 	return nil
 }
 
+// handlePathCostFrame attaches the costs advertised by the peer to the corresponding paths
+func (pm *pathManager) handlePathCostFrame(f *wire.PathCostFrame) {
+	pm.sess.pathsLock.Lock()
+	defer pm.sess.pathsLock.Unlock()
+	for i, pathID := range f.PathIDs {
+		if pth, ok := pm.sess.paths[pathID]; ok {
+			pth.setCost(f.Costs[i])
+		} else {
+			pm.remoteCosts[pathID] = f.Costs[i]
+		}
+		if pm.sess.logger.Debug() {
+			pm.sess.logger.Debugf("Path %x has cost %f", pathID, f.Costs[i])
+		}
+	}
+}
+
 func (pm *pathManager) closePath(pthID protocol.PathID) error {
 	pm.sess.pathsLock.RLock()
 	defer pm.sess.pathsLock.RUnlock()
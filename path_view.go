@@ -0,0 +1,154 @@
+package quic
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// A PathView is the read-only state of a path that the schedulers decide on.
+// A path is a PathView, the tests of the schedulers use fake views instead of a session.
+type PathView interface {
+	PathID() protocol.PathID
+	SmoothedRTT() time.Duration
+	LatestRTT() time.Duration
+	MeanDeviation() time.Duration
+	// OneWayDelay is the estimate of the one-way delay of the path, half its smoothed RTT
+	OneWayDelay() time.Duration
+	CongestionWindow() protocol.ByteCount
+	BytesInFlight() protocol.ByteCount
+	// SendingAllowed tells if the path is open and its congestion window allows sending a packet
+	SendingAllowed() bool
+	PotentiallyFailed() bool
+	// Cost is the cost of sending a packet on the path
+	Cost() float64
+	// Alpha is the factor the bandit of the batch schedulers applies to the one-way delay
+	Alpha() float64
+	// RemoteQuality is the quality of the path advertised by the peer in its PathQualityFrames.
+	// Until the peer advertised the path, it has no delay nor loss, meets all deadlines, and has an unlimited budget.
+	RemoteQuality() PathQuality
+}
+
+// A PathQuality is the view of a path that an endpoint shares with its peer in PathQualityFrames
+type PathQuality = wire.PathQuality
+
+// A QueueView is the state of the data waiting to be sent that the schedulers decide on
+type QueueView interface {
+	// QueuedBytes is the amount of stream data waiting to be sent
+	QueuedBytes() protocol.ByteCount
+	// SendWindow is the send window of the data stream
+	SendWindow() protocol.ByteCount
+}
+
+var _ PathView = &path{}
+
+func (p *path) PathID() protocol.PathID { return p.pathID }
+
+func (p *path) SmoothedRTT() time.Duration { return p.rttStats.SmoothedRTT() }
+
+func (p *path) LatestRTT() time.Duration { return p.rttStats.LatestRTT() }
+
+func (p *path) MeanDeviation() time.Duration { return p.rttStats.MeanDeviation() }
+
+func (p *path) OneWayDelay() time.Duration { return p.rttStats.SmoothedRTT() / 2 }
+
+func (p *path) CongestionWindow() protocol.ByteCount {
+	return p.sentPacketHandler.GetCongestionWindow()
+}
+
+func (p *path) BytesInFlight() protocol.ByteCount { return p.sentPacketHandler.GetBytesInFlight() }
+
+func (p *path) PotentiallyFailed() bool { return p.potentiallyFailed.Get() }
+
+func (p *path) Cost() float64 { return p.getCost() }
+
+func (p *path) Alpha() float64 { return float64(p.sentPacketHandler.GetPathAlpha()) }
+
+func (p *path) RemoteQuality() PathQuality {
+	if p.remoteQuality == nil {
+		return PathQuality{PathID: p.pathID, MeetRatio: 1, Budget: math.Inf(1)}
+	}
+	return *p.remoteQuality
+}
+
+// pathViews returns the paths of the session, sorted by path ID
+func (s *session) pathViews() []PathView {
+	paths := make([]PathView, 0, len(s.paths))
+	for _, pth := range s.sortedPaths() {
+		paths = append(paths, pth)
+	}
+	return paths
+}
+
+// sortedPaths returns the paths of the session sorted by path ID.
+// Loops whose order changes what is sent use it instead of ranging over the map, so that a run can be reproduced.
+func (s *session) sortedPaths() []*path {
+	paths := make([]*path, 0, len(s.paths))
+	for _, pth := range s.paths {
+		paths = append(paths, pth)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].pathID < paths[j].pathID })
+	return paths
+}
+
+// viewOf returns the view of a path, nil for no path
+func viewOf(pth *path) PathView {
+	if pth == nil {
+		return nil
+	}
+	return pth
+}
+
+// pathOf returns the path of a view returned by pathViews, nil for no view
+func pathOf(v PathView) *path {
+	if v == nil {
+		return nil
+	}
+	return v.(*path)
+}
+
+// pathsOf returns the paths of views returned by pathViews
+func pathsOf(views []PathView) []*path {
+	if views == nil {
+		return nil
+	}
+	paths := make([]*path, len(views))
+	for i, v := range views {
+		paths[i] = pathOf(v)
+	}
+	return paths
+}
+
+// sessionQueue is the QueueView of a session
+type sessionQueue struct {
+	s *session
+}
+
+func (q sessionQueue) QueuedBytes() protocol.ByteCount {
+	var queueSize protocol.ByteCount
+	q.s.streamsMap.Iterate(func(s *stream) (bool, error) {
+		if s != nil {
+			queueSize += s.lenOfDataForWriting()
+		}
+		return true, nil
+	})
+	return queueSize
+}
+
+func (q sessionQueue) SendWindow() protocol.ByteCount {
+	sendWindow, _ := q.s.flowControlManager.SendWindowSize(protocol.StreamID(5))
+	return sendWindow
+}
+
+// initialPath returns the initial path, nil if there is none
+func initialPath(paths []PathView) PathView {
+	for _, pth := range paths {
+		if pth.PathID() == protocol.InitialPathID {
+			return pth
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package quic
+
+import (
+	"math"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakePath is a PathView with a fixed state
+type fakePath struct {
+	id        protocol.PathID
+	srtt      time.Duration
+	latestRTT time.Duration
+	deviation time.Duration
+	cwnd      protocol.ByteCount
+	inFlight  protocol.ByteCount
+	// blocked paths don't allow sending
+	blocked bool
+	failed  bool
+	cost    float64
+	alpha   float64
+	// remote is the quality of the path advertised by the peer
+	remote PathQuality
+}
+
+var _ PathView = &fakePath{}
+
+// newFakePath creates an open path with a congestion window of 10 packets
+func newFakePath(id protocol.PathID, srtt time.Duration) *fakePath {
+	return &fakePath{id: id, srtt: srtt, latestRTT: srtt, cwnd: 10 * protocol.MaxPacketSize, alpha: 1, remote: PathQuality{PathID: id, MeetRatio: 1, Budget: math.Inf(1)}}
+}
+
+func (p *fakePath) PathID() protocol.PathID              { return p.id }
+func (p *fakePath) SmoothedRTT() time.Duration           { return p.srtt }
+func (p *fakePath) LatestRTT() time.Duration             { return p.latestRTT }
+func (p *fakePath) MeanDeviation() time.Duration         { return p.deviation }
+func (p *fakePath) OneWayDelay() time.Duration           { return p.srtt / 2 }
+func (p *fakePath) CongestionWindow() protocol.ByteCount { return p.cwnd }
+func (p *fakePath) BytesInFlight() protocol.ByteCount    { return p.inFlight }
+func (p *fakePath) SendingAllowed() bool                 { return !p.blocked }
+func (p *fakePath) PotentiallyFailed() bool              { return p.failed }
+func (p *fakePath) Cost() float64                        { return p.cost }
+func (p *fakePath) Alpha() float64                       { return p.alpha }
+func (p *fakePath) RemoteQuality() PathQuality           { return p.remote }
+
+// fakeQueue is a QueueView with a fixed state
+type fakeQueue struct {
+	queued     protocol.ByteCount
+	sendWindow protocol.ByteCount
+}
+
+func (q fakeQueue) QueuedBytes() protocol.ByteCount { return q.queued }
+func (q fakeQueue) SendWindow() protocol.ByteCount  { return q.sendWindow }
+
+// views returns the fake paths as the path views of a session
+func views(paths ...*fakePath) []PathView {
+	v := make([]PathView, len(paths))
+	for i, pth := range paths {
+		v[i] = pth
+	}
+	return v
+}
+
+// newTestSession creates a session without run loop, for the specs of the scheduler, the statistics and the observer
+func newTestSession(config *Config, sch *scheduler) *session {
+	return &session{
+		connectionID: 0x1337,
+		version:      protocol.VersionMP,
+		config:       config,
+		paths:        make(map[protocol.PathID]*path),
+		scheduler:    sch,
+		logger:       utils.DefaultLogger,
+		clock:        utils.SystemClock{},
+	}
+}
+
+// newTestPath creates an open path of a session created by newTestSession, with an RTT sample of rtt unless it is 0.
+// The path goes from port 1000+pathID of 10.0.0.1 to 10.0.0.2:443.
+func newTestPath(sess *session, pathID protocol.PathID, rtt time.Duration) *path {
+	rttStats := &congestion.RTTStats{}
+	if rtt != 0 {
+		rttStats.UpdateRTT(rtt, 0, time.Now())
+	}
+	pth := &path{
+		pathID:                pathID,
+		sess:                  sess,
+		rttStats:              rttStats,
+		sentPacketHandler:     ackhandler.NewSentPacketHandler(sess.version, rttStats, nil, nil, utils.DefaultLogger),
+		receivedPacketHandler: ackhandler.NewReceivedPacketHandler(sess.version),
+		conn: &conn{
+			pconn:       &mockPacketConn{addr: &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1000 + int(pathID)}},
+			currentAddr: &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 443},
+		},
+	}
+	pth.open.Set(true)
+	return pth
+}
+
+var _ = Describe("Path views", func() {
+	newPath := func(pathID protocol.PathID, rtt time.Duration) *path {
+		rttStats := &congestion.RTTStats{}
+		rttStats.UpdateRTT(rtt, 0, time.Now())
+		pth := &path{
+			pathID:            pathID,
+			rttStats:          rttStats,
+			sentPacketHandler: ackhandler.NewSentPacketHandler(protocol.VersionWhatever, rttStats, nil, nil, utils.DefaultLogger),
+		}
+		pth.open.Set(true)
+		return pth
+	}
+
+	It("shows the state of a path", func() {
+		pth := newPath(3, 40*time.Millisecond)
+		pth.setCost(path1Cost)
+		pth.potentiallyFailed.Set(true)
+		Expect(pth.PathID()).To(Equal(protocol.PathID(3)))
+		Expect(pth.SmoothedRTT()).To(Equal(40 * time.Millisecond))
+		Expect(pth.LatestRTT()).To(Equal(40 * time.Millisecond))
+		Expect(pth.OneWayDelay()).To(Equal(20 * time.Millisecond))
+		Expect(pth.CongestionWindow()).To(Equal(pth.sentPacketHandler.GetCongestionWindow()))
+		Expect(pth.BytesInFlight()).To(BeZero())
+		Expect(pth.SendingAllowed()).To(BeTrue())
+		Expect(pth.PotentiallyFailed()).To(BeTrue())
+		Expect(pth.Cost()).To(Equal(path1Cost))
+		Expect(pth.Alpha()).To(Equal(float64(pth.sentPacketHandler.GetPathAlpha())))
+		Expect(pth.RemoteQuality()).To(Equal(PathQuality{PathID: 3, MeetRatio: 1, Budget: math.Inf(1)}))
+	})
+
+	It("shows the quality advertised by the peer", func() {
+		pth := newPath(3, 40*time.Millisecond)
+		q := wire.PathQuality{PathID: 3, OneWayDelay: 25 * time.Millisecond, LossRate: 0.02, MeetRatio: 0.25, Budget: 4}
+		pth.remoteQuality = &q
+		Expect(pth.RemoteQuality()).To(Equal(q))
+	})
+
+	It("sorts the paths of a session by path ID", func() {
+		sess := &session{paths: make(map[protocol.PathID]*path)}
+		for _, id := range []protocol.PathID{5, 0, 3, 1} {
+			sess.paths[id] = newPath(id, 20*time.Millisecond)
+		}
+		var ids []protocol.PathID
+		for _, v := range sess.pathViews() {
+			ids = append(ids, v.PathID())
+		}
+		Expect(ids).To(Equal([]protocol.PathID{0, 1, 3, 5}))
+		Expect(pathsOf(sess.pathViews())).To(Equal([]*path{sess.paths[0], sess.paths[1], sess.paths[3], sess.paths[5]}))
+		Expect(pathOf(nil)).To(BeNil())
+		Expect(viewOf(nil)).To(BeNil())
+	})
+})
@@ -0,0 +1,112 @@
+package pcapng
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+const (
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	udpHeaderLen  = 8
+
+	protocolUDP = 17
+	ttl         = 64
+)
+
+// encapsulate wraps the datagram of a packet in IP and UDP headers.
+// The packet is an IPv4 packet unless one of its addresses is an IPv6 address,
+// an unspecified local address, e.g. of a socket listening on any address, takes the family of the remote one.
+func encapsulate(p *Packet) []byte {
+	localIP, localPort := udpAddr(p.LocalAddr)
+	remoteIP, remotePort := udpAddr(p.RemoteAddr)
+	srcIP, srcPort, dstIP, dstPort := localIP, localPort, remoteIP, remotePort
+	if p.Direction == Inbound {
+		srcIP, srcPort, dstIP, dstPort = remoteIP, remotePort, localIP, localPort
+	}
+
+	udp := make([]byte, udpHeaderLen, udpHeaderLen+len(p.Data))
+	binary.BigEndian.PutUint16(udp[0:2], srcPort)
+	binary.BigEndian.PutUint16(udp[2:4], dstPort)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpHeaderLen+len(p.Data)))
+	udp = append(udp, p.Data...)
+
+	if isIPv4(localIP, remoteIP) {
+		ip := make([]byte, ipv4HeaderLen, ipv4HeaderLen+len(udp))
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(ipv4HeaderLen+len(udp)))
+		// don't fragment
+		ip[6] = 0x40
+		ip[8] = ttl
+		ip[9] = protocolUDP
+		copy(ip[12:16], to4(srcIP))
+		copy(ip[16:20], to4(dstIP))
+		binary.BigEndian.PutUint16(ip[10:12], checksum(0, ip))
+		// the UDP checksum is optional over IPv4
+		return append(ip, udp...)
+	}
+
+	ip := make([]byte, ipv6HeaderLen, ipv6HeaderLen+len(udp))
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:6], uint16(len(udp)))
+	ip[6] = protocolUDP
+	ip[7] = ttl
+	copy(ip[8:24], to16(srcIP))
+	copy(ip[24:40], to16(dstIP))
+	// the UDP checksum is mandatory over IPv6, it covers a pseudo header
+	pseudo := make([]byte, 40)
+	copy(pseudo[0:32], ip[8:40])
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(udp)))
+	pseudo[39] = protocolUDP
+	sum := checksum(sumWords(0, pseudo), udp)
+	if sum == 0 {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(udp[6:8], sum)
+	return append(ip, udp...)
+}
+
+// udpAddr returns the IP and port of an address, or the unspecified IPv4 address if it isn't a UDP address
+func udpAddr(addr net.Addr) (net.IP, uint16) {
+	if udpAddr, ok := addr.(*net.UDPAddr); ok && udpAddr.IP != nil {
+		return udpAddr.IP, uint16(udpAddr.Port)
+	}
+	return net.IPv4zero, 0
+}
+
+func isIPv4(local, remote net.IP) bool {
+	return (local.To4() != nil || local.IsUnspecified()) && (remote.To4() != nil || remote.IsUnspecified())
+}
+
+func to4(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return net.IPv4zero.To4()
+}
+
+func to16(ip net.IP) net.IP {
+	if ip.IsUnspecified() {
+		return net.IPv6unspecified
+	}
+	return ip.To16()
+}
+
+// checksum is the internet checksum of the data, starting from a partial sum
+func checksum(sum uint32, data []byte) uint16 {
+	sum = sumWords(sum, data)
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+func sumWords(sum uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i : i+2]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
@@ -0,0 +1,13 @@
+package pcapng
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPcapng(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "pcapng Suite")
+}
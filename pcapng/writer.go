@@ -0,0 +1,187 @@
+// Package pcapng writes the datagrams of multipath sessions to a pcapng capture file.
+//
+// The UDP payloads are wrapped in synthesized IP and UDP headers, so that the capture can be read by the usual tools.
+// Every local address gets its own interface, named after the address.
+//
+// The payloads are encrypted. To decrypt them, set the KeyLogWriter of the quic.Config:
+// it receives one line per encryption level of every session, with hex encoded values and the connection ID as in the logs:
+//
+//	QUIC_CRYPTO_SECURE <connection ID> <client key> <client IV> <server key> <server IV>
+//	QUIC_CRYPTO_FORWARD_SECURE <connection ID> <client key> <client IV> <server key> <server IV>
+//
+// The payloads are sealed with AES-128-GCM and 12 byte tags. The nonce of a packet is the IV followed by its
+// packet number in little endian, and the associated data is its public header.
+package pcapng
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// A Direction is the direction of a captured packet, as seen from the local address
+type Direction uint8
+
+const (
+	// Inbound packets were received
+	Inbound Direction = 1
+	// Outbound packets were sent
+	Outbound Direction = 2
+)
+
+// A Packet is a captured UDP datagram
+type Packet struct {
+	Time       time.Time
+	Direction  Direction
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// Data is only valid during the call to Capture
+	Data    []byte
+	Comment string
+}
+
+// A Capturer receives the datagrams sent and received by the sessions it is configured for.
+// It is called from the goroutines of all these sessions, and must be safe for concurrent use.
+type Capturer interface {
+	Capture(p *Packet)
+}
+
+const (
+	blockTypeSectionHeader  = 0x0a0d0d0a
+	blockTypeInterface      = 0x00000001
+	blockTypeEnhancedPacket = 0x00000006
+
+	byteOrderMagic = 0x1a2b3c4d
+
+	optionEndOfOptions = 0
+	optionComment      = 1
+	// options of the interface description block
+	optionInterfaceName  = 2
+	optionTimeResolution = 9
+	// options of the enhanced packet block
+	optionPacketFlags = 2
+
+	// linkTypeRaw is for packets starting with an IPv4 or IPv6 header
+	linkTypeRaw = 101
+)
+
+// A Writer is a Capturer that writes a pcapng capture
+type Writer struct {
+	mutex sync.Mutex
+
+	w          io.Writer
+	interfaces map[string]uint32
+	err        error
+}
+
+var _ Capturer = &Writer{}
+
+// NewWriter creates a new Writer and writes the section header of the capture
+func NewWriter(w io.Writer) (*Writer, error) {
+	cw := &Writer{w: w, interfaces: make(map[string]uint32)}
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint32(body[0:4], byteOrderMagic)
+	binary.LittleEndian.PutUint16(body[4:6], 1)
+	binary.LittleEndian.PutUint16(body[6:8], 0)
+	// the length of the section is not known in advance
+	binary.LittleEndian.PutUint64(body[8:16], 0xffffffffffffffff)
+	if err := cw.writeBlock(blockTypeSectionHeader, appendEndOfOptions(body)); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Capture writes a packet, and the interface of its local address if it is the first packet on it
+func (w *Writer) Capture(p *Packet) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err != nil {
+		return
+	}
+	id, err := w.interfaceID(p.LocalAddr)
+	if err != nil {
+		return
+	}
+
+	data := encapsulate(p)
+	ts := uint64(p.Time.UnixNano())
+	body := make([]byte, 20)
+	binary.LittleEndian.PutUint32(body[0:4], id)
+	binary.LittleEndian.PutUint32(body[4:8], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(ts))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(data)))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(len(data)))
+	body = appendPadded(body, data)
+	if p.Comment != "" {
+		body = appendOption(body, optionComment, []byte(p.Comment))
+	}
+	flags := make([]byte, 4)
+	binary.LittleEndian.PutUint32(flags, uint32(p.Direction))
+	body = appendOption(body, optionPacketFlags, flags)
+	w.writeBlock(blockTypeEnhancedPacket, appendEndOfOptions(body))
+}
+
+// Err returns the first error returned by the underlying io.Writer, after which no more packets are written
+func (w *Writer) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+// interfaceID returns the interface of a local address, writing its description the first time
+func (w *Writer) interfaceID(addr net.Addr) (uint32, error) {
+	name := "unknown"
+	if addr != nil {
+		name = addr.String()
+	}
+	if id, ok := w.interfaces[name]; ok {
+		return id, nil
+	}
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:2], linkTypeRaw)
+	// no limit on the length of the captured packets
+	binary.LittleEndian.PutUint32(body[4:8], 0)
+	body = appendOption(body, optionInterfaceName, []byte(name))
+	// timestamps are in nanoseconds
+	body = appendOption(body, optionTimeResolution, []byte{9})
+	if err := w.writeBlock(blockTypeInterface, appendEndOfOptions(body)); err != nil {
+		return 0, err
+	}
+	id := uint32(len(w.interfaces))
+	w.interfaces[name] = id
+	return id, nil
+}
+
+func (w *Writer) writeBlock(blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+	block := make([]byte, 8, length)
+	binary.LittleEndian.PutUint32(block[0:4], blockType)
+	binary.LittleEndian.PutUint32(block[4:8], length)
+	block = append(block, body...)
+	block = append(block, block[4:8]...)
+	_, w.err = w.w.Write(block)
+	return w.err
+}
+
+// appendPadded appends data, padded to 32 bits
+func appendPadded(b []byte, data []byte) []byte {
+	b = append(b, data...)
+	for i := len(data); i%4 != 0; i++ {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	header := make([]byte, 4)
+	binary.LittleEndian.PutUint16(header[0:2], code)
+	binary.LittleEndian.PutUint16(header[2:4], uint16(len(value)))
+	return appendPadded(append(b, header...), value)
+}
+
+func appendEndOfOptions(b []byte) []byte {
+	return append(b, optionEndOfOptions, 0, 0, 0)
+}
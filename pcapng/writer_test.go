@@ -0,0 +1,169 @@
+package pcapng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type block struct {
+	typ  uint32
+	body []byte
+}
+
+func readBlocks(data []byte) []block {
+	var blocks []block
+	for len(data) > 0 {
+		Expect(len(data)).To(BeNumerically(">=", 12))
+		length := binary.LittleEndian.Uint32(data[4:8])
+		Expect(length % 4).To(BeZero())
+		Expect(binary.LittleEndian.Uint32(data[length-4 : length])).To(Equal(length))
+		blocks = append(blocks, block{typ: binary.LittleEndian.Uint32(data[0:4]), body: data[8 : length-4]})
+		data = data[length:]
+	}
+	return blocks
+}
+
+// readOptions returns the options of a block, starting at the given offset
+func readOptions(b []byte) map[uint16][]byte {
+	options := make(map[uint16][]byte)
+	for {
+		code := binary.LittleEndian.Uint16(b[0:2])
+		length := int(binary.LittleEndian.Uint16(b[2:4]))
+		if code == optionEndOfOptions {
+			return options
+		}
+		options[code] = b[4 : 4+length]
+		b = b[4+(length+3)/4*4:]
+	}
+}
+
+// packetData returns the data of an enhanced packet block and its options
+func packetData(b block) (uint32, []byte, map[uint16][]byte) {
+	Expect(b.typ).To(BeEquivalentTo(blockTypeEnhancedPacket))
+	length := int(binary.LittleEndian.Uint32(b.body[12:16]))
+	return binary.LittleEndian.Uint32(b.body[0:4]), b.body[20 : 20+length], readOptions(b.body[20+(length+3)/4*4:])
+}
+
+type failingWriter struct{ written int }
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.written > 0 {
+		return 0, errors.New("disk full")
+	}
+	w.written += len(b)
+	return len(b), nil
+}
+
+var _ = Describe("Writer", func() {
+	var (
+		buf    *bytes.Buffer
+		writer *Writer
+		local1 = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4433}
+		local2 = &net.UDPAddr{IP: net.IPv4(10, 0, 1, 1), Port: 4434}
+		remote = &net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 1337}
+		now    = time.Unix(1500000000, 123456789)
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		var err error
+		writer, err = NewWriter(buf)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("writes the section header", func() {
+		blocks := readBlocks(buf.Bytes())
+		Expect(blocks).To(HaveLen(1))
+		Expect(blocks[0].typ).To(BeEquivalentTo(blockTypeSectionHeader))
+		Expect(binary.LittleEndian.Uint32(blocks[0].body[0:4])).To(BeEquivalentTo(byteOrderMagic))
+		Expect(binary.LittleEndian.Uint16(blocks[0].body[4:6])).To(BeEquivalentTo(1))
+	})
+
+	It("writes one interface per local address", func() {
+		writer.Capture(&Packet{Time: now, Direction: Outbound, LocalAddr: local1, RemoteAddr: remote, Data: []byte("foo")})
+		writer.Capture(&Packet{Time: now, Direction: Outbound, LocalAddr: local2, RemoteAddr: remote, Data: []byte("bar")})
+		writer.Capture(&Packet{Time: now, Direction: Inbound, LocalAddr: local1, RemoteAddr: remote, Data: []byte("baz")})
+		Expect(writer.Err()).ToNot(HaveOccurred())
+
+		blocks := readBlocks(buf.Bytes())
+		Expect(blocks).To(HaveLen(6))
+		Expect(blocks[1].typ).To(BeEquivalentTo(blockTypeInterface))
+		Expect(binary.LittleEndian.Uint16(blocks[1].body[0:2])).To(BeEquivalentTo(linkTypeRaw))
+		Expect(readOptions(blocks[1].body[8:])).To(HaveKeyWithValue(uint16(optionInterfaceName), []byte("10.0.0.1:4433")))
+		Expect(blocks[3].typ).To(BeEquivalentTo(blockTypeInterface))
+		Expect(readOptions(blocks[3].body[8:])).To(HaveKeyWithValue(uint16(optionInterfaceName), []byte("10.0.1.1:4434")))
+
+		id, _, _ := packetData(blocks[2])
+		Expect(id).To(BeEquivalentTo(0))
+		id, _, _ = packetData(blocks[4])
+		Expect(id).To(BeEquivalentTo(1))
+		id, _, _ = packetData(blocks[5])
+		Expect(id).To(BeEquivalentTo(0))
+	})
+
+	It("writes the timestamp, direction and comment of the packets", func() {
+		writer.Capture(&Packet{Time: now, Direction: Inbound, LocalAddr: local1, RemoteAddr: remote, Data: []byte("foo"), Comment: "path 1, packet 42"})
+		blocks := readBlocks(buf.Bytes())
+		Expect(blocks).To(HaveLen(3))
+		ts := uint64(binary.LittleEndian.Uint32(blocks[2].body[4:8]))<<32 | uint64(binary.LittleEndian.Uint32(blocks[2].body[8:12]))
+		Expect(ts).To(BeEquivalentTo(now.UnixNano()))
+		_, _, options := packetData(blocks[2])
+		Expect(options).To(HaveKeyWithValue(uint16(optionComment), []byte("path 1, packet 42")))
+		Expect(options).To(HaveKeyWithValue(uint16(optionPacketFlags), []byte{1, 0, 0, 0}))
+	})
+
+	It("wraps IPv4 datagrams in IPv4 and UDP headers", func() {
+		writer.Capture(&Packet{Time: now, Direction: Outbound, LocalAddr: local1, RemoteAddr: remote, Data: []byte("foobar")})
+		_, data, _ := packetData(readBlocks(buf.Bytes())[2])
+		Expect(data).To(HaveLen(ipv4HeaderLen + udpHeaderLen + 6))
+		Expect(data[0]).To(BeEquivalentTo(0x45))
+		Expect(binary.BigEndian.Uint16(data[2:4])).To(BeEquivalentTo(len(data)))
+		Expect(data[9]).To(BeEquivalentTo(protocolUDP))
+		Expect(checksum(0, data[:ipv4HeaderLen])).To(BeZero())
+		Expect(net.IP(data[12:16]).Equal(local1.IP)).To(BeTrue())
+		Expect(net.IP(data[16:20]).Equal(remote.IP)).To(BeTrue())
+		udp := data[ipv4HeaderLen:]
+		Expect(binary.BigEndian.Uint16(udp[0:2])).To(BeEquivalentTo(4433))
+		Expect(binary.BigEndian.Uint16(udp[2:4])).To(BeEquivalentTo(1337))
+		Expect(udp[udpHeaderLen:]).To(Equal([]byte("foobar")))
+	})
+
+	It("uses the remote address as source of inbound packets", func() {
+		writer.Capture(&Packet{Time: now, Direction: Inbound, LocalAddr: &net.UDPAddr{IP: net.IPv6unspecified, Port: 4433}, RemoteAddr: remote, Data: []byte("foo")})
+		_, data, _ := packetData(readBlocks(buf.Bytes())[2])
+		Expect(data[0]).To(BeEquivalentTo(0x45))
+		Expect(net.IP(data[12:16]).Equal(remote.IP)).To(BeTrue())
+		Expect(net.IP(data[16:20]).Equal(net.IPv4zero)).To(BeTrue())
+		Expect(binary.BigEndian.Uint16(data[ipv4HeaderLen : ipv4HeaderLen+2])).To(BeEquivalentTo(1337))
+	})
+
+	It("wraps IPv6 datagrams in IPv6 and UDP headers, with a valid UDP checksum", func() {
+		local := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 4433}
+		remote6 := &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 1337}
+		writer.Capture(&Packet{Time: now, Direction: Outbound, LocalAddr: local, RemoteAddr: remote6, Data: []byte("foobar!")})
+		_, data, _ := packetData(readBlocks(buf.Bytes())[2])
+		Expect(data).To(HaveLen(ipv6HeaderLen + udpHeaderLen + 7))
+		Expect(data[0] >> 4).To(BeEquivalentTo(6))
+		Expect(binary.BigEndian.Uint16(data[4:6])).To(BeEquivalentTo(udpHeaderLen + 7))
+		Expect(net.IP(data[8:24]).Equal(local.IP)).To(BeTrue())
+		Expect(net.IP(data[24:40]).Equal(remote6.IP)).To(BeTrue())
+		pseudo := make([]byte, 40)
+		copy(pseudo[0:32], data[8:40])
+		binary.BigEndian.PutUint32(pseudo[32:36], uint32(udpHeaderLen+7))
+		pseudo[39] = protocolUDP
+		Expect(checksum(sumWords(0, pseudo), data[ipv6HeaderLen:])).To(BeZero())
+	})
+
+	It("stops writing after an error", func() {
+		w, err := NewWriter(&failingWriter{})
+		Expect(err).ToNot(HaveOccurred())
+		w.Capture(&Packet{Time: now, Direction: Outbound, LocalAddr: local1, RemoteAddr: remote, Data: []byte("foo")})
+		Expect(w.Err()).To(MatchError("disk full"))
+	})
+})
@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"errors"
 	"net"
 	"strings"
 	"sync"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/pcapng"
 	// reuse "github.com/jbenet/go-reuseport"
 )
 
@@ -18,6 +20,9 @@ type receivedRawPacket struct {
 	rcvTime    time.Time
 }
 
+// interfacesPollInterval is the interval at which the client looks for new interfaces to create paths on
+const interfacesPollInterval = 2 * time.Second
+
 type pconnManager struct {
 	// Two kinds of PacketConn: on specific unicast address and the "master"
 	// listening on any
@@ -34,8 +39,72 @@ type pconnManager struct {
 	changePaths chan struct{}
 	closeConns  chan struct{}
 	closed      chan struct{}
-	errorConn   chan error
-	timer       *time.Timer
+	closeOnce   sync.Once
+	// closing is set once the sockets are closed, it is protected by the mutex
+	closing   bool
+	errorConn chan error
+
+	capture pcapng.Capturer
+	network PacketNetwork
+	// clock timestamps the received datagrams
+	clock Clock
+}
+
+// newPconnManager creates a pconnManager, capturing the datagrams it receives if the config has a packet capture.
+// Its sockets are opened on the network of the config, if any, and the datagrams are timestamped with its clock.
+func newPconnManager(perspective protocol.Perspective, config *Config) *pconnManager {
+	pcm := &pconnManager{perspective: perspective, network: udpNetwork{}, clock: utils.SystemClock{}}
+	if config != nil {
+		pcm.capture = config.PacketCapture
+		if config.Network != nil {
+			pcm.network = config.Network
+		}
+		if config.Clock != nil {
+			pcm.clock = config.Clock
+		}
+	}
+	return pcm
+}
+
+// udpNetwork is the PacketNetwork of the operating system
+type udpNetwork struct{}
+
+var _ PacketNetwork = udpNetwork{}
+
+// InterfaceIPs returns the addresses of the Ethernet, cellular and Wi-Fi interfaces
+func (udpNetwork) InterfaceIPs() ([]net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, i := range ifaces {
+		// TODO (QDC): do this in a generic way
+		if !strings.Contains(i.Name, "eth") && !strings.Contains(i.Name, "rmnet") && !strings.Contains(i.Name, "wlan") {
+			continue
+		}
+		addrs, err := i.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ip, _, err := net.ParseCIDR(a.String())
+			if err != nil {
+				return nil, err
+			}
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// ListenUDP opens a UDP socket
+func (udpNetwork) ListenUDP(addr *net.UDPAddr) (net.PacketConn, error) {
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
 }
 
 // Setup the pconn_manager and the pconnAny connection
@@ -47,7 +116,6 @@ func (pcm *pconnManager) setup(pconnArg net.PacketConn, listenAddr net.Addr) err
 	pcm.closeConns = make(chan struct{}, 1)
 	pcm.closed = make(chan struct{}, 1)
 	pcm.errorConn = make(chan error, 1) // Made non-blocking for tests
-	pcm.timer = time.NewTimer(0)
 
 	if pconnArg == nil {
 		// XXX (QDC): waiting for native support of SO_REUSEADDR in go...
@@ -57,7 +125,7 @@ func (pcm *pconnManager) setup(pconnArg net.PacketConn, listenAddr net.Addr) err
 		//} else {
 		//	listenAddrStr = listenAddr.String()
 		//}
-		pconn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+		pconn, err := pcm.network.ListenUDP(&net.UDPAddr{IP: net.IPv4zero, Port: 0})
 		// pconn, err := reuse.ListenPacket("udp", listenAddrStr)
 		if err != nil {
 			utils.Errorf("pconn_manager: %v", err)
@@ -82,34 +150,25 @@ func (pcm *pconnManager) setup(pconnArg net.PacketConn, listenAddr net.Addr) err
 }
 
 func (pcm *pconnManager) listen(pconn net.PacketConn) {
-	var err error
-
-listenLoop:
 	for {
-		var n int
-		var addr net.Addr
 		data := getPacketBuffer()
 		data = data[:protocol.MaxReceivePacketSize]
 		// The packet size should not exceed protocol.MaxReceivePacketSize bytes
 		// If it does, we only read a truncate packet, which will then end up undecryptable
-		n, addr, err = pconn.ReadFrom(data)
+		n, addr, err := pconn.ReadFrom(data)
 		if err != nil {
-			// XXX (QDC): as soon as a path failed, kill the connection.
-			// TODO (QDC): be more resilient in the future without breaking expectations
+			if pconn != pcm.pconnAny {
+				// The socket of an interface fails when the interface goes away. Its paths fail,
+				// the connection goes on over the others, and the socket is created again when the interface comes back.
+				pcm.removePconn(pconn, err)
+				return
+			}
 			select {
 			case pcm.errorConn <- err:
 			default:
 				// Don't block
 			}
-			break listenLoop
-			// if pconn == pconnAny {
-
-			// }
-			// if !strings.HasSuffix(err.Error(), "use of closed network connection") {
-			// TODO
-			// c.session.Close(err)
-			// }
-			// break
+			return
 		}
 		data = data[:n]
 
@@ -117,10 +176,15 @@ listenLoop:
 			rcvPconn:   pconn,
 			remoteAddr: addr,
 			data:       data,
-			rcvTime:    time.Now(),
+			rcvTime:    pcm.clock.Now(),
 		}
+		pcm.captureReceived(rcvRawPacket)
 
-		pcm.rcvRawPackets <- rcvRawPacket
+		select {
+		case pcm.rcvRawPackets <- rcvRawPacket:
+		case <-pcm.closed:
+			return
+		}
 	}
 }
 
@@ -137,27 +201,34 @@ func (pcm *pconnManager) run() {
 	case pcm.changePaths <- struct{}{}:
 	default:
 	}
-	// Start the timer for periodic interface checking (only for client)
-	duration, _ := time.ParseDuration("2s")
+	// Only the client checks its interfaces periodically, on the clock of the session
+	var poll <-chan struct{}
+	stopPoll := func() bool { return false }
 	if pcm.perspective == protocol.PerspectiveClient {
-		pcm.timer.Reset(duration)
-	} else {
-		if !pcm.timer.Stop() {
-			<-pcm.timer.C
-		}
+		poll, stopPoll = pcm.schedulePoll()
 	}
-runLoop:
 	for {
 		select {
 		case <-pcm.closeConns:
-			break runLoop
-		case <-pcm.timer.C:
+			stopPoll()
+			pcm.closePconns()
+			return
+		case <-pcm.closed:
+			// the client closed the sockets
+			stopPoll()
+			return
+		case <-poll:
 			pcm.createPconns()
-			pcm.timer.Reset(duration)
+			poll, stopPoll = pcm.schedulePoll()
 		}
 	}
-	// Close pconns
-	pcm.closePconns()
+}
+
+// schedulePoll returns a channel closed when the interfaces are to be checked next
+func (pcm *pconnManager) schedulePoll() (<-chan struct{}, func() bool) {
+	poll := make(chan struct{})
+	stop := pcm.clock.AfterFunc(interfacesPollInterval, func() { close(poll) })
+	return poll, stop
 }
 
 func (pcm *pconnManager) createPconn(ip net.IP) (*net.UDPAddr, error) {
@@ -169,7 +240,7 @@ func (pcm *pconnManager) createPconn(ip net.IP) (*net.UDPAddr, error) {
 	//	listenAddrStr = "[" + ip.String() + "]:0"
 	//}
 	// pconn, err := reuse.ListenPacket("udp", listenAddrStr)
-	pconn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: 0})
+	pconn, err := pcm.network.ListenUDP(&net.UDPAddr{IP: ip, Port: 0})
 	if err != nil {
 		return nil, err
 	}
@@ -178,7 +249,13 @@ func (pcm *pconnManager) createPconn(ip net.IP) (*net.UDPAddr, error) {
 		return nil, err
 	}
 	pcm.mutex.Lock()
+	if pcm.closing {
+		pcm.mutex.Unlock()
+		pconn.Close()
+		return nil, errors.New("pconn_manager: closed")
+	}
 	pcm.pconns[locAddr.String()] = pconn
+	pcm.localAddrs = append(pcm.localAddrs, *locAddr)
 	pcm.mutex.Unlock()
 	if utils.Debug() {
 		utils.Debugf("Created pconn on %s", pconn.LocalAddr().String())
@@ -193,54 +270,66 @@ func (pcm *pconnManager) createPconn(ip net.IP) (*net.UDPAddr, error) {
 	return locAddr, nil
 }
 
+// hasLocalAddr tells if a socket is open on the IP
+func (pcm *pconnManager) hasLocalAddr(ip net.IP) bool {
+	pcm.mutex.Lock()
+	defer pcm.mutex.Unlock()
+	for _, locAddr := range pcm.localAddrs {
+		if ip.Equal(locAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
 func (pcm *pconnManager) createPconns() error {
-	ifaces, err := net.Interfaces()
+	ips, err := pcm.network.InterfaceIPs()
 	if err != nil {
 		return err
 	}
-	for _, i := range ifaces {
-		// TODO (QDC): do this in a generic way
-		if !strings.Contains(i.Name, "eth") && !strings.Contains(i.Name, "rmnet") && !strings.Contains(i.Name, "wlan") {
+	for _, ip := range ips {
+		// If not Global Unicast, bypass
+		if !ip.IsGlobalUnicast() {
 			continue
 		}
-		addrs, err := i.Addrs()
-		if err != nil {
-			return err
-		}
-		for _, a := range addrs {
-			ip, _, err := net.ParseCIDR(a.String())
-			if err != nil {
+		if !pcm.hasLocalAddr(ip) {
+			if _, err := pcm.createPconn(ip); err != nil {
 				return err
 			}
-			// If not Global Unicast, bypass
-			if !ip.IsGlobalUnicast() {
-				continue
-			}
-			// TODO (QDC): Clearly not optimal
-			found := false
-		lookingLoop:
-			for _, locAddr := range pcm.localAddrs {
-				if ip.Equal(locAddr.IP) {
-					found = true
-					break lookingLoop
-				}
-			}
-			if !found {
-				locAddr, err := pcm.createPconn(ip)
-				if err != nil {
-					return err
-				}
-				pcm.localAddrs = append(pcm.localAddrs, *locAddr)
-			}
 		}
 	}
 	return nil
 }
 
-func (pcm *pconnManager) closePconns() {
-	for _, pconn := range pcm.pconns {
-		pconn.Close()
+// removePconn forgets the socket of an interface that failed, unless the sockets are being closed
+func (pcm *pconnManager) removePconn(pconn net.PacketConn, err error) {
+	pcm.mutex.Lock()
+	defer pcm.mutex.Unlock()
+	if pcm.closing {
+		return
+	}
+	utils.Infof("pconn_manager: socket on %s failed: %s", pconn.LocalAddr(), err)
+	pconn.Close()
+	locAddr := pconn.LocalAddr().String()
+	delete(pcm.pconns, locAddr)
+	for i := range pcm.localAddrs {
+		if pcm.localAddrs[i].String() == locAddr {
+			pcm.localAddrs = append(pcm.localAddrs[:i], pcm.localAddrs[i+1:]...)
+			break
+		}
 	}
-	pcm.pconnAny.Close()
-	close(pcm.closed)
+}
+
+// closePconns closes the sockets and stops the manager, it can be called several times
+func (pcm *pconnManager) closePconns() {
+	pcm.closeOnce.Do(func() {
+		pcm.mutex.Lock()
+		pcm.closing = true
+		for _, pconn := range pcm.pconns {
+			pconn.Close()
+		}
+		pcm.mutex.Unlock()
+		pcm.pconnAny.Close()
+		close(pcm.closed)
+	})
 }
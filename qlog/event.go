@@ -0,0 +1,346 @@
+package qlog
+
+import (
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// An Event is a structured event of a session
+type Event struct {
+	Time         time.Time
+	ConnectionID protocol.ConnectionID
+	Perspective  protocol.Perspective
+	Data         EventData
+}
+
+// EventData is the payload of an Event, one of the event types of this package
+type EventData interface {
+	// Name is the qlog name of the event, in the form category:event
+	Name() string
+	// fields are the qlog data of the event
+	fields() map[string]interface{}
+}
+
+// Bandits used by the schedulers
+const (
+	// BanditAlpha is the UCB bandit choosing the deadline discount factor alpha of a path
+	BanditAlpha = "alpha"
+	// BanditPath is the LinUCB bandit of the lowband scheduler choosing between the two best paths
+	BanditPath = "path"
+)
+
+// PacketSent is traced when a packet is sent on a path
+type PacketSent struct {
+	PathID       protocol.PathID
+	PacketNumber protocol.PacketNumber
+	Length       protocol.ByteCount
+	Frames       []wire.Frame
+	// Deadline of the packet, zero if it has none
+	Deadline time.Time
+	// CurNotSent and Alpha are the values written in the public header
+	CurNotSent uint8
+	Alpha      uint8
+	// Arm is the arm of the alpha bandit of the path when the packet was sent
+	Arm int
+	// Cost is the cost of sending a packet on the path
+	Cost float64
+}
+
+// PacketReceived is traced when a packet is received on a path
+type PacketReceived struct {
+	PathID       protocol.PathID
+	PacketNumber protocol.PacketNumber
+	Length       protocol.ByteCount
+	Frames       []wire.Frame
+	Deadline     time.Time
+	CurNotSent   uint8
+	Alpha        uint8
+}
+
+// AckProcessed is traced when an ACK frame was processed by the sent packet handler of a path
+type AckProcessed struct {
+	PathID          protocol.PathID
+	LargestAcked    protocol.PacketNumber
+	LowestAcked     protocol.PacketNumber
+	AckedPackets    int
+	LostPackets     int
+	NumMeetDeadline uint16
+	NumHasDeadline  uint16
+}
+
+// MetricsUpdated is traced when the congestion window or the RTT estimates of a path change
+type MetricsUpdated struct {
+	PathID           protocol.PathID
+	CongestionWindow protocol.ByteCount
+	BytesInFlight    protocol.ByteCount
+	SmoothedRTT      time.Duration
+	LatestRTT        time.Duration
+	MinRTT           time.Duration
+	RTTVariance      time.Duration
+}
+
+// PathAdded is traced when a path is created, by either peer
+type PathAdded struct {
+	PathID     protocol.PathID
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+}
+
+// PathClosed is traced when a path is closed
+type PathClosed struct {
+	PathID protocol.PathID
+}
+
+// SchedulerDecision is traced every time the scheduler selects the path of the next packet
+type SchedulerDecision struct {
+	Scheduler string
+	// Selected is false if the scheduler decided not to send
+	Selected       bool
+	PathID         protocol.PathID
+	Retransmission bool
+	// Arm is the arm chosen by the bandit of the scheduler, -1 if it has none
+	Arm int
+}
+
+// BanditUpdate is traced when a bandit is rewarded for an arm
+type BanditUpdate struct {
+	// Bandit is BanditAlpha or BanditPath
+	Bandit string
+	PathID protocol.PathID
+	Arm    int
+	Reward float64
+	// NextArm is the arm the bandit plays next, -1 if the bandit chooses it on every decision
+	NextArm int
+}
+
+// DeadlineMissSummary is traced when a session closes, with the causes of the deadline misses of its packets
+type DeadlineMissSummary struct {
+	NoFeasiblePath uint64
+	Deferred       uint64
+	HeldForCost    uint64
+	Retransmitted  uint64
+	NotSent        uint64
+	LateOnPath     uint64
+}
+
+var (
+	_ EventData = &PacketSent{}
+	_ EventData = &PacketReceived{}
+	_ EventData = &AckProcessed{}
+	_ EventData = &MetricsUpdated{}
+	_ EventData = &PathAdded{}
+	_ EventData = &PathClosed{}
+	_ EventData = &SchedulerDecision{}
+	_ EventData = &BanditUpdate{}
+	_ EventData = &DeadlineMissSummary{}
+)
+
+// Name returns the qlog name of the event
+func (e *PacketSent) Name() string { return "transport:packet_sent" }
+
+func (e *PacketSent) fields() map[string]interface{} {
+	f := packetFields(e.PathID, e.PacketNumber, e.Length, e.Frames, e.Deadline, e.CurNotSent, e.Alpha)
+	f["arm"] = e.Arm
+	if e.Cost > 0 {
+		f["cost"] = e.Cost
+	}
+	return f
+}
+
+// Name returns the qlog name of the event
+func (e *PacketReceived) Name() string { return "transport:packet_received" }
+
+func (e *PacketReceived) fields() map[string]interface{} {
+	return packetFields(e.PathID, e.PacketNumber, e.Length, e.Frames, e.Deadline, e.CurNotSent, e.Alpha)
+}
+
+// Name returns the qlog name of the event
+func (e *AckProcessed) Name() string { return "multipath:ack_processed" }
+
+func (e *AckProcessed) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"path_id":           e.PathID,
+		"largest_acked":     e.LargestAcked,
+		"lowest_acked":      e.LowestAcked,
+		"acked_packets":     e.AckedPackets,
+		"lost_packets":      e.LostPackets,
+		"num_meet_deadline": e.NumMeetDeadline,
+		"num_has_deadline":  e.NumHasDeadline,
+	}
+}
+
+// Name returns the qlog name of the event
+func (e *MetricsUpdated) Name() string { return "recovery:metrics_updated" }
+
+func (e *MetricsUpdated) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"path_id":           e.PathID,
+		"congestion_window": e.CongestionWindow,
+		"bytes_in_flight":   e.BytesInFlight,
+		"smoothed_rtt":      milliseconds(e.SmoothedRTT),
+		"latest_rtt":        milliseconds(e.LatestRTT),
+		"min_rtt":           milliseconds(e.MinRTT),
+		"rtt_variance":      milliseconds(e.RTTVariance),
+	}
+}
+
+// Name returns the qlog name of the event
+func (e *PathAdded) Name() string { return "multipath:path_added" }
+
+func (e *PathAdded) fields() map[string]interface{} {
+	f := map[string]interface{}{"path_id": e.PathID}
+	if e.LocalAddr != nil {
+		f["local_address"] = e.LocalAddr.String()
+	}
+	if e.RemoteAddr != nil {
+		f["remote_address"] = e.RemoteAddr.String()
+	}
+	return f
+}
+
+// Name returns the qlog name of the event
+func (e *PathClosed) Name() string { return "multipath:path_closed" }
+
+func (e *PathClosed) fields() map[string]interface{} {
+	return map[string]interface{}{"path_id": e.PathID}
+}
+
+// Name returns the qlog name of the event
+func (e *SchedulerDecision) Name() string { return "multipath:scheduler_decision" }
+
+func (e *SchedulerDecision) fields() map[string]interface{} {
+	f := map[string]interface{}{
+		"scheduler":      e.Scheduler,
+		"selected":       e.Selected,
+		"retransmission": e.Retransmission,
+	}
+	if e.Selected {
+		f["path_id"] = e.PathID
+	}
+	if e.Arm >= 0 {
+		f["arm"] = e.Arm
+	}
+	return f
+}
+
+// Name returns the qlog name of the event
+func (e *BanditUpdate) Name() string { return "multipath:bandit_update" }
+
+func (e *BanditUpdate) fields() map[string]interface{} {
+	f := map[string]interface{}{
+		"bandit":  e.Bandit,
+		"path_id": e.PathID,
+		"arm":     e.Arm,
+		"reward":  e.Reward,
+	}
+	if e.NextArm >= 0 {
+		f["next_arm"] = e.NextArm
+	}
+	return f
+}
+
+// Name returns the qlog name of the event
+func (e *DeadlineMissSummary) Name() string { return "multipath:deadline_miss_summary" }
+
+func (e *DeadlineMissSummary) fields() map[string]interface{} {
+	return map[string]interface{}{
+		"no_feasible_path": e.NoFeasiblePath,
+		"deferred":         e.Deferred,
+		"held_for_cost":    e.HeldForCost,
+		"retransmitted":    e.Retransmitted,
+		"not_sent":         e.NotSent,
+		"late_on_path":     e.LateOnPath,
+	}
+}
+
+// packetFields lays out a packet the way qlog visualisers expect it, with the multipath extensions next to the header
+func packetFields(pathID protocol.PathID, pn protocol.PacketNumber, length protocol.ByteCount, frames []wire.Frame, deadline time.Time, curNotSent, alpha uint8) map[string]interface{} {
+	f := map[string]interface{}{
+		"header": map[string]interface{}{
+			"packet_type":   "1RTT",
+			"packet_number": pn,
+			"path_id":       pathID,
+		},
+		"raw":          map[string]interface{}{"length": length},
+		"frames":       frameList(frames),
+		"cur_not_sent": curNotSent,
+		"alpha":        float64(alpha) / 10,
+	}
+	if !deadline.IsZero() {
+		f["deadline"] = deadline.UTC().Format(time.RFC3339Nano)
+	}
+	return f
+}
+
+func frameList(frames []wire.Frame) []map[string]interface{} {
+	l := make([]map[string]interface{}, 0, len(frames))
+	for _, frame := range frames {
+		l = append(l, frameFields(frame))
+	}
+	return l
+}
+
+func frameFields(frame wire.Frame) map[string]interface{} {
+	switch f := frame.(type) {
+	case *wire.StreamFrame:
+		return map[string]interface{}{
+			"frame_type": "stream",
+			"stream_id":  f.StreamID,
+			"offset":     f.Offset,
+			"length":     len(f.Data),
+			"fin":        f.FinBit,
+		}
+	case *wire.AckFrame:
+		return map[string]interface{}{
+			"frame_type":    "ack",
+			"path_id":       f.PathID,
+			"largest_acked": f.LargestAcked,
+			"lowest_acked":  f.LowestAcked,
+		}
+	case *wire.StopWaitingFrame:
+		return map[string]interface{}{"frame_type": "stop_waiting", "least_unacked": f.LeastUnacked}
+	case *wire.WindowUpdateFrame:
+		return map[string]interface{}{"frame_type": "window_update", "stream_id": f.StreamID, "byte_offset": f.ByteOffset}
+	case *wire.BlockedFrame:
+		return map[string]interface{}{"frame_type": "blocked", "stream_id": f.StreamID}
+	case *wire.RstStreamFrame:
+		return map[string]interface{}{"frame_type": "rst_stream", "stream_id": f.StreamID}
+	case *wire.ConnectionCloseFrame:
+		return map[string]interface{}{"frame_type": "connection_close", "error_code": f.ErrorCode, "reason": f.ReasonPhrase}
+	case *wire.GoawayFrame:
+		return map[string]interface{}{"frame_type": "goaway"}
+	case *wire.PingFrame:
+		return map[string]interface{}{"frame_type": "ping"}
+	case *wire.AddAddressFrame:
+		return map[string]interface{}{"frame_type": "add_address", "address": f.Addr.String()}
+	case *wire.ClosePathFrame:
+		return map[string]interface{}{"frame_type": "close_path", "path_id": f.PathID}
+	case *wire.PathsFrame:
+		return map[string]interface{}{"frame_type": "paths", "path_ids": pathIDList(f.PathIDs)}
+	case *wire.PathCostFrame:
+		return map[string]interface{}{"frame_type": "path_cost", "path_ids": pathIDList(f.PathIDs)}
+	case *wire.PathQualityFrame:
+		pathIDs := make([]protocol.PathID, 0, len(f.Paths))
+		for _, q := range f.Paths {
+			pathIDs = append(pathIDs, q.PathID)
+		}
+		return map[string]interface{}{"frame_type": "path_quality", "path_ids": pathIDList(pathIDs)}
+	}
+	return map[string]interface{}{"frame_type": "unknown"}
+}
+
+// pathIDList converts path IDs to ints, since encoding/json writes byte slices as base64 strings
+func pathIDList(pathIDs []protocol.PathID) []int {
+	l := make([]int, len(pathIDs))
+	for i, pathID := range pathIDs {
+		l[i] = int(pathID)
+	}
+	return l
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
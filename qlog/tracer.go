@@ -0,0 +1,54 @@
+package qlog
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// A Tracer receives the events of the sessions it is configured for.
+// It is called from the goroutines of all these sessions, and must be safe for concurrent use.
+type Tracer interface {
+	Trace(ev *Event)
+}
+
+// A ConnectionTracer stamps the events of one session and passes them to a Tracer.
+// A nil ConnectionTracer drops all events, so that callers don't need to check whether tracing is enabled.
+type ConnectionTracer struct {
+	tracer       Tracer
+	clock        utils.Clock
+	connectionID protocol.ConnectionID
+	perspective  protocol.Perspective
+}
+
+// NewConnectionTracer creates a new ConnectionTracer, or returns nil if the tracer is nil.
+// The events are stamped with the time of the clock, the clock of the session.
+func NewConnectionTracer(tracer Tracer, clock utils.Clock, pers protocol.Perspective, connectionID protocol.ConnectionID) *ConnectionTracer {
+	if tracer == nil {
+		return nil
+	}
+	return &ConnectionTracer{
+		tracer:       tracer,
+		clock:        clock,
+		connectionID: connectionID,
+		perspective:  pers,
+	}
+}
+
+// Enabled returns whether events are traced at all.
+// It can be used to avoid computing the data of an event that would be dropped.
+func (t *ConnectionTracer) Enabled() bool {
+	return t != nil
+}
+
+// Trace traces an event of the session
+func (t *ConnectionTracer) Trace(data EventData) {
+	if t == nil {
+		return
+	}
+	t.tracer.Trace(&Event{
+		Time:         t.clock.Now(),
+		ConnectionID: t.connectionID,
+		Perspective:  t.perspective,
+		Data:         data,
+	})
+}
@@ -0,0 +1,44 @@
+package qlog
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockTracer struct {
+	events []*Event
+}
+
+func (t *mockTracer) Trace(ev *Event) {
+	t.events = append(t.events, ev)
+}
+
+var _ = Describe("ConnectionTracer", func() {
+	It("is nil if there's no tracer", func() {
+		Expect(NewConnectionTracer(nil, utils.SystemClock{}, protocol.PerspectiveClient, 1)).To(BeNil())
+	})
+
+	It("drops events if it is nil", func() {
+		var t *ConnectionTracer
+		Expect(t.Enabled()).To(BeFalse())
+		Expect(func() { t.Trace(&PathClosed{}) }).ToNot(Panic())
+	})
+
+	It("stamps events with the session", func() {
+		tracer := &mockTracer{}
+		t := NewConnectionTracer(tracer, utils.SystemClock{}, protocol.PerspectiveServer, 0x1337)
+		Expect(t.Enabled()).To(BeTrue())
+		t.Trace(&PathClosed{PathID: 1})
+		Expect(tracer.events).To(HaveLen(1))
+		ev := tracer.events[0]
+		Expect(ev.ConnectionID).To(Equal(protocol.ConnectionID(0x1337)))
+		Expect(ev.Perspective).To(Equal(protocol.PerspectiveServer))
+		Expect(ev.Time).To(BeTemporally("~", time.Now(), 10*time.Millisecond))
+		Expect(ev.Data).To(Equal(&PathClosed{PathID: 1}))
+	})
+})
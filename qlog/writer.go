@@ -0,0 +1,111 @@
+package qlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// recordSeparator starts every record of a JSON text sequence (RFC 7464)
+const recordSeparator = 0x1e
+
+const qlogVersion = "0.3"
+
+// A Writer is a Tracer that writes the events as a qlog trace in the JSON-SEQ format.
+// The trace starts with a header record, followed by one record per event.
+// Events of different sessions are told apart by their group_id, the connection ID.
+type Writer struct {
+	mutex sync.Mutex
+
+	w             io.Writer
+	title         string
+	referenceTime time.Time
+	wroteHeader   bool
+	err           error
+}
+
+var _ Tracer = &Writer{}
+
+// NewWriter creates a new Writer.
+// The times of the events are relative to the time of the first event,
+// so that the trace follows the clock of the sessions, even if it is virtual.
+func NewWriter(w io.Writer, title string) *Writer {
+	return &Writer{
+		w:     w,
+		title: title,
+	}
+}
+
+// Trace writes an event
+func (w *Writer) Trace(ev *Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err != nil {
+		return
+	}
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.referenceTime = ev.Time
+		if w.err = w.writeRecord(w.header(ev.Perspective)); w.err != nil {
+			return
+		}
+	}
+	w.err = w.writeRecord(map[string]interface{}{
+		"time":     milliseconds(ev.Time.Sub(w.referenceTime)),
+		"name":     ev.Data.Name(),
+		"group_id": fmt.Sprintf("%x", ev.ConnectionID),
+		"data":     ev.Data.fields(),
+	})
+}
+
+// Err returns the first error returned by the underlying io.Writer, after which no more events are written
+func (w *Writer) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+// header is the first record of the trace.
+// The vantage point is the perspective of the first traced session.
+func (w *Writer) header(pers protocol.Perspective) map[string]interface{} {
+	vantagePoint := "unknown"
+	switch pers {
+	case protocol.PerspectiveClient:
+		vantagePoint = "client"
+	case protocol.PerspectiveServer:
+		vantagePoint = "server"
+	}
+	return map[string]interface{}{
+		"qlog_version": qlogVersion,
+		"qlog_format":  "JSON-SEQ",
+		"title":        w.title,
+		"trace": map[string]interface{}{
+			"vantage_point": map[string]interface{}{"type": vantagePoint},
+			"common_fields": map[string]interface{}{
+				"reference_time": float64(w.referenceTime.UnixNano()) / float64(time.Millisecond),
+				"time_format":    "relative",
+			},
+		},
+	}
+}
+
+// writeRecord writes a record. Records that can't be encoded, e.g. because of a NaN value, are dropped.
+func (w *Writer) writeRecord(record map[string]interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		utils.Errorf("qlog: dropping record %v: %s", record["name"], err)
+		return nil
+	}
+	buf := make([]byte, 0, len(data)+2)
+	buf = append(buf, recordSeparator)
+	buf = append(buf, data...)
+	buf = append(buf, '\n')
+	_, err = w.w.Write(buf)
+	return err
+}
@@ -0,0 +1,207 @@
+package qlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) { return 0, errors.New("write error") }
+
+var _ = Describe("Writer", func() {
+	var (
+		buf    *bytes.Buffer
+		writer *Writer
+		tracer *ConnectionTracer
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		writer = NewWriter(buf, "test trace")
+		tracer = NewConnectionTracer(writer, utils.SystemClock{}, protocol.PerspectiveClient, 0xdecafbad)
+	})
+
+	// records splits the JSON text sequence into its records
+	records := func() []map[string]interface{} {
+		var recs []map[string]interface{}
+		for _, r := range bytes.Split(buf.Bytes(), []byte{recordSeparator}) {
+			if len(r) == 0 {
+				continue
+			}
+			Expect(r[len(r)-1]).To(Equal(byte('\n')))
+			var rec map[string]interface{}
+			Expect(json.Unmarshal(r, &rec)).To(Succeed())
+			recs = append(recs, rec)
+		}
+		return recs
+	}
+
+	It("doesn't write anything before the first event", func() {
+		Expect(buf.Len()).To(BeZero())
+	})
+
+	It("writes the header before the first event", func() {
+		tracer.Trace(&PathClosed{PathID: 3})
+		tracer.Trace(&PathClosed{PathID: 5})
+		Expect(buf.Bytes()[0]).To(Equal(byte(recordSeparator)))
+		recs := records()
+		Expect(recs).To(HaveLen(3))
+		Expect(recs[0]).To(HaveKeyWithValue("qlog_version", "0.3"))
+		Expect(recs[0]).To(HaveKeyWithValue("qlog_format", "JSON-SEQ"))
+		Expect(recs[0]).To(HaveKeyWithValue("title", "test trace"))
+		trace := recs[0]["trace"].(map[string]interface{})
+		Expect(trace["vantage_point"]).To(Equal(map[string]interface{}{"type": "client"}))
+		Expect(recs[1]).To(HaveKeyWithValue("name", "multipath:path_closed"))
+		Expect(recs[1]).To(HaveKeyWithValue("group_id", "decafbad"))
+		Expect(recs[1]["data"]).To(Equal(map[string]interface{}{"path_id": 3.0}))
+		Expect(recs[2]["data"]).To(Equal(map[string]interface{}{"path_id": 5.0}))
+	})
+
+	It("writes times relative to the first event", func() {
+		start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		writer.Trace(&Event{Time: start, Data: &PathClosed{}})
+		writer.Trace(&Event{Time: start.Add(1500 * time.Microsecond), Data: &PathClosed{}})
+		recs := records()
+		Expect(recs[0]["trace"].(map[string]interface{})["common_fields"]).To(HaveKeyWithValue("reference_time", float64(start.UnixNano())/1e6))
+		Expect(recs[1]).To(HaveKeyWithValue("time", 0.0))
+		Expect(recs[2]).To(HaveKeyWithValue("time", 1.5))
+	})
+
+	It("uses the server vantage point", func() {
+		NewConnectionTracer(writer, utils.SystemClock{}, protocol.PerspectiveServer, 1).Trace(&PathClosed{})
+		trace := records()[0]["trace"].(map[string]interface{})
+		Expect(trace["vantage_point"]).To(Equal(map[string]interface{}{"type": "server"}))
+	})
+
+	It("writes sent packets", func() {
+		deadline := time.Date(2018, 1, 2, 3, 4, 5, 6000, time.UTC)
+		tracer.Trace(&PacketSent{
+			PathID:       1,
+			PacketNumber: 42,
+			Length:       1350,
+			Frames: []wire.Frame{
+				&wire.StreamFrame{StreamID: 5, Offset: 100, Data: []byte("foobar"), FinBit: true},
+				&wire.PathsFrame{PathIDs: []protocol.PathID{1, 3}},
+			},
+			Deadline:   deadline,
+			CurNotSent: 2,
+			Alpha:      11,
+			Arm:        2,
+			Cost:       1.5,
+		})
+		data := records()[1]["data"].(map[string]interface{})
+		Expect(data["header"]).To(Equal(map[string]interface{}{"packet_type": "1RTT", "packet_number": 42.0, "path_id": 1.0}))
+		Expect(data["raw"]).To(Equal(map[string]interface{}{"length": 1350.0}))
+		Expect(data["frames"]).To(Equal([]interface{}{
+			map[string]interface{}{"frame_type": "stream", "stream_id": 5.0, "offset": 100.0, "length": 6.0, "fin": true},
+			map[string]interface{}{"frame_type": "paths", "path_ids": []interface{}{1.0, 3.0}},
+		}))
+		Expect(data).To(HaveKeyWithValue("deadline", "2018-01-02T03:04:05.000006Z"))
+		Expect(data).To(HaveKeyWithValue("cur_not_sent", 2.0))
+		Expect(data).To(HaveKeyWithValue("alpha", 1.1))
+		Expect(data).To(HaveKeyWithValue("arm", 2.0))
+		Expect(data).To(HaveKeyWithValue("cost", 1.5))
+	})
+
+	It("omits the deadline of received packets without one", func() {
+		tracer.Trace(&PacketReceived{PathID: 1, PacketNumber: 42, Frames: []wire.Frame{&wire.PingFrame{}}})
+		rec := records()[1]
+		Expect(rec).To(HaveKeyWithValue("name", "transport:packet_received"))
+		data := rec["data"].(map[string]interface{})
+		Expect(data).ToNot(HaveKey("deadline"))
+		Expect(data["frames"]).To(Equal([]interface{}{map[string]interface{}{"frame_type": "ping"}}))
+	})
+
+	It("writes metrics in milliseconds", func() {
+		tracer.Trace(&MetricsUpdated{
+			PathID:           1,
+			CongestionWindow: 32000,
+			BytesInFlight:    1000,
+			SmoothedRTT:      25 * time.Millisecond,
+			LatestRTT:        30500 * time.Microsecond,
+			MinRTT:           20 * time.Millisecond,
+			RTTVariance:      5 * time.Millisecond,
+		})
+		rec := records()[1]
+		Expect(rec).To(HaveKeyWithValue("name", "recovery:metrics_updated"))
+		Expect(rec["data"]).To(Equal(map[string]interface{}{
+			"path_id":           1.0,
+			"congestion_window": 32000.0,
+			"bytes_in_flight":   1000.0,
+			"smoothed_rtt":      25.0,
+			"latest_rtt":        30.5,
+			"min_rtt":           20.0,
+			"rtt_variance":      5.0,
+		}))
+	})
+
+	It("writes the addresses of added paths", func() {
+		tracer.Trace(&PathAdded{
+			PathID:     1,
+			LocalAddr:  &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234},
+			RemoteAddr: &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 4321},
+		})
+		Expect(records()[1]["data"]).To(Equal(map[string]interface{}{
+			"path_id":        1.0,
+			"local_address":  "10.0.0.1:1234",
+			"remote_address": "10.0.0.2:4321",
+		}))
+	})
+
+	It("writes scheduler decisions", func() {
+		tracer.Trace(&SchedulerDecision{Scheduler: "lowband", Selected: true, PathID: 3, Arm: 1})
+		tracer.Trace(&SchedulerDecision{Scheduler: "rtt", Retransmission: true, Arm: -1})
+		recs := records()
+		Expect(recs[1]["data"]).To(Equal(map[string]interface{}{"scheduler": "lowband", "selected": true, "path_id": 3.0, "retransmission": false, "arm": 1.0}))
+		Expect(recs[2]["data"]).To(Equal(map[string]interface{}{"scheduler": "rtt", "selected": false, "retransmission": true}))
+	})
+
+	It("writes bandit updates", func() {
+		tracer.Trace(&BanditUpdate{Bandit: BanditAlpha, PathID: 1, Arm: 2, Reward: 0.5, NextArm: 3})
+		tracer.Trace(&BanditUpdate{Bandit: BanditPath, PathID: 3, Arm: 1, Reward: 0.25, NextArm: -1})
+		recs := records()
+		Expect(recs[1]["data"]).To(Equal(map[string]interface{}{"bandit": "alpha", "path_id": 1.0, "arm": 2.0, "reward": 0.5, "next_arm": 3.0}))
+		Expect(recs[2]["data"]).To(Equal(map[string]interface{}{"bandit": "path", "path_id": 3.0, "arm": 1.0, "reward": 0.25}))
+	})
+
+	It("writes deadline miss summaries", func() {
+		tracer.Trace(&DeadlineMissSummary{NoFeasiblePath: 1, Deferred: 2, HeldForCost: 3, Retransmitted: 4, NotSent: 5, LateOnPath: 6})
+		recs := records()
+		Expect(recs[1]).To(HaveKeyWithValue("name", "multipath:deadline_miss_summary"))
+		Expect(recs[1]["data"]).To(Equal(map[string]interface{}{
+			"no_feasible_path": 1.0,
+			"deferred":         2.0,
+			"held_for_cost":    3.0,
+			"retransmitted":    4.0,
+			"not_sent":         5.0,
+			"late_on_path":     6.0,
+		}))
+	})
+
+	It("drops events that can't be encoded", func() {
+		tracer.Trace(&BanditUpdate{Reward: math.NaN()})
+		tracer.Trace(&PathClosed{PathID: 1})
+		recs := records()
+		Expect(recs).To(HaveLen(2))
+		Expect(recs[1]).To(HaveKeyWithValue("name", "multipath:path_closed"))
+		Expect(writer.Err()).ToNot(HaveOccurred())
+	})
+
+	It("stops writing after a write error", func() {
+		writer = NewWriter(errorWriter{}, "")
+		writer.Trace(&Event{Data: &PathClosed{}})
+		Expect(writer.Err()).To(MatchError("write error"))
+	})
+})
@@ -0,0 +1,159 @@
+// Package schedlog records the decisions of the batch schedulers as JSON lines, for offline analysis and replay.
+//
+// Every line is a Record. A Decision records the inputs that the scheduler gave its solver and the
+// packets that it preserved or deferred, a PacketSent links a packet of the batch to its packet number,
+// and a PacketOutcome tells what happened to that packet later. Records of the same session share
+// their connection_id, and the packets of a decision share its batch number.
+package schedlog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// SchemaVersion is the version of the schema of the records.
+// It is only incremented for changes that break readers, new fields can be added at any time.
+const SchemaVersion = 1
+
+// Record types
+const (
+	TypeDecision   = "decision"
+	TypePacketSent = "packet_sent"
+	TypeOutcome    = "packet_outcome"
+	TypeExperience = "experience"
+)
+
+// Packet outcomes
+const (
+	// OutcomeAcked is recorded when the packet is acknowledged
+	OutcomeAcked = "acked"
+	// OutcomeRetransmitted is recorded when the packet is declared lost, or queued for retransmission by a TLP or RTO
+	OutcomeRetransmitted = "retransmitted"
+)
+
+// NoPath is the path ID recorded for packets of a batch that the scheduler sent on no path
+const NoPath = -1
+
+// A Record is a line of the decision log. Exactly one of Decision, Packet, Outcome and Experience is set, depending on Type.
+type Record struct {
+	Version      int            `json:"v"`
+	Type         string         `json:"type"`
+	Time         time.Time      `json:"time"`
+	ConnectionID string         `json:"connection_id"`
+	Decision     *Decision      `json:"decision,omitempty"`
+	Packet       *PacketSent    `json:"packet,omitempty"`
+	Outcome      *PacketOutcome `json:"outcome,omitempty"`
+	Experience   *Experience    `json:"experience,omitempty"`
+}
+
+// Data is the payload of a Record, one of Decision, PacketSent, PacketOutcome and Experience
+type Data interface {
+	setIn(r *Record)
+}
+
+// NewRecord creates a record of the current schema version
+func NewRecord(connectionID protocol.ConnectionID, t time.Time, data Data) *Record {
+	r := &Record{
+		Version:      SchemaVersion,
+		Time:         t,
+		ConnectionID: fmt.Sprintf("%x", connectionID),
+	}
+	data.setIn(r)
+	return r
+}
+
+// Solvers of a decision
+const (
+	// SolverLinOpt is the linear program of BatchLinOpt
+	SolverLinOpt = "linopt"
+	// SolverLinOptCost is the linear program of BatchLinOpt with the cost constraint of CaDaMPS
+	SolverLinOptCost = "linopt_cost"
+	// SolverEDF assigns the packets, sorted by deadline, to the path with the lowest RTT
+	SolverEDF = "edf"
+	// SolverFirstPath sends all packets on the first path
+	SolverFirstPath = "first_path"
+	// SolverSinglePath is used as long as the session has a single path
+	SolverSinglePath = "single_path"
+)
+
+// A Decision is the decision of a batch scheduler for a batch of packets
+type Decision struct {
+	// Batch numbers the decisions of a session, starting at 1
+	Batch     uint64 `json:"batch"`
+	Scheduler string `json:"scheduler"`
+	// Solver is empty if the scheduler found no path to give to a solver
+	Solver string `json:"solver"`
+	// Deadlines of the packets of the batch in ms, relative to the time of the decision, in the order of the policy
+	Deadlines []int `json:"deadlines_ms"`
+	// Paths are the paths the solver could choose from, in the order of the policy
+	Paths []PathState `json:"paths"`
+	// Budget is the cost budget of the batch, only set if the solver has a cost constraint
+	Budget float64 `json:"budget,omitempty"`
+	// Policy is the result of the solver: for each packet, the 1-based index of its path in Paths, or 0 if it has none
+	Policy []int `json:"policy,omitempty"`
+	// Selected is the path ID chosen for each packet, or NoPath
+	Selected []int `json:"selected"`
+	// Preserved are the indexes of the packets without a path, kept for the next batch because their deadline is far enough
+	Preserved []int `json:"preserved,omitempty"`
+	// Deferred are the indexes of the packets taken off a costly path, kept for the next batch to wait for a cheaper one
+	Deferred []int `json:"deferred,omitempty"`
+}
+
+func (d *Decision) setIn(r *Record) {
+	r.Type = TypeDecision
+	r.Decision = d
+}
+
+// PathState is the state of a path given to the solver
+type PathState struct {
+	PathID      int     `json:"path_id"`
+	SmoothedRTT float64 `json:"smoothed_rtt_ms"`
+	// Delay is the one-way delay used by the solver in ms, i.e. half of the smoothed RTT scaled by Alpha
+	Delay            float64 `json:"delay_ms"`
+	Alpha            float64 `json:"alpha"`
+	CongestionWindow uint64  `json:"cwnd"`
+	BytesInFlight    uint64  `json:"bytes_in_flight"`
+	// RemainingCwnd is the number of full-sized packets that still fit in the congestion window
+	RemainingCwnd float64 `json:"remaining_cwnd_packets"`
+	Cost          float64 `json:"cost"`
+}
+
+// PacketSent is recorded when a packet of a batch is sent
+type PacketSent struct {
+	Batch        uint64    `json:"batch"`
+	Index        int       `json:"index"`
+	PathID       int       `json:"path_id"`
+	PacketNumber uint64    `json:"packet_number"`
+	Deadline     time.Time `json:"deadline"`
+}
+
+func (p *PacketSent) setIn(r *Record) {
+	r.Type = TypePacketSent
+	r.Packet = p
+}
+
+// PacketOutcome is recorded when a sent packet is acknowledged or retransmitted.
+// It is recorded for every packet of the session, not only for the packets of batches.
+type PacketOutcome struct {
+	PathID       int    `json:"path_id"`
+	PacketNumber uint64 `json:"packet_number"`
+	Outcome      string `json:"outcome"`
+}
+
+func (o *PacketOutcome) setIn(r *Record) {
+	r.Type = TypeOutcome
+	r.Outcome = o
+}
+
+// An Experience is a step of the DQN agent, recorded if the experiences are dumped
+type Experience struct {
+	State  []float64 `json:"state"`
+	Action int       `json:"action"`
+}
+
+func (e *Experience) setIn(r *Record) {
+	r.Type = TypeExperience
+	r.Experience = e
+}
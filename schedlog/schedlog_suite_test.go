@@ -0,0 +1,13 @@
+package schedlog
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSchedlog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "schedlog Suite")
+}
@@ -0,0 +1,90 @@
+package schedlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// A Recorder receives the records of the sessions it is configured for.
+// It is called from the goroutines of all these sessions, and must be safe for concurrent use.
+type Recorder interface {
+	Record(r *Record)
+}
+
+// A Writer is a Recorder that writes one JSON record per line
+type Writer struct {
+	mutex sync.Mutex
+
+	w   io.Writer
+	err error
+}
+
+var _ Recorder = &Writer{}
+
+// NewWriter creates a new Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Record writes a record. Records that can't be encoded, e.g. because of a NaN value, are dropped.
+func (w *Writer) Record(r *Record) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.err != nil {
+		return
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		utils.Errorf("schedlog: dropping %s record: %s", r.Type, err)
+		return
+	}
+	_, w.err = w.w.Write(append(data, '\n'))
+}
+
+// Err returns the first error returned by the underlying io.Writer, after which no more records are written
+func (w *Writer) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+// A Reader reads the records written by a Writer
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader creates a new Reader
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24)
+	return &Reader{scanner: scanner}
+}
+
+// Read reads the next record, skipping empty lines. It returns io.EOF at the end of the log.
+func (r *Reader) Read() (*Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := &Record{}
+		if err := json.Unmarshal(r.scanner.Bytes(), rec); err != nil {
+			return nil, fmt.Errorf("schedlog: line %d: %s", r.line, err)
+		}
+		if rec.Version != SchemaVersion {
+			return nil, fmt.Errorf("schedlog: line %d: unsupported schema version %d", r.line, rec.Version)
+		}
+		return rec, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
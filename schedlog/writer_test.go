@@ -0,0 +1,126 @@
+package schedlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) { return 0, errors.New("write error") }
+
+var _ = Describe("Decision log", func() {
+	var (
+		buf    *bytes.Buffer
+		writer *Writer
+		now    time.Time
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		writer = NewWriter(buf)
+		now = time.Date(2018, 1, 2, 3, 4, 5, 6000, time.UTC)
+	})
+
+	It("writes one record per line", func() {
+		writer.Record(NewRecord(0xdecafbad, now, &PacketOutcome{PathID: 1, PacketNumber: 3, Outcome: OutcomeAcked}))
+		writer.Record(NewRecord(0xdecafbad, now, &PacketOutcome{PathID: 3, PacketNumber: 4, Outcome: OutcomeRetransmitted}))
+		lines := strings.Split(buf.String(), "\n")
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[2]).To(BeEmpty())
+		var rec map[string]interface{}
+		Expect(json.Unmarshal([]byte(lines[0]), &rec)).To(Succeed())
+		Expect(rec).To(Equal(map[string]interface{}{
+			"v":             1.0,
+			"type":          "packet_outcome",
+			"time":          "2018-01-02T03:04:05.000006Z",
+			"connection_id": "decafbad",
+			"outcome":       map[string]interface{}{"path_id": 1.0, "packet_number": 3.0, "outcome": "acked"},
+		}))
+	})
+
+	It("reads the records it writes", func() {
+		decision := &Decision{
+			Batch:     1,
+			Scheduler: "BatchLinOpt",
+			Solver:    SolverLinOptCost,
+			Deadlines: []int{20, 35, 48},
+			Paths: []PathState{
+				{PathID: 1, SmoothedRTT: 40, Delay: 22, Alpha: 1.1, CongestionWindow: 32000, BytesInFlight: 1350, RemainingCwnd: 22, Cost: 2},
+				{PathID: 3, SmoothedRTT: 20, Delay: 10, Alpha: 1, CongestionWindow: 16000, RemainingCwnd: 11, Cost: 1},
+			},
+			Budget:    4,
+			Policy:    []int{2, 1, 0},
+			Selected:  []int{3, 1, NoPath},
+			Preserved: []int{2},
+			Deferred:  []int{1},
+		}
+		sent := &PacketSent{Batch: 1, Index: 0, PathID: 3, PacketNumber: 7, Deadline: now.Add(20 * time.Millisecond)}
+		experience := &Experience{State: []float64{0.5, 1}, Action: 1}
+		writer.Record(NewRecord(0x1337, now, decision))
+		writer.Record(NewRecord(0x1337, now, sent))
+		writer.Record(NewRecord(0x1337, now, experience))
+
+		reader := NewReader(buf)
+		rec, err := reader.Read()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rec.Type).To(Equal(TypeDecision))
+		Expect(rec.ConnectionID).To(Equal("1337"))
+		Expect(rec.Time.Equal(now)).To(BeTrue())
+		Expect(rec.Decision).To(Equal(decision))
+		Expect(rec.Packet).To(BeNil())
+		rec, err = reader.Read()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rec.Type).To(Equal(TypePacketSent))
+		Expect(rec.Packet.PacketNumber).To(BeEquivalentTo(7))
+		Expect(rec.Packet.Deadline.Equal(sent.Deadline)).To(BeTrue())
+		rec, err = reader.Read()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rec.Experience).To(Equal(experience))
+		_, err = reader.Read()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("drops records that can't be encoded", func() {
+		writer.Record(NewRecord(1, now, &Decision{Paths: []PathState{{Delay: math.Inf(1)}}}))
+		writer.Record(NewRecord(1, now, &PacketOutcome{Outcome: OutcomeAcked}))
+		Expect(strings.Count(buf.String(), "\n")).To(Equal(1))
+		Expect(writer.Err()).ToNot(HaveOccurred())
+	})
+
+	It("stops writing after a write error", func() {
+		writer = NewWriter(errorWriter{})
+		writer.Record(NewRecord(1, now, &PacketOutcome{}))
+		Expect(writer.Err()).To(MatchError("write error"))
+	})
+
+	It("skips empty lines", func() {
+		reader := NewReader(strings.NewReader("\n" + `{"v":1,"type":"packet_outcome","outcome":{"outcome":"acked"}}` + "\n\n"))
+		rec, err := reader.Read()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rec.Outcome.Outcome).To(Equal(OutcomeAcked))
+		_, err = reader.Read()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("rejects other schema versions", func() {
+		_, err := NewReader(strings.NewReader(`{"v":2,"type":"decision"}`)).Read()
+		Expect(err).To(MatchError("schedlog: line 1: unsupported schema version 2"))
+	})
+
+	It("reports the line of invalid records", func() {
+		reader := NewReader(strings.NewReader(`{"v":1,"type":"decision"}` + "\nfoobar\n"))
+		_, err := reader.Read()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = reader.Read()
+		Expect(err).To(MatchError(ContainSubstring("schedlog: line 2:")))
+	})
+})
@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"github.com/lucas-clemente/quic-go/ackhandler"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
-	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
-	"gonum.org/v1/gonum/stat/distuv"
+	"github.com/lucas-clemente/quic-go/qlog"
+	"github.com/lucas-clemente/quic-go/schedlog"
 	"math"
 	"math/rand"
 	"os"
@@ -29,6 +29,8 @@ type scheduler struct {
 	SchedulerName string
 	// Is training?
 	Training bool
+	// Epsilon is the exploration rate of the training agent
+	Epsilon float64
 	// Training Agent
 	TrainingAgent agents.TrainingAgent
 	// Normal Agent
@@ -68,25 +70,55 @@ type scheduler struct {
 	// Retrans cache
 	retrans map[protocol.PathID]uint64
 
-	// Write experiences
-	DumpExp   bool
-	DumpPath  string
-	dumpAgent experienceAgent
+	// Record experiences in the decision log
+	DumpExp bool
 
 	//czy
 	NotSentPackets   uint64
 	totalCost        float64
 	totalPktWithCost uint64
 	curNotSentPacket uint8
+	// number of packets held back for a later batch
+	deferredPackets uint64
 
-	waitPackets []time.Time
-	startTime   time.Time
+	waitPackets []heldPacket
+	// packets of the current batch held back in an earlier one, indexed like its deadlines
+	batchHeld []heldPacket
+	startTime time.Time
+
+	// rand draws the random decisions and the synthetic deadlines of the scheduler
+	rand *rand.Rand
+
+	// arm chosen by the bandit of the last decision, -1 if the scheduler has none
+	banditArm int
+
+	// whether BatchLinOpt solves the cost-constrained program of CaDaMPS
+	costConstraint bool
+
+	// number of batch decisions, and the decision being recorded in the decision log
+	batchCount uint64
+	decision   *schedlog.Decision
+	// solver of the last batch decision, and of the one before it
+	solver         string
+	previousSolver string
+
+	// whether the cost budget was spent
+	budgetExhausted bool
+
+	// causes of the deadline misses of the packets the batch schedulers didn't send
+	unsentDeadlineMisses DeadlineMisses
 }
 
 func (sch *scheduler) setup() {
 	sch.quotas = make(map[protocol.PathID]uint)
 	sch.retrans = make(map[protocol.PathID]uint64)
 	sch.waiting = 0
+	sch.costConstraint = costConstraintAvailable
+	// BatchLinOptNoCost is BatchLinOpt without the cost constraint, i.e. DaMPS rather than CaDaMPS
+	if sch.SchedulerName == "BatchLinOptNoCost" {
+		sch.SchedulerName = "BatchLinOpt"
+		sch.costConstraint = false
+	}
 
 	//Read lin to buffer
 	// file, err := os.Open("/App/output/lin")
@@ -113,15 +145,10 @@ func (sch *scheduler) setup() {
 	}
 	file.Close()
 
-	//TODO: expose to config
-	sch.DumpPath = "/tmp/"
-	sch.dumpAgent.Setup()
-
-	sch.startTime = time.Now()
-
 	sch.cachedState = types.Vector{-1, -1}
 	if sch.SchedulerName == "dqnAgent" {
 		if sch.Training {
+			// the agent doesn't explore itself, trainingAction draws the exploration from the seeded source
 			sch.TrainingAgent = GetTrainingAgent("", "", "", 0.)
 		} else {
 			sch.Agent = GetAgent("", "")
@@ -136,7 +163,7 @@ func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, ret
 		// XXX We need to check on ALL paths if any packet should be first retransmitted
 		s.pathsLock.RLock()
 	retransmitLoop:
-		for _, pthTmp := range s.paths {
+		for _, pthTmp := range s.sortedPaths() {
 			retransmitPacket = pthTmp.sentPacketHandler.DequeuePacketForRetransmission()
 			if retransmitPacket != nil {
 				pth = pthTmp
@@ -154,10 +181,10 @@ func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, ret
 				// Don't retransmit handshake packets when the handshake is complete
 				continue
 			}
-			utils.Debugf("\tDequeueing handshake retransmission for packet 0x%x", retransmitPacket.PacketNumber)
+			s.logger.Debugf("\tDequeueing handshake retransmission for packet 0x%x", retransmitPacket.PacketNumber)
 			return
 		}
-		utils.Debugf("\tDequeueing retransmission of packet 0x%x from path %d", retransmitPacket.PacketNumber, pth.pathID)
+		s.logger.Debugf("\tDequeueing retransmission of packet 0x%x from path %d", retransmitPacket.PacketNumber, pth.pathID)
 		// resend the frames that were in the packet
 		for _, frame := range retransmitPacket.GetFramesForRetransmission() {
 			switch f := frame.(type) {
@@ -170,7 +197,7 @@ func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, ret
 				if err == nil && f.ByteOffset >= currentOffset {
 					s.packer.QueueControlFrame(f, pth)
 				}
-			case *wire.PathsFrame:
+			case *wire.PathsFrame, *wire.PathQualityFrame:
 				// Schedule a new PATHS frame to send
 				s.schedulePathsFrame()
 			default:
@@ -236,52 +263,70 @@ pathLoop:
 }
 
 func (sch *scheduler) selectPathLowLatency(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-	utils.Debugf("selectPathLowLatency")
-	// XXX Avoid using PathID 0 if there is more than 1 path
-	if len(s.paths) <= 1 {
-		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			utils.Debugf("Only initial path and sending not allowed without retransmission")
-			utils.Debugf("SCH RTT - NIL")
-			return nil
+	s.logger.Debugf("selectPathLowLatency")
+	pth := pathOf(sch.lowLatencyPath(s.pathViews(), hasRetransmission, hasStreamRetransmission, viewOf(fromPth)))
+	if pth == nil {
+		s.logger.Debugf("SCH RTT - NIL")
+	} else {
+		s.logger.Debugf("SCH RTT - Selecting %d by low RTT", pth.pathID)
+	}
+	return pth
+}
+
+// singlePath returns the only path, if sending is allowed on it or if the packet is a retransmission
+func singlePath(paths []PathView, hasRetransmission bool) PathView {
+	if len(paths) == 0 || (!hasRetransmission && !paths[0].SendingAllowed()) {
+		return nil
+	}
+	return paths[0]
+}
+
+// lowerQuotaPath returns another path than the initial one and fromPth that sent fewer packets than fromPth, if any.
+// A potentially failed path is not a candidate, its quota doesn't grow while it is skipped.
+func (sch *scheduler) lowerQuotaPath(paths []PathView, fromPth PathView) PathView {
+	currentQuota := sch.quotas[fromPth.PathID()]
+	for _, pth := range paths {
+		if pth.PathID() == protocol.InitialPathID || pth.PathID() == fromPth.PathID() || pth.PotentiallyFailed() {
+			continue
 		}
-		utils.Debugf("Only initial path and sending is allowed or has retransmission")
-		utils.Debugf("SCH RTT - InitialPath")
-		return s.paths[protocol.InitialPathID]
+		// The congestion window was checked when duplicating the packet
+		if sch.quotas[pth.PathID()] < currentQuota {
+			return pth
+		}
+	}
+	return nil
+}
+
+// lowLatencyPath selects the path with the lowest smoothed RTT, the paths not probed yet by their quotas
+func (sch *scheduler) lowLatencyPath(paths []PathView, hasRetransmission bool, hasStreamRetransmission bool, fromPth PathView) PathView {
+	// XXX Avoid using PathID 0 if there is more than 1 path
+	if len(paths) <= 1 {
+		return singlePath(paths, hasRetransmission)
 	}
 
 	// FIXME Only works at the beginning... Cope with new paths during the connection
-	if hasRetransmission && hasStreamRetransmission && fromPth.rttStats.SmoothedRTT() == 0 {
+	if hasRetransmission && hasStreamRetransmission && fromPth.SmoothedRTT() == 0 {
 		// Is there any other path with a lower number of packet sent?
-		currentQuota := sch.quotas[fromPth.pathID]
-		for pathID, pth := range s.paths {
-			if pathID == protocol.InitialPathID || pathID == fromPth.pathID {
-				continue
-			}
-			// The congestion window was checked when duplicating the packet
-			if sch.quotas[pathID] < currentQuota {
-				utils.Debugf("has ret, has stream ret and sRTT == 0")
-				utils.Debugf("SCH RTT - Selecting %d by low quota", pathID)
-				return pth
-			}
+		if pth := sch.lowerQuotaPath(paths, fromPth); pth != nil {
+			return pth
 		}
 	}
 
-	var selectedPath *path
+	var selectedPath PathView
 	var lowerRTT time.Duration
 	var currentRTT time.Duration
 	selectedPathID := protocol.PathID(255)
 
 pathLoop:
-	for pathID, pth := range s.paths {
+	for _, pth := range paths {
+		pathID := pth.PathID()
 		// Don't block path usage if we retransmit, even on another path
 		if !hasRetransmission && !pth.SendingAllowed() {
-			utils.Debugf("Discarding %d - no has ret and sending is not allowed ", pathID)
 			continue pathLoop
 		}
 
 		// If this path is potentially failed, do not consider it for sending
-		if pth.potentiallyFailed.Get() {
-			utils.Debugf("Discarding %d - potentially failed", pathID)
+		if pth.PotentiallyFailed() {
 			continue pathLoop
 		}
 
@@ -290,12 +335,11 @@ pathLoop:
 			continue pathLoop
 		}
 
-		currentRTT = pth.rttStats.SmoothedRTT()
+		currentRTT = pth.SmoothedRTT()
 
 		// Prefer staying single-path if not blocked by current path
 		// Don't consider this sample if the smoothed RTT is 0
 		if lowerRTT != 0 && currentRTT == 0 {
-			utils.Debugf("Discarding %d - currentRTT == 0 and lowerRTT != 0 ", pathID)
 			continue pathLoop
 		}
 
@@ -308,13 +352,11 @@ pathLoop:
 			}
 			lowerQuota, _ := sch.quotas[selectedPathID]
 			if selectedPath != nil && currentQuota > lowerQuota {
-				utils.Debugf("Discarding %d - higher quota ", pathID)
 				continue pathLoop
 			}
 		}
 
 		if currentRTT != 0 && lowerRTT != 0 && selectedPath != nil && currentRTT >= lowerRTT {
-			utils.Debugf("Discarding %d - higher SRTT ", pathID)
 			continue pathLoop
 		}
 
@@ -323,51 +365,46 @@ pathLoop:
 		selectedPath = pth
 		selectedPathID = pathID
 	}
-	utils.Debugf("SCH RTT - Selecting %d by low RTT: %f", selectedPathID, lowerRTT)
 	return selectedPath
 }
 
 func (sch *scheduler) selectBLEST(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-	utils.Debugf("selectPathBLEST")
+	s.logger.Debugf("selectPathBLEST")
+	return pathOf(sch.blestPath(s.pathViews(), sessionQueue{s}, hasRetransmission, hasStreamRetransmission, viewOf(fromPth)))
+}
+
+// blestPath selects the fastest path, or waits for it if the slower path would block the send window
+func (sch *scheduler) blestPath(paths []PathView, queue QueueView, hasRetransmission bool, hasStreamRetransmission bool, fromPth PathView) PathView {
 	// XXX Avoid using PathID 0 if there is more than 1 path
-	if len(s.paths) <= 1 {
-		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			return nil
-		}
-		return s.paths[protocol.InitialPathID]
+	if len(paths) <= 1 {
+		return singlePath(paths, hasRetransmission)
 	}
 
 	// FIXME Only works at the beginning... Cope with new paths during the connection
-	if hasRetransmission && hasStreamRetransmission && fromPth.rttStats.SmoothedRTT() == 0 {
+	if hasRetransmission && hasStreamRetransmission && fromPth.SmoothedRTT() == 0 {
 		// Is there any other path with a lower number of packet sent?
-		currentQuota := sch.quotas[fromPth.pathID]
-		for pathID, pth := range s.paths {
-			if pathID == protocol.InitialPathID || pathID == fromPth.pathID {
-				continue
-			}
-			// The congestion window was checked when duplicating the packet
-			if sch.quotas[pathID] < currentQuota {
-				return pth
-			}
+		if pth := sch.lowerQuotaPath(paths, fromPth); pth != nil {
+			return pth
 		}
 	}
 
-	var bestPath *path
-	var secondBestPath *path
+	var bestPath PathView
+	var secondBestPath PathView
 	var lowerRTT time.Duration
 	var currentRTT time.Duration
 	var secondLowerRTT time.Duration
 	bestPathID := protocol.PathID(255)
 
 pathLoop:
-	for pathID, pth := range s.paths {
+	for _, pth := range paths {
+		pathID := pth.PathID()
 		// Don't block path usage if we retransmit, even on another path
 		if !hasRetransmission && !pth.SendingAllowed() {
 			continue pathLoop
 		}
 
 		// If this path is potentially failed, do not consider it for sending
-		if pth.potentiallyFailed.Get() {
+		if pth.PotentiallyFailed() {
 			continue pathLoop
 		}
 
@@ -376,7 +413,7 @@ pathLoop:
 			continue pathLoop
 		}
 
-		currentRTT = pth.rttStats.SmoothedRTT()
+		currentRTT = pth.SmoothedRTT()
 
 		// Prefer staying single-path if not blocked by current path
 		// Don't consider this sample if the smoothed RTT is 0
@@ -428,10 +465,10 @@ pathLoop:
 	if secondBestPath == nil {
 		return nil
 	}
-	cwndBest := uint64(bestPath.sentPacketHandler.GetCongestionWindow())
+	cwndBest := uint64(bestPath.CongestionWindow())
 	FirstCo := uint64(protocol.DefaultTCPMSS) * uint64(secondLowerRTT) * (cwndBest*2*uint64(lowerRTT) + uint64(secondLowerRTT) - uint64(lowerRTT))
-	BSend, _ := s.flowControlManager.SendWindowSize(protocol.StreamID(5))
-	SecondCo := 2 * 1 * uint64(lowerRTT) * uint64(lowerRTT) * (uint64(BSend) - (uint64(secondBestPath.sentPacketHandler.GetBytesInFlight()) + uint64(protocol.DefaultTCPMSS)))
+	BSend := queue.SendWindow()
+	SecondCo := 2 * 1 * uint64(lowerRTT) * uint64(lowerRTT) * (uint64(BSend) - (uint64(secondBestPath.BytesInFlight()) + uint64(protocol.DefaultTCPMSS)))
 
 	if FirstCo > SecondCo {
 		return nil
@@ -441,46 +478,42 @@ pathLoop:
 }
 
 func (sch *scheduler) selectECF(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-	utils.Debugf("selectPathECF")
+	s.logger.Debugf("selectPathECF")
+	return pathOf(sch.ecfPath(s.pathViews(), sessionQueue{s}, hasRetransmission, hasStreamRetransmission, viewOf(fromPth)))
+}
+
+// ecfPath selects the fastest path, or waits for it if it would complete the transfer earlier than the slower path
+func (sch *scheduler) ecfPath(paths []PathView, queue QueueView, hasRetransmission bool, hasStreamRetransmission bool, fromPth PathView) PathView {
 	// XXX Avoid using PathID 0 if there is more than 1 path
-	if len(s.paths) <= 1 {
-		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			return nil
-		}
-		return s.paths[protocol.InitialPathID]
+	if len(paths) <= 1 {
+		return singlePath(paths, hasRetransmission)
 	}
 
 	// FIXME Only works at the beginning... Cope with new paths during the connection
-	if hasRetransmission && hasStreamRetransmission && fromPth.rttStats.SmoothedRTT() == 0 {
+	if hasRetransmission && hasStreamRetransmission && fromPth.SmoothedRTT() == 0 {
 		// Is there any other path with a lower number of packet sent?
-		currentQuota := sch.quotas[fromPth.pathID]
-		for pathID, pth := range s.paths {
-			if pathID == protocol.InitialPathID || pathID == fromPth.pathID {
-				continue
-			}
-			// The congestion window was checked when duplicating the packet
-			if sch.quotas[pathID] < currentQuota {
-				return pth
-			}
+		if pth := sch.lowerQuotaPath(paths, fromPth); pth != nil {
+			return pth
 		}
 	}
 
-	var bestPath *path
-	var secondBestPath *path
+	var bestPath PathView
+	var secondBestPath PathView
 	var lowerRTT time.Duration
 	var currentRTT time.Duration
 	var secondLowerRTT time.Duration
 	bestPathID := protocol.PathID(255)
 
 pathLoop:
-	for pathID, pth := range s.paths {
+	for _, pth := range paths {
+		pathID := pth.PathID()
 		// Don't block path usage if we retransmit, even on another path
 		if !hasRetransmission && !pth.SendingAllowed() {
 			continue pathLoop
 		}
 
 		// If this path is potentially failed, do not consider it for sending
-		if pth.potentiallyFailed.Get() {
+		if pth.PotentiallyFailed() {
 			continue pathLoop
 		}
 
@@ -489,7 +522,7 @@ pathLoop:
 			continue pathLoop
 		}
 
-		currentRTT = pth.rttStats.SmoothedRTT()
+		currentRTT = pth.SmoothedRTT()
 
 		// Prefer staying single-path if not blocked by current path
 		// Don't consider this sample if the smoothed RTT is 0
@@ -542,19 +575,11 @@ pathLoop:
 		return nil
 	}
 
-	var queueSize uint64
-	getQueueSize := func(s *stream) (bool, error) {
-		if s != nil {
-			queueSize = queueSize + uint64(s.lenOfDataForWriting())
-		}
-		return true, nil
-	}
-	s.streamsMap.Iterate(getQueueSize)
-
-	cwndBest := uint64(bestPath.sentPacketHandler.GetCongestionWindow())
-	cwndSecond := uint64(secondBestPath.sentPacketHandler.GetCongestionWindow())
-	deviationBest := uint64(bestPath.rttStats.MeanDeviation())
-	deviationSecond := uint64(secondBestPath.rttStats.MeanDeviation())
+	queueSize := uint64(queue.QueuedBytes())
+	cwndBest := uint64(bestPath.CongestionWindow())
+	cwndSecond := uint64(secondBestPath.CongestionWindow())
+	deviationBest := uint64(bestPath.MeanDeviation())
+	deviationSecond := uint64(secondBestPath.MeanDeviation())
 
 	delta := deviationBest
 	if deviationBest < deviationSecond {
@@ -599,7 +624,7 @@ func (sch *scheduler) selectPathLowBandit(s *session, hasRetransmission bool, ha
 		// Is there any other path with a lower number of packet sent?
 		currentQuota := sch.quotas[fromPth.pathID]
 		for pathID, pth := range s.paths {
-			if pathID == protocol.InitialPathID || pathID == fromPth.pathID {
+			if pathID == protocol.InitialPathID || pathID == fromPth.pathID || pth.potentiallyFailed.Get() {
 				continue
 			}
 			// The congestion window was checked when duplicating the packet
@@ -679,7 +704,7 @@ pathLoop:
 				cureNum = uint64(secondBestPath.sentPacketHandler.GetLeastUnacked() - 1)
 			}
 			if sch.packetvector[sch.episoderecord] <= cureNum {
-				curereward = float64(protocol.DefaultTCPMSS) / float64(time.Since(sch.zz[sch.episoderecord]))
+				curereward = float64(protocol.DefaultTCPMSS) / float64(s.clock.Now().Sub(sch.zz[sch.episoderecord]))
 			} else {
 				break
 			}
@@ -745,6 +770,17 @@ pathLoop:
 				}
 				sch.se += 1
 			}
+			updatedPath := bestPath
+			if sch.actionvector[sch.episoderecord] != 0 {
+				updatedPath = secondBestPath
+			}
+			s.tracer.Trace(&qlog.BanditUpdate{
+				Bandit:  qlog.BanditPath,
+				PathID:  updatedPath.pathID,
+				Arm:     sch.actionvector[sch.episoderecord],
+				Reward:  curereward,
+				NextArm: -1,
+			})
 			//Update pointer
 			sch.episoderecord += 1
 		}
@@ -866,14 +902,16 @@ pathLoop:
 		//Make decision based on bandit value
 		if (thetaSPro.At(0, 0) + banditAlpha*math.Sqrt(featureSProTwo.At(0, 0))) < (thetaFPro.At(0, 0) + banditAlpha*math.Sqrt(featureFProTwo.At(0, 0))) {
 			sch.waiting = 1
-			sch.zz[sch.record] = time.Now()
+			sch.banditArm = 0
+			sch.zz[sch.record] = s.clock.Now()
 			sch.actionvector[sch.record] = 0
 			sch.packetvector[sch.record] = bestPath.sentPacketHandler.GetLastPackets() + 1
 			sch.record += 1
 			return nil
 		} else {
 			sch.waiting = 0
-			sch.zz[sch.record] = time.Now()
+			sch.banditArm = 1
+			sch.zz[sch.record] = s.clock.Now()
 			sch.actionvector[sch.record] = 1
 			sch.packetvector[sch.record] = secondBestPath.sentPacketHandler.GetLastPackets() + 1
 			sch.record += 1
@@ -885,40 +923,45 @@ pathLoop:
 }
 
 func (sch *scheduler) selectPathPeek(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
+	return pathOf(sch.peekPath(s.pathViews(), sessionQueue{s}, hasRetransmission, hasStreamRetransmission, viewOf(fromPth)))
+}
+
+// initialPathIfAllowed returns the initial path if sending is allowed on it or if the packet is a retransmission
+func initialPathIfAllowed(paths []PathView, hasRetransmission bool) PathView {
+	initial := initialPath(paths)
+	if initial != nil && (initial.SendingAllowed() || hasRetransmission) {
+		return initial
+	}
+	return nil
+}
+
+// peekPath selects the fastest path, or lets the bandit decide between the slower path and waiting for the fastest one
+func (sch *scheduler) peekPath(paths []PathView, queue QueueView, hasRetransmission bool, hasStreamRetransmission bool, fromPth PathView) PathView {
 	// XXX Avoid using PathID 0 if there is more than 1 path
-	if len(s.paths) <= 1 {
-		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			return nil
-		}
-		return s.paths[protocol.InitialPathID]
+	if len(paths) <= 1 {
+		return singlePath(paths, hasRetransmission)
 	}
 
 	// FIXME Only works at the beginning... Cope with new paths during the connection
-	if hasRetransmission && hasStreamRetransmission && fromPth.rttStats.SmoothedRTT() == 0 {
+	if hasRetransmission && hasStreamRetransmission && fromPth.SmoothedRTT() == 0 {
 		// Is there any other path with a lower number of packet sent?
-		currentQuota := sch.quotas[fromPth.pathID]
-		for pathID, pth := range s.paths {
-			if pathID == protocol.InitialPathID || pathID == fromPth.pathID {
-				continue
-			}
-			// The congestion window was checked when duplicating the packet
-			if sch.quotas[pathID] < currentQuota {
-				return pth
-			}
+		if pth := sch.lowerQuotaPath(paths, fromPth); pth != nil {
+			return pth
 		}
 	}
 
-	var bestPath *path
-	var secondBestPath *path
+	var bestPath PathView
+	var secondBestPath PathView
 	var lowerRTT time.Duration
 	var currentRTT time.Duration
 	var secondLowerRTT time.Duration
 	bestPathID := protocol.PathID(255)
 
 pathLoop:
-	for pathID, pth := range s.paths {
+	for _, pth := range paths {
+		pathID := pth.PathID()
 		// If this path is potentially failed, do not consider it for sending
-		if pth.potentiallyFailed.Get() {
+		if pth.PotentiallyFailed() {
 			continue pathLoop
 		}
 
@@ -927,7 +970,7 @@ pathLoop:
 			continue pathLoop
 		}
 
-		currentRTT = pth.rttStats.SmoothedRTT()
+		currentRTT = pth.SmoothedRTT()
 
 		// Prefer staying single-path if not blocked by current path
 		// Don't consider this sample if the smoothed RTT is 0
@@ -970,29 +1013,21 @@ pathLoop:
 		if secondBestPath != nil {
 			return secondBestPath
 		}
-		if s.paths[protocol.InitialPathID].SendingAllowed() || hasRetransmission {
-			return s.paths[protocol.InitialPathID]
-		} else {
-			return nil
-		}
+		return initialPathIfAllowed(paths, hasRetransmission)
 	}
 	if bestPath.SendingAllowed() {
 		sch.waiting = 0
 		return bestPath
 	}
 	if secondBestPath == nil {
-		if s.paths[protocol.InitialPathID].SendingAllowed() || hasRetransmission {
-			return s.paths[protocol.InitialPathID]
-		} else {
-			return nil
-		}
+		return initialPathIfAllowed(paths, hasRetransmission)
 	}
 
 	if hasRetransmission && secondBestPath.SendingAllowed() {
 		return secondBestPath
 	}
 	if hasRetransmission {
-		return s.paths[protocol.InitialPathID]
+		return initialPath(paths)
 	}
 
 	if sch.waiting == 1 {
@@ -1021,13 +1056,13 @@ pathLoop:
 		}
 
 		//Features
-		cwndBest := float64(bestPath.sentPacketHandler.GetCongestionWindow())
-		cwndSecond := float64(secondBestPath.sentPacketHandler.GetCongestionWindow())
-		BSend, _ := s.flowControlManager.SendWindowSize(protocol.StreamID(5))
-		inflightf := float64(bestPath.sentPacketHandler.GetBytesInFlight())
-		inflights := float64(secondBestPath.sentPacketHandler.GetBytesInFlight())
-		llowerRTT := bestPath.rttStats.LatestRTT()
-		lsecondLowerRTT := secondBestPath.rttStats.LatestRTT()
+		cwndBest := float64(bestPath.CongestionWindow())
+		cwndSecond := float64(secondBestPath.CongestionWindow())
+		BSend := queue.SendWindow()
+		inflightf := float64(bestPath.BytesInFlight())
+		inflights := float64(secondBestPath.BytesInFlight())
+		llowerRTT := bestPath.LatestRTT()
+		lsecondLowerRTT := secondBestPath.LatestRTT()
 		feature := mat.NewDense(banditDimension, 1, nil)
 		if 0 < float64(lsecondLowerRTT) && 0 < float64(llowerRTT) {
 			feature.Set(0, 0, cwndBest/float64(llowerRTT))
@@ -1065,7 +1100,7 @@ pathLoop:
 
 		//Make decision based on bandit value and stochastic value
 		if thetaSPro.At(0, 0) < thetaFPro.At(0, 0) {
-			if rand.Intn(100) < 70 {
+			if sch.rand.Intn(100) < 70 {
 				sch.waiting = 1
 				return nil
 			} else {
@@ -1073,7 +1108,7 @@ pathLoop:
 				return secondBestPath
 			}
 		} else {
-			if rand.Intn(100) < 90 {
+			if sch.rand.Intn(100) < 90 {
 				sch.waiting = 0
 				return secondBestPath
 			} else {
@@ -1086,7 +1121,7 @@ pathLoop:
 }
 
 func (sch *scheduler) selectPathRandom(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
-	utils.Debugf("selectPathRandom")
+	s.logger.Debugf("selectPathRandom")
 	// XXX Avoid using PathID 0 if there is more than 1 path
 	if len(s.paths) <= 1 {
 		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
@@ -1110,8 +1145,8 @@ func (sch *scheduler) selectPathRandom(s *session, hasRetransmission bool, hasSt
 		return nil
 	}
 
-	pathID := rand.Intn(len(availablePaths))
-	utils.Debugf("Selecting path %d", pathID)
+	pathID := sch.rand.Intn(len(availablePaths))
+	s.logger.Debugf("Selecting path %d", pathID)
 	return s.paths[availablePaths[pathID]]
 }
 
@@ -1159,7 +1194,7 @@ func (sch *scheduler) selectPathDQNAgent(s *session, hasRetransmission bool, has
 	if len(s.paths) == 2 {
 		for pathID, path := range s.paths {
 			if pathID != protocol.InitialPathID {
-				utils.Debugf("Selecting path %d as unique path", pathID)
+				s.logger.Debugf("Selecting path %d as unique path", pathID)
 				return path
 			}
 		}
@@ -1194,6 +1229,30 @@ func (sch *scheduler) selectPathDQNAgent(s *session, hasRetransmission bool, has
 
 // Lock of s.paths must be held
 func (sch *scheduler) selectPath(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
+	sch.banditArm = -1
+	pth := sch.selectPathByName(s, hasRetransmission, hasStreamRetransmission, fromPth)
+	sch.traceDecision(s, pth, hasRetransmission)
+	return pth
+}
+
+// traceDecision traces the path selected by the scheduler, nil if it decided not to send
+func (sch *scheduler) traceDecision(s *session, pth *path, hasRetransmission bool) {
+	if !s.tracer.Enabled() {
+		return
+	}
+	ev := &qlog.SchedulerDecision{
+		Scheduler:      sch.SchedulerName,
+		Retransmission: hasRetransmission,
+		Arm:            sch.banditArm,
+	}
+	if pth != nil {
+		ev.Selected = true
+		ev.PathID = pth.pathID
+	}
+	s.tracer.Trace(ev)
+}
+
+func (sch *scheduler) selectPathByName(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
 	// XXX Currently round-robin
 	if sch.SchedulerName == "rtt" {
 		return sch.selectPathLowLatency(s, hasRetransmission, hasStreamRetransmission, fromPth)
@@ -1223,15 +1282,14 @@ func (sch *scheduler) selectPath(s *session, hasRetransmission bool, hasStreamRe
 
 // Lock of s.paths must be free (in case of log print)
 func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wire.WindowUpdateFrame,
-	pth *path, deadline time.Time, curNotSent uint8, alpha uint8) (*ackhandler.Packet, bool, error) {
+	pth *path, deadline time.Time, held heldPacket, curNotSent uint8, alpha uint8) (*ackhandler.Packet, bool, error) {
 
 	// add cost here
-	if pth.pathID == protocol.PathID(1) {
-		sch.totalCost += path1Cost
-		sch.totalPktWithCost += 1
-	} else if pth.pathID == protocol.PathID(3) {
-		sch.totalCost += path3Cost
+	if cost := pth.getCost(); cost > 0 {
+		sch.totalCost += cost
 		sch.totalPktWithCost += 1
+		pth.costSpent += cost
+		sch.checkBudget(s)
 	}
 	// add a retransmittable frame
 	if pth.sentPacketHandler.ShouldSendRetransmittablePacket() {
@@ -1242,9 +1300,11 @@ func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wir
 		// always trigger by payloadFrame = 0
 		return nil, false, err
 	}
+	packet.deferred, packet.heldForCost = held.deferred, held.heldForCost
 	if err = s.sendPackedPacket(packet, pth); err != nil {
-		// not execute
-		return nil, false, err
+		if err = sch.failPath(s, err); err != nil {
+			return nil, false, err
+		}
 	}
 
 	// send every window update twice
@@ -1264,26 +1324,26 @@ func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wir
 			if frame.FinBit {
 				// Last packet to send on the stream, print stats
 				s.pathsLock.RLock()
-				utils.Infof("Info for stream %x of %x", frame.StreamID, s.connectionID)
+				s.logger.Infof("Info for stream %x of %x", frame.StreamID, s.connectionID)
 				for pathID, pth := range s.paths {
 					sntPkts, sntRetrans, sntLost := pth.sentPacketHandler.GetStatistics()
 					//rcvPkts := pth.receivedPacketHandler.GetStatistics()
 					rcvPkts, hasDeadlinePkts, meetDeadlinePkts := pth.receivedPacketHandler.GetStatistics()
-					utils.Infof("Path %x: sent %d retrans %d lost %d; rcv %d rtt %v", pathID, sntPkts, sntRetrans, sntLost, rcvPkts, pth.rttStats.SmoothedRTT())
-					utils.Infof("hasDeadlinePkts %d; meetDeadlinePkts %d", hasDeadlinePkts, meetDeadlinePkts)
+					s.logger.Infof("Path %x: sent %d retrans %d lost %d; rcv %d rtt %v", pathID, sntPkts, sntRetrans, sntLost, rcvPkts, pth.rttStats.SmoothedRTT())
+					s.logger.Infof("hasDeadlinePkts %d; meetDeadlinePkts %d", hasDeadlinePkts, meetDeadlinePkts)
 					// TODO: Remove it
-					utils.Infof("Congestion Window: %d", pth.sentPacketHandler.GetCongestionWindow())
+					s.logger.Infof("Congestion Window: %d", pth.sentPacketHandler.GetCongestionWindow())
 					if sch.Training {
 						sRTT[pathID] = pth.rttStats.SmoothedRTT()
 					}
 				}
 				notSentPkts := sch.GetNotSentPackets()
-				utils.Infof("Not Sent Packets Num:%d", notSentPkts) //only linOpt not zeros
+				s.logger.Infof("Not Sent Packets Num:%d", notSentPkts) //only linOpt not zeros
 				TotalCost := sch.GetTotalCost()
 				totalPkts := sch.GetTotalPktWithCost()
-				fmt.Println("Total Packets:", totalPkts)
-				fmt.Println("scheduler total cost:", TotalCost)
-				fmt.Println("normalization total cost", TotalCost/float64(totalPkts))
+				s.logger.Infof("Total Packets: %d", totalPkts)
+				s.logger.Infof("scheduler total cost: %f", TotalCost)
+				s.logger.Infof("normalization total cost: %f", TotalCost/float64(totalPkts))
 				// peekaboo log
 				// utils.Infof("Action: %d", sch.actionvector)
 				// utils.Infof("record: %d", sch.record)
@@ -1291,7 +1351,7 @@ func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wir
 				// utils.Infof("fe: %d", sch.fe)
 				// utils.Infof("se: %d", sch.se)
 				if sch.Training && sch.SchedulerName == "dqnAgent" {
-					duration := time.Since(s.sessionCreationTime)
+					duration := s.clock.Now().Sub(s.sessionCreationTime)
 					var maxRTT time.Duration
 					for pathID := range sRTT {
 						if sRTT[pathID] > maxRTT {
@@ -1300,11 +1360,6 @@ func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wir
 					}
 					sch.TrainingAgent.CloseEpisode(uint64(s.connectionID), RewardFinalGoodput(sch, s, duration, maxRTT), false)
 				}
-				utils.Infof("Dump: %t, Training:%t, scheduler:%s", sch.DumpExp, sch.Training, sch.SchedulerName)
-				if sch.DumpExp && !sch.Training && sch.SchedulerName == "dqnAgent" {
-					utils.Infof("Closing episode %d", uint64(s.connectionID))
-					sch.dumpAgent.CloseExperience(uint64(s.connectionID))
-				}
 				s.pathsLock.RUnlock()
 				//Write lin parameters
 				// os.Remove("/App/output/lin")
@@ -1346,6 +1401,25 @@ func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wir
 	return pkt, true, nil
 }
 
+// probePath pings fromPth if it is potentially failed. The schedulers skip such paths,
+// so when all paths failed, the ping on the RTO of a path is the only way it gets back in service.
+func (sch *scheduler) probePath(s *session, fromPth *path) error {
+	if fromPth != nil && fromPth.potentiallyFailed.Get() {
+		if err := s.sendPing(fromPth); err != nil {
+			return sch.failPath(s, err)
+		}
+	}
+	return nil
+}
+
+// failPath takes the lock of s.paths to handle the error of a sendPackedPacket, see session.failPath
+// Lock of s.paths must be free
+func (sch *scheduler) failPath(s *session, err error) error {
+	s.pathsLock.RLock()
+	defer s.pathsLock.RUnlock()
+	return s.failPath(err)
+}
+
 // Lock of s.paths must be free
 func (sch *scheduler) ackRemainingPaths(s *session, totalWindowUpdateFrames []*wire.WindowUpdateFrame) error {
 	// Either we run out of data, or CWIN of usable paths are full
@@ -1360,7 +1434,7 @@ func (sch *scheduler) ackRemainingPaths(s *session, totalWindowUpdateFrames []*w
 	if len(windowUpdateFrames) == 0 {
 		windowUpdateFrames = s.getWindowUpdateFrames(s.peerBlocked)
 	}
-	for _, pthTmp := range s.paths {
+	for _, pthTmp := range s.sortedPaths() {
 		ackTmp := pthTmp.GetAckFrame()
 		for _, wuf := range windowUpdateFrames {
 			s.packer.QueueControlFrame(wuf, pthTmp)
@@ -1390,7 +1464,9 @@ func (sch *scheduler) ackRemainingPaths(s *session, totalWindowUpdateFrames []*w
 			}
 			err = s.sendPackedPacket(packet, pthTmp)
 			if err != nil {
-				return err
+				if err = s.failPath(err); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -1454,36 +1530,46 @@ func (sch *scheduler) sendPacket(s *session) error {
 			hasStreamRetransmission := s.streamFramer.HasFramesForRetransmission()
 
 			// czy:generate batch size deadline
-			generateTime := time.Now()
+			generateTime := s.clock.Now()
 			deadlineBatch := sch.GenerateBatchDeadline(batch, generateTime)
 
 			// select paths here for batch packet——Default: all select first path
 			s.pathsLock.RLock()
 			// whether scheduler can make decision
-			if len(s.paths) > 1 && !sch.canMadeDecision(s, batch) {
+			if len(s.paths) > 1 && !sch.canMadeDecision(s.pathViews(), batch) {
 				// can not make decision
 				s.pathsLock.RUnlock()
 				windowUpdateFrames := s.getWindowUpdateFrames(false)
 				return sch.ackRemainingPaths(s, windowUpdateFrames)
 			}
+			sch.startDecision(s)
 			pthBatch := sch.selectBatchPath(s, hasRetransmission, hasStreamRetransmission, fromPth, deadlineBatch)
+			sch.recordSelection(deadlineBatch, pthBatch)
+			sch.checkSolverSwitch(s)
 			s.pathsLock.RUnlock()
+			sch.banditArm = -1
+			for _, pthValue := range pthBatch {
+				sch.traceDecision(s, pthValue, hasRetransmission)
+			}
 
 			// selective preservation of packets without feasible paths
 			sch.selectivePreservation(s, deadlineBatch, pthBatch, generateTime)
 			if sch.maybeUpdateWindow(s) {
+				sch.recordDecision(s)
 				windowUpdateFrames := s.getWindowUpdateFrames(false)
 				return sch.ackRemainingPaths(s, windowUpdateFrames)
 			}
 
 			// Wait for lower cost module: CaDaMPS will wait for low-cost path
-			if costConstraintAvailable {
+			if sch.costConstraint {
 				sch.choosePacketsForLowCost(s, deadlineBatch, pthBatch, generateTime)
 				if sch.maybeUpdateWindow(s) {
+					sch.recordDecision(s)
 					windowUpdateFrames := s.getWindowUpdateFrames(false)
 					return sch.ackRemainingPaths(s, windowUpdateFrames)
 				}
 			}
+			sch.recordDecision(s)
 
 			// this pth to deal with the special case, like retransmission
 			// set the first not nil value of pthBatch to pth
@@ -1499,6 +1585,9 @@ func (sch *scheduler) sendPacket(s *session) error {
 			// XXX No more path available, should we have a new QUIC error message?
 			// TODO:pth and pthBatch is necessary?
 			if pth == nil {
+				if err := sch.probePath(s, fromPth); err != nil {
+					return err
+				}
 				windowUpdateFrames := s.getWindowUpdateFrames(false)
 				return sch.ackRemainingPaths(s, windowUpdateFrames)
 			}
@@ -1518,7 +1607,9 @@ func (sch *scheduler) sendPacket(s *session) error {
 					return err
 				}
 				if err = s.sendPackedPacket(packet, pth); err != nil {
-					return err
+					if err = sch.failPath(s, err); err != nil {
+						return err
+					}
 				}
 				// not execute
 				continue
@@ -1548,10 +1639,18 @@ func (sch *scheduler) sendPacket(s *session) error {
 				s.packer.QueueControlFrame(aaf, pth)
 			}
 
+			// Also add PATH COST frames, if any
+			for pcf := s.streamFramer.PopPathCostFrame(); pcf != nil; pcf = s.streamFramer.PopPathCostFrame() {
+				s.packer.QueueControlFrame(pcf, pth)
+			}
+
 			// Also add PATHS frames, if any
 			for pf := s.streamFramer.PopPathsFrame(); pf != nil; pf = s.streamFramer.PopPathsFrame() {
 				s.packer.QueueControlFrame(pf, pth)
 			}
+			for pqf := s.streamFramer.PopPathQualityFrame(); pqf != nil; pqf = s.streamFramer.PopPathQualityFrame() {
+				s.packer.QueueControlFrame(pqf, pth)
+			}
 
 			// Initial curNotSentPacket
 			sch.curNotSentPacket = 0
@@ -1569,15 +1668,16 @@ func (sch *scheduler) sendPacket(s *session) error {
 				if pth == nil {
 					//LOG packets not transmit
 					sch.NotSentPackets++
+					sch.countUnsentDeadlineMiss(s, i, deadlineBatch[i])
 					continue
 				}
 				// TODO:pth may be nil
 				alpha := pth.sentPacketHandler.GetPathAlpha() * 10.0
 				alpha_10 := int(math.Round(float64(alpha))) // alpha * 10, and sent to client
-				pkt, sent, err := sch.performPacketSending(s, windowUpdateFrames, pth, deadline, sch.curNotSentPacket, uint8(alpha_10))
+				pkt, sent, err := sch.performPacketSending(s, windowUpdateFrames, pth, deadline, sch.heldPacket(i), sch.curNotSentPacket, uint8(alpha_10))
 				if err != nil {
 					if err == ackhandler.ErrTooManyTrackedSentPackets {
-						utils.Errorf("Closing episode")
+						s.logger.Errorf("Closing episode")
 					}
 					return err
 				}
@@ -1587,6 +1687,7 @@ func (sch *scheduler) sendPacket(s *session) error {
 					// Prevent sending empty packets
 					return sch.ackRemainingPaths(s, windowUpdateFrames)
 				}
+				sch.recordPacketSent(s, i, pth, pkt, deadline)
 
 				// Duplicate traffic when it was sent on an unknown performing path
 				// FIXME adapt for new paths coming during the connection
@@ -1594,11 +1695,12 @@ func (sch *scheduler) sendPacket(s *session) error {
 					currentQuota := sch.quotas[pth.pathID]
 					// Was the packet duplicated on all potential paths?
 				duplicateLoop1:
-					for pathID, tmpPth := range s.paths {
+					for _, tmpPth := range s.sortedPaths() {
+						pathID := tmpPth.pathID
 						if pathID == protocol.InitialPathID || pathID == pth.pathID {
 							continue
 						}
-						if sch.quotas[pathID] < currentQuota && tmpPth.sentPacketHandler.SendingAllowed() {
+						if sch.quotas[pathID] < currentQuota && !tmpPth.potentiallyFailed.Get() && tmpPth.sentPacketHandler.SendingAllowed() {
 							// Duplicate it
 							pth.sentPacketHandler.DuplicatePacket(pkt)
 							break duplicateLoop1
@@ -1607,11 +1709,8 @@ func (sch *scheduler) sendPacket(s *session) error {
 				}
 
 				// And try pinging on potentially failed paths
-				if fromPth != nil && fromPth.potentiallyFailed.Get() {
-					err = s.sendPing(fromPth)
-					if err != nil {
-						return err
-					}
+				if err = sch.probePath(s, fromPth); err != nil {
+					return err
 				}
 			}
 		}
@@ -1623,8 +1722,8 @@ func (sch *scheduler) sendPacket(s *session) error {
 			hasStreamRetransmission := s.streamFramer.HasFramesForRetransmission()
 
 			//czy:generate deadline hear
-			randNum := rand.Intn(30) + 20 //20ms - 50ms
-			deadline := time.Now().Add(time.Duration(randNum) * time.Millisecond)
+			randNum := sch.rand.Intn(30) + 20 //20ms - 50ms
+			deadline := s.clock.Now().Add(time.Duration(randNum) * time.Millisecond)
 
 			// Select the path here
 			s.pathsLock.RLock()
@@ -1633,6 +1732,9 @@ func (sch *scheduler) sendPacket(s *session) error {
 
 			// XXX No more path available, should we have a new QUIC error message?
 			if pth == nil {
+				if err := sch.probePath(s, fromPth); err != nil {
+					return err
+				}
 				windowUpdateFrames := s.getWindowUpdateFrames(false)
 				return sch.ackRemainingPaths(s, windowUpdateFrames)
 			}
@@ -1645,7 +1747,9 @@ func (sch *scheduler) sendPacket(s *session) error {
 					return err
 				}
 				if err = s.sendPackedPacket(packet, pth); err != nil {
-					return err
+					if err = sch.failPath(s, err); err != nil {
+						return err
+					}
 				}
 				// not execute
 				continue
@@ -1675,16 +1779,24 @@ func (sch *scheduler) sendPacket(s *session) error {
 				s.packer.QueueControlFrame(aaf, pth)
 			}
 
+			// Also add PATH COST frames, if any
+			for pcf := s.streamFramer.PopPathCostFrame(); pcf != nil; pcf = s.streamFramer.PopPathCostFrame() {
+				s.packer.QueueControlFrame(pcf, pth)
+			}
+
 			// Also add PATHS frames, if any
 			for pf := s.streamFramer.PopPathsFrame(); pf != nil; pf = s.streamFramer.PopPathsFrame() {
 				s.packer.QueueControlFrame(pf, pth)
 			}
+			for pqf := s.streamFramer.PopPathQualityFrame(); pqf != nil; pqf = s.streamFramer.PopPathQualityFrame() {
+				s.packer.QueueControlFrame(pqf, pth)
+			}
 
 			// This pkt is Packet, sent is true
-			pkt, sent, err := sch.performPacketSending(s, windowUpdateFrames, pth, deadline, uint8(0), uint8(10))
+			pkt, sent, err := sch.performPacketSending(s, windowUpdateFrames, pth, deadline, heldPacket{}, uint8(0), uint8(10))
 			if err != nil {
 				if err == ackhandler.ErrTooManyTrackedSentPackets {
-					utils.Errorf("Closing episode")
+					s.logger.Errorf("Closing episode")
 					if sch.SchedulerName == "dqnAgent" && sch.Training {
 						sch.TrainingAgent.CloseEpisode(uint64(s.connectionID), -100, false)
 					}
@@ -1701,13 +1813,15 @@ func (sch *scheduler) sendPacket(s *session) error {
 			// FIXME adapt for new paths coming during the connection
 			if pth.rttStats.SmoothedRTT() == 0 {
 				currentQuota := sch.quotas[pth.pathID]
-				// Was the packet duplicated on all potential paths?
+				// Was the packet duplicated on all potential paths? A potentially failed path never catches up,
+				// duplicating for it would loop on the new path.
 			duplicateLoop:
-				for pathID, tmpPth := range s.paths {
+				for _, tmpPth := range s.sortedPaths() {
+					pathID := tmpPth.pathID
 					if pathID == protocol.InitialPathID || pathID == pth.pathID {
 						continue
 					}
-					if sch.quotas[pathID] < currentQuota && tmpPth.sentPacketHandler.SendingAllowed() {
+					if sch.quotas[pathID] < currentQuota && !tmpPth.potentiallyFailed.Get() && tmpPth.sentPacketHandler.SendingAllowed() {
 						// Duplicate it
 						pth.sentPacketHandler.DuplicatePacket(pkt)
 						// almost not execute
@@ -1717,11 +1831,8 @@ func (sch *scheduler) sendPacket(s *session) error {
 			}
 
 			// And try pinging on potentially failed paths
-			if fromPth != nil && fromPth.potentiallyFailed.Get() {
-				err = s.sendPing(fromPth)
-				if err != nil {
-					return err
-				}
+			if err = sch.probePath(s, fromPth); err != nil {
+				return err
 			}
 		}
 	}
@@ -1735,9 +1846,15 @@ func (sch *scheduler) selectivePreservation(s *session, deadlinBatch []int, pthB
 	}
 	for i, deadline := range deadlinBatch {
 		if pthBatch[i] == nil && float64(deadline) > (minRtt*3.0/2.0) {
-			deadlineTime := generateTime.Add(time.Duration(deadline) * time.Millisecond)
-			sch.waitPackets = append(sch.waitPackets, deadlineTime)
+			held := sch.heldPacket(i)
+			held.deadline = generateTime.Add(time.Duration(deadline) * time.Millisecond)
+			held.deferred = true
+			sch.waitPackets = append(sch.waitPackets, held)
 			sch.NotSentPackets--
+			sch.deferredPackets++
+			if sch.decision != nil {
+				sch.decision.Preserved = append(sch.decision.Preserved, i)
+			}
 		}
 	}
 }
@@ -1750,10 +1867,16 @@ func (sch *scheduler) choosePacketsForLowCost(s *session, deadlineBatch []int, p
 	}
 	for i, deadline := range deadlineBatch {
 		if pthBatch[i] != nil && pthBatch[i].pathID == protocol.PathID(1) && float64(deadline) > (minRtt*3.0/2.0) {
-			deadlineTime := generateTime.Add(time.Duration(deadline) * time.Millisecond)
-			sch.waitPackets = append(sch.waitPackets, deadlineTime)
+			held := sch.heldPacket(i)
+			held.deadline = generateTime.Add(time.Duration(deadline) * time.Millisecond)
+			held.heldForCost = true
+			sch.waitPackets = append(sch.waitPackets, held)
 			pthBatch[i] = nil
 			sch.NotSentPackets--
+			sch.deferredPackets++
+			if sch.decision != nil {
+				sch.decision.Deferred = append(sch.decision.Deferred, i)
+			}
 		}
 	}
 }
@@ -1781,25 +1904,19 @@ func (sch *scheduler) maybeUpdateWindow(s *session) bool {
 	return false
 }
 
-func uniformDeadlineGenerator(min int, max int) time.Time {
-	uniformDist := distuv.Uniform{
-		Min: float64(min), // min value
-		Max: float64(max), // max value
-	}
-	randFloat := uniformDist.Rand()
+func uniformDeadlineGenerator(r *rand.Rand, now time.Time, min int, max int) time.Time {
+	// uniform in [min, max)
+	randFloat := float64(min) + r.Float64()*float64(max-min)
 	randInt := int(randFloat)
 
-	Deadline := time.Now().Add(time.Duration(randInt) * time.Millisecond)
+	Deadline := now.Add(time.Duration(randInt) * time.Millisecond)
 
 	return Deadline
 }
 
-func normalDeadlineGenerator(mu int, sigma int) time.Time {
-	normalDist := distuv.Normal{
-		Mu:    float64(mu),    // 均值
-		Sigma: float64(sigma), // 标准差
-	}
-	randFloat := normalDist.Rand()
+func normalDeadlineGenerator(r *rand.Rand, now time.Time, mu int, sigma int) time.Time {
+	// 均值 mu, 标准差 sigma
+	randFloat := r.NormFloat64()*float64(sigma) + float64(mu)
 	randInt := int(randFloat)
 
 	//cut off deadline
@@ -1814,7 +1931,7 @@ func normalDeadlineGenerator(mu int, sigma int) time.Time {
 		}
 	}
 
-	Deadline := time.Now().Add(time.Duration(randInt) * time.Millisecond)
+	Deadline := now.Add(time.Duration(randInt) * time.Millisecond)
 
 	return Deadline
 }
@@ -5,9 +5,7 @@ import (
 	"bitbucket.com/marcmolla/gorl/agents"
 	"bitbucket.com/marcmolla/gorl/types"
 	"errors"
-	"fmt"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
-	"github.com/lucas-clemente/quic-go/internal/utils"
 	"io/ioutil"
 	"time"
 )
@@ -87,6 +85,16 @@ func RewardFinalGoodput(sch *scheduler, s *session, duration time.Duration, _ ti
 	return partialReward
 }
 
+// trainingAction returns the action of the training agent for the state, or a random one with probability Epsilon.
+// The exploration is drawn from the random source of the scheduler, so that training follows the RandSeed of the session.
+func (sch *scheduler) trainingAction(state types.Vector) int {
+	if sch.rand.Float64() < sch.Epsilon {
+		// the state describes two paths, an action selects one of them
+		return sch.rand.Intn(2)
+	}
+	return sch.TrainingAgent.GetAction(state)
+}
+
 func GetStateAndReward(sch *scheduler, s *session) (int, []*path) {
 	packetNumber := make(map[protocol.PathID]uint64)
 	retransNumber := make(map[protocol.PathID]uint64)
@@ -137,7 +145,7 @@ func GetStateAndReward(sch *scheduler, s *session) (int, []*path) {
 	//Action
 	var action int
 	if sch.Training {
-		action = sch.TrainingAgent.GetAction(state)
+		action = sch.trainingAction(state)
 	} else {
 		action = sch.Agent.GetAction(state)
 	}
@@ -165,11 +173,11 @@ func GetStateAndReward(sch *scheduler, s *session) (int, []*path) {
 			sch.TrainingAgent.SaveStep(uint64(s.connectionID), partialReward, realstate, realaction)
 		} else {
 			if sch.DumpExp {
-				sch.dumpAgent.AddStep(uint64(s.connectionID), []string{fmt.Sprint(sch.statevector[sch.record]), fmt.Sprint(sch.actionvector[sch.record])})
+				sch.recordExperience(s, sch.statevector[sch.record], sch.actionvector[sch.record])
 			}
 		}
 	} else {
-		elapsedtime = types.Output(time.Since(sch.lastfiretime))
+		elapsedtime = types.Output(s.clock.Now().Sub(sch.lastfiretime))
 		sch.recordDuration[sch.record] = elapsedtime
 		benchmark := sch.packetvector[sch.episoderecord-1]
 		if benchmark < (sentPackets - retransPackets) {
@@ -186,7 +194,7 @@ func GetStateAndReward(sch *scheduler, s *session) (int, []*path) {
 						sch.TrainingAgent.SaveStep(uint64(s.connectionID), partialReward, realstate, realaction)
 					} else {
 						if sch.DumpExp {
-							sch.dumpAgent.AddStep(uint64(s.connectionID), []string{fmt.Sprint(sch.statevector[sch.episoderecord]), fmt.Sprint(sch.actionvector[sch.episoderecord])})
+							sch.recordExperience(s, sch.statevector[sch.episoderecord], sch.actionvector[sch.episoderecord])
 						}
 					}
 					sch.episoderecord += 1
@@ -200,7 +208,7 @@ func GetStateAndReward(sch *scheduler, s *session) (int, []*path) {
 						sch.TrainingAgent.SaveStep(uint64(s.connectionID), partialReward, realstate, realaction)
 					} else {
 						if sch.DumpExp {
-							sch.dumpAgent.AddStep(uint64(s.connectionID), []string{fmt.Sprint(sch.statevector[sch.episoderecord]), fmt.Sprint(sch.actionvector[sch.episoderecord])})
+							sch.recordExperience(s, sch.statevector[sch.episoderecord], sch.actionvector[sch.episoderecord])
 						}
 					}
 					sch.episoderecord += 1
@@ -211,7 +219,7 @@ func GetStateAndReward(sch *scheduler, s *session) (int, []*path) {
 
 	//Main pointer and fire time
 	sch.record += 1
-	sch.lastfiretime = time.Now()
+	sch.lastfiretime = s.clock.Now()
 
 	return action, []*path{s.paths[firstPath], s.paths[secondPath]}
 }
@@ -222,7 +230,7 @@ func CheckAction(action int, state types.Vector, s *session, sch *scheduler) {
 	}
 	if state[4] < 1 || state[5] < 1 {
 		// penalize not sending with one path allowed
-		utils.Errorf("not sending with one path allowed")
+		s.logger.Errorf("not sending with one path allowed")
 		sch.TrainingAgent.CloseEpisode(uint64(s.connectionID), -100, false)
 		s.closeLocal(errors.New("not sending with one path allowed"))
 	}
@@ -3,6 +3,8 @@ package quic
 import (
 	"github.com/draffensperger/golp"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/schedlog"
+	"math"
 	"math/rand"
 	"sort"
 	"time"
@@ -11,6 +13,7 @@ import (
 // some parameter
 const banditAvailable = true
 const costConstraintAvailable = true
+// Default costs, used for paths without a configured or advertised cost
 const path1Cost = 2.0 //cellular link
 const path3Cost = 0.2 //WiFi link
 const budget = 4
@@ -92,7 +95,7 @@ func linOpt(packetsNum []int, packetsDeadline []float64, pathDelay []float64, pa
 	return policy
 }
 
-func linOptCost(packetsNum []int, packetsDeadline []float64, pathDelay []float64,
+func linOptCost(r *rand.Rand, packetsNum []int, packetsDeadline []float64, pathDelay []float64,
 	pathCwnd []float64, pathCost []float64, budgetConstraint float64) []int {
 	// TODO:packetsNum is unnecessary
 	S := len(packetsNum)     // num of packets
@@ -148,7 +151,7 @@ func linOptCost(packetsNum []int, packetsDeadline []float64, pathDelay []float64
 	}
 
 	// Solve
-	lp := golp.NewLP(0, S*n+1)
+	lp := golp.NewLP(0, S*n)
 	lp.SetObjFn(C)
 	lp.SetMaximize()
 
@@ -167,36 +170,61 @@ func linOptCost(packetsNum []int, packetsDeadline []float64, pathDelay []float64
 		}
 	}
 	// Convert solution to policy
-	policy = resultToPolicyWithGreedyRounding(result, S, n)
+	policy = resultToPolicyWithGreedyRounding(r, result, S, n)
+	return enforceCapacity(policy, packetsDeadline, pathDelay, pathCwnd, pathCost, budgetConstraint)
+}
+
+// enforceCapacity doesn't send the packets that the rounding put beyond the CWND of their path, or beyond the budget.
+// The packets that meet their deadline on their path are kept first, by earliest deadline, then the others by deadline:
+// the packets left unsent are those that miss their deadline anyway, or that have the most time to wait for the next batch.
+func enforceCapacity(policy []int, packetsDeadline []float64, pathDelay []float64, pathCwnd []float64, pathCost []float64, budgetConstraint float64) []int {
+	meets := func(i int) bool {
+		return policy[i] > 0 && packetsDeadline[i] >= pathDelay[policy[i]-1]
+	}
+	order := make([]int, len(policy))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if meets(i) != meets(j) {
+			return meets(i)
+		}
+		return packetsDeadline[i] < packetsDeadline[j]
+	})
+	assigned := make([]float64, len(pathCwnd))
+	var cost float64
+	for _, i := range order {
+		p := policy[i]
+		if p == 0 {
+			continue
+		}
+		if assigned[p-1]+1 > pathCwnd[p-1] || cost+pathCost[p-1] > budgetConstraint {
+			policy[i] = 0
+			continue
+		}
+		assigned[p-1]++
+		cost += pathCost[p-1]
+	}
 	return policy
 }
 
+// resultToPolicy sends every packet on the first path of the solution that carries it
 func resultToPolicy(result [][]float64) []int {
-	// TODO:just adapt to two paths
 	policy := make([]int, len(result))
 	for i := 0; i < len(result); i++ {
-		if len(result[i]) == 1 {
-			if result[i][0] == 0 {
-				policy[i] = 0
-			} else {
-				policy[i] = 1
-			}
-		} else if len(result[i]) == 2 {
-			if result[i][0] == 0 && result[i][1] == 0 {
-				policy[i] = 0
-			} else if result[i][0] != 0 {
-				policy[i] = 1
-			} else {
-				policy[i] = 2
+		for j := 0; j < len(result[i]); j++ {
+			if result[i][j] != 0 {
+				policy[i] = j + 1
+				break
 			}
-		} else {
 		}
 	}
 	return policy
 }
 
-//Greedy Rounding
-func resultToPolicyWithGreedyRounding(result [][]float64, S int, n int) []int {
+//Greedy Rounding, the fractional decisions are rounded at random with r
+func resultToPolicyWithGreedyRounding(r *rand.Rand, result [][]float64, S int, n int) []int {
 	policy := make([]int, len(result))
 
 	for i := 0; i < S; i++ {
@@ -216,7 +244,7 @@ func resultToPolicyWithGreedyRounding(result [][]float64, S int, n int) []int {
 				if result[i][j] != 0 && policy[i] == 0 {
 					policyCandidate := []int{j + 1, 0} // rounding到第j个path或不发
 					prob := []float64{result[i][j], 1 - result[i][j]}
-					policy[i] = chooseByProb(policyCandidate, prob)
+					policy[i] = chooseByProb(r, policyCandidate, prob)
 				}
 			}
 		}
@@ -235,12 +263,12 @@ func findIndex(arr []float64, target float64) int {
 }
 
 // chooseByProb choose a value by probability
-func chooseByProb(value []int, Prob []float64) int {
-	r := rand.Float64()
+func chooseByProb(r *rand.Rand, value []int, Prob []float64) int {
+	x := r.Float64()
 	sum := 0.0
 	for i, p := range Prob {
 		sum += p
-		if r < sum {
+		if x < sum {
 			return value[i]
 		}
 	}
@@ -254,41 +282,49 @@ func (sch *scheduler) GenerateBatchDeadline(size int, curTime time.Time) []int {
 	Deadline := make([]int, size-lenWait)
 	for i := 0; i < size-lenWait; i++ {
 		//randNum := rand.Intn(50)
-		randNum := rand.Intn(30) + 20 //20-50 ms
+		randNum := sch.rand.Intn(30) + 20 //20-50 ms
 		Deadline[i] = randNum
 	}
-	for _, deadlineTime := range sch.waitPackets {
-		durationTime := deadlineTime.Sub(curTime)
+	sch.batchHeld = make([]heldPacket, size-lenWait, size)
+	for _, held := range sch.waitPackets {
+		durationTime := held.deadline.Sub(curTime)
 		Deadline = append(Deadline, int(durationTime.Milliseconds()))
+		sch.batchHeld = append(sch.batchHeld, held)
 		//fmt.Println("new Deadline:", int(durationTime.Milliseconds()))
 	}
-	sch.waitPackets = make([]time.Time, 0)
+	sch.waitPackets = make([]heldPacket, 0)
 	return Deadline
 }
 
 // select path for batch packet
 func (sch *scheduler) selectBatchPath(s *session, hasRetransmission bool,
 	hasStreamRetransmission bool, fromPth *path, deadlineBatch []int) []*path {
+	return pathsOf(sch.batchPaths(s.pathViews(), sch.batchBudget(s), hasRetransmission, hasStreamRetransmission, viewOf(fromPth), deadlineBatch))
+}
+
+// batchPaths selects the path of every packet of a batch, nil for the packets that are not sent
+func (sch *scheduler) batchPaths(paths []PathView, batchBudget float64, hasRetransmission bool,
+	hasStreamRetransmission bool, fromPth PathView, deadlineBatch []int) []PathView {
 	// XXX Currently round-robin
 	if sch.SchedulerName == "BatchLinOpt" {
-		result := sch.selectBatchlinOpt(s, hasRetransmission, hasStreamRetransmission, fromPth, deadlineBatch)
+		result := sch.selectBatchlinOpt(paths, batchBudget, hasRetransmission, deadlineBatch)
 		if isAllNil(result) {
 			nilCount++
 			if nilCount >= maxNilCount {
 				nilCount = 0
-				return sch.selectBatchEDF(s, hasRetransmission, hasStreamRetransmission, fromPth, deadlineBatch)
+				return sch.selectBatchEDF(paths, hasRetransmission, hasStreamRetransmission, fromPth, deadlineBatch)
 			}
 		}
-		return sch.selectBatchlinOpt(s, hasRetransmission, hasStreamRetransmission, fromPth, deadlineBatch)
+		return result
 	} else if sch.SchedulerName == "BatchEDF" {
-		return sch.selectBatchEDF(s, hasRetransmission, hasStreamRetransmission, fromPth, deadlineBatch)
+		return sch.selectBatchEDF(paths, hasRetransmission, hasStreamRetransmission, fromPth, deadlineBatch)
 	} else {
 		// Default, all select first path
-		return sch.selectBatchFirstPath(s, hasRetransmission, hasStreamRetransmission, fromPth, deadlineBatch)
+		return sch.selectBatchFirstPath(paths, hasRetransmission, deadlineBatch)
 	}
 }
 
-func isAllNil(paths []*path) bool {
+func isAllNil(paths []PathView) bool {
 	for _, p := range paths {
 		if p != nil {
 			return false
@@ -297,35 +333,33 @@ func isAllNil(paths []*path) bool {
 	return true
 }
 
-func (sch *scheduler) selectBatchFirstPath(s *session,
-	hasRetransmission bool, hasStreamRetransmission bool,
-	fromPth *path, deadlineBatch []int) []*path {
-	if len(s.paths) <= 1 {
-		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			return nil
-		}
-		paths := make([]*path, len(deadlineBatch))
-		for i := range paths {
-			paths[i] = s.paths[protocol.InitialPathID]
-		}
-		return paths
+// singlePathBatch sends the whole batch on the only path, if sending is allowed on it or if it is a retransmission
+func (sch *scheduler) singlePathBatch(paths []PathView, hasRetransmission bool, size int) []PathView {
+	sch.recordSolver(schedlog.SolverSinglePath)
+	pth := singlePath(paths, hasRetransmission)
+	if pth == nil {
+		return nil
+	}
+	batch := make([]PathView, size)
+	for i := range batch {
+		batch[i] = pth
 	}
+	return batch
+}
 
-	// some information
-	//for pathID, path := range s.paths {
-	//	fmt.Println("pathID:", pathID)
-	//	fmt.Println("CWND:", path.sentPacketHandler.GetCongestionWindow())
-	//	fmt.Println("Inflight Bytes:", path.sentPacketHandler.GetBytesInFlight())
-	//	fmt.Println("RTT:", path.rttStats.SmoothedRTT())
-	//}
+func (sch *scheduler) selectBatchFirstPath(paths []PathView, hasRetransmission bool, deadlineBatch []int) []PathView {
+	if len(paths) <= 1 {
+		return sch.singlePathBatch(paths, hasRetransmission, len(deadlineBatch))
+	}
 
-	paths := make([]*path, len(deadlineBatch))
+	sch.recordSolver(schedlog.SolverFirstPath)
+	batch := make([]PathView, len(deadlineBatch))
 	// a flag
 	canSend := false
 	for i := 0; i < len(deadlineBatch); i++ {
-		for pathID, pth := range s.paths {
-			if pathID == protocol.PathID(1) && pth.SendingAllowed() {
-				paths[i] = pth
+		for _, pth := range paths {
+			if pth.PathID() == protocol.PathID(1) && pth.SendingAllowed() {
+				batch[i] = pth
 				canSend = true
 				break
 			}
@@ -333,38 +367,27 @@ func (sch *scheduler) selectBatchFirstPath(s *session,
 	}
 
 	if canSend {
-		return paths
+		return batch
 	} else {
 		return nil
 	}
 }
 
-func (sch *scheduler) selectBatchlinOpt(s *session,
-	hasRetransmission bool, hasStreamRetransmission bool,
-	fromPth *path, deadlineBatch []int) []*path {
-	if len(s.paths) <= 1 {
-		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			return nil
-		}
-		paths := make([]*path, len(deadlineBatch))
-		for i := range paths {
-			paths[i] = s.paths[protocol.InitialPathID]
-		}
-		return paths
+func (sch *scheduler) selectBatchlinOpt(paths []PathView, batchBudget float64, hasRetransmission bool, deadlineBatch []int) []PathView {
+	if len(paths) <= 1 {
+		return sch.singlePathBatch(paths, hasRetransmission, len(deadlineBatch))
 	}
 
 	// Create a slice to store the eligible paths
-	eligiblePaths := []*path{}
+	eligiblePaths := []PathView{}
 
 	// Iterate over the paths and filter out the initial path
-	for pathID, pth := range s.paths {
-		if pathID == protocol.InitialPathID {
+	for _, pth := range paths {
+		if pth.PathID() == protocol.InitialPathID {
 			continue
 		} else {
-			pthCwnd := pth.sentPacketHandler.GetCongestionWindow() // notice this Cwnd is bytes
-			pthInflight := pth.sentPacketHandler.GetBytesInFlight()
-			// path that has remaining cwnd is available
-			if pthCwnd >= pthInflight {
+			// path that has remaining cwnd is available, notice this Cwnd is bytes
+			if pth.CongestionWindow() >= pth.BytesInFlight() {
 				eligiblePaths = append(eligiblePaths, pth)
 			}
 		}
@@ -376,26 +399,18 @@ func (sch *scheduler) selectBatchlinOpt(s *session,
 	// cost constraint
 	pathCost := make([]float64, len(eligiblePaths))
 	for i, pth := range eligiblePaths {
-		//pathDelays[i] = (float64(pth.rttStats.SmoothedRTT()) / float64(time.Millisecond)) / 2
-		tempPathDelays := (float64(pth.rttStats.SmoothedRTT()) / float64(time.Millisecond)) / 2
+		tempPathDelays := float64(pth.OneWayDelay()) / float64(time.Millisecond)
 		if banditAvailable {
-			pathDelays[i] = tempPathDelays * float64(pth.sentPacketHandler.GetPathAlpha())
+			pathDelays[i] = tempPathDelays * pth.Alpha()
 			//pathDelays[i] = tempPathDelays * alpha1
 			//pathDelays[i] = tempPathDelays * alpha2
 		} else {
 			pathDelays[i] = tempPathDelays
 		}
 
-		if pth.pathID == protocol.PathID(1) {
-			pathCost[i] = path1Cost
-		} else if pth.pathID == protocol.PathID(3) {
-			pathCost[i] = path3Cost
-		}
+		pathCost[i] = pth.Cost()
 
-		//if banditAvailable {
-		//	fmt.Println("select arm pathID:", pth.pathID, ", alpha:", pth.sentPacketHandler.GetPathAlpha())
-		//}
-		remainingCwnd := pth.sentPacketHandler.GetCongestionWindow() - pth.sentPacketHandler.GetBytesInFlight()
+		remainingCwnd := pth.CongestionWindow() - pth.BytesInFlight()
 		// TODO:remainingCwnd / protocol.MaxPacketSize is a uint64
 		pathCWNDs[i] = float64(remainingCwnd / protocol.MaxPacketSize)
 	}
@@ -408,64 +423,76 @@ func (sch *scheduler) selectBatchlinOpt(s *session,
 	packetsNum := generateSequence(len(deadlineBatch))
 	packetsDeadline := convertToIntSlice(deadlineBatch)
 
-	// linOpt solver, when costConstraintAvailable is true, call linOptCost
+	// linOpt solver, when the cost constraint is enabled, call linOptCost
 	// policy is a 1*batchSize vector
 	var policy []int
-	if costConstraintAvailable {
-		policy = linOptCost(packetsNum, packetsDeadline, pathDelays, pathCWNDs, pathCost, budget)
+	if sch.costConstraint {
+		policy = linOptCost(sch.rand, packetsNum, packetsDeadline, pathDelays, pathCWNDs, pathCost, batchBudget)
+		sch.recordSolver(schedlog.SolverLinOptCost)
+		if sch.decision != nil {
+			sch.decision.Budget = batchBudget
+		}
 	} else {
 		policy = linOpt(packetsNum, packetsDeadline, pathDelays, pathCWNDs)
+		sch.recordSolver(schedlog.SolverLinOpt)
 	}
-	paths := PolicyToSelectPath(policy, eligiblePaths)
-
-	// compute cost
-	//if costConstraintAvailable {
-	//	cost := computeCost(paths)
-	//	fmt.Println("Current Cost:", cost)
-	//	//sch.totalCost += cost // can not add total cost here, because maybe some packets is not sent
-	//}
-	return paths
+	sch.recordSolverInputs(eligiblePaths, pathDelays, pathCWNDs, policy)
+	return PolicyToSelectPath(policy, eligiblePaths)
 }
 
-func (sch *scheduler) selectBatchEDF(s *session,
-	hasRetransmission bool, hasStreamRetransmission bool,
-	fromPth *path, deadlineBatch []int) []*path {
+func (sch *scheduler) selectBatchEDF(paths []PathView, hasRetransmission bool,
+	hasStreamRetransmission bool, fromPth PathView, deadlineBatch []int) []PathView {
 
-	// Sort Deadline, will change scheduler.go DeadlineBatch
-	sort.Ints(deadlineBatch)
+	// the caller sends the packets of the batch in order, earliest deadline first
+	sch.sortBatchByDeadline(deadlineBatch)
 
-	if len(s.paths) <= 1 {
-		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			return nil
-		}
-		paths := make([]*path, len(deadlineBatch))
-		for i := range paths {
-			paths[i] = s.paths[protocol.InitialPathID]
-		}
-		return paths
+	if len(paths) <= 1 {
+		return sch.singlePathBatch(paths, hasRetransmission, len(deadlineBatch))
 	}
 
-	// Create a slice to store the eligible paths
-	eligiblePaths := []*path{}
+	sch.recordSolver(schedlog.SolverEDF)
 
 	// Iterate and pick out the pathBatch through minRTT
+	batch := make([]PathView, 0, len(deadlineBatch))
 	for i := 0; i < len(deadlineBatch); i++ {
-		pth := sch.selectPathLowLatency(s, hasRetransmission, hasStreamRetransmission, fromPth)
-		eligiblePaths = append(eligiblePaths, pth)
+		batch = append(batch, sch.lowLatencyPath(paths, hasRetransmission, hasStreamRetransmission, fromPth))
+	}
+
+	return batch
+}
+
+// sortBatchByDeadline sorts the deadlines of a batch in place, with the packets of the batch held back in earlier batches
+func (sch *scheduler) sortBatchByDeadline(deadlineBatch []int) {
+	order := make([]int, len(deadlineBatch))
+	for i := range order {
+		order[i] = i
 	}
+	sort.SliceStable(order, func(i, j int) bool { return deadlineBatch[order[i]] < deadlineBatch[order[j]] })
 
-	return eligiblePaths
+	deadlines := append([]int(nil), deadlineBatch...)
+	held := make([]heldPacket, len(deadlineBatch))
+	for i, o := range order {
+		deadlineBatch[i] = deadlines[o]
+		held[i] = sch.heldPacket(o)
+	}
+	if len(sch.batchHeld) > 0 {
+		sch.batchHeld = held
+	}
+}
+
+// batchBudget returns the cost budget of a batch, bounded by what remains of the session cost budget
+func (sch *scheduler) batchBudget(s *session) float64 {
+	if s.config.CostBudget <= 0 {
+		return budget
+	}
+	return math.Min(budget, math.Max(0, s.config.CostBudget-sch.totalCost))
 }
 
 func computeCost(paths []*path) float64 {
 	var cost float64
 	for _, pth := range paths {
 		if pth != nil {
-			if pth.pathID == protocol.PathID(1) {
-				cost += path1Cost
-			} else if pth.pathID == protocol.PathID(3) {
-				cost += path3Cost
-			}
+			cost += pth.getCost()
 		}
 	}
 	return cost
@@ -487,8 +514,9 @@ func convertToIntSlice(input []int) []float64 {
 	return converted
 }
 
-func PolicyToSelectPath(policy []int, eligiblePath []*path) []*path {
-	var selectedPaths []*path
+// PolicyToSelectPath returns the path of every packet of a policy, nil for the packets that are not sent
+func PolicyToSelectPath(policy []int, eligiblePath []PathView) []PathView {
+	var selectedPaths []PathView
 
 	for _, p := range policy {
 		if p == 0 {
@@ -503,29 +531,20 @@ func PolicyToSelectPath(policy []int, eligiblePath []*path) []*path {
 	return selectedPaths
 }
 
-func (sch *scheduler) canMadeDecision(s *session, batch int) bool {
-	// Create a slice to store the eligible paths
-	eligiblePaths := []*path{}
-	for pathID, pth := range s.paths {
-		if pathID == protocol.InitialPathID {
-			continue
-		} else {
-			pthCwnd := pth.sentPacketHandler.GetCongestionWindow() // notice this Cwnd is bytes
-			pthInflight := pth.sentPacketHandler.GetBytesInFlight()
-			// path that has remaining cwnd is available
-			if pthCwnd >= pthInflight {
-				eligiblePaths = append(eligiblePaths, pth)
-			}
-		}
-	}
-
+// canMadeDecision tells if the remaining CWNDs of the paths hold a batch
+func (sch *scheduler) canMadeDecision(paths []PathView, batch int) bool {
 	// Collect all the path CWNDs
 	var allPathCwnds int
-
-	for _, pth := range eligiblePaths {
-		remainingCwnd := pth.sentPacketHandler.GetCongestionWindow() - pth.sentPacketHandler.GetBytesInFlight()
-		// TODO:remainingCwnd / protocol.MaxPacketSize is a uint64
-		allPathCwnds = allPathCwnds + int(remainingCwnd/protocol.MaxPacketSize)
+	for _, pth := range paths {
+		if pth.PathID() == protocol.InitialPathID {
+			continue
+		}
+		// path that has remaining cwnd is available, notice this Cwnd is bytes
+		if pth.CongestionWindow() >= pth.BytesInFlight() {
+			remainingCwnd := pth.CongestionWindow() - pth.BytesInFlight()
+			// TODO:remainingCwnd / protocol.MaxPacketSize is a uint64
+			allPathCwnds = allPathCwnds + int(remainingCwnd/protocol.MaxPacketSize)
+		}
 	}
 	if allPathCwnds >= batch {
 		return true
@@ -0,0 +1,366 @@
+package quic
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// a batchInstance is an input of the solvers, the way selectBatchlinOpt builds it
+type batchInstance struct {
+	deadlines []float64
+	delays    []float64
+	cwnds     []float64
+	costs     []float64
+	budget    float64
+}
+
+// randomBatchInstance draws an instance with the deadlines of GenerateBatchDeadline
+func randomBatchInstance(r *rand.Rand) batchInstance {
+	packets := r.Intn(12) + 1
+	paths := r.Intn(3) + 1
+	in := batchInstance{budget: float64(r.Intn(9))}
+	for i := 0; i < packets; i++ {
+		in.deadlines = append(in.deadlines, float64(r.Intn(30)+20))
+	}
+	for i := 0; i < paths; i++ {
+		in.delays = append(in.delays, float64(r.Intn(55)+5))
+		in.cwnds = append(in.cwnds, float64(r.Intn(packets+1)))
+		in.costs = append(in.costs, []float64{0, path3Cost, path1Cost}[r.Intn(3)])
+	}
+	return in
+}
+
+func (in batchInstance) linOpt() []int {
+	return linOpt(generateSequence(len(in.deadlines)), in.deadlines, in.delays, in.cwnds)
+}
+
+func (in batchInstance) linOptCost(r *rand.Rand) []int {
+	return linOptCost(r, generateSequence(len(in.deadlines)), in.deadlines, in.delays, in.cwnds, in.costs, in.budget)
+}
+
+// edf assigns the packets in the order of their deadlines to the path with the lowest delay that has room left
+func (in batchInstance) edf() []int {
+	order := make([]int, len(in.deadlines))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return in.deadlines[order[a]] < in.deadlines[order[b]] })
+	room := append([]float64(nil), in.cwnds...)
+	policy := make([]int, len(in.deadlines))
+	for _, i := range order {
+		best := -1
+		for j := range in.delays {
+			if room[j] >= 1 && (best < 0 || in.delays[j] < in.delays[best]) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			room[best]--
+			policy[i] = best + 1
+		}
+	}
+	return policy
+}
+
+// met counts the packets of a policy that arrive before their deadline
+func (in batchInstance) met(policy []int) int {
+	var met int
+	for i, p := range policy {
+		if p > 0 && in.deadlines[i] >= in.delays[p-1] {
+			met++
+		}
+	}
+	return met
+}
+
+func (in batchInstance) cost(policy []int) float64 {
+	var cost float64
+	for _, p := range policy {
+		if p > 0 {
+			cost += in.costs[p-1]
+		}
+	}
+	return cost
+}
+
+// expectValidPolicy checks that every packet is assigned to at most one existing path, without exceeding the CWNDs
+func (in batchInstance) expectValidPolicy(policy []int) {
+	ExpectWithOffset(1, policy).To(HaveLen(len(in.deadlines)))
+	assigned := make([]int, len(in.delays))
+	for _, p := range policy {
+		ExpectWithOffset(1, p).To(And(BeNumerically(">=", 0), BeNumerically("<=", len(in.delays))))
+		if p > 0 {
+			assigned[p-1]++
+		}
+	}
+	for j := range assigned {
+		ExpectWithOffset(1, float64(assigned[j])).To(BeNumerically("<=", in.cwnds[j]), "packets on path %d", j+1)
+	}
+}
+
+var _ = Describe("Batch optimisation", func() {
+	const instances = 200
+
+	Context("linOpt", func() {
+		It("sends the packet with the tight deadline on the fast path", func() {
+			in := batchInstance{deadlines: []float64{10, 50}, delays: []float64{5, 40}, cwnds: []float64{1, 1}}
+			Expect(in.linOpt()).To(Equal([]int{1, 2}))
+		})
+
+		It("uses more than two paths", func() {
+			in := batchInstance{deadlines: []float64{10, 20, 30}, delays: []float64{25, 15, 5}, cwnds: []float64{1, 1, 1}}
+			Expect(in.linOpt()).To(Equal([]int{3, 2, 1}))
+		})
+
+		It("computes valid policies, never worse than EDF", func() {
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			for i := 0; i < instances; i++ {
+				in := randomBatchInstance(r)
+				policy := in.linOpt()
+				in.expectValidPolicy(policy)
+				Expect(in.met(policy)).To(BeNumerically(">=", in.met(in.edf())), "instance %+v", in)
+			}
+		})
+
+		It("doesn't send anything without paths", func() {
+			in := batchInstance{deadlines: []float64{20, 30}}
+			Expect(in.linOpt()).To(Equal([]int{0, 0}))
+		})
+
+		It("doesn't send anything without CWND", func() {
+			in := batchInstance{deadlines: []float64{20, 30}, delays: []float64{5, 10}, cwnds: []float64{0, 0}}
+			Expect(in.linOpt()).To(Equal([]int{0, 0}))
+		})
+
+		It("doesn't send packets that can't meet their deadline", func() {
+			in := batchInstance{deadlines: []float64{20, 30}, delays: []float64{40, 50}, cwnds: []float64{2, 2}}
+			Expect(in.linOpt()).To(Equal([]int{0, 0}))
+		})
+	})
+
+	Context("linOptCost", func() {
+		It("keeps the packets off the expensive path when the budget is spent", func() {
+			in := batchInstance{
+				deadlines: []float64{30, 30},
+				delays:    []float64{5, 10},
+				cwnds:     []float64{2, 2},
+				costs:     []float64{path1Cost, 0},
+				budget:    0,
+			}
+			Expect(in.linOptCost(rand.New(rand.NewSource(0)))).To(Equal([]int{2, 2}))
+		})
+
+		Context("dropping the packets beyond the capacity", func() {
+			for _, t := range []struct {
+				name      string
+				policy    []int
+				deadlines []float64
+				cwnds     []float64
+				costs     []float64
+				budget    float64
+				expected  []int
+			}{
+				{name: "keeps the earliest deadlines within the CWND", policy: []int{1, 1, 1}, deadlines: []float64{40, 20, 30},
+					cwnds: []float64{2}, costs: []float64{0}, expected: []int{0, 1, 1}},
+				{name: "keeps the earliest deadlines within the budget", policy: []int{1, 1, 2}, deadlines: []float64{40, 20, 30},
+					cwnds: []float64{3, 3}, costs: []float64{path1Cost, 0}, budget: path1Cost, expected: []int{0, 1, 2}},
+				{name: "drops the packets that miss their deadline first", policy: []int{2, 2}, deadlines: []float64{20, 45},
+					cwnds: []float64{1, 1}, costs: []float64{0, 0}, expected: []int{0, 2}},
+				{name: "keeps the packets that meet their deadline on a full path", policy: []int{2, 2, 2}, deadlines: []float64{20, 45, 30},
+					cwnds: []float64{2, 2}, costs: []float64{0, 0}, expected: []int{2, 2, 0}},
+				{name: "keeps the policy within the capacity", policy: []int{0, 2, 1}, deadlines: []float64{20, 45, 30},
+					cwnds: []float64{1, 1}, costs: []float64{0, 0}, expected: []int{0, 2, 1}},
+			} {
+				t := t
+				It(t.name, func() {
+					// path 1 has a delay of 10, path 2 of 40
+					Expect(enforceCapacity(t.policy, t.deadlines, []float64{10, 40}, t.cwnds, t.costs, t.budget)).To(Equal(t.expected))
+				})
+			}
+		})
+
+		It("computes valid policies within the budget", func() {
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			for i := 0; i < instances; i++ {
+				in := randomBatchInstance(r)
+				policy := in.linOptCost(r)
+				in.expectValidPolicy(policy)
+				Expect(in.cost(policy)).To(BeNumerically("<=", in.budget+1e-9), "instance %+v, policy %v", in, policy)
+			}
+		})
+
+		It("is never worse than EDF when the budget covers every packet", func() {
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			for i := 0; i < instances; i++ {
+				in := randomBatchInstance(r)
+				in.budget = float64(len(in.deadlines)) * path1Cost
+				policy := in.linOptCost(r)
+				in.expectValidPolicy(policy)
+				Expect(in.met(policy)).To(BeNumerically(">=", in.met(in.edf())), "instance %+v", in)
+			}
+		})
+
+		It("doesn't send anything without paths", func() {
+			in := batchInstance{deadlines: []float64{20, 30}, budget: budget}
+			Expect(in.linOptCost(rand.New(rand.NewSource(0)))).To(Equal([]int{0, 0}))
+		})
+
+		It("doesn't send anything without CWND", func() {
+			in := batchInstance{deadlines: []float64{20, 30}, delays: []float64{5, 10}, cwnds: []float64{0, 0}, costs: []float64{0, 0}, budget: budget}
+			Expect(in.linOptCost(rand.New(rand.NewSource(0)))).To(Equal([]int{0, 0}))
+		})
+
+		It("doesn't send packets that can't meet their deadline", func() {
+			in := batchInstance{deadlines: []float64{20, 30}, delays: []float64{40, 50}, cwnds: []float64{2, 2}, costs: []float64{0, 0}, budget: budget}
+			Expect(in.linOptCost(rand.New(rand.NewSource(0)))).To(Equal([]int{0, 0}))
+		})
+	})
+
+	It("converts a solution to a policy", func() {
+		Expect(resultToPolicy([][]float64{})).To(BeEmpty())
+		Expect(resultToPolicy([][]float64{{}, {}})).To(Equal([]int{0, 0}))
+		Expect(resultToPolicy([][]float64{{0}, {1}})).To(Equal([]int{0, 1}))
+		Expect(resultToPolicy([][]float64{{0, 0}, {1, 0}, {0, 1}})).To(Equal([]int{0, 1, 2}))
+		Expect(resultToPolicy([][]float64{{0, 0, 1}, {0, 1, 0}})).To(Equal([]int{3, 2}))
+	})
+
+	Context("greedy rounding", func() {
+		It("keeps the integral decisions", func() {
+			result := [][]float64{{0, 1}, {1, 0}, {0, 0}}
+			Expect(resultToPolicyWithGreedyRounding(rand.New(rand.NewSource(0)), result, 3, 2)).To(Equal([]int{2, 1, 0}))
+		})
+
+		It("rounds a fractional decision with its probability", func() {
+			r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+			var sent int
+			for i := 0; i < 1000; i++ {
+				policy := resultToPolicyWithGreedyRounding(r, [][]float64{{0.3, 0}}, 1, 2)
+				Expect(policy[0]).To(Or(Equal(0), Equal(1)))
+				if policy[0] == 1 {
+					sent++
+				}
+			}
+			Expect(sent).To(BeNumerically("~", 300, 60))
+		})
+	})
+
+	It("selects the paths of a policy", func() {
+		paths := views(newFakePath(1, 0), newFakePath(3, 0))
+		Expect(PolicyToSelectPath([]int{2, 1, 0}, paths)).To(Equal([]PathView{paths[1], paths[0], nil}))
+		// a policy beyond the paths doesn't send the packet
+		Expect(PolicyToSelectPath([]int{3, 1}, paths)).To(Equal([]PathView{nil, paths[0]}))
+		Expect(PolicyToSelectPath([]int{1}, nil)).To(Equal([]PathView{nil}))
+	})
+
+	It("decides when the remaining CWNDs of the paths hold the batch", func() {
+		sch := &scheduler{}
+		initial := newFakePath(protocol.InitialPathID, 30*time.Millisecond)
+		Expect(sch.canMadeDecision(views(initial), 1)).To(BeFalse())
+		Expect(sch.canMadeDecision(views(initial), 0)).To(BeTrue())
+
+		fast := newFakePath(1, 20*time.Millisecond)
+		fast.inFlight = 4 * protocol.MaxPacketSize
+		slow := newFakePath(3, 80*time.Millisecond)
+		// more in flight than the window, the path doesn't count
+		overfull := newFakePath(5, 80*time.Millisecond)
+		overfull.inFlight = overfull.cwnd + 1
+		paths := views(initial, fast, slow, overfull)
+		Expect(sch.canMadeDecision(paths, 16)).To(BeTrue())
+		Expect(sch.canMadeDecision(paths, 17)).To(BeFalse())
+	})
+
+	Context("on path views", func() {
+		var (
+			initial, fast, slow *fakePath
+			paths               []PathView
+			sch                 *scheduler
+		)
+
+		BeforeEach(func() {
+			initial = newFakePath(protocol.InitialPathID, 30*time.Millisecond)
+			// one-way delays of 5 and 40 ms
+			fast = newFakePath(1, 10*time.Millisecond)
+			slow = newFakePath(3, 80*time.Millisecond)
+			paths = views(initial, fast, slow)
+			sch = &scheduler{quotas: make(map[protocol.PathID]uint), rand: rand.New(rand.NewSource(GinkgoRandomSeed()))}
+		})
+
+		type batchCase struct {
+			name      string
+			scheduler string
+			// setup changes the paths or the scheduler from their initial state
+			setup     func()
+			deadlines []int
+			// selected are the IDs of the selected paths, -1 for the packets not sent, nil if nothing is sent
+			selected []int
+		}
+
+		cases := []batchCase{
+			{name: "EDF sends everything on the fastest path", scheduler: "BatchEDF", deadlines: []int{50, 10, 30}, selected: []int{1, 1, 1}},
+			{name: "EDF avoids a potentially failed path", scheduler: "BatchEDF", setup: func() { fast.failed = true }, deadlines: []int{50, 10}, selected: []int{3, 3}},
+			{name: "EDF doesn't send without an open path", scheduler: "BatchEDF", setup: func() {
+				fast.blocked = true
+				slow.blocked = true
+			}, deadlines: []int{50, 10}, selected: []int{-1, -1}},
+			{name: "EDF sends on the only path", scheduler: "BatchEDF", setup: func() { paths = views(initial) }, deadlines: []int{50, 10}, selected: []int{0, 0}},
+			{name: "the first path scheduler sends on path 1", scheduler: "BatchFirstPath", deadlines: []int{50, 10}, selected: []int{1, 1}},
+			{name: "the first path scheduler doesn't send when path 1 is blocked", scheduler: "BatchFirstPath", setup: func() { fast.blocked = true }, deadlines: []int{50, 10}},
+			{name: "DaMPS sends the packet with the loose deadline on the slow path", scheduler: "BatchLinOpt", setup: func() {
+				sch.costConstraint = false
+				fast.cwnd = protocol.MaxPacketSize
+				slow.cwnd = protocol.MaxPacketSize
+			}, deadlines: []int{50, 10}, selected: []int{3, 1}},
+			{name: "DaMPS ignores the paths without room in their CWND", scheduler: "BatchLinOpt", setup: func() {
+				sch.costConstraint = false
+				fast.inFlight = fast.cwnd + 1
+			}, deadlines: []int{50, 10}, selected: []int{3, -1}},
+			{name: "CaDaMPS keeps the packets off the expensive path without budget", scheduler: "BatchLinOpt", setup: func() {
+				sch.costConstraint = true
+				fast.cost = path1Cost
+			}, deadlines: []int{50, 10}, selected: []int{3, -1}},
+			{name: "CaDaMPS scales the delays by the alpha of the bandit", scheduler: "BatchLinOpt", setup: func() {
+				sch.costConstraint = true
+				slow.alpha = 2
+			}, deadlines: []int{50, 45}, selected: []int{1, 1}},
+		}
+
+		for i := range cases {
+			c := cases[i]
+			It(c.name, func() {
+				sch.SchedulerName = c.scheduler
+				if c.setup != nil {
+					c.setup()
+				}
+				batch := sch.batchPaths(paths, 0, false, false, nil, c.deadlines)
+				if c.selected == nil {
+					Expect(batch).To(BeNil())
+					return
+				}
+				Expect(batch).To(HaveLen(len(c.selected)))
+				for i, id := range c.selected {
+					if id < 0 {
+						Expect(batch[i]).To(BeNil())
+					} else {
+						Expect(batch[i]).ToNot(BeNil())
+						Expect(batch[i].PathID()).To(Equal(protocol.PathID(id)))
+					}
+				}
+			})
+		}
+
+		It("sorts the deadlines of an EDF batch with the packets held back in earlier batches", func() {
+			sch.SchedulerName = "BatchEDF"
+			deadlines := []int{50, 10, 30}
+			held := time.Now().Add(10 * time.Millisecond)
+			sch.batchHeld = []heldPacket{{}, {deadline: held, deferred: true}, {}}
+			sch.batchPaths(paths, 0, false, false, nil, deadlines)
+			Expect(deadlines).To(Equal([]int{10, 30, 50}))
+			Expect(sch.batchHeld).To(Equal([]heldPacket{{deadline: held, deferred: true}, {}, {}}))
+		})
+	})
+})
@@ -0,0 +1,94 @@
+package quic
+
+import (
+	"math/rand"
+	"time"
+
+	"bitbucket.com/marcmolla/gorl/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fixedAgent is a training agent that always selects the same action
+type fixedAgent struct {
+	action int
+	calls  int
+}
+
+func (a *fixedAgent) GetAction(types.Vector) int {
+	a.calls++
+	return a.action
+}
+func (a *fixedAgent) LoadWeights(string) error                         { return nil }
+func (a *fixedAgent) SaveStep(uint64, types.Output, types.Vector, int) {}
+func (a *fixedAgent) CloseEpisode(uint64, types.Output, bool)          {}
+
+var _ = Describe("Scheduler randomness", func() {
+	newScheduler := func(seed int64) *scheduler {
+		return &scheduler{SchedulerName: "BatchLinOpt", rand: rand.New(rand.NewSource(seed))}
+	}
+
+	It("generates the same batch deadlines from the same seed", func() {
+		deadlines := newScheduler(42).GenerateBatchDeadline(100, time.Now())
+		Expect(newScheduler(42).GenerateBatchDeadline(100, time.Now())).To(Equal(deadlines))
+		Expect(newScheduler(43).GenerateBatchDeadline(100, time.Now())).ToNot(Equal(deadlines))
+		for _, d := range deadlines {
+			Expect(d).To(And(BeNumerically(">=", 20), BeNumerically("<", 50)))
+		}
+	})
+
+	It("rounds with the probabilities of the solution", func() {
+		r := rand.New(rand.NewSource(42))
+		var first int
+		for i := 0; i < 1000; i++ {
+			if chooseByProb(r, []int{1, 0}, []float64{0.3, 0.7}) == 1 {
+				first++
+			}
+		}
+		Expect(first).To(BeNumerically("~", 300, 50))
+		Expect(chooseByProb(r, []int{1, 0}, []float64{0, 0})).To(Equal(0))
+	})
+
+	It("draws uniform and normal deadlines from the source", func() {
+		now := time.Now()
+		for i := 0; i < 100; i++ {
+			d := uniformDeadlineGenerator(rand.New(rand.NewSource(int64(i))), now, 20, 50).Sub(now)
+			Expect(d).To(And(BeNumerically(">=", 20*time.Millisecond), BeNumerically("<", 50*time.Millisecond)))
+			d = normalDeadlineGenerator(rand.New(rand.NewSource(int64(i))), now, 100, 10).Sub(now)
+			Expect(d).To(And(BeNumerically(">=", 70*time.Millisecond), BeNumerically("<=", 130*time.Millisecond)))
+		}
+		Expect(normalDeadlineGenerator(rand.New(rand.NewSource(1)), now, 100, 10)).To(Equal(normalDeadlineGenerator(rand.New(rand.NewSource(1)), now, 100, 10)))
+	})
+
+	Context("exploration of the training agent", func() {
+		actions := func(seed int64, epsilon float64, agent *fixedAgent) []int {
+			sch := newScheduler(seed)
+			sch.Epsilon = epsilon
+			sch.TrainingAgent = agent
+			var actions []int
+			for i := 0; i < 100; i++ {
+				actions = append(actions, sch.trainingAction(types.Vector{}))
+			}
+			return actions
+		}
+
+		It("asks the agent without exploration", func() {
+			agent := &fixedAgent{action: 1}
+			for _, a := range actions(42, 0, agent) {
+				Expect(a).To(Equal(1))
+			}
+			Expect(agent.calls).To(Equal(100))
+		})
+
+		It("draws the exploration from the source", func() {
+			agent := &fixedAgent{action: 1}
+			explored := actions(42, 1, agent)
+			Expect(agent.calls).To(BeZero())
+			Expect(explored).To(ContainElement(0))
+			Expect(explored).To(ContainElement(1))
+			Expect(actions(42, 1, agent)).To(Equal(explored))
+			Expect(actions(42, 0.5, &fixedAgent{})).To(Equal(actions(42, 0.5, &fixedAgent{})))
+		})
+	})
+})
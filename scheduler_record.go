@@ -0,0 +1,150 @@
+package quic
+
+import (
+	"sort"
+	"time"
+
+	"bitbucket.com/marcmolla/gorl/types"
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/schedlog"
+)
+
+// record writes a record to the decision log, if any
+func (s *session) record(data schedlog.Data) {
+	if s.config.DecisionRecorder == nil {
+		return
+	}
+	s.config.DecisionRecorder.Record(schedlog.NewRecord(s.connectionID, s.clock.Now(), data))
+}
+
+// onPacketOutcome records what happened to a packet sent on the path, and whether it missed its deadline
+func (p *path) onPacketOutcome(packet *ackhandler.Packet, acked bool) {
+	p.countDeadlineMiss(packet, acked, p.sess.clock.Now())
+	outcome := schedlog.OutcomeRetransmitted
+	if acked {
+		outcome = schedlog.OutcomeAcked
+	}
+	p.sess.record(&schedlog.PacketOutcome{
+		PathID:       int(p.pathID),
+		PacketNumber: uint64(packet.PacketNumber),
+		Outcome:      outcome,
+	})
+}
+
+// startDecision starts a batch decision, and records it if the session has a decision log.
+// The state of all the paths the scheduler may use is recorded, solvers that filter them overwrite it.
+func (sch *scheduler) startDecision(s *session) {
+	sch.batchCount++
+	sch.decision = nil
+	if s.config.DecisionRecorder == nil {
+		return
+	}
+	sch.decision = &schedlog.Decision{
+		Batch:     sch.batchCount,
+		Scheduler: sch.SchedulerName,
+	}
+	for pathID, pth := range s.paths {
+		if pathID == protocol.InitialPathID && len(s.paths) > 1 {
+			continue
+		}
+		state := pathState(pth)
+		state.Delay = state.SmoothedRTT / 2
+		if banditAvailable {
+			state.Delay *= state.Alpha
+		}
+		sch.decision.Paths = append(sch.decision.Paths, state)
+	}
+	sort.Slice(sch.decision.Paths, func(i, j int) bool { return sch.decision.Paths[i].PathID < sch.decision.Paths[j].PathID })
+}
+
+// recordSolver records the solver of the decision
+func (sch *scheduler) recordSolver(solver string) {
+	sch.solver = solver
+	if sch.decision != nil {
+		sch.decision.Solver = solver
+	}
+}
+
+// recordSolverInputs records the paths given to a solver, with the delays and remaining windows it used, and its policy
+func (sch *scheduler) recordSolverInputs(paths []PathView, delays []float64, cwnds []float64, policy []int) {
+	if sch.decision == nil {
+		return
+	}
+	sch.decision.Paths = make([]schedlog.PathState, len(paths))
+	for i, pth := range paths {
+		state := pathState(pth)
+		state.Delay = delays[i]
+		state.RemainingCwnd = cwnds[i]
+		sch.decision.Paths[i] = state
+	}
+	sch.decision.Policy = append([]int(nil), policy...)
+}
+
+// recordSelection records the deadlines of the batch and the paths selected for them
+func (sch *scheduler) recordSelection(deadlineBatch []int, pthBatch []*path) {
+	if sch.decision == nil {
+		return
+	}
+	sch.decision.Deadlines = append([]int(nil), deadlineBatch...)
+	sch.decision.Selected = make([]int, len(deadlineBatch))
+	for i := range sch.decision.Selected {
+		sch.decision.Selected[i] = schedlog.NoPath
+		if i < len(pthBatch) && pthBatch[i] != nil {
+			sch.decision.Selected[i] = int(pthBatch[i].pathID)
+		}
+	}
+}
+
+// recordDecision writes the decision being recorded to the decision log
+func (sch *scheduler) recordDecision(s *session) {
+	if sch.decision == nil {
+		return
+	}
+	s.record(sch.decision)
+	sch.decision = nil
+}
+
+// recordPacketSent records that the packet of the current batch with the given index was sent
+func (sch *scheduler) recordPacketSent(s *session, index int, pth *path, pkt *ackhandler.Packet, deadline time.Time) {
+	if s.config.DecisionRecorder == nil || pkt == nil {
+		return
+	}
+	s.record(&schedlog.PacketSent{
+		Batch:        sch.batchCount,
+		Index:        index,
+		PathID:       int(pth.pathID),
+		PacketNumber: uint64(pkt.PacketNumber),
+		Deadline:     deadline,
+	})
+}
+
+// recordExperience records a step of the DQN agent
+func (sch *scheduler) recordExperience(s *session, state types.Vector, action int) {
+	experience := &schedlog.Experience{
+		State:  make([]float64, len(state)),
+		Action: action,
+	}
+	for i, v := range state {
+		experience.State[i] = float64(v)
+	}
+	s.record(experience)
+}
+
+// pathState is the state of a path recorded in a decision
+func pathState(pth PathView) schedlog.PathState {
+	cwnd := pth.CongestionWindow()
+	inFlight := pth.BytesInFlight()
+	state := schedlog.PathState{
+		PathID:           int(pth.PathID()),
+		SmoothedRTT:      float64(pth.SmoothedRTT()) / float64(time.Millisecond),
+		Alpha:            pth.Alpha(),
+		CongestionWindow: uint64(cwnd),
+		BytesInFlight:    uint64(inFlight),
+		Cost:             pth.Cost(),
+	}
+	if cwnd > inFlight {
+		state.RemainingCwnd = float64((cwnd - inFlight) / protocol.MaxPacketSize)
+	}
+	return state
+}
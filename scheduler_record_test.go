@@ -0,0 +1,113 @@
+package quic
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/schedlog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockRecorder struct {
+	records []*schedlog.Record
+}
+
+func (r *mockRecorder) Record(rec *schedlog.Record) {
+	r.records = append(r.records, rec)
+}
+
+var _ = Describe("Decision log", func() {
+	var (
+		sess     *session
+		sch      *scheduler
+		recorder *mockRecorder
+	)
+
+	BeforeEach(func() {
+		recorder = &mockRecorder{}
+		sch = &scheduler{SchedulerName: "BatchLinOpt"}
+		sess = newTestSession(&Config{DecisionRecorder: recorder}, sch)
+		sess.paths[0] = newTestPath(sess, 0, 30*time.Millisecond)
+		sess.paths[3] = newTestPath(sess, 3, 20*time.Millisecond)
+		sess.paths[1] = newTestPath(sess, 1, 40*time.Millisecond)
+	})
+
+	It("records the paths, the selection and the preserved and deferred packets of a decision", func() {
+		now := time.Now()
+		deadlines := []int{20, 40, 50}
+		pthBatch := []*path{sess.paths[3], nil, sess.paths[1]}
+		sch.startDecision(sess)
+		sch.recordSolver(schedlog.SolverEDF)
+		sch.recordSelection(deadlines, pthBatch)
+		sch.selectivePreservation(sess, deadlines, pthBatch, now)
+		sch.choosePacketsForLowCost(sess, deadlines, pthBatch, now)
+		sch.recordDecision(sess)
+
+		Expect(recorder.records).To(HaveLen(1))
+		rec := recorder.records[0]
+		Expect(rec.Type).To(Equal(schedlog.TypeDecision))
+		Expect(rec.ConnectionID).To(Equal("1337"))
+		d := rec.Decision
+		Expect(d.Batch).To(BeEquivalentTo(1))
+		Expect(d.Scheduler).To(Equal("BatchLinOpt"))
+		Expect(d.Solver).To(Equal(schedlog.SolverEDF))
+		Expect(d.Deadlines).To(Equal(deadlines))
+		Expect(d.Paths).To(HaveLen(2))
+		Expect(d.Paths[0].PathID).To(Equal(1))
+		Expect(d.Paths[0].SmoothedRTT).To(Equal(40.0))
+		Expect(d.Paths[0].Cost).To(Equal(path1Cost))
+		Expect(d.Paths[1].PathID).To(Equal(3))
+		Expect(d.Selected).To(Equal([]int{3, schedlog.NoPath, 1}))
+		Expect(d.Preserved).To(Equal([]int{1}))
+		Expect(d.Deferred).To(Equal([]int{2}))
+		Expect(sch.decision).To(BeNil())
+	})
+
+	It("records the inputs and the policy of a solver", func() {
+		sch.startDecision(sess)
+		sch.recordSolverInputs([]PathView{sess.paths[3]}, []float64{11}, []float64{7}, []int{1, 0})
+		sch.recordSelection([]int{20, 30}, nil)
+		sch.recordDecision(sess)
+		d := recorder.records[0].Decision
+		Expect(d.Paths).To(HaveLen(1))
+		Expect(d.Paths[0].PathID).To(Equal(3))
+		Expect(d.Paths[0].Delay).To(Equal(11.0))
+		Expect(d.Paths[0].RemainingCwnd).To(Equal(7.0))
+		Expect(d.Policy).To(Equal([]int{1, 0}))
+		Expect(d.Selected).To(Equal([]int{schedlog.NoPath, schedlog.NoPath}))
+	})
+
+	It("numbers the decisions", func() {
+		for i := 0; i < 2; i++ {
+			sch.startDecision(sess)
+			sch.recordDecision(sess)
+		}
+		Expect(recorder.records).To(HaveLen(2))
+		Expect(recorder.records[1].Decision.Batch).To(BeEquivalentTo(2))
+	})
+
+	It("records sent packets and their outcomes", func() {
+		sch.startDecision(sess)
+		deadline := time.Now().Add(20 * time.Millisecond)
+		sch.recordPacketSent(sess, 2, sess.paths[3], &ackhandler.Packet{PacketNumber: 42}, deadline)
+		sess.paths[3].onPacketOutcome(&ackhandler.Packet{PacketNumber: 42}, true)
+		sess.paths[1].onPacketOutcome(&ackhandler.Packet{PacketNumber: 7}, false)
+		Expect(recorder.records).To(HaveLen(3))
+		Expect(recorder.records[0].Packet).To(Equal(&schedlog.PacketSent{Batch: 1, Index: 2, PathID: 3, PacketNumber: 42, Deadline: deadline}))
+		Expect(recorder.records[1].Outcome).To(Equal(&schedlog.PacketOutcome{PathID: 3, PacketNumber: 42, Outcome: schedlog.OutcomeAcked}))
+		Expect(recorder.records[2].Outcome).To(Equal(&schedlog.PacketOutcome{PathID: 1, PacketNumber: 7, Outcome: schedlog.OutcomeRetransmitted}))
+	})
+
+	It("doesn't record anything without a decision log", func() {
+		sess.config.DecisionRecorder = nil
+		sch.startDecision(sess)
+		Expect(sch.decision).To(BeNil())
+		sch.recordSelection([]int{20}, nil)
+		sch.selectivePreservation(sess, []int{40}, []*path{nil}, time.Now())
+		sch.recordDecision(sess)
+		sch.recordPacketSent(sess, 0, sess.paths[3], &ackhandler.Packet{PacketNumber: 1}, time.Now())
+		sess.paths[3].onPacketOutcome(&ackhandler.Packet{PacketNumber: 1}, true)
+		Expect(recorder.records).To(BeEmpty())
+	})
+})
@@ -0,0 +1,202 @@
+package quic
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/congestion"
+	"github.com/lucas-clemente/quic-go/internal/flowcontrol"
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qlog"
+	"github.com/lucas-clemente/quic-go/schedlog"
+)
+
+// ReplaySchedulers are the schedulers that can be replayed.
+// BatchLinOptNoCost is BatchLinOpt without the cost constraint of CaDaMPS.
+var ReplaySchedulers = []string{"rtt", "ecf", "blest", "BatchEDF", "BatchLinOpt", "BatchLinOptNoCost"}
+
+// A SchedulerReplay runs a scheduler on recorded path states, without a network.
+// Schedulers that select a path per packet are called once per packet of the batch,
+// with the bytes in flight of the selected path growing by a full-sized packet every time.
+type SchedulerReplay struct {
+	sess *session
+	sch  *scheduler
+}
+
+// NewSchedulerReplay creates a replay of one of the ReplaySchedulers
+func NewSchedulerReplay(schedulerName string) (*SchedulerReplay, error) {
+	known := false
+	for _, name := range ReplaySchedulers {
+		known = known || name == schedulerName
+	}
+	if !known {
+		return nil, fmt.Errorf("scheduler %s can't be replayed", schedulerName)
+	}
+	sch := &scheduler{
+		SchedulerName:  schedulerName,
+		quotas:         make(map[protocol.PathID]uint),
+		retrans:        make(map[protocol.PathID]uint64),
+		costConstraint: costConstraintAvailable,
+		banditArm:      -1,
+		// the rounding of the solutions is random, replays are reproducible
+		rand: rand.New(rand.NewSource(0)),
+	}
+	if schedulerName == "BatchLinOptNoCost" {
+		sch.SchedulerName = "BatchLinOpt"
+		sch.costConstraint = false
+	}
+	rttStats := &congestion.RTTStats{}
+	sess := &session{
+		perspective: protocol.PerspectiveServer,
+		version:     protocol.VersionMP,
+		config:      &Config{},
+		scheduler:   sch,
+		logger:      utils.DefaultLogger.WithPrefix("replay"),
+		rttStats:    rttStats,
+		clock:       utils.SystemClock{},
+	}
+	sess.connectionParameters = handshake.NewConnectionParamatersManager(
+		sess.perspective,
+		sess.version,
+		protocol.DefaultMaxReceiveStreamFlowControlWindowServer,
+		protocol.DefaultMaxReceiveConnectionFlowControlWindowServer,
+		protocol.DefaultIdleTimeout,
+	)
+	sess.flowControlManager = flowcontrol.NewFlowControlManager(sess.connectionParameters, rttStats, make(map[protocol.PathID]time.Duration), sess.clock)
+	sess.streamsMap = newStreamsMap(nil, sess.perspective, sess.connectionParameters)
+	return &SchedulerReplay{sess: sess, sch: sch}, nil
+}
+
+// SelectBatch runs the scheduler on the path states and deadlines of a recorded decision.
+// It returns the path ID selected for each packet of the batch, or schedlog.NoPath.
+func (r *SchedulerReplay) SelectBatch(d *schedlog.Decision) []int {
+	r.setPaths(d.Paths)
+	// reproduce the budget of the decision, it is bounded by the default budget of a batch
+	r.sess.config.CostBudget = d.Budget
+	r.sch.totalCost = 0
+
+	selected := make([]int, len(d.Deadlines))
+	for i := range selected {
+		selected[i] = schedlog.NoPath
+	}
+	if len(r.sess.paths) == 0 {
+		return selected
+	}
+
+	if len(r.sch.SchedulerName) > 5 && r.sch.SchedulerName[:5] == "Batch" {
+		deadlines := append([]int(nil), d.Deadlines...)
+		for i, pth := range r.sch.selectBatchPath(r.sess, false, false, nil, deadlines) {
+			if pth != nil && i < len(selected) {
+				selected[i] = int(pth.pathID)
+			}
+		}
+		return selected
+	}
+	for i := range selected {
+		pth := r.sch.selectPath(r.sess, false, false, nil)
+		if pth == nil {
+			continue
+		}
+		selected[i] = int(pth.pathID)
+		pth.sentPacketHandler.(*replaySentPacketHandler).bytesInFlight += protocol.MaxPacketSize
+	}
+	return selected
+}
+
+// setPaths replaces the paths of the session by paths in the recorded states.
+// An initial path is added if needed, since the schedulers never use it when there are other paths.
+func (r *SchedulerReplay) setPaths(states []schedlog.PathState) {
+	r.sess.paths = make(map[protocol.PathID]*path)
+	for _, state := range states {
+		r.sess.paths[protocol.PathID(state.PathID)] = r.newPath(state)
+	}
+	if _, ok := r.sess.paths[protocol.InitialPathID]; !ok && len(states) > 0 {
+		r.sess.paths[protocol.InitialPathID] = r.newPath(schedlog.PathState{PathID: int(protocol.InitialPathID), Alpha: 1})
+	}
+}
+
+func (r *SchedulerReplay) newPath(state schedlog.PathState) *path {
+	rttStats := &congestion.RTTStats{}
+	if state.SmoothedRTT > 0 {
+		rttStats.UpdateRTT(time.Duration(state.SmoothedRTT*float64(time.Millisecond)), 0, time.Now())
+	}
+	pth := &path{
+		pathID:   protocol.PathID(state.PathID),
+		sess:     r.sess,
+		rttStats: rttStats,
+		sentPacketHandler: &replaySentPacketHandler{
+			congestionWindow: protocol.ByteCount(state.CongestionWindow),
+			bytesInFlight:    protocol.ByteCount(state.BytesInFlight),
+			alpha:            float32(state.Alpha),
+		},
+		cost:    state.Cost,
+		hasCost: true,
+	}
+	pth.open.Set(true)
+	return pth
+}
+
+// errNotReplayed is returned by the methods of the replayed paths that the schedulers aren't expected to call
+var errNotReplayed = errors.New("SchedulerReplay: the recorded paths don't send nor receive packets")
+
+// replaySentPacketHandler is the recorded congestion state of a path.
+// The schedulers only query the congestion state of the paths, the other methods have no effect
+// and report nothing, those changing the state of the path fail with errNotReplayed.
+type replaySentPacketHandler struct {
+	congestionWindow protocol.ByteCount
+	bytesInFlight    protocol.ByteCount
+	alpha            float32
+}
+
+var _ ackhandler.SentPacketHandler = &replaySentPacketHandler{}
+
+func (h *replaySentPacketHandler) GetCongestionWindow() protocol.ByteCount {
+	return h.congestionWindow
+}
+
+func (h *replaySentPacketHandler) GetBytesInFlight() protocol.ByteCount {
+	return h.bytesInFlight
+}
+
+// SendingAllowed is false for a path recorded without congestion window, e.g. the initial path added by setPaths
+func (h *replaySentPacketHandler) SendingAllowed() bool {
+	return h.congestionWindow > 0 && h.bytesInFlight <= h.congestionWindow
+}
+
+func (h *replaySentPacketHandler) GetPathAlpha() float32 {
+	return h.alpha
+}
+
+func (h *replaySentPacketHandler) SentPacket(*ackhandler.Packet) error { return errNotReplayed }
+func (h *replaySentPacketHandler) ReceivedAck(*wire.AckFrame, protocol.PacketNumber, time.Time) error {
+	return errNotReplayed
+}
+func (h *replaySentPacketHandler) ReceivedClosePath(*wire.ClosePathFrame, protocol.PacketNumber, time.Time) error {
+	return errNotReplayed
+}
+func (h *replaySentPacketHandler) SetInflightAsLost()                                      {}
+func (h *replaySentPacketHandler) GetStopWaitingFrame(bool) *wire.StopWaitingFrame         { return nil }
+func (h *replaySentPacketHandler) ShouldSendRetransmittablePacket() bool                   { return false }
+func (h *replaySentPacketHandler) DequeuePacketForRetransmission() *ackhandler.Packet      { return nil }
+func (h *replaySentPacketHandler) GetLeastUnacked() protocol.PacketNumber                  { return 1 }
+func (h *replaySentPacketHandler) GetAlarmTimeout() time.Time                              { return time.Time{} }
+func (h *replaySentPacketHandler) OnAlarm()                                                {}
+func (h *replaySentPacketHandler) DuplicatePacket(*ackhandler.Packet)                      {}
+func (h *replaySentPacketHandler) GetStatistics() (uint64, uint64, uint64)                 { return 0, 0, 0 }
+func (h *replaySentPacketHandler) GetDeadlineStatistics() (uint64, uint64)                 { return 0, 0 }
+func (h *replaySentPacketHandler) GetLastPackets() uint64                                  { return 0 }
+func (h *replaySentPacketHandler) GetAckedBytes() protocol.ByteCount                       { return 0 }
+func (h *replaySentPacketHandler) GetSentBytes() protocol.ByteCount                        { return 0 }
+func (h *replaySentPacketHandler) GetPathArm() int                                         { return 0 }
+func (h *replaySentPacketHandler) SetTracer(*qlog.ConnectionTracer, protocol.PathID)       {}
+func (h *replaySentPacketHandler) SetPacketOutcomeCallback(func(*ackhandler.Packet, bool)) {}
+func (h *replaySentPacketHandler) SetClock(congestion.Clock)                               {}
+func (h *replaySentPacketHandler) CalculateMeetRatio() float32                             { return 0 }
+func (h *replaySentPacketHandler) CalculateInstantMeetRatio() float32                      { return 0 }
+func (h *replaySentPacketHandler) CalculateHistoryMeetRatio(int) float32                   { return 0 }
@@ -0,0 +1,92 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/schedlog"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Scheduler replay", func() {
+	var decision *schedlog.Decision
+
+	BeforeEach(func() {
+		decision = &schedlog.Decision{
+			Deadlines: []int{20, 30, 40},
+			Paths: []schedlog.PathState{
+				{PathID: 1, SmoothedRTT: 40, Alpha: 1, CongestionWindow: 10 * 1350, RemainingCwnd: 10, Cost: 2},
+				{PathID: 3, SmoothedRTT: 20, Alpha: 1, CongestionWindow: 2 * 1350, RemainingCwnd: 2, Cost: 0.2},
+			},
+		}
+	})
+
+	It("refuses unknown schedulers", func() {
+		_, err := NewSchedulerReplay("dqnAgent")
+		Expect(err).To(MatchError("scheduler dqnAgent can't be replayed"))
+	})
+
+	It("replays the lowest RTT scheduler until the window of the fastest path is full", func() {
+		replay, err := NewSchedulerReplay("rtt")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replay.SelectBatch(decision)).To(Equal([]int{3, 3, 3}))
+		decision.Paths[1].BytesInFlight = 2 * 1350
+		Expect(replay.SelectBatch(decision)).To(Equal([]int{3, 1, 1}))
+	})
+
+	It("doesn't send on a path recorded without congestion window", func() {
+		replay, err := NewSchedulerReplay("rtt")
+		Expect(err).ToNot(HaveOccurred())
+		decision.Paths[1].CongestionWindow = 0
+		Expect(replay.SelectBatch(decision)).To(Equal([]int{1, 1, 1}))
+	})
+
+	It("replays the batch EDF scheduler on the fastest path", func() {
+		replay, err := NewSchedulerReplay("BatchEDF")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replay.SelectBatch(decision)).To(Equal([]int{3, 3, 3}))
+	})
+
+	It("replays BatchLinOpt with and without the cost constraint", func() {
+		for _, name := range []string{"BatchLinOpt", "BatchLinOptNoCost"} {
+			replay, err := NewSchedulerReplay(name)
+			Expect(err).ToNot(HaveOccurred())
+			selected := replay.SelectBatch(decision)
+			Expect(selected).To(HaveLen(3))
+			for i, pathID := range selected {
+				// the solver only selects paths on which the packet meets its deadline
+				switch pathID {
+				case 1:
+					Expect(decision.Deadlines[i]).To(BeNumerically(">=", 20))
+				case 3:
+					Expect(decision.Deadlines[i]).To(BeNumerically(">=", 10))
+				default:
+					Expect(pathID).To(Equal(schedlog.NoPath))
+				}
+			}
+		}
+	})
+
+	It("starts every batch from the recorded state", func() {
+		replay, err := NewSchedulerReplay("ecf")
+		Expect(err).ToNot(HaveOccurred())
+		first := replay.SelectBatch(decision)
+		Expect(replay.SelectBatch(decision)).To(Equal(first))
+	})
+
+	It("doesn't select paths without recorded paths", func() {
+		replay, err := NewSchedulerReplay("blest")
+		Expect(err).ToNot(HaveOccurred())
+		decision.Paths = nil
+		Expect(replay.SelectBatch(decision)).To(Equal([]int{schedlog.NoPath, schedlog.NoPath, schedlog.NoPath}))
+	})
+
+	It("fails clearly when the scheduler sends on a recorded path", func() {
+		replay, err := NewSchedulerReplay("rtt")
+		Expect(err).ToNot(HaveOccurred())
+		pth := replay.newPath(decision.Paths[0])
+		Expect(pth.sentPacketHandler.SentPacket(&ackhandler.Packet{PacketNumber: 1})).To(MatchError(errNotReplayed))
+		Expect(pth.sentPacketHandler.DequeuePacketForRetransmission()).To(BeNil())
+		sent, retransmissions, losses := pth.sentPacketHandler.GetStatistics()
+		Expect([]uint64{sent, retransmissions, losses}).To(Equal([]uint64{0, 0, 0}))
+	})
+})
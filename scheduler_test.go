@@ -0,0 +1,181 @@
+package quic
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path selection", func() {
+	// the paths are the initial path, a fast and a slow path
+	var (
+		initial, fast, slow *fakePath
+		paths               []PathView
+		queue               fakeQueue
+		sch                 *scheduler
+	)
+
+	BeforeEach(func() {
+		initial = newFakePath(protocol.InitialPathID, 30*time.Millisecond)
+		fast = newFakePath(1, 20*time.Millisecond)
+		slow = newFakePath(3, 80*time.Millisecond)
+		paths = views(initial, fast, slow)
+		queue = fakeQueue{sendWindow: protocol.DefaultTCPMSS}
+		sch = &scheduler{quotas: make(map[protocol.PathID]uint), rand: rand.New(rand.NewSource(GinkgoRandomSeed()))}
+	})
+
+	type selectionCase struct {
+		name string
+		// setup changes the paths, the queue or the scheduler from their initial state
+		setup             func()
+		hasRetransmission bool
+		// selected is the ID of the selected path, -1 for no path
+		selected int
+	}
+
+	expectSelected := func(pth PathView, selected int) {
+		if selected < 0 {
+			ExpectWithOffset(1, pth).To(BeNil())
+			return
+		}
+		ExpectWithOffset(1, pth).ToNot(BeNil())
+		ExpectWithOffset(1, pth.PathID()).To(Equal(protocol.PathID(selected)))
+	}
+
+	// the cases that all the schedulers decide the same way
+	commonCases := []selectionCase{
+		{name: "uses the only path", setup: func() { paths = views(initial) }, selected: 0},
+		{name: "doesn't use the only path when it is blocked", setup: func() { initial.blocked = true; paths = views(initial) }, selected: -1},
+		{name: "uses the only path for a retransmission", setup: func() { initial.blocked = true; paths = views(initial) }, hasRetransmission: true, selected: 0},
+		{name: "selects nothing without paths", setup: func() { paths = nil }, selected: -1},
+		{name: "selects the path with the lowest RTT", selected: 1},
+		{name: "skips a potentially failed path", setup: func() { fast.failed = true }, selected: 3},
+		{name: "selects the path sending fewer packets before the RTTs are known", setup: func() {
+			fast.srtt = 0
+			slow.srtt = 0
+			sch.quotas[1] = 5
+			sch.quotas[3] = 2
+		}, selected: 3},
+	}
+
+	run := func(selector func(hasRetransmission bool) PathView, cases []selectionCase) {
+		for i := range cases {
+			c := cases[i]
+			It(c.name, func() {
+				if c.setup != nil {
+					c.setup()
+				}
+				expectSelected(selector(c.hasRetransmission), c.selected)
+			})
+		}
+	}
+
+	Context("lowest RTT", func() {
+		run(func(hasRetransmission bool) PathView {
+			return sch.lowLatencyPath(paths, hasRetransmission, false, nil)
+		}, append(commonCases,
+			selectionCase{name: "skips a blocked path", setup: func() { fast.blocked = true }, selected: 3},
+			selectionCase{name: "uses a blocked path for a retransmission", setup: func() { fast.blocked = true }, hasRetransmission: true, selected: 1},
+			selectionCase{name: "selects nothing when all the paths are blocked", setup: func() {
+				fast.blocked = true
+				slow.blocked = true
+			}, selected: -1},
+		))
+
+		It("retransmits on a path that sent fewer packets before the RTTs are known", func() {
+			fast.srtt = 0
+			sch.quotas[1] = 3
+			sch.quotas[3] = 1
+			expectSelected(sch.lowLatencyPath(paths, true, true, fast), 3)
+		})
+
+		It("doesn't retransmit on a potentially failed path that sent fewer packets", func() {
+			fast.srtt = 0
+			slow.failed = true
+			sch.quotas[1] = 3
+			expectSelected(sch.lowLatencyPath(paths, true, true, fast), 1)
+		})
+	})
+
+	// ECF and BLEST skip the blocked paths, so the fastest path they find always allows sending.
+	// They only compare it with the slower path when it is blocked, which never happens.
+	Context("ECF", func() {
+		run(func(hasRetransmission bool) PathView {
+			return sch.ecfPath(paths, queue, hasRetransmission, false, nil)
+		}, append(commonCases,
+			selectionCase{name: "uses the slow path when the fast path is blocked", setup: func() {
+				fast.blocked = true
+				fast.srtt = 10 * time.Millisecond
+				slow.srtt = 100 * time.Millisecond
+			}, selected: 3},
+			selectionCase{name: "retransmits on the fast path", setup: func() { fast.blocked = true }, hasRetransmission: true, selected: 1},
+		))
+	})
+
+	Context("BLEST", func() {
+		run(func(hasRetransmission bool) PathView {
+			return sch.blestPath(paths, queue, hasRetransmission, false, nil)
+		}, append(commonCases,
+			selectionCase{name: "uses the slow path when the fast path is blocked", setup: func() { fast.blocked = true }, selected: 3},
+			selectionCase{name: "retransmits on the fast path", setup: func() { fast.blocked = true }, hasRetransmission: true, selected: 1},
+		))
+	})
+
+	Context("Peek", func() {
+		run(func(hasRetransmission bool) PathView {
+			return sch.peekPath(paths, queue, hasRetransmission, false, nil)
+		}, append(commonCases,
+			selectionCase{name: "falls back to the initial path when the other paths are blocked", setup: func() {
+				fast.blocked = true
+				slow.blocked = true
+			}, selected: 0},
+			selectionCase{name: "retransmits on the slow path when the fast path is blocked", setup: func() { fast.blocked = true }, hasRetransmission: true, selected: 3},
+			selectionCase{name: "keeps waiting for the fast path", setup: func() {
+				fast.blocked = true
+				sch.waiting = 1
+			}, selected: -1},
+		))
+
+		Context("with the bandit", func() {
+			// decide counts how often the scheduler waits for the fast path rather than using the slow path
+			decide := func() (waited int) {
+				fast.blocked = true
+				for i := 0; i < 1000; i++ {
+					sch.waiting = 0
+					pth := sch.peekPath(paths, queue, false, false, nil)
+					if pth == nil {
+						Expect(sch.waiting).To(Equal(uint64(1)))
+						waited++
+					} else {
+						Expect(pth).To(Equal(slow))
+					}
+				}
+				return waited
+			}
+
+			BeforeEach(func() {
+				for i := 0; i < banditDimension; i++ {
+					sch.MAaF[i][i] = 1
+					sch.MAaS[i][i] = 1
+				}
+			})
+
+			It("mostly waits when the fast path is worth it", func() {
+				for i := range sch.MbaF {
+					sch.MbaF[i] = 1
+				}
+				Expect(decide()).To(BeNumerically("~", 700, 60))
+			})
+
+			It("mostly uses the slow path when it is worth it", func() {
+				for i := range sch.MbaS {
+					sch.MbaS[i] = 1
+				}
+				Expect(decide()).To(BeNumerically("~", 100, 40))
+			})
+		})
+	})
+})
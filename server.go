@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
 	"sync"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/pcapng"
 	"github.com/lucas-clemente/quic-go/qerr"
 )
 
@@ -69,9 +71,9 @@ func ListenAddrImpl(addr string, tlsConf *tls.Config, config *Config, pconnMgrAr
 
 	if pconnMgrArg == nil {
 		// Create the pconnManager here. It will be used to start udp connections
-		pconnMgr = &pconnManager{perspective: protocol.PerspectiveServer}
+		pconnMgr = newPconnManager(protocol.PerspectiveServer, config)
 		// XXX (QDC): make this cleaner
-		pconn, err := net.ListenUDP("udp", udpAddr)
+		pconn, err := pconnMgr.network.ListenUDP(udpAddr)
 		if err != nil {
 			utils.Errorf("pconn_manager: %v", err)
 			// Format for expected consistency
@@ -93,7 +95,7 @@ func ListenAddrImpl(addr string, tlsConf *tls.Config, config *Config, pconnMgrAr
 // The tls.Config must not be nil, the quic.Config may be nil.
 func Listen(pconn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener, error) {
 	// Create the pconnManager here. It will be used to start udp connections
-	pconnMgr := &pconnManager{perspective: protocol.PerspectiveServer}
+	pconnMgr := newPconnManager(protocol.PerspectiveServer, config)
 	err := pconnMgr.setup(pconn, nil)
 	if err != nil {
 		return nil, err
@@ -119,7 +121,7 @@ func ListenImpl(pconn net.PacketConn, tlsConf *tls.Config, config *Config, pconn
 	var pconnMgr *pconnManager
 
 	if pconnMgrArg == nil {
-		pconnMgr = &pconnManager{perspective: protocol.PerspectiveServer}
+		pconnMgr = newPconnManager(protocol.PerspectiveServer, config)
 		err := pconnMgr.setup(pconn, nil)
 		if err != nil {
 			return nil, err
@@ -187,6 +189,10 @@ func populateServerConfig(config *Config) *Config {
 	if config.IdleTimeout != 0 {
 		idleTimeout = config.IdleTimeout
 	}
+	pathsFrameInterval := protocol.DefaultPathsFrameInterval
+	if config.PathsFrameInterval != 0 {
+		pathsFrameInterval = config.PathsFrameInterval
+	}
 
 	maxReceiveStreamFlowControlWindow := config.MaxReceiveStreamFlowControlWindow
 	if maxReceiveStreamFlowControlWindow == 0 {
@@ -211,6 +217,18 @@ func populateServerConfig(config *Config) *Config {
 		Epsilon:                               config.Epsilon,
 		AllowedCongestion:                     config.AllowedCongestion,
 		DumpExperiences:                       config.DumpExperiences,
+		PathCosts:                             config.PathCosts,
+		CostBudget:                            config.CostBudget,
+		PathsFrameInterval:                    pathsFrameInterval,
+		Tracer:                                config.Tracer,
+		DecisionRecorder:                      config.DecisionRecorder,
+		PacketCapture:                         config.PacketCapture,
+		KeyLogWriter:                          config.KeyLogWriter,
+		Observer:                              config.Observer,
+		DeadlineMissRateThreshold:             config.DeadlineMissRateThreshold,
+		Network:                               config.Network,
+		Clock:                                 config.Clock,
+		RandSeed:                              config.RandSeed,
 	}
 }
 
@@ -309,7 +327,9 @@ func (s *server) handlePacket(rcvRawPacket *receivedRawPacket) error {
 	hdr, err := wire.ParsePublicHeader(r, protocol.PerspectiveClient, version)
 
 	if err == wire.ErrPacketWithUnknownVersion {
-		_, err = pconn.WriteTo(wire.WritePublicReset(connID, 0, 0), remoteAddr)
+		data := wire.WritePublicReset(connID, 0, 0)
+		capturePacket(s.config.PacketCapture, time.Now(), pcapng.Outbound, pconn.LocalAddr(), remoteAddr, data, fmt.Sprintf("public reset, connection %x", connID))
+		_, err = pconn.WriteTo(data, remoteAddr)
 		return err
 	}
 	if err != nil {
@@ -323,7 +343,7 @@ func (s *server) handlePacket(rcvRawPacket *receivedRawPacket) error {
 			var pr *wire.PublicReset
 			pr, err = wire.ParsePublicReset(r)
 			if err != nil {
-				utils.Infof("Received a Public Reset for connection %x. An error occurred parsing the packet.")
+				utils.Infof("Received a Public Reset for connection %x. An error occurred parsing the packet.", hdr.ConnectionID)
 			} else {
 				utils.Infof("Received a Public Reset for connection %x, rejected packet number: 0x%x.", hdr.ConnectionID, pr.RejectedPacketNumber)
 			}
@@ -347,7 +367,9 @@ func (s *server) handlePacket(rcvRawPacket *receivedRawPacket) error {
 			return errors.New("dropping small packet with unknown version")
 		}
 		utils.Infof("Client offered version %s, sending VersionNegotiationPacket", hdr.VersionNumber)
-		_, err = pconn.WriteTo(wire.ComposeVersionNegotiation(hdr.ConnectionID, s.config.Versions), remoteAddr)
+		data := wire.ComposeVersionNegotiation(hdr.ConnectionID, s.config.Versions)
+		capturePacket(s.config.PacketCapture, time.Now(), pcapng.Outbound, pconn.LocalAddr(), remoteAddr, data, fmt.Sprintf("version negotiation, connection %x", hdr.ConnectionID))
+		_, err = pconn.WriteTo(data, remoteAddr)
 		return err
 	}
 
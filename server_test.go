@@ -67,6 +67,7 @@ func (s *mockSession) LocalAddr() net.Addr              { panic("not implemented
 func (s *mockSession) RemoteAddr() net.Addr             { return s.remoteAddr }
 func (*mockSession) Context() context.Context           { panic("not implemented") }
 func (*mockSession) GetVersion() protocol.VersionNumber { return protocol.VersionWhatever }
+func (*mockSession) ConnectionStats() ConnectionStats   { panic("not implemented") }
 
 var _ Session = &mockSession{}
 var _ NonFWSession = &mockSession{}
@@ -99,7 +100,7 @@ var _ = Describe("Server", func() {
 	)
 
 	BeforeEach(func() {
-		pconnMgr = &pconnManager{}
+		pconnMgr = &pconnManager{clock: utils.SystemClock{}}
 		conn = &mockPacketConn{addr: &net.UDPAddr{}}
 		pconnMgr.setup(conn, nil)
 		config = &Config{Versions: protocol.SupportedVersions}
@@ -125,6 +126,9 @@ var _ = Describe("Server", func() {
 			utils.LittleEndian.WriteUint32(b, protocol.VersionNumberToTag(protocol.SupportedVersions[0]))
 			firstPacket = []byte{0x09, 0xf6, 0x19, 0x86, 0x66, 0x9b, 0x9f, 0xfa, 0x4c}
 			firstPacket = append(append(firstPacket, b.Bytes()...), 0x01)
+			// the deadline, curNotSent and alpha of the multipath deadline version
+			deadline, _ := time.Time{}.MarshalBinary()
+			firstPacket = append(append(firstPacket, deadline...), 0x00, 0x00)
 		})
 
 		It("returns the address", func() {
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/rand"
 	"net"
 	"sync"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	"github.com/lucas-clemente/quic-go/qerr"
+	"github.com/lucas-clemente/quic-go/qlog"
 )
 
 type unpacker interface {
@@ -73,6 +75,8 @@ type session struct {
 	lastPathsFrameSent time.Time
 
 	streamFramer *streamFramer
+	// streamDeadlines accounts deadline meets and misses of received StreamFrames
+	streamDeadlines *streamDeadlines
 
 	flowControlManager flowcontrol.FlowControlManager
 
@@ -85,6 +89,10 @@ type session struct {
 
 	receivedPackets  chan *receivedPacket
 	sendingScheduled chan struct{}
+	// pathsFrameRequests carries the requests of the path manager for a PATHS frame, built by the run loop
+	pathsFrameRequests chan struct{}
+	// statsRequests carries the requests for a snapshot of the statistics, served by the run loop
+	statsRequests chan chan ConnectionStats
 	// closeChan is used to notify the run loop that it should terminate.
 	closeChan chan closeError
 	closeOnce sync.Once
@@ -111,6 +119,11 @@ type session struct {
 
 	connectionParameters handshake.ConnectionParametersManager
 
+	// clock gives the time of the session, the system clock unless the config sets one
+	clock Clock
+	// randSeed seeds the random source of the scheduler, the RandSeed of the config unless it is zero
+	randSeed int64
+
 	sessionCreationTime     time.Time
 	lastNetworkActivityTime time.Time
 
@@ -125,6 +138,13 @@ type session struct {
 	pathManagerLaunched bool
 
 	scheduler *scheduler
+
+	tracer *qlog.ConnectionTracer
+	logger utils.Logger
+
+	observerEvents observerQueue
+	// whether the deadline miss rate was above the threshold of the observer at the last ACK
+	deadlineMissRateAbove bool
 }
 
 var _ Session = &session{}
@@ -193,12 +213,18 @@ func (s *session) setup(
 	s.handshakeCompleteChan = make(chan error, 1)
 	s.receivedPackets = make(chan *receivedPacket, protocol.MaxSessionUnprocessedPackets)
 	s.closeChan = make(chan closeError, 1)
+	s.statsRequests = make(chan chan ConnectionStats)
 	s.sendingScheduled = make(chan struct{}, 1)
+	s.pathsFrameRequests = make(chan struct{}, 1)
 	s.undecryptablePackets = make([]*receivedPacket, 0, protocol.MaxUndecryptablePackets)
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 
-	s.timer = utils.NewTimer()
-	now := time.Now()
+	s.clock = s.config.Clock
+	if s.clock == nil {
+		s.clock = utils.SystemClock{}
+	}
+	s.timer = utils.NewClockTimer(s.clock)
+	now := s.clock.Now()
 	s.lastNetworkActivityTime = now
 	s.sessionCreationTime = now
 
@@ -210,10 +236,22 @@ func (s *session) setup(
 		s.config.IdleTimeout,
 	)
 
+	s.logger = utils.DefaultLogger.WithPrefix(fmt.Sprintf("%s %x", s.perspective, s.connectionID))
+	s.tracer = qlog.NewConnectionTracer(s.config.Tracer, s.clock, s.perspective, s.connectionID)
+
+	// the seed is reported in the ConnectionStats, so that the session can be reproduced by setting it as the RandSeed of the config
+	s.randSeed = s.config.RandSeed
+	if s.randSeed == 0 {
+		s.randSeed = time.Now().UnixNano()
+	}
+	s.logger.Infof("Random seed %d", s.randSeed)
 	s.scheduler = &scheduler{SchedulerName: s.config.SchedulerName,
 		Training:          s.config.Training,
+		Epsilon:           s.config.Epsilon,
 		AllowedCongestion: s.config.AllowedCongestion,
-		DumpExp:           s.config.DumpExperiences}
+		DumpExp:           s.config.DumpExperiences,
+		startTime:         now,
+		rand:              rand.New(rand.NewSource(s.randSeed))}
 	s.scheduler.setup()
 
 	if pconnMgr == nil && conn != nil {
@@ -232,9 +270,10 @@ func (s *session) setup(
 	}
 	// XXX (QDC): use the PathID 0 as the session RTT path
 	s.rttStats = s.paths[protocol.InitialPathID].rttStats
-	s.flowControlManager = flowcontrol.NewFlowControlManager(s.connectionParameters, s.rttStats, s.remoteRTTs)
+	s.flowControlManager = flowcontrol.NewFlowControlManager(s.connectionParameters, s.rttStats, s.remoteRTTs, s.clock)
 	s.streamsMap = newStreamsMap(s.newStream, s.perspective, s.connectionParameters)
-	s.streamFramer = newStreamFramer(s.streamsMap, s.flowControlManager)
+	s.streamFramer = newStreamFramer(s.streamsMap, s.flowControlManager, s.version)
+	s.streamDeadlines = newStreamDeadlines()
 	s.pathTimers = make(chan *path)
 
 	var err error
@@ -264,6 +303,7 @@ func (s *session) setup(
 				s.config.Versions,
 				verifySourceAddr,
 				aeadChanged,
+				s.config.KeyLogWriter,
 			)
 		}
 	} else {
@@ -288,6 +328,7 @@ func (s *session) setup(
 				aeadChanged,
 				&handshake.TransportParameters{RequestConnectionIDTruncation: s.config.RequestConnectionIDTruncation, CacheHandshake: s.config.CacheHandshake},
 				negotiatedVersions,
+				s.config.KeyLogWriter,
 			)
 		}
 	}
@@ -301,6 +342,7 @@ func (s *session) setup(
 		s.streamFramer,
 		s.perspective,
 		s.version,
+		s.logger,
 	)
 	s.unpacker = &packetUnpacker{aead: s.cryptoSetup, version: s.version}
 
@@ -349,6 +391,9 @@ runLoop:
 				s.pathManager.runClosed <- struct{}{}
 			}
 			break runLoop
+		case req := <-s.statsRequests:
+			req <- s.connectionStats()
+			continue
 		case <-s.timer.Chan():
 			s.timer.SetRead()
 			// We do all the interesting stuff after the switch statement, so
@@ -385,7 +430,7 @@ runLoop:
 			}
 		}
 
-		now := time.Now()
+		now := s.clock.Now()
 		if timerPth != nil {
 			if timeout := timerPth.sentPacketHandler.GetAlarmTimeout(); !timeout.IsZero() && timeout.Before(now) {
 				// This could cause packets to be retransmitted, so check it before trying
@@ -403,17 +448,25 @@ runLoop:
 			}
 		}
 
-		if s.config.KeepAlive && s.handshakeComplete && time.Since(s.lastNetworkActivityTime) >= s.idleTimeout()/2 {
+		if s.config.KeepAlive && s.handshakeComplete && now.Sub(s.lastNetworkActivityTime) >= s.idleTimeout()/2 {
 			// send the PING frame since there is no activity in the session
 			s.pathsLock.RLock()
 			// XXX (QDC): send PING over all paths, but is it really needed/useful?
-			for _, tmpPth := range s.paths {
+			for _, tmpPth := range s.sortedPaths() {
 				s.packer.QueueControlFrame(&wire.PingFrame{}, tmpPth)
 			}
 			s.pathsLock.RUnlock()
 			s.keepAlivePingSent = true
 		}
 
+		// the requests aren't a case of the select above, whose random choice between ready cases would
+		// make the simulations irreproducible
+		select {
+		case <-s.pathsFrameRequests:
+			s.schedulePathsFrame()
+		default:
+		}
+
 		if err := s.sendPacket(); err != nil {
 			s.closeLocal(err)
 		}
@@ -427,8 +480,8 @@ runLoop:
 			s.closeLocal(qerr.Error(qerr.NetworkIdleTimeout, "No recent network activity."))
 		}
 
-		// Check if we should send a PATHS frame (currently hardcoded at 200 ms) only when at least one stream is open (not counting streams 1 and 3 never closed...)
-		if s.handshakeComplete && s.version >= protocol.VersionMP && now.Sub(s.lastPathsFrameSent) >= 200*time.Millisecond && len(s.streamsMap.openStreams) > 2 {
+		// Check if we should send a PATHS frame only when at least one stream is open (not counting streams 1 and 3 never closed...)
+		if s.handshakeComplete && s.version >= protocol.VersionMP && now.Sub(s.lastPathsFrameSent) >= s.pathsFrameInterval() && len(s.streamsMap.openStreams) > 2 {
 			s.schedulePathsFrame()
 		}
 
@@ -441,6 +494,7 @@ runLoop:
 		s.handshakeCompleteChan <- closeErr.err
 		s.handshakeChan <- handshakeEvent{err: closeErr.err}
 	}
+	s.reportDeadlineMisses()
 	s.handleCloseError(closeErr)
 	defer s.ctxCancel()
 	return closeErr.err
@@ -473,6 +527,21 @@ func (s *session) idleTimeout() time.Duration {
 	return s.connectionParameters.GetIdleConnectionStateLifetime()
 }
 
+func (s *session) pathsFrameInterval() time.Duration {
+	if s.config.PathsFrameInterval == 0 {
+		return protocol.DefaultPathsFrameInterval
+	}
+	return s.config.PathsFrameInterval
+}
+
+// availableBudget returns the cost budget the host is still willing to spend on a path
+func (s *session) availableBudget(pth *path) float64 {
+	if s.config.CostBudget <= 0 || pth.getCost() == 0 {
+		return math.Inf(1)
+	}
+	return math.Max(0, s.config.CostBudget-s.scheduler.totalCost)
+}
+
 func (s *session) handlePacketImpl(p *receivedPacket) error {
 	if s.perspective == protocol.PerspectiveClient {
 		diversificationNonce := p.publicHeader.DiversificationNonce
@@ -483,7 +552,7 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 
 	if p.rcvTime.IsZero() {
 		// To simplify testing
-		p.rcvTime = time.Now()
+		p.rcvTime = s.clock.Now()
 	}
 
 	s.lastNetworkActivityTime = p.rcvTime
@@ -517,7 +586,7 @@ func (s *session) handleFrames(fs []wire.Frame, p *path) error {
 			//if !isFloat32Zero(historyMeetRatio) {
 			//	fmt.Println("PathID:", p.pathID, ".HistoryMeetRatio:", historyMeetRatio)
 			//}
-			fmt.Println("wire.AckFrame:", frame)
+			s.logger.Debugf("wire.AckFrame: %#v", frame)
 			err = s.handleAckFrame(frame)
 		case *wire.ConnectionCloseFrame:
 			s.closeRemote(qerr.Error(frame.ErrorCode, frame.ReasonPhrase))
@@ -541,17 +610,31 @@ func (s *session) handleFrames(fs []wire.Frame, p *path) error {
 			}
 		case *wire.ClosePathFrame:
 			s.handleClosePathFrame(frame)
+		case *wire.PathCostFrame:
+			if s.pathManager != nil {
+				s.pathManager.handlePathCostFrame(frame)
+			}
 		case *wire.PathsFrame:
 			// So far, do nothing
 			s.pathsLock.RLock()
 			for i := 0; i < int(frame.NumPaths); i++ {
 				s.remoteRTTs[frame.PathIDs[i]] = frame.RemoteRTTs[i]
 				if frame.RemoteRTTs[i] >= 30*time.Minute {
-					// Path is potentially failed
-					s.paths[frame.PathIDs[i]].potentiallyFailed.Set(true)
+					// Path is potentially failed, the peer may know of paths this side didn't create yet
+					if pth, ok := s.paths[frame.PathIDs[i]]; ok {
+						pth.setPotentiallyFailed()
+					}
 				}
 			}
 			s.pathsLock.RUnlock()
+		case *wire.PathQualityFrame:
+			s.pathsLock.Lock()
+			for i := range frame.Paths {
+				if pth, ok := s.paths[frame.Paths[i].PathID]; ok {
+					pth.remoteQuality = &frame.Paths[i]
+				}
+			}
+			s.pathsLock.Unlock()
 		default:
 			return errors.New("Session BUG: unexpected frame type")
 		}
@@ -562,7 +645,7 @@ func (s *session) handleFrames(fs []wire.Frame, p *path) error {
 				// Can happen e.g. when packets thought missing arrive late
 			case errRstStreamOnInvalidStream:
 				// Can happen when RST_STREAMs arrive early or late (?)
-				utils.Errorf("Ignoring error in session: %s", err.Error())
+				s.logger.Errorf("Ignoring error in session: %s", err.Error())
 			case errWindowUpdateOnClosedStream:
 				// Can happen when we already sent the last StreamFrame with the FinBit, but the client already sent a WindowUpdate for this Stream
 			default:
@@ -599,16 +682,13 @@ func (s *session) handleStreamFrame(frame *wire.StreamFrame) error {
 	if frame.FinBit {
 		// Receiving end of stream, print stats about it
 		// Print client statistics about its paths
-		s.pathsLock.RLock()
-		utils.Infof("Info for stream %x of %x", frame.StreamID, s.connectionID)
-		for pathID, pth := range s.paths {
-			sntPkts, sntRetrans, sntLost := pth.sentPacketHandler.GetStatistics()
-			//rcvPkts := pth.receivedPacketHandler.GetStatistics()
-			rcvPkts, hasDeadlinePkts, meetDeadlinePkts := pth.receivedPacketHandler.GetStatistics()
-			utils.Infof("Path %x: sent %d retrans %d lost %d; rcv %d", pathID, sntPkts, sntRetrans, sntLost, rcvPkts)
-			utils.Infof("hasDeadlinePkts %d; meetDeadlinePkts %d", hasDeadlinePkts, meetDeadlinePkts)
+		s.logger.Infof("Info for stream %x of %x", frame.StreamID, s.connectionID)
+		for _, ps := range s.connectionStats().Paths {
+			s.logger.Infof("Path %x: sent %d retrans %d lost %d; rcv %d", ps.PathID, ps.PacketsSent, ps.Retransmissions, ps.PacketsLost, ps.PacketsReceived)
+			s.logger.Infof("hasDeadlinePkts %d; meetDeadlinePkts %d", ps.ReceivedWithDeadline, ps.ReceivedMeetDeadline)
 		}
-		s.pathsLock.RUnlock()
+		hasDeadlineFrames, meetDeadlineFrames := s.streamDeadlines.GetStatistics(frame.StreamID)
+		s.logger.Infof("Stream %x: hasDeadlineFrames %d; meetDeadlineFrames %d", frame.StreamID, hasDeadlineFrames, meetDeadlineFrames)
 	}
 	return str.AddStreamFrame(frame)
 }
@@ -647,6 +727,9 @@ func (s *session) handleAckFrame(frame *wire.AckFrame) error {
 		// Update the session RTT, which comes to take the max RTT on all paths
 		s.rttStats.UpdateSessionRTT(pth.rttStats.SmoothedRTT())
 	}
+	if err == nil {
+		s.checkDeadlineMissRate()
+	}
 	return err
 }
 
@@ -692,9 +775,14 @@ func (s *session) closePath(pthID protocol.PathID, sendClosePathFrame bool) erro
 	return nil
 }
 
+// schedulePathsFrame queues the PATHS frame, and the PATH_QUALITY frame in versions with deadlines.
+// It must be called from the run loop, other goroutines use requestPathsFrame.
 func (s *session) schedulePathsFrame() {
-	s.lastPathsFrameSent = time.Now()
+	s.lastPathsFrameSent = s.clock.Now()
 	s.streamFramer.AddPathsFrameForTransmission(s)
+	if s.version.UsesDeadlines() {
+		s.streamFramer.AddPathQualityFrameForTransmission(s)
+	}
 }
 
 func (s *session) closePaths() {
@@ -755,9 +843,9 @@ func (s *session) handleCloseError(closeErr closeError) error {
 	}
 	// Don't log 'normal' reasons
 	if quicErr.ErrorCode == qerr.PeerGoingAway || quicErr.ErrorCode == qerr.NetworkIdleTimeout {
-		utils.Infof("Closing connection %x", s.connectionID)
+		s.logger.Infof("Closing connection %x", s.connectionID)
 	} else {
-		utils.Errorf("Closing session with error: %s", closeErr.err.Error())
+		s.logger.Errorf("Closing session with error: %s", closeErr.err.Error())
 	}
 
 	s.streamsMap.CloseWithError(quicErr)
@@ -791,24 +879,73 @@ func (s *session) sendPackedPacket(packet *packedPacket, pth *path) error {
 	defer putPacketBuffer(packet.raw)
 	//czy
 	//fmt.Println("In sendPackedPacket, packet.raw:", packet.raw)
-	fmt.Println("current select path:", pth.pathID)
+	s.logger.Debugf("current select path: %d", pth.pathID)
 	err := pth.sentPacketHandler.SentPacket(&ackhandler.Packet{
 		PacketNumber:    packet.number,
 		Frames:          packet.frames,
 		Length:          protocol.ByteCount(len(packet.raw)),
 		EncryptionLevel: packet.encryptionLevel,
 		//czy: add deadline
-		Deadline: packet.m_deadline,
+		Deadline:    packet.m_deadline,
+		Deferred:    packet.deferred,
+		HeldForCost: packet.heldForCost,
 	})
 	if err != nil {
 		return err
 	}
 	pth.sentPacket <- struct{}{}
 
+	s.tracer.Trace(&qlog.PacketSent{
+		PathID:       pth.pathID,
+		PacketNumber: packet.number,
+		Length:       protocol.ByteCount(len(packet.raw)),
+		Frames:       packet.frames,
+		Deadline:     packet.m_deadline,
+		CurNotSent:   packet.curNotSent,
+		Alpha:        packet.alpha,
+		Arm:          pth.sentPacketHandler.GetPathArm(),
+		Cost:         pth.getCost(),
+	})
+
 	// fmt.Println("sendPackedPacket")
 	s.logPacket(packet, pth.pathID)
+	s.captureSent(pth.conn, packet.raw, func() string { return s.scheduler.packetComment(packet, pth) })
 	//czy: only write raw data, where is the PacketNumber and Packet head information
-	return pth.conn.Write(packet.raw)
+	if err := pth.conn.Write(packet.raw); err != nil {
+		return &pathWriteError{pth: pth, packetNumber: packet.number, err: err}
+	}
+	return nil
+}
+
+// A pathWriteError is returned by sendPackedPacket when the socket of the path fails to write the packet,
+// e.g. when its interface goes away. The packet was registered as sent before the write.
+type pathWriteError struct {
+	pth          *path
+	packetNumber protocol.PacketNumber
+	err          error
+}
+
+func (e *pathWriteError) Error() string {
+	return e.err.Error()
+}
+
+// failPath handles the error of a sendPackedPacket.
+// If it is a pathWriteError and another path can carry the data, the path fails rather than the session:
+// the packet counts as sent, and is retransmitted once it is declared lost. Otherwise it returns the error.
+// Lock of s.paths must be held
+func (s *session) failPath(err error) error {
+	wErr, ok := err.(*pathWriteError)
+	if !ok {
+		return err
+	}
+	for _, p := range s.paths {
+		if p != wErr.pth && p.open.Get() && !p.potentiallyFailed.Get() {
+			s.logger.Infof("Path %x failed to send packet 0x%x: %s", wErr.pth.pathID, wErr.packetNumber, wErr.err)
+			wErr.pth.setPotentiallyFailed()
+			return nil
+		}
+	}
+	return wErr.err
 }
 
 func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
@@ -822,7 +959,11 @@ func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
 	}
 	s.logPacket(packet, protocol.InitialPathID)
 	// XXX (QDC): seems reasonable to send on pathID 0, but this can change
-	return s.paths[protocol.InitialPathID].conn.Write(packet.raw)
+	conn := s.paths[protocol.InitialPathID].conn
+	s.captureSent(conn, packet.raw, func() string {
+		return fmt.Sprintf("path %d, packet %d, connection close: %s", protocol.InitialPathID, packet.number, quicErr.ErrorCode)
+	})
+	return conn.Write(packet.raw)
 }
 
 func (s *session) sendPing(pth *path) error {
@@ -837,14 +978,14 @@ func (s *session) sendPing(pth *path) error {
 }
 
 func (s *session) logPacket(packet *packedPacket, pathID protocol.PathID) {
-	if !utils.Debug() {
+	if !s.logger.Debug() {
 		// We don't need to allocate the slices for calling the format functions
 		return
 	}
-	utils.Debugf("Time: %d", time.Since(s.sessionCreationTime).Nanoseconds()/1000000)
-	utils.Debugf(("Path: %d, Cong: %d"), pathID, s.paths[pathID].sentPacketHandler.GetCongestionWindow())
-	utils.Debugf(("Path: %d, BytesInFlight: %d"), pathID, s.paths[pathID].sentPacketHandler.GetBytesInFlight())
-	utils.Debugf("-> Sending packet 0x%x (%d bytes) for connection %x on path %x, %s", packet.number, len(packet.raw), s.connectionID, pathID, packet.encryptionLevel)
+	s.logger.Debugf("Time: %d", s.clock.Now().Sub(s.sessionCreationTime).Nanoseconds()/1000000)
+	s.logger.Debugf(("Path: %d, Cong: %d"), pathID, s.paths[pathID].sentPacketHandler.GetCongestionWindow())
+	s.logger.Debugf(("Path: %d, BytesInFlight: %d"), pathID, s.paths[pathID].sentPacketHandler.GetBytesInFlight())
+	s.logger.Debugf("-> Sending packet 0x%x (%d bytes) for connection %x on path %x, %s", packet.number, len(packet.raw), s.connectionID, pathID, packet.encryptionLevel)
 	for _, frame := range packet.frames {
 		wire.LogFrame(frame, true)
 	}
@@ -894,7 +1035,9 @@ func (s *session) newStream(id protocol.StreamID) *stream {
 	} else {
 		s.flowControlManager.NewStream(id, true)
 	}
-	return newStream(id, s.scheduleSending, s.queueResetStreamFrame, s.flowControlManager)
+	str := newStream(id, s.scheduleSending, s.queueResetStreamFrame, s.flowControlManager)
+	str.clock = s.clock
+	return str
 }
 
 // garbageCollectStreams goes through all streams and removes EOF'ed streams
@@ -914,9 +1057,12 @@ func (s *session) garbageCollectStreams() {
 }
 
 func (s *session) sendPublicReset(rejectedPacketNumber protocol.PacketNumber) error {
-	utils.Infof("Sending public reset for connection %x, packet number %d", s.connectionID, rejectedPacketNumber)
+	s.logger.Infof("Sending public reset for connection %x, packet number %d", s.connectionID, rejectedPacketNumber)
 	// XXX: seems reasonable to send on the pathID 0, but this can change
-	return s.paths[protocol.InitialPathID].conn.Write(wire.WritePublicReset(s.connectionID, rejectedPacketNumber, 0))
+	conn := s.paths[protocol.InitialPathID].conn
+	data := wire.WritePublicReset(s.connectionID, rejectedPacketNumber, 0)
+	s.captureSent(conn, data, func() string { return fmt.Sprintf("public reset, connection %x", s.connectionID) })
+	return conn.Write(data)
 }
 
 // scheduleSending signals that we have data for sending
@@ -927,21 +1073,30 @@ func (s *session) scheduleSending() {
 	}
 }
 
+// requestPathsFrame asks the run loop to schedule the PATHS frame
+func (s *session) requestPathsFrame() {
+	select {
+	case s.pathsFrameRequests <- struct{}{}:
+	default:
+	}
+	s.scheduleSending()
+}
+
 func (s *session) tryQueueingUndecryptablePacket(p *receivedPacket) {
 	if s.handshakeComplete {
-		utils.Debugf("Received undecryptable packet from %s after the handshake: %#v, %d bytes data", p.remoteAddr.String(), p.publicHeader, len(p.data))
+		s.logger.Debugf("Received undecryptable packet from %s after the handshake: %#v, %d bytes data", p.remoteAddr.String(), p.publicHeader, len(p.data))
 		return
 	}
 	if len(s.undecryptablePackets)+1 > protocol.MaxUndecryptablePackets {
 		// if this is the first time the undecryptablePackets runs full, start the timer to send a Public Reset
 		if s.receivedTooManyUndecrytablePacketsTime.IsZero() {
-			s.receivedTooManyUndecrytablePacketsTime = time.Now()
+			s.receivedTooManyUndecrytablePacketsTime = s.clock.Now()
 			s.maybeResetTimer()
 		}
-		utils.Infof("Dropping undecrytable packet 0x%x (undecryptable packet queue full)", p.publicHeader.PacketNumber)
+		s.logger.Infof("Dropping undecrytable packet 0x%x (undecryptable packet queue full)", p.publicHeader.PacketNumber)
 		return
 	}
-	utils.Infof("Queueing packet 0x%x for later decryption", p.publicHeader.PacketNumber)
+	s.logger.Infof("Queueing packet 0x%x for later decryption", p.publicHeader.PacketNumber)
 	s.undecryptablePackets = append(s.undecryptablePackets, p)
 }
 
@@ -952,6 +1107,17 @@ func (s *session) tryDecryptingQueuedPackets() {
 	s.undecryptablePackets = s.undecryptablePackets[:0]
 }
 
+// peerAddressChanged retransmits the flow control frames when the peer moved to a new address on pth, e.g. after a NAT rebinding.
+// The frames sent to the old address may have been dropped, and a peer blocked by flow control would wait for them forever.
+func (s *session) peerAddressChanged(pth *path) {
+	s.logger.Infof("Path %x: peer moved to %s", pth.pathID, pth.conn.RemoteAddr())
+	for _, f := range s.getWindowUpdateFrames(true) {
+		s.packer.QueueControlFrame(f, pth)
+	}
+	s.streamFramer.QueueBlockedFrames()
+	s.scheduleSending()
+}
+
 func (s *session) getWindowUpdateFrames(force bool) []*wire.WindowUpdateFrame {
 	updates := s.flowControlManager.GetWindowUpdates(force)
 	res := make([]*wire.WindowUpdateFrame, len(updates))
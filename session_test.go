@@ -15,20 +15,24 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/lucas-clemente/quic-go/ackhandler"
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/crypto"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	"github.com/lucas-clemente/quic-go/internal/mocks/mocks_fc"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	"github.com/lucas-clemente/quic-go/qerr"
+	"github.com/lucas-clemente/quic-go/qlog"
 )
 
 type mockConnection struct {
 	remoteAddr net.Addr
 	localAddr  net.Addr
 	written    chan []byte
+	writeErr   error
 }
 
 func newMockConnection() *mockConnection {
@@ -39,6 +43,9 @@ func newMockConnection() *mockConnection {
 }
 
 func (m *mockConnection) Write(p []byte) error {
+	if m.writeErr != nil {
+		return m.writeErr
+	}
 	b := make([]byte, len(p))
 	copy(b, p)
 	select {
@@ -101,7 +108,7 @@ func (h *mockSentPacketHandler) ShouldSendRetransmittablePacket() bool {
 	h.shouldSendRetransmittablePacket = false
 	return b
 }
-func (h *mockSentPacketHandler) GetStatistics() (uint64, uint64, uint64) { panic("not implemented") }
+func (h *mockSentPacketHandler) GetStatistics() (uint64, uint64, uint64) { return 0, 0, 0 }
 
 func (h *mockSentPacketHandler) GetStopWaitingFrame(force bool) *wire.StopWaitingFrame {
 	h.requestedStopWaiting = true
@@ -144,10 +151,8 @@ func (m *mockReceivedPacketHandler) ReceivedPacket(packetNumber protocol.PacketN
 func (m *mockReceivedPacketHandler) SetLowerLimit(protocol.PacketNumber) {
 	panic("not implemented")
 }
-func (m *mockReceivedPacketHandler) GetAlarmTimeout() time.Time { return m.ackAlarm }
-func (m *mockReceivedPacketHandler) GetStatistics() uint64 {
-	panic("not implemented")
-}
+func (m *mockReceivedPacketHandler) GetAlarmTimeout() time.Time              { return m.ackAlarm }
+func (m *mockReceivedPacketHandler) GetStatistics() (uint64, uint64, uint64) { return 0, 0, 0 }
 
 func (m *mockReceivedPacketHandler) GetClosePathFrame() *wire.ClosePathFrame {
 	panic("not implemented")
@@ -186,6 +191,7 @@ var _ = Describe("Session", func() {
 			_ []protocol.VersionNumber,
 			_ func(net.Addr, *Cookie) bool,
 			aeadChangedP chan<- protocol.EncryptionLevel,
+			_ io.Writer,
 		) (handshake.CryptoSetup, error) {
 			aeadChanged = aeadChangedP
 			return cryptoSetup, nil
@@ -241,6 +247,7 @@ var _ = Describe("Session", func() {
 				_ []protocol.VersionNumber,
 				cookieFunc func(net.Addr, *Cookie) bool,
 				_ chan<- protocol.EncryptionLevel,
+				_ io.Writer,
 			) (handshake.CryptoSetup, error) {
 				cookieVerify = cookieFunc
 				return cryptoSetup, nil
@@ -704,6 +711,45 @@ var _ = Describe("Session", func() {
 		Expect(sess.GetVersion()).To(Equal(protocol.VersionNumber(4242)))
 	})
 
+	It("picks a seed and reports it in the statistics", func() {
+		Expect(sess.randSeed).ToNot(BeZero())
+		Expect(sess.connectionStats().RandSeed).To(Equal(sess.randSeed))
+		pSess, _, err := newSession(
+			mconn,
+			nil,
+			true, // Try doing multipath
+			protocol.Version37,
+			0,
+			scfg,
+			nil,
+			populateServerConfig(&Config{RandSeed: 42}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pSess.(*session).connectionStats().RandSeed).To(BeEquivalentTo(42))
+	})
+
+	It("only sends PathQualityFrames in versions with deadlines", func() {
+		sess.version = protocol.VersionMP
+		sess.schedulePathsFrame()
+		Expect(sess.streamFramer.PopPathsFrame()).ToNot(BeNil())
+		Expect(sess.streamFramer.PopPathQualityFrame()).To(BeNil())
+		sess.version = protocol.VersionMPDeadline
+		sess.schedulePathsFrame()
+		Expect(sess.streamFramer.PopPathsFrame()).ToNot(BeNil())
+		Expect(sess.streamFramer.PopPathQualityFrame()).ToNot(BeNil())
+	})
+
+	It("leaves the PATHS frames requested by other goroutines to the run loop", func() {
+		sess.version = protocol.VersionMPDeadline
+		sess.requestPathsFrame()
+		sess.requestPathsFrame()
+		Expect(sess.streamFramer.PopPathsFrame()).To(BeNil())
+		Expect(sess.streamFramer.PopPathQualityFrame()).To(BeNil())
+		Expect(sess.pathsFrameRequests).To(Receive())
+		Expect(sess.pathsFrameRequests).ToNot(Receive())
+		Expect(sess.sendingScheduled).To(Receive())
+	})
+
 	Context("waiting until the handshake completes", func() {
 		It("waits until the handshake is complete", func(done Done) {
 			go sess.run()
@@ -929,6 +975,16 @@ var _ = Describe("Session", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("counts the bytes of the received packets, duplicates included", func() {
+			hdr.PacketNumber = 5
+			hdr.Raw = make([]byte, 10)
+			err := sess.handlePacketImpl(&receivedPacket{publicHeader: hdr, data: make([]byte, 100)})
+			Expect(err).ToNot(HaveOccurred())
+			err = sess.handlePacketImpl(&receivedPacket{publicHeader: hdr, data: make([]byte, 100)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.connectionStats().Paths[0].BytesReceived).To(BeEquivalentTo(220))
+		})
+
 		Context("updating the remote address", func() {
 			It("sets the remote address", func() {
 				remoteIP := &net.IPAddr{IP: net.IPv4(192, 168, 0, 100)}
@@ -978,6 +1034,30 @@ var _ = Describe("Session", func() {
 				// XXX (QDC): ugly...
 				Expect(sess.paths[0].conn.(*mockConnection).remoteAddr).To(Equal(remoteIP))
 			})
+
+			It("retransmits the WINDOW_UPDATEs when the peer moves to a new address", func() {
+				p := receivedPacket{
+					remoteAddr:   &net.IPAddr{IP: net.IPv4(192, 168, 0, 100)},
+					publicHeader: &wire.PublicHeader{PacketNumber: 1337},
+				}
+				err := sess.handlePacketImpl(&p)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sess.packer.controlFrames).To(ContainElement(&wire.WindowUpdateFrame{
+					StreamID:   0,
+					ByteOffset: protocol.ReceiveConnectionFlowControlWindow,
+				}))
+				Expect(sess.sendingScheduled).To(Receive())
+			})
+
+			It("doesn't retransmit the WINDOW_UPDATEs when the peer keeps its address", func() {
+				p := receivedPacket{
+					remoteAddr:   sess.paths[0].conn.RemoteAddr(),
+					publicHeader: &wire.PublicHeader{PacketNumber: 1337},
+				}
+				err := sess.handlePacketImpl(&p)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sess.packer.controlFrames).To(BeEmpty())
+			})
 		})
 	})
 
@@ -1026,6 +1106,50 @@ var _ = Describe("Session", func() {
 			Expect(mconn.written).To(Receive(ContainSubstring(string([]byte{0x04, 0x05, 0, 0, 0}))))
 		})
 
+		Context("when writing fails", func() {
+			var packet *packedPacket
+
+			BeforeEach(func() {
+				mconn.writeErr = errors.New("network unreachable")
+				packet = &packedPacket{number: 1, raw: getPacketBuffer()[:20]}
+			})
+
+			It("returns a pathWriteError", func() {
+				err := sess.sendPackedPacket(packet, sess.paths[0])
+				Expect(err).To(BeAssignableToTypeOf(&pathWriteError{}))
+				Expect(err.(*pathWriteError).pth).To(Equal(sess.paths[0]))
+				Expect(err).To(MatchError("network unreachable"))
+			})
+
+			It("fails the path rather than the session if another path is usable", func() {
+				sess.paths[1] = newTestPath(sess, 1, 0)
+				err := sess.failPath(sess.sendPackedPacket(packet, sess.paths[0]))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sess.paths[0].potentiallyFailed.Get()).To(BeTrue())
+			})
+
+			It("returns the error if no other path is usable", func() {
+				sess.paths[1] = newTestPath(sess, 1, 0)
+				sess.paths[1].potentiallyFailed.Set(true)
+				err := sess.failPath(sess.sendPackedPacket(packet, sess.paths[0]))
+				Expect(err).To(MatchError("network unreachable"))
+				Expect(err).ToNot(BeAssignableToTypeOf(&pathWriteError{}))
+			})
+
+			It("returns the error on the only path", func() {
+				err := sess.failPath(sess.sendPackedPacket(packet, sess.paths[0]))
+				Expect(err).To(MatchError("network unreachable"))
+				Expect(sess.paths[0].potentiallyFailed.Get()).To(BeFalse())
+			})
+
+			It("returns the other errors", func() {
+				sess.paths[1] = newTestPath(sess, 1, 0)
+				err := sess.failPath(errors.New("packing failed"))
+				Expect(err).To(MatchError("packing failed"))
+				Expect(sess.paths[0].potentiallyFailed.Get()).To(BeFalse())
+			})
+		})
+
 		It("sends public reset", func() {
 			err := sess.sendPublicReset(1)
 			Expect(err).NotTo(HaveOccurred())
@@ -1748,13 +1872,14 @@ var _ = Describe("Client Session", func() {
 			aeadChangedP chan<- protocol.EncryptionLevel,
 			_ *handshake.TransportParameters,
 			_ []protocol.VersionNumber,
+			_ io.Writer,
 		) (handshake.CryptoSetup, error) {
 			aeadChanged = aeadChangedP
 			return cryptoSetup, nil
 		}
 
 		mconn = newMockConnection()
-		pconnMgr = &pconnManager{}
+		pconnMgr = &pconnManager{clock: utils.SystemClock{}}
 		sessP, _, err := newClientSession(
 			mconn,
 			pconnMgr,
@@ -1804,3 +1929,27 @@ var _ = Describe("Client Session", func() {
 		close(done)
 	})
 })
+
+func (h *mockSentPacketHandler) GetDeadlineStatistics() (uint64, uint64) { return 0, 0 }
+func (h *mockSentPacketHandler) GetLastPackets() uint64                  { return 0 }
+func (h *mockSentPacketHandler) GetAckedBytes() protocol.ByteCount       { return 0 }
+func (h *mockSentPacketHandler) GetSentBytes() protocol.ByteCount        { return 0 }
+func (h *mockSentPacketHandler) GetCongestionWindow() protocol.ByteCount {
+	return protocol.MaxByteCount
+}
+func (h *mockSentPacketHandler) GetBytesInFlight() protocol.ByteCount                    { return 0 }
+func (h *mockSentPacketHandler) GetPathAlpha() float32                                   { return 0 }
+func (h *mockSentPacketHandler) GetPathArm() int                                         { return 0 }
+func (h *mockSentPacketHandler) SetTracer(*qlog.ConnectionTracer, protocol.PathID)       {}
+func (h *mockSentPacketHandler) SetPacketOutcomeCallback(func(*ackhandler.Packet, bool)) {}
+func (h *mockSentPacketHandler) SetClock(congestion.Clock)                               {}
+func (h *mockSentPacketHandler) CalculateMeetRatio() float32                             { return 0 }
+func (h *mockSentPacketHandler) CalculateInstantMeetRatio() float32                      { return 0 }
+func (h *mockSentPacketHandler) CalculateHistoryMeetRatio(int) float32                   { return 0 }
+
+func (m *mockReceivedPacketHandler) StatisticPacketMeet(*wire.PublicHeader, time.Time) error {
+	return nil
+}
+func (m *mockReceivedPacketHandler) UpdateCurNotSent(uint16)   {}
+func (m *mockReceivedPacketHandler) UpdateAlpha(uint16)        {}
+func (m *mockReceivedPacketHandler) SetClock(congestion.Clock) {}
@@ -55,6 +55,12 @@ type stream struct {
 	rstSent        utils.AtomicBool
 	writeChan      chan struct{}
 	writeDeadline  time.Time
+	// deliveryBudget is the time the peer is given to receive data after it was written
+	deliveryBudget time.Duration
+	// dataDeadline is the delivery deadline of the data currently being written
+	dataDeadline time.Time
+	// clock gives the time the delivery deadlines start from, the read and write deadlines follow the system clock
+	clock Clock
 
 	flowControlManager flowcontrol.FlowControlManager
 }
@@ -82,6 +88,7 @@ func newStream(StreamID protocol.StreamID,
 		frameQueue:         newStreamFrameSorter(),
 		readChan:           make(chan struct{}, 1),
 		writeChan:          make(chan struct{}, 1),
+		clock:              utils.SystemClock{},
 	}
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 	return s
@@ -197,6 +204,11 @@ func (s *stream) Write(p []byte) (int, error) {
 
 	s.dataForWriting = make([]byte, len(p))
 	copy(s.dataForWriting, p)
+	if s.deliveryBudget > 0 {
+		s.dataDeadline = s.clock.Now().Add(s.deliveryBudget)
+	} else {
+		s.dataDeadline = time.Time{}
+	}
 	s.onData()
 
 	var err error
@@ -262,6 +274,13 @@ func (s *stream) getDataForWriting(maxBytes protocol.ByteCount) []byte {
 	return ret
 }
 
+// getDataDeadline returns the delivery deadline of the data returned by getDataForWriting
+func (s *stream) getDataDeadline() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dataDeadline
+}
+
 // Close implements io.Closer
 func (s *stream) Close() error {
 	s.finishedWriting.Set(true)
@@ -351,6 +370,12 @@ func (s *stream) SetDeadline(t time.Time) error {
 	return nil
 }
 
+func (s *stream) SetDeliveryDeadline(d time.Duration) {
+	s.mutex.Lock()
+	s.deliveryBudget = d
+	s.mutex.Unlock()
+}
+
 // CloseRemote makes the stream receive a "virtual" FIN stream frame at a given offset
 func (s *stream) CloseRemote(offset protocol.ByteCount) {
 	s.AddStreamFrame(&wire.StreamFrame{FinBit: true, Offset: offset})
@@ -0,0 +1,58 @@
+package quic
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+type streamDeadlineStat struct {
+	hasDeadline  uint64
+	meetDeadline uint64
+}
+
+// streamDeadlines counts, for every stream, the received StreamFrames that had a deadline and the ones that met it.
+// Every StreamFrame is accounted against its own deadline, not the one of the packet carrying it,
+// which is the earliest deadline of the frames in that packet.
+type streamDeadlines struct {
+	mutex sync.Mutex
+	stats map[protocol.StreamID]*streamDeadlineStat
+}
+
+func newStreamDeadlines() *streamDeadlines {
+	return &streamDeadlines{stats: make(map[protocol.StreamID]*streamDeadlineStat)}
+}
+
+// received accounts the StreamFrames with a deadline of a packet received at rcvTime
+func (d *streamDeadlines) received(frames []wire.Frame, rcvTime time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, f := range frames {
+		sf, ok := f.(*wire.StreamFrame)
+		if !ok || sf.Deadline.IsZero() {
+			continue
+		}
+		stat, ok := d.stats[sf.StreamID]
+		if !ok {
+			stat = &streamDeadlineStat{}
+			d.stats[sf.StreamID] = stat
+		}
+		stat.hasDeadline++
+		if sf.Deadline.After(rcvTime) {
+			stat.meetDeadline++
+		}
+	}
+}
+
+// GetStatistics returns the number of received StreamFrames of a stream that had a deadline and that met it
+func (d *streamDeadlines) GetStatistics(id protocol.StreamID) (uint64, uint64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	stat, ok := d.stats[id]
+	if !ok {
+		return 0, 0
+	}
+	return stat.hasDeadline, stat.meetDeadline
+}
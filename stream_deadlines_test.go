@@ -0,0 +1,59 @@
+package quic
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream deadlines", func() {
+	var d *streamDeadlines
+
+	BeforeEach(func() {
+		d = newStreamDeadlines()
+	})
+
+	It("accounts met and missed deadlines per stream", func() {
+		now := time.Now()
+		d.received([]wire.Frame{
+			&wire.StreamFrame{StreamID: 3, Deadline: now.Add(time.Second)},
+			&wire.StreamFrame{StreamID: 5, Deadline: now.Add(time.Second)},
+			&wire.PingFrame{},
+		}, now)
+		d.received([]wire.Frame{&wire.StreamFrame{StreamID: 5, Deadline: now.Add(-time.Second)}}, now)
+		hasDeadline, meetDeadline := d.GetStatistics(3)
+		Expect(hasDeadline).To(BeEquivalentTo(1))
+		Expect(meetDeadline).To(BeEquivalentTo(1))
+		hasDeadline, meetDeadline = d.GetStatistics(5)
+		Expect(hasDeadline).To(BeEquivalentTo(2))
+		Expect(meetDeadline).To(BeEquivalentTo(1))
+	})
+
+	It("accounts every frame of a packet against its own deadline", func() {
+		now := time.Now()
+		// the packet deadline is the earliest one, which the frame of stream 3 misses
+		d.received([]wire.Frame{
+			&wire.StreamFrame{StreamID: 3, Deadline: now.Add(-time.Millisecond)},
+			&wire.StreamFrame{StreamID: 5, Deadline: now.Add(time.Second)},
+			&wire.StreamFrame{StreamID: 7},
+		}, now)
+		hasDeadline, meetDeadline := d.GetStatistics(3)
+		Expect(hasDeadline).To(BeEquivalentTo(1))
+		Expect(meetDeadline).To(BeZero())
+		hasDeadline, meetDeadline = d.GetStatistics(5)
+		Expect(hasDeadline).To(BeEquivalentTo(1))
+		Expect(meetDeadline).To(BeEquivalentTo(1))
+		hasDeadline, meetDeadline = d.GetStatistics(7)
+		Expect(hasDeadline).To(BeZero())
+		Expect(meetDeadline).To(BeZero())
+	})
+
+	It("ignores frames without a deadline", func() {
+		d.received([]wire.Frame{&wire.StreamFrame{StreamID: 3}}, time.Now())
+		hasDeadline, meetDeadline := d.GetStatistics(3)
+		Expect(hasDeadline).To(BeZero())
+		Expect(meetDeadline).To(BeZero())
+	})
+})
@@ -14,18 +14,23 @@ type streamFramer struct {
 	streamsMap *streamsMap
 
 	flowControlManager flowcontrol.FlowControlManager
+	// version gives the length of the StreamFrames, it depends on whether they carry a deadline
+	version protocol.VersionNumber
 
 	retransmissionQueue  []*wire.StreamFrame
 	blockedFrameQueue    []*wire.BlockedFrame
 	addAddressFrameQueue []*wire.AddAddressFrame
 	closePathFrameQueue  []*wire.ClosePathFrame
+	pathCostFrameQueue   []*wire.PathCostFrame
 	pathsFrame           *wire.PathsFrame
+	pathQualityFrame     *wire.PathQualityFrame
 }
 
-func newStreamFramer(streamsMap *streamsMap, flowControlManager flowcontrol.FlowControlManager) *streamFramer {
+func newStreamFramer(streamsMap *streamsMap, flowControlManager flowcontrol.FlowControlManager, version protocol.VersionNumber) *streamFramer {
 	return &streamFramer{
 		streamsMap:         streamsMap,
 		flowControlManager: flowControlManager,
+		version:            version,
 	}
 }
 
@@ -47,6 +52,24 @@ func (f *streamFramer) PopBlockedFrame() *wire.BlockedFrame {
 	return frame
 }
 
+// QueueBlockedFrames queues the BLOCKED frames again, for the connection if it is blocked by flow control,
+// else for the streams with data that are blocked by flow control
+func (f *streamFramer) QueueBlockedFrames() {
+	if f.flowControlManager.RemainingConnectionWindowSize() == 0 {
+		f.blockedFrameQueue = append(f.blockedFrameQueue, &wire.BlockedFrame{StreamID: 0})
+		return
+	}
+	f.streamsMap.Iterate(func(s *stream) (bool, error) {
+		if s == nil || s.streamID == 1 /* crypto stream is handled separately */ || s.lenOfDataForWriting() == 0 {
+			return true, nil
+		}
+		if sendWindowSize, _ := f.flowControlManager.SendWindowSize(s.streamID); sendWindowSize == 0 {
+			f.blockedFrameQueue = append(f.blockedFrameQueue, &wire.BlockedFrame{StreamID: s.streamID})
+		}
+		return true, nil
+	})
+}
+
 func (f *streamFramer) AddAddressForTransmission(ipVersion uint8, addr net.UDPAddr) {
 	f.addAddressFrameQueue = append(f.addAddressFrameQueue, &wire.AddAddressFrame{IPVersion: ipVersion, Addr: addr})
 }
@@ -65,15 +88,13 @@ func (f *streamFramer) AddPathsFrameForTransmission(s *session) {
 	defer s.pathsLock.RUnlock()
 	paths := make([]protocol.PathID, len(s.paths))
 	remoteRTTs := make([]time.Duration, len(s.paths))
-	i := 0
-	for pathID := range s.paths {
-		paths[i] = pathID
-		if s.paths[pathID].potentiallyFailed.Get() {
+	for i, pth := range s.sortedPaths() {
+		paths[i] = pth.pathID
+		if pth.potentiallyFailed.Get() {
 			remoteRTTs[i] = time.Hour
 		} else {
-			remoteRTTs[i] = s.paths[pathID].rttStats.SmoothedRTT()
+			remoteRTTs[i] = pth.rttStats.SmoothedRTT()
 		}
-		i++
 	}
 	f.pathsFrame = &wire.PathsFrame{MaxNumPaths: 255, NumPaths: uint8(len(paths)), PathIDs: paths, RemoteRTTs: remoteRTTs}
 }
@@ -87,6 +108,24 @@ func (f *streamFramer) PopPathsFrame() *wire.PathsFrame {
 	return frame
 }
 
+func (f *streamFramer) AddPathQualityFrameForTransmission(s *session) {
+	s.pathsLock.RLock()
+	defer s.pathsLock.RUnlock()
+	paths := make([]wire.PathQuality, 0, len(s.paths))
+	for _, pth := range s.sortedPaths() {
+		q := pth.quality()
+		q.Budget = s.availableBudget(pth)
+		paths = append(paths, q)
+	}
+	f.pathQualityFrame = &wire.PathQualityFrame{Paths: paths}
+}
+
+func (f *streamFramer) PopPathQualityFrame() *wire.PathQualityFrame {
+	frame := f.pathQualityFrame
+	f.pathQualityFrame = nil
+	return frame
+}
+
 func (f *streamFramer) AddClosePathFrameForTransmission(closePathFrame *wire.ClosePathFrame) {
 	f.closePathFrameQueue = append(f.closePathFrameQueue, closePathFrame)
 }
@@ -100,6 +139,19 @@ func (f *streamFramer) PopClosePathFrame() *wire.ClosePathFrame {
 	return frame
 }
 
+func (f *streamFramer) AddPathCostFrameForTransmission(pathCostFrame *wire.PathCostFrame) {
+	f.pathCostFrameQueue = append(f.pathCostFrameQueue, pathCostFrame)
+}
+
+func (f *streamFramer) PopPathCostFrame() *wire.PathCostFrame {
+	if len(f.pathCostFrameQueue) == 0 {
+		return nil
+	}
+	frame := f.pathCostFrameQueue[0]
+	f.pathCostFrameQueue = f.pathCostFrameQueue[1:]
+	return frame
+}
+
 func (f *streamFramer) HasFramesForRetransmission() bool {
 	return len(f.retransmissionQueue) > 0
 }
@@ -121,7 +173,7 @@ func (f *streamFramer) PopCryptoStreamFrame(maxLen protocol.ByteCount) *wire.Str
 		StreamID: 1,
 		Offset:   cs.writeOffset,
 	}
-	frameHeaderBytes, _ := frame.MinLength(protocol.VersionWhatever) // can never error
+	frameHeaderBytes, _ := frame.MinLength(f.version) // can never error
 	frame.Data = cs.getDataForWriting(maxLen - frameHeaderBytes)
 	return frame
 }
@@ -131,7 +183,7 @@ func (f *streamFramer) maybePopFramesForRetransmission(maxLen protocol.ByteCount
 		frame := f.retransmissionQueue[0]
 		frame.DataLenPresent = true
 
-		frameHeaderLen, _ := frame.MinLength(protocol.VersionWhatever) // can never error
+		frameHeaderLen, _ := frame.MinLength(f.version) // can never error
 		if currentLen+frameHeaderLen >= maxLen {
 			break
 		}
@@ -174,7 +226,7 @@ func (f *streamFramer) maybePopNormalFrames(maxBytes protocol.ByteCount) (res []
 		frame.StreamID = s.streamID
 		// not perfect, but thread-safe since writeOffset is only written when getting data
 		frame.Offset = s.writeOffset
-		frameHeaderBytes, _ := frame.MinLength(protocol.VersionWhatever) // can never error
+		frameHeaderBytes, _ := frame.MinLength(f.version) // can never error
 		if currentLen+frameHeaderBytes > maxBytes {
 			return false, nil // theoretically, we could find another stream that fits, but this is quite unlikely, so we stop here
 		}
@@ -210,6 +262,7 @@ func (f *streamFramer) maybePopNormalFrames(maxBytes protocol.ByteCount) (res []
 		}
 
 		frame.Data = data
+		frame.Deadline = s.getDataDeadline()
 		f.flowControlManager.AddBytesSent(s.streamID, protocol.ByteCount(len(data)))
 
 		// Finally, check if we are now FC blocked and should queue a BLOCKED frame
@@ -254,5 +307,6 @@ func maybeSplitOffFrame(frame *wire.StreamFrame, n protocol.ByteCount) *wire.Str
 		Offset:         frame.Offset,
 		Data:           frame.Data[:n],
 		DataLenPresent: frame.DataLenPresent,
+		Deadline:       frame.Deadline,
 	}
 }
@@ -2,6 +2,7 @@ package quic
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/mocks/mocks_fc"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -42,7 +43,7 @@ var _ = Describe("Stream Framer", func() {
 		streamsMap.putStream(stream2)
 
 		mockFcm = mocks_fc.NewMockFlowControlManager(mockCtrl)
-		framer = newStreamFramer(streamsMap, mockFcm)
+		framer = newStreamFramer(streamsMap, mockFcm, protocol.VersionWhatever)
 	})
 
 	It("says if it has retransmissions", func() {
@@ -69,6 +70,14 @@ var _ = Describe("Stream Framer", func() {
 		Expect(fs[0].DataLenPresent).To(BeTrue())
 	})
 
+	It("queues PATH_COST frames", func() {
+		Expect(framer.PopPathCostFrame()).To(BeNil())
+		f := &wire.PathCostFrame{PathIDs: []protocol.PathID{1}, Costs: []float64{2}}
+		framer.AddPathCostFrameForTransmission(f)
+		Expect(framer.PopPathCostFrame()).To(Equal(f))
+		Expect(framer.PopPathCostFrame()).To(BeNil())
+	})
+
 	Context("Popping", func() {
 		It("returns nil when popping an empty framer", func() {
 			Expect(framer.PopStreamFrames(1000)).To(BeEmpty())
@@ -98,6 +107,28 @@ var _ = Describe("Stream Framer", func() {
 			Expect(framer.PopStreamFrames(1000)).To(BeEmpty())
 		})
 
+		It("attaches the stream deadline to normal frames", func() {
+			mockFcm.EXPECT().SendWindowSize(id1).Return(protocol.MaxByteCount, nil)
+			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(6))
+			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
+			deadline := time.Now().Add(time.Second)
+			stream1.dataForWriting = []byte("foobar")
+			stream1.dataDeadline = deadline
+			fs := framer.PopStreamFrames(1000)
+			Expect(fs).To(HaveLen(1))
+			Expect(fs[0].Deadline).To(Equal(deadline))
+		})
+
+		It("keeps the deadline of frames for retransmission", func() {
+			deadline := time.Now().Add(time.Second)
+			retransmittedFrame1.Deadline = deadline
+			mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame1.StreamID, retransmittedFrame1.DataLen())
+			framer.AddFrameForRetransmission(retransmittedFrame1)
+			fs := framer.PopStreamFrames(1000)
+			Expect(fs).To(HaveLen(1))
+			Expect(fs[0].Deadline).To(Equal(deadline))
+		})
+
 		It("returns multiple normal frames", func() {
 			mockFcm.EXPECT().SendWindowSize(id1).Return(protocol.MaxByteCount, nil)
 			mockFcm.EXPECT().AddBytesSent(id1, protocol.ByteCount(6))
@@ -201,6 +232,19 @@ var _ = Describe("Stream Framer", func() {
 				Expect(f.FinBit).To(BeTrue())
 			})
 
+			It("keeps the deadline when splitting", func() {
+				deadline := time.Now().Add(time.Second)
+				f := &wire.StreamFrame{
+					StreamID: 1,
+					Data:     []byte("foobar"),
+					Deadline: deadline,
+				}
+				previous := maybeSplitOffFrame(f, 3)
+				Expect(previous).ToNot(BeNil())
+				Expect(previous.Deadline).To(Equal(deadline))
+				Expect(f.Deadline).To(Equal(deadline))
+			})
+
 			It("splits a frame", func() {
 				mockFcm.EXPECT().AddBytesRetrans(retransmittedFrame2.StreamID, protocol.ByteCount(2))
 				framer.AddFrameForRetransmission(retransmittedFrame2)
@@ -465,5 +509,34 @@ var _ = Describe("Stream Framer", func() {
 			Expect(blockedFrame.StreamID).To(Equal(stream1.StreamID()))
 			Expect(framer.PopBlockedFrame()).To(BeNil())
 		})
+
+		It("queues the BLOCKED frames of the blocked streams again", func() {
+			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
+			mockFcm.EXPECT().SendWindowSize(id1).Return(protocol.ByteCount(0), nil)
+			stream1.dataForWriting = []byte("foo")
+			framer.QueueBlockedFrames()
+			blockedFrame := framer.PopBlockedFrame()
+			Expect(blockedFrame).ToNot(BeNil())
+			Expect(blockedFrame.StreamID).To(Equal(stream1.StreamID()))
+			Expect(framer.PopBlockedFrame()).To(BeNil())
+		})
+
+		It("queues the BLOCKED frame of the connection again", func() {
+			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.ByteCount(0))
+			stream1.dataForWriting = []byte("foo")
+			framer.QueueBlockedFrames()
+			blockedFrame := framer.PopBlockedFrame()
+			Expect(blockedFrame).ToNot(BeNil())
+			Expect(blockedFrame.StreamID).To(BeZero())
+			Expect(framer.PopBlockedFrame()).To(BeNil())
+		})
+
+		It("does not queue BLOCKED frames for streams that are not blocked", func() {
+			mockFcm.EXPECT().RemainingConnectionWindowSize().Return(protocol.MaxByteCount)
+			mockFcm.EXPECT().SendWindowSize(id1).Return(protocol.ByteCount(3), nil)
+			stream1.dataForWriting = []byte("foo")
+			framer.QueueBlockedFrames()
+			Expect(framer.PopBlockedFrame()).To(BeNil())
+		})
 	})
 })
@@ -760,6 +760,29 @@ var _ = Describe("Stream", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("sets the delivery deadline of written data", func() {
+			str.SetDeliveryDeadline(time.Second)
+			go func() {
+				defer GinkgoRecover()
+				_, err := strWithTimeout.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			Eventually(func() time.Time { return str.getDataDeadline() }).ShouldNot(BeZero())
+			Expect(str.getDataDeadline()).To(BeTemporally("~", time.Now().Add(time.Second), 100*time.Millisecond))
+			str.getDataForWriting(1000)
+		})
+
+		It("doesn't set a delivery deadline by default", func() {
+			go func() {
+				defer GinkgoRecover()
+				_, err := strWithTimeout.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			Eventually(func() protocol.ByteCount { return str.lenOfDataForWriting() }).Should(Equal(protocol.ByteCount(6)))
+			Expect(str.getDataDeadline()).To(BeZero())
+			str.getDataForWriting(1000)
+		})
+
 		It("writes and gets data in two turns", func() {
 			done := make(chan struct{})
 			go func() {
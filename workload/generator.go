@@ -0,0 +1,86 @@
+package workload
+
+import (
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// A Generator writes the messages of a spec on a session
+type Generator struct {
+	Spec *Spec
+	// Seed draws the sizes of the messages, see Spec.Schedule
+	Seed int64
+	// Clock times the messages, it must be the Clock of the session in a netem.Simulation.
+	// If not set, the system clock is used.
+	Clock quic.Clock
+}
+
+// Run opens a stream per flow and writes its messages at their time, with their deadline.
+// A message whose time passed while the stream was still busy with the previous ones is written immediately.
+// Run returns when all messages were written and the streams closed, or on the first error.
+func (g *Generator) Run(sess quic.Session) error {
+	clock := g.Clock
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+	flows := make([][]Message, len(g.Spec.Flows))
+	for _, m := range g.Spec.Schedule(g.Seed) {
+		flows[m.Flow] = append(flows[m.Flow], m)
+	}
+
+	streams := make([]quic.Stream, len(flows))
+	for i := range flows {
+		str, err := sess.OpenStreamSync()
+		if err != nil {
+			return err
+		}
+		p := &preface{flows: len(flows), name: g.Spec.Flows[i].Name, count: len(flows[i])}
+		if err := p.write(str); err != nil {
+			return err
+		}
+		streams[i] = str
+	}
+
+	start := clock.Now()
+	errs := make(chan error, len(flows))
+	for i := range flows {
+		go func(str quic.Stream, messages []Message) {
+			errs <- writeFlow(clock, start, str, messages)
+		}(streams[i], flows[i])
+	}
+	var firstErr error
+	for range flows {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeFlow writes the messages of a flow and closes its stream
+func writeFlow(clock quic.Clock, start time.Time, str quic.Stream, messages []Message) error {
+	var buf []byte
+	for _, m := range messages {
+		sleepUntil(clock, start.Add(m.At))
+		h := &header{seq: m.Seq, size: m.Size, sent: clock.Now(), deadline: m.Deadline}
+		buf = h.appendMessage(buf[:0])
+		str.SetDeliveryDeadline(m.Deadline)
+		if _, err := str.Write(buf); err != nil {
+			return err
+		}
+	}
+	return str.Close()
+}
+
+// sleepUntil blocks until the time t of the clock
+func sleepUntil(clock quic.Clock, t time.Time) {
+	d := t.Sub(clock.Now())
+	if d <= 0 {
+		return
+	}
+	wake := make(chan struct{})
+	clock.AfterFunc(d, func() { close(wake) })
+	<-wake
+}
@@ -0,0 +1,109 @@
+package workload
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A flow stream starts with a preface: the number of flows of the workload (uint16), the length of the name of the
+// flow (uint16), its name and its number of messages (uint32). Every message follows with its header and its payload.
+// The integers are big endian.
+
+const (
+	// maxNameLen is the maximum length of the name of a flow
+	maxNameLen = 255
+	// maxMessageSize is the maximum payload of a message
+	maxMessageSize = 1 << 24
+	// headerLen is the length of the header of a message:
+	// its sequence number (uint32), payload size (uint32), the time it was written (int64 Unix nanoseconds)
+	// and its deadline (int64 nanoseconds)
+	headerLen = 24
+)
+
+// a preface opens the stream of a flow
+type preface struct {
+	flows int
+	name  string
+	count int
+}
+
+func (p *preface) write(w io.Writer) error {
+	b := make([]byte, 8+len(p.name))
+	binary.BigEndian.PutUint16(b, uint16(p.flows))
+	binary.BigEndian.PutUint16(b[2:], uint16(len(p.name)))
+	copy(b[4:], p.name)
+	binary.BigEndian.PutUint32(b[4+len(p.name):], uint32(p.count))
+	_, err := w.Write(b)
+	return err
+}
+
+func readPreface(r io.Reader) (*preface, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+	p := &preface{flows: int(binary.BigEndian.Uint16(b[:]))}
+	name := make([]byte, binary.BigEndian.Uint16(b[2:]))
+	if len(name) > maxNameLen {
+		return nil, fmt.Errorf("workload: flow name of %d bytes", len(name))
+	}
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, unexpected(err)
+	}
+	p.name = string(name)
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, unexpected(err)
+	}
+	p.count = int(binary.BigEndian.Uint32(b[:]))
+	if p.flows == 0 {
+		return nil, errors.New("workload: preface of a workload without flows")
+	}
+	return p, nil
+}
+
+// a header precedes the payload of a message
+type header struct {
+	seq      uint32
+	size     int
+	sent     time.Time
+	deadline time.Duration
+}
+
+// appendMessage appends the header and a zero payload of the message
+func (h *header) appendMessage(b []byte) []byte {
+	var hdr [headerLen]byte
+	binary.BigEndian.PutUint32(hdr[:], h.seq)
+	binary.BigEndian.PutUint32(hdr[4:], uint32(h.size))
+	binary.BigEndian.PutUint64(hdr[8:], uint64(h.sent.UnixNano()))
+	binary.BigEndian.PutUint64(hdr[16:], uint64(h.deadline))
+	b = append(b, hdr[:]...)
+	return append(b, make([]byte, h.size)...)
+}
+
+func readHeader(r io.Reader) (*header, error) {
+	var b [headerLen]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return nil, err
+	}
+	h := &header{
+		seq:      binary.BigEndian.Uint32(b[:]),
+		size:     int(binary.BigEndian.Uint32(b[4:])),
+		sent:     time.Unix(0, int64(binary.BigEndian.Uint64(b[8:]))),
+		deadline: time.Duration(binary.BigEndian.Uint64(b[16:])),
+	}
+	if h.size > maxMessageSize {
+		return nil, fmt.Errorf("workload: message of %d bytes", h.size)
+	}
+	return h, nil
+}
+
+// unexpected turns an EOF within a preface or a message into an io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package workload
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Messages", func() {
+	It("writes and reads a preface", func() {
+		var buf bytes.Buffer
+		Expect((&preface{flows: 2, name: "video", count: 250}).write(&buf)).To(Succeed())
+		p, err := readPreface(&buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p).To(Equal(&preface{flows: 2, name: "video", count: 250}))
+	})
+
+	It("writes and reads a message", func() {
+		sent := time.Unix(1500000000, 123456789)
+		b := (&header{seq: 7, size: 1000, sent: sent, deadline: 150 * time.Millisecond}).appendMessage(nil)
+		Expect(b).To(HaveLen(headerLen + 1000))
+		r := bytes.NewReader(b)
+		h, err := readHeader(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(h.seq).To(Equal(uint32(7)))
+		Expect(h.size).To(Equal(1000))
+		Expect(h.sent.Equal(sent)).To(BeTrue())
+		Expect(h.deadline).To(Equal(150 * time.Millisecond))
+		Expect(r.Len()).To(Equal(1000))
+	})
+
+	It("errors on a truncated preface", func() {
+		var buf bytes.Buffer
+		Expect((&preface{flows: 1, name: "video", count: 1}).write(&buf)).To(Succeed())
+		_, err := readPreface(bytes.NewReader(buf.Bytes()[:6]))
+		Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+	})
+
+	It("rejects oversized messages", func() {
+		b := (&header{size: maxMessageSize + 1}).appendMessage(nil)
+		_, err := readHeader(bytes.NewReader(b))
+		Expect(err).To(MatchError(ContainSubstring("message of")))
+	})
+})
@@ -0,0 +1,218 @@
+package workload
+
+import (
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// An Arrival is a message received by a Sink
+type Arrival struct {
+	Flow string
+	Seq  uint32
+	Size int
+	// Sent is the time the generator wrote the message, on its clock
+	Sent time.Time
+	// Arrived is the time the sink read the last byte of the message
+	Arrived  time.Time
+	Deadline time.Duration
+}
+
+// Latency is the time from the write of the message until its arrival
+func (a *Arrival) Latency() time.Duration {
+	return a.Arrived.Sub(a.Sent)
+}
+
+// Met tells if the message arrived within its deadline. A message without deadline always meets it.
+func (a *Arrival) Met() bool {
+	return a.Deadline == 0 || a.Latency() <= a.Deadline
+}
+
+// A FlowReport sums up the arrivals of a flow
+type FlowReport struct {
+	Name string
+	// Messages is the number of messages the generator announced
+	Messages int
+	Received int
+	// Bytes is the payload received
+	Bytes uint64
+	// Met is the number of messages received within their deadline
+	Met int
+	// MedianLatency, P95Latency and MaxLatency are the latencies of the received messages
+	MedianLatency time.Duration
+	P95Latency    time.Duration
+	MaxLatency    time.Duration
+}
+
+// HitRate is the fraction of the announced messages that arrived within their deadline.
+// The messages that never arrived missed it.
+func (r *FlowReport) HitRate() float64 {
+	if r.Messages == 0 {
+		return 0
+	}
+	return float64(r.Met) / float64(r.Messages)
+}
+
+// A Sink receives the flows of generators and timestamps the arrival of their messages.
+// It can serve several sessions, the flows of the same name are reported together.
+type Sink struct {
+	// Clock timestamps the arrivals, it must be the clock of the generator.
+	// If not set, the system clock is used.
+	Clock quic.Clock
+
+	mutex    sync.Mutex
+	names    []string
+	messages map[string]int
+	arrivals []Arrival
+}
+
+// Serve reads the flows of a generator from a session.
+// It returns once all flows were received until the end of their stream, or on the first error.
+// The session is left open, the caller closes it.
+func (s *Sink) Serve(sess quic.Session) error {
+	// a result is the number of flows of the workload once a flow was read, or an error
+	type result struct {
+		flows int
+		err   error
+	}
+	done := make(chan struct{})
+	defer close(done)
+	results := make(chan result)
+	report := func(r result) {
+		select {
+		case results <- r:
+		case <-done:
+		}
+	}
+	go func() {
+		for {
+			str, err := sess.AcceptStream()
+			if err != nil {
+				report(result{err: err})
+				return
+			}
+			go func() {
+				flows, err := s.readFlow(str)
+				report(result{flows: flows, err: err})
+			}()
+		}
+	}()
+
+	for finished := 1; ; finished++ {
+		r := <-results
+		if r.err != nil {
+			return r.err
+		}
+		if finished == r.flows {
+			return nil
+		}
+	}
+}
+
+// readFlow reads the messages of a flow until the end of its stream, and returns the number of flows of the workload
+func (s *Sink) readFlow(str quic.Stream) (int, error) {
+	clock := s.Clock
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+	p, err := readPreface(str)
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	s.addFlow(p)
+	for {
+		h, err := readHeader(str)
+		if err == io.EOF {
+			return p.flows, nil
+		}
+		if err != nil {
+			return 0, unexpected(err)
+		}
+		if _, err := io.CopyN(ioutil.Discard, str, int64(h.size)); err != nil {
+			return 0, unexpected(err)
+		}
+		s.add(Arrival{
+			Flow:     p.name,
+			Seq:      h.seq,
+			Size:     h.size,
+			Sent:     h.sent,
+			Arrived:  clock.Now(),
+			Deadline: h.deadline,
+		})
+	}
+}
+
+func (s *Sink) addFlow(p *preface) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.messages == nil {
+		s.messages = make(map[string]int)
+	}
+	if _, ok := s.messages[p.name]; !ok {
+		s.names = append(s.names, p.name)
+	}
+	s.messages[p.name] += p.count
+}
+
+func (s *Sink) add(a Arrival) {
+	s.mutex.Lock()
+	s.arrivals = append(s.arrivals, a)
+	s.mutex.Unlock()
+}
+
+// Arrivals returns the messages received, in the order they arrived
+func (s *Sink) Arrivals() []Arrival {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Arrival(nil), s.arrivals...)
+}
+
+// Report sums up the arrivals of every flow, in the order the flows started
+func (s *Sink) Report() []FlowReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	reports := make([]FlowReport, len(s.names))
+	latencies := make([][]time.Duration, len(s.names))
+	index := make(map[string]int)
+	for i, name := range s.names {
+		index[name] = i
+		reports[i] = FlowReport{Name: name, Messages: s.messages[name]}
+	}
+	for i := range s.arrivals {
+		a := &s.arrivals[i]
+		j := index[a.Flow]
+		r := &reports[j]
+		r.Received++
+		r.Bytes += uint64(a.Size)
+		if a.Met() {
+			r.Met++
+		}
+		latencies[j] = append(latencies[j], a.Latency())
+	}
+	for i := range reports {
+		l := latencies[i]
+		if len(l) == 0 {
+			continue
+		}
+		sort.Slice(l, func(a, b int) bool { return l[a] < l[b] })
+		reports[i].MedianLatency = percentile(l, 0.5)
+		reports[i].P95Latency = percentile(l, 0.95)
+		reports[i].MaxLatency = l[len(l)-1]
+	}
+	return reports
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,111 @@
+package workload
+
+import (
+	"crypto/tls"
+	"os"
+	"strings"
+	"time"
+
+	quic "github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/internal/testdata"
+	"github.com/lucas-clemente/quic-go/netem"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sink", func() {
+	It("reports the arrivals of a flow", func() {
+		start := time.Unix(1500000000, 0)
+		s := &Sink{}
+		s.addFlow(&preface{flows: 1, name: "video", count: 5})
+		for i, latency := range []time.Duration{50, 120, 90, 200} {
+			s.add(Arrival{Flow: "video", Seq: uint32(i), Size: 1000, Sent: start, Arrived: start.Add(latency * time.Millisecond), Deadline: 150 * time.Millisecond})
+		}
+		Expect(s.Report()).To(Equal([]FlowReport{{
+			Name:          "video",
+			Messages:      5,
+			Received:      4,
+			Bytes:         4000,
+			Met:           3,
+			MedianLatency: 90 * time.Millisecond,
+			P95Latency:    200 * time.Millisecond,
+			MaxLatency:    200 * time.Millisecond,
+		}}))
+		Expect(s.Report()[0].HitRate()).To(Equal(0.6))
+		Expect(s.Arrivals()).To(HaveLen(4))
+	})
+
+	It("receives a workload over an emulated network", func() {
+		// the schedulers read their bandit parameters from this file
+		if _, err := os.Stat("../output/lin"); err != nil {
+			Skip("the sessions need ../output/lin: " + err.Error())
+		}
+		spec, err := ParseSpec(strings.NewReader(`{"flows": [
+			{"name": "video", "period": "40ms", "count": 25, "deadline": "150ms",
+			 "size": {"distribution": "uniform", "min": 3000, "max": 5000}, "key_every": 10, "key_size": {"mean": 15000}},
+			{"name": "bulk", "count": 1, "size": {"mean": 50000}}
+		]}`))
+		Expect(err).ToNot(HaveOccurred())
+
+		sim := netem.NewSimulation(GinkgoRandomSeed())
+		clientHost := sim.AddHost("client")
+		serverHost := sim.AddHost("server")
+		link := netem.LinkConfig{Delay: 20 * time.Millisecond, Bandwidth: 1.25e6, QueueSize: 100}
+		_, err = sim.Connect(clientHost, "10.0.0.1", serverHost, "10.2.0.1", link, link)
+		Expect(err).ToNot(HaveOccurred())
+		clock := sim.Clock()
+
+		server, err := quic.ListenAddr("10.2.0.1:4433", testdata.GetTLSConfig(), &quic.Config{Network: serverHost, Clock: clock})
+		Expect(err).ToNot(HaveOccurred())
+		defer server.Close()
+
+		sink := &Sink{Clock: clock}
+		done := make(chan struct{})
+		errs := make(chan error, 2)
+		go func() {
+			defer GinkgoRecover()
+			sess, err := server.Accept()
+			Expect(err).ToNot(HaveOccurred())
+			errs <- sink.Serve(sess)
+			sess.Close(nil)
+			close(done)
+		}()
+		go func() {
+			defer GinkgoRecover()
+			sess, err := quic.DialAddr("10.2.0.1:4433", &tls.Config{InsecureSkipVerify: true}, &quic.Config{Network: clientHost, Clock: clock})
+			Expect(err).ToNot(HaveOccurred())
+			g := &Generator{Spec: spec, Seed: GinkgoRandomSeed(), Clock: clock}
+			errs <- g.Run(sess)
+		}()
+		Expect(sim.Run(done, time.Minute)).To(Succeed())
+		Expect(<-errs).ToNot(HaveOccurred())
+		Expect(<-errs).ToNot(HaveOccurred())
+
+		reports := sink.Report()
+		Expect(reports).To(HaveLen(2))
+		var video, bulk FlowReport
+		for _, r := range reports {
+			switch r.Name {
+			case "video":
+				video = r
+			case "bulk":
+				bulk = r
+			}
+		}
+		Expect(video.Messages).To(Equal(25))
+		Expect(video.Received).To(Equal(25))
+		Expect(video.MedianLatency).To(BeNumerically(">=", 20*time.Millisecond))
+		Expect(video.HitRate()).To(BeNumerically(">", 0.5))
+		Expect(bulk.Received).To(Equal(1))
+		Expect(bulk.Bytes).To(Equal(uint64(50000)))
+		Expect(bulk.HitRate()).To(Equal(1.))
+
+		var sizes uint64
+		for _, m := range spec.Schedule(GinkgoRandomSeed()) {
+			if m.Flow == 0 {
+				sizes += uint64(m.Size)
+			}
+		}
+		Expect(video.Bytes).To(Equal(sizes))
+	})
+})
@@ -0,0 +1,253 @@
+// Package workload generates application messages with delivery deadlines on a session, and measures at the peer
+// which of them arrived in time.
+//
+// A Spec describes the flows of a workload, e.g. the periodic frames of a video with larger key frames and a
+// deadline per frame, or a bulk transfer. A Generator writes each flow on its own stream of a session, every message
+// with the delivery deadline of its flow, and a Sink at the peer timestamps the arrival of every message and reports
+// the deadline hit rate of each flow.
+//
+// The sink compares the arrival time with the time the generator wrote the message, so both ends must share a clock:
+// run them on the same host, on hosts with synchronised clocks, or over a netem network.
+package workload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+)
+
+// Size distributions
+const (
+	// Constant sizes are always the Mean
+	Constant = "constant"
+	// Uniform sizes are drawn between Min and Max
+	Uniform = "uniform"
+	// Normal sizes are drawn around the Mean with the StdDev, within Min and Max
+	Normal = "normal"
+	// Exponential sizes are drawn with the Mean, within Min and Max
+	Exponential = "exponential"
+)
+
+// A Spec is a workload of one or more flows, sent concurrently
+type Spec struct {
+	Flows []Flow `json:"flows"`
+}
+
+// A Flow is a sequence of messages sent on a stream.
+// The messages are sent in bursts every period, the first burst Start after the workload started.
+// A flow without a period sends all its messages at once, e.g. a bulk transfer of a single large message.
+type Flow struct {
+	Name  string   `json:"name"`
+	Start Duration `json:"start,omitempty"`
+	// Period is the time between two bursts
+	Period Duration `json:"period,omitempty"`
+	// Count is the number of messages of the flow
+	Count int `json:"count"`
+	// Burst is the number of messages sent back to back every period, 1 if not set
+	Burst int  `json:"burst,omitempty"`
+	Size  Size `json:"size"`
+	// Every KeyEvery messages, starting with the first, the size of the message is drawn from KeySize,
+	// e.g. for the key frames of a video. If KeyEvery is zero, all messages are drawn from Size.
+	KeyEvery int  `json:"key_every,omitempty"`
+	KeySize  Size `json:"key_size,omitempty"`
+	// Deadline is the delivery deadline of every message, from the time it is written. Zero means no deadline.
+	Deadline Duration `json:"deadline,omitempty"`
+}
+
+// A Size is a distribution of message sizes, in bytes
+type Size struct {
+	// Distribution is one of Constant, Uniform, Normal and Exponential, Constant if not set
+	Distribution string `json:"distribution,omitempty"`
+	Mean         int    `json:"mean,omitempty"`
+	StdDev       int    `json:"stddev,omitempty"`
+	Min          int    `json:"min,omitempty"`
+	// Max bounds the sizes, if not zero
+	Max int `json:"max,omitempty"`
+}
+
+// A Duration is a time.Duration written as a string in JSON, e.g. "40ms"
+type Duration time.Duration
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON reads a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("workload: duration must be a string like \"40ms\": %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("workload: %s", err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// ParseSpec reads a JSON spec and validates it
+func ParseSpec(r io.Reader) (*Spec, error) {
+	spec := &Spec{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(spec); err != nil {
+		return nil, fmt.Errorf("workload: invalid spec: %s", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// LoadSpec reads a JSON spec from a file
+func LoadSpec(filename string) (*Spec, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSpec(f)
+}
+
+// Validate checks that the flows of the spec can be generated
+func (s *Spec) Validate() error {
+	if len(s.Flows) == 0 {
+		return errors.New("workload: the spec has no flows")
+	}
+	if len(s.Flows) > math.MaxUint16 {
+		return fmt.Errorf("workload: the spec has %d flows, at most %d are allowed", len(s.Flows), math.MaxUint16)
+	}
+	names := make(map[string]bool)
+	for i := range s.Flows {
+		f := &s.Flows[i]
+		if f.Name == "" {
+			return fmt.Errorf("workload: flow %d has no name", i)
+		}
+		if len(f.Name) > maxNameLen {
+			return fmt.Errorf("workload: the name of flow %d is longer than %d bytes", i, maxNameLen)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("workload: flow %s is defined twice", f.Name)
+		}
+		names[f.Name] = true
+		if f.Count <= 0 {
+			return fmt.Errorf("workload: flow %s has no messages", f.Name)
+		}
+		if f.Start < 0 || f.Period < 0 || f.Deadline < 0 || f.Burst < 0 || f.KeyEvery < 0 {
+			return fmt.Errorf("workload: flow %s has a negative value", f.Name)
+		}
+		if err := f.Size.validate(); err != nil {
+			return fmt.Errorf("workload: flow %s: %s", f.Name, err)
+		}
+		if f.KeyEvery > 0 {
+			if err := f.KeySize.validate(); err != nil {
+				return fmt.Errorf("workload: flow %s: key size: %s", f.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Size) validate() error {
+	if s.Mean < 0 || s.StdDev < 0 || s.Min < 0 || s.Max < 0 {
+		return errors.New("negative size")
+	}
+	if s.Max > 0 && s.Min > s.Max {
+		return fmt.Errorf("min %d is above max %d", s.Min, s.Max)
+	}
+	if s.Max > maxMessageSize {
+		return fmt.Errorf("max %d is above %d", s.Max, maxMessageSize)
+	}
+	switch s.Distribution {
+	case "", Constant, Normal, Exponential:
+		if s.Mean > maxMessageSize {
+			return fmt.Errorf("mean %d is above %d", s.Mean, maxMessageSize)
+		}
+	case Uniform:
+		if s.Max == 0 {
+			return errors.New("a uniform size needs a max")
+		}
+	default:
+		return fmt.Errorf("unknown distribution %q", s.Distribution)
+	}
+	return nil
+}
+
+// sample draws a size from the distribution
+func (s *Size) sample(rng *rand.Rand) int {
+	var v float64
+	switch s.Distribution {
+	case Uniform:
+		return s.Min + rng.Intn(s.Max-s.Min+1)
+	case Normal:
+		v = float64(s.Mean) + float64(s.StdDev)*rng.NormFloat64()
+	case Exponential:
+		v = float64(s.Mean) * rng.ExpFloat64()
+	default:
+		return s.Mean
+	}
+	size := int(math.Round(v))
+	if size < s.Min {
+		size = s.Min
+	}
+	if s.Max > 0 && size > s.Max {
+		size = s.Max
+	}
+	if size > maxMessageSize {
+		size = maxMessageSize
+	}
+	return size
+}
+
+// A Message is a message of a flow, as scheduled by the spec
+type Message struct {
+	// Flow is the index of the flow in the spec
+	Flow int
+	Seq  uint32
+	// At is the time the message is written, from the start of the workload
+	At       time.Duration
+	Size     int
+	Deadline time.Duration
+}
+
+// Schedule draws the messages of the flows, in the order they are written.
+// The same seed always draws the same sizes.
+func (s *Spec) Schedule(seed int64) []Message {
+	rng := rand.New(rand.NewSource(seed))
+	var messages []Message
+	for i := range s.Flows {
+		messages = append(messages, s.Flows[i].schedule(i, rng)...)
+	}
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].At < messages[j].At })
+	return messages
+}
+
+func (f *Flow) schedule(index int, rng *rand.Rand) []Message {
+	burst := f.Burst
+	if burst == 0 {
+		burst = 1
+	}
+	messages := make([]Message, f.Count)
+	for i := range messages {
+		size := &f.Size
+		if f.KeyEvery > 0 && i%f.KeyEvery == 0 {
+			size = &f.KeySize
+		}
+		messages[i] = Message{
+			Flow:     index,
+			Seq:      uint32(i),
+			At:       time.Duration(f.Start) + time.Duration(i/burst)*time.Duration(f.Period),
+			Size:     size.sample(rng),
+			Deadline: time.Duration(f.Deadline),
+		}
+	}
+	return messages
+}
@@ -0,0 +1,117 @@
+package workload
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Spec", func() {
+	const video = `{"flows": [
+		{"name": "video", "period": "40ms", "count": 6, "deadline": "150ms",
+		 "size": {"distribution": "normal", "mean": 4000, "stddev": 500, "min": 1000, "max": 6000},
+		 "key_every": 3, "key_size": {"mean": 20000}},
+		{"name": "audio", "start": "10ms", "period": "20ms", "count": 4, "burst": 2, "size": {"mean": 160}, "deadline": "100ms"},
+		{"name": "bulk", "count": 1, "size": {"mean": 100000}}
+	]}`
+
+	It("parses a spec", func() {
+		spec, err := ParseSpec(strings.NewReader(video))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spec.Flows).To(HaveLen(3))
+		f := spec.Flows[0]
+		Expect(f.Name).To(Equal("video"))
+		Expect(time.Duration(f.Period)).To(Equal(40 * time.Millisecond))
+		Expect(time.Duration(f.Deadline)).To(Equal(150 * time.Millisecond))
+		Expect(f.Size).To(Equal(Size{Distribution: Normal, Mean: 4000, StdDev: 500, Min: 1000, Max: 6000}))
+		Expect(f.KeyEvery).To(Equal(3))
+		Expect(f.KeySize.Mean).To(Equal(20000))
+	})
+
+	It("schedules the messages in the order they are written", func() {
+		spec, err := ParseSpec(strings.NewReader(video))
+		Expect(err).ToNot(HaveOccurred())
+		messages := spec.Schedule(42)
+		Expect(messages).To(HaveLen(11))
+		var at []time.Duration
+		for _, m := range messages {
+			at = append(at, m.At)
+		}
+		ms := time.Millisecond
+		Expect(at).To(Equal([]time.Duration{0, 0, 10 * ms, 10 * ms, 30 * ms, 30 * ms, 40 * ms, 80 * ms, 120 * ms, 160 * ms, 200 * ms}))
+		for _, m := range messages {
+			switch m.Flow {
+			case 0:
+				if m.Seq%3 == 0 {
+					Expect(m.Size).To(Equal(20000))
+				} else {
+					Expect(m.Size).To(BeNumerically(">=", 1000))
+					Expect(m.Size).To(BeNumerically("<=", 6000))
+				}
+				Expect(m.Deadline).To(Equal(150 * time.Millisecond))
+			case 1:
+				Expect(m.Size).To(Equal(160))
+			case 2:
+				Expect(m.Size).To(Equal(100000))
+				Expect(m.Deadline).To(BeZero())
+			}
+		}
+		Expect(spec.Schedule(42)).To(Equal(messages))
+	})
+
+	It("draws the sizes from their distribution", func() {
+		rng := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		mean := func(s Size) float64 {
+			var sum int
+			for i := 0; i < 10000; i++ {
+				v := s.sample(rng)
+				Expect(v).To(BeNumerically(">=", s.Min))
+				if s.Max > 0 {
+					Expect(v).To(BeNumerically("<=", s.Max))
+				}
+				sum += v
+			}
+			return float64(sum) / 10000
+		}
+		Expect(mean(Size{Mean: 1200})).To(Equal(1200.))
+		Expect(mean(Size{Distribution: Uniform, Min: 1000, Max: 3000})).To(BeNumerically("~", 2000, 50))
+		Expect(mean(Size{Distribution: Normal, Mean: 5000, StdDev: 1000})).To(BeNumerically("~", 5000, 50))
+		Expect(mean(Size{Distribution: Exponential, Mean: 2000})).To(BeNumerically("~", 2000, 100))
+		Expect(mean(Size{Distribution: Exponential, Mean: 2000, Max: 2000})).To(BeNumerically("<", 2000))
+	})
+
+	It("writes durations as strings", func() {
+		spec := &Spec{Flows: []Flow{{Name: "frames", Period: Duration(40 * time.Millisecond), Count: 1}}}
+		var buf bytes.Buffer
+		Expect(json.NewEncoder(&buf).Encode(spec)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring(`"period":"40ms"`))
+		parsed, err := ParseSpec(&buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(parsed).To(Equal(spec))
+	})
+
+	It("rejects invalid specs", func() {
+		for _, spec := range []string{
+			`{"flows": []}`,
+			`{"flows": [{"count": 1}]}`,
+			`{"flows": [{"name": "a", "count": 0}]}`,
+			`{"flows": [{"name": "a", "count": 1}, {"name": "a", "count": 1}]}`,
+			`{"flows": [{"name": "a", "count": 1, "period": 40}]}`,
+			`{"flows": [{"name": "a", "count": 1, "period": "-40ms"}]}`,
+			`{"flows": [{"name": "a", "count": 1, "size": {"distribution": "pareto"}}]}`,
+			`{"flows": [{"name": "a", "count": 1, "size": {"distribution": "uniform", "min": 10}}]}`,
+			`{"flows": [{"name": "a", "count": 1, "size": {"min": 10, "max": 5}}]}`,
+			`{"flows": [{"name": "a", "count": 1, "size": {"mean": 100000000}}]}`,
+			`{"flows": [{"name": "a", "count": 1, "key_every": 2, "key_size": {"mean": -1}}]}`,
+			`{"flows": [{"name": "a", "count": 1, "sizes": {}}]}`,
+		} {
+			_, err := ParseSpec(strings.NewReader(spec))
+			Expect(err).To(HaveOccurred(), spec)
+		}
+	})
+})